@@ -0,0 +1,309 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// tpctl checks tech profile definitions offline before they are handed to the adapters:
+//
+//	tpctl validate [-technology XGS-PON] FILE...
+//	tpctl render [-technology XGS-PON] [-tp-id 64] [-uni olt-{olt}/pon-{0}/onu-{1}/uni-{0}] [-alloc-id 1024] [-gem-port-id 1025] FILE
+//	tpctl push [-technology XGS-PON] [-tp-id 64] [-kv-store-type etcd] [-kv-store-address 127.0.0.1:2379] FILE
+//
+// validate reports every problem found with the path of the offending field, render prints the
+// TP instance built for a UNI with sequential resource IDs along with the pbit to GEM port mapping,
+// and push stores the validated profile where the TechProfileMgr reads it from.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/db"
+	"github.com/opencord/voltha-lib-go/v7/pkg/techprofile"
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	numPbits  = 8
+	sampleUni = "olt-{olt}/pon-{0}/onu-{1}/uni-{0}"
+)
+
+// errInvalidProfile signals that the problems were already reported
+var errInvalidProfile = errors.New("invalid tech profile")
+
+func usage(stderr io.Writer) {
+	fmt.Fprintln(stderr, "usage: tpctl validate|render|push [flags] FILE...")
+	fmt.Fprintln(stderr, "run 'tpctl COMMAND -h' for the flags of a command")
+}
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command and returns the exit code of the tool
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	flags := flag.NewFlagSet("tpctl "+args[0], flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	technology := flags.String("technology", "XGS-PON", "PON technology of the profile: GPON, XGPON, XGS-PON, 25GS-PON or EPON")
+	tpID := flags.Uint("tp-id", techprofile.DEFAULT_TECH_PROFILE_TABLE_ID, "tech profile table ID")
+
+	var err error
+	switch args[0] {
+	case "validate":
+		if err = flags.Parse(args[1:]); err != nil {
+			return 2
+		}
+		err = validate(stdout, stderr, *technology, flags.Args())
+	case "render":
+		uni := flags.String("uni", sampleUni, "UNI port name the instance is rendered for")
+		allocID := flags.Uint("alloc-id", 1024, "alloc ID of the rendered instance")
+		gemPortID := flags.Uint("gem-port-id", 1025, "first GEM port ID of the rendered instance, the others follow it")
+		if err = flags.Parse(args[1:]); err != nil {
+			return 2
+		}
+		err = render(ctx, stdout, stderr, *technology, uint32(*tpID), *uni, uint32(*allocID), uint32(*gemPortID), flags.Args())
+	case "push":
+		kvStoreType := flags.String("kv-store-type", "etcd", "KV store type: etcd or redis")
+		kvStoreAddress := flags.String("kv-store-address", "127.0.0.1:2379", "KV store address")
+		kvStoreTimeout := flags.Duration("kv-store-timeout", 5*time.Second, "KV store request timeout")
+		if err = flags.Parse(args[1:]); err != nil {
+			return 2
+		}
+		err = push(ctx, stdout, stderr, *technology, uint32(*tpID), *kvStoreType, *kvStoreAddress, *kvStoreTimeout, flags.Args())
+	default:
+		usage(stderr)
+		return 2
+	}
+	if err != nil {
+		if !errors.Is(err, errInvalidProfile) {
+			fmt.Fprintf(stderr, "tpctl %s: %s\n", args[0], err)
+		}
+		return 1
+	}
+	return 0
+}
+
+// loadProfile decodes the TP file of the technology. Fields the decoder does not know, like the
+// copyright notice of the sample files, are ignored and returned as a warning.
+func loadProfile(technology string, file string) (proto.Message, string, error) {
+	tech, ok := techprofile.LookupTechnology(technology)
+	if !ok {
+		return nil, "", fmt.Errorf("unknown technology %q", technology)
+	}
+	value, err := os.ReadFile(file)
+	if err != nil {
+		return nil, "", err
+	}
+	var tp proto.Message = &tp_pb.TechProfile{}
+	if _, ok := tech.(techprofile.EponFamilyTechnology); ok {
+		tp = &tp_pb.EponTechProfile{}
+	}
+	var warning string
+	if err = protojson.Unmarshal(value, tp); err != nil {
+		proto.Reset(tp)
+		if lenientErr := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(value, tp); lenientErr != nil {
+			return nil, "", fmt.Errorf("%s: %s", file, lenientErr)
+		}
+		warning = fmt.Sprintf("%s: unknown fields are ignored, the pushed profile will not contain them (%s)", file, err)
+	}
+	return tp, warning, nil
+}
+
+// validateProfile checks the TP as the technology does
+func validateProfile(technology string, tp proto.Message) error {
+	tech, _ := techprofile.LookupTechnology(technology)
+	switch tp := tp.(type) {
+	case *tp_pb.EponTechProfile:
+		return tech.(techprofile.EponFamilyTechnology).ValidateTechProfile(tp)
+	case *tp_pb.TechProfile:
+		return tech.(techprofile.GponFamilyTechnology).ValidateTechProfile(tp)
+	}
+	return fmt.Errorf("unsupported profile %T", tp)
+}
+
+// loadValidProfile loads the TP file and reports its problems, if any
+func loadValidProfile(stderr io.Writer, technology string, file string) (proto.Message, error) {
+	tp, warning, err := loadProfile(technology, file)
+	if err != nil {
+		return nil, err
+	}
+	if warning != "" {
+		fmt.Fprintln(stderr, "warning:", warning)
+	}
+	if err = validateProfile(technology, tp); err != nil {
+		var errs techprofile.ValidationErrors
+		if !errors.As(err, &errs) {
+			return nil, err
+		}
+		for _, e := range errs {
+			fmt.Fprintf(stderr, "%s: %s\n", file, e)
+		}
+		return nil, errInvalidProfile
+	}
+	return tp, nil
+}
+
+func validate(stdout, stderr io.Writer, technology string, files []string) error {
+	if len(files) == 0 {
+		return errors.New("no tech profile file given")
+	}
+	var failed bool
+	for _, file := range files {
+		if _, err := loadValidProfile(stderr, technology, file); err != nil {
+			if !errors.Is(err, errInvalidProfile) {
+				fmt.Fprintln(stderr, err)
+			}
+			failed = true
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: ok\n", file)
+	}
+	if failed {
+		return errInvalidProfile
+	}
+	return nil
+}
+
+func singleFile(files []string) (string, error) {
+	if len(files) != 1 {
+		return "", fmt.Errorf("expected one tech profile file, got %d", len(files))
+	}
+	return files[0], nil
+}
+
+// pbitQueue is a unicast queue of an instance as far as the pbit mapping is concerned
+type pbitQueue struct {
+	gemPortID uint32
+	pbitMap   string
+}
+
+// writePbitMapping prints the GEM port each pbit is mapped to in both directions
+func writePbitMapping(w io.Writer, upstream, downstream []pbitQueue) {
+	gemForPbit := func(queues []pbitQueue, pbit uint) string {
+		for _, q := range queues {
+			if mask, err := techprofile.ParsePbitMap(q.pbitMap); err == nil && mask&(1<<pbit) != 0 {
+				return fmt.Sprint(q.gemPortID)
+			}
+		}
+		return "-"
+	}
+	fmt.Fprintf(w, "%-6s%-12s%s\n", "pbit", "upstream", "downstream")
+	for pbit := uint(0); pbit < numPbits; pbit++ {
+		fmt.Fprintf(w, "%-6d%-12s%s\n", pbit, gemForPbit(upstream, pbit), gemForPbit(downstream, pbit))
+	}
+}
+
+func render(ctx context.Context, stdout, stderr io.Writer, technology string, tpID uint32, uni string,
+	allocID uint32, gemPortID uint32, files []string) error {
+	file, err := singleFile(files)
+	if err != nil {
+		return err
+	}
+	tp, err := loadValidProfile(stderr, technology, file)
+	if err != nil {
+		return err
+	}
+
+	var instance proto.Message
+	var upstream, downstream []pbitQueue
+	var multicast []string
+	switch tp := tp.(type) {
+	case *tp_pb.EponTechProfile:
+		gemPorts := make([]uint32, tp.NumGemPorts)
+		for i := range gemPorts {
+			gemPorts[i] = gemPortID + uint32(i)
+		}
+		inst, err := techprofile.RenderEponTechProfileInstance(ctx, technology, tp, tpID, uni, allocID, gemPorts)
+		if err != nil {
+			return err
+		}
+		for _, q := range inst.UpstreamQueueAttributeList {
+			upstream = append(upstream, pbitQueue{gemPortID: q.GemportId, pbitMap: q.PbitMap})
+		}
+		for _, q := range inst.DownstreamQueueAttributeList {
+			downstream = append(downstream, pbitQueue{gemPortID: q.GemportId, pbitMap: q.PbitMap})
+		}
+		instance = inst
+	case *tp_pb.TechProfile:
+		gemPorts := make([]uint32, tp.NumGemPorts)
+		for i := range gemPorts {
+			gemPorts[i] = gemPortID + uint32(i)
+		}
+		inst, err := techprofile.RenderTechProfileInstance(ctx, technology, tp, tpID, uni, allocID, gemPorts)
+		if err != nil {
+			return err
+		}
+		for _, gem := range inst.UpstreamGemPortAttributeList {
+			upstream = append(upstream, pbitQueue{gemPortID: gem.GemportId, pbitMap: gem.PbitMap})
+		}
+		for _, gem := range inst.DownstreamGemPortAttributeList {
+			if gem.MulticastGemId != 0 {
+				multicast = append(multicast, fmt.Sprintf("%d (pbits %s)", gem.MulticastGemId, gem.PbitMap))
+				continue
+			}
+			downstream = append(downstream, pbitQueue{gemPortID: gem.GemportId, pbitMap: gem.PbitMap})
+		}
+		instance = inst
+	}
+
+	value, err := protojson.MarshalOptions{Multiline: true, UseProtoNames: true}.Marshal(instance)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s\n\n", value)
+	writePbitMapping(stdout, upstream, downstream)
+	for _, gem := range multicast {
+		fmt.Fprintln(stdout, "multicast GEM port", gem)
+	}
+	return nil
+}
+
+func push(ctx context.Context, stdout, stderr io.Writer, technology string, tpID uint32,
+	kvStoreType string, kvStoreAddress string, kvStoreTimeout time.Duration, files []string) error {
+	file, err := singleFile(files)
+	if err != nil {
+		return err
+	}
+	tp, err := loadValidProfile(stderr, technology, file)
+	if err != nil {
+		return err
+	}
+	// the manager decodes the profile strictly, store it as the decoder sees it
+	value, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(tp)
+	if err != nil {
+		return err
+	}
+	backend := db.NewBackend(ctx, kvStoreType, kvStoreAddress, kvStoreTimeout, techprofile.TechProfileKVPrefix)
+	if backend.Client == nil {
+		return fmt.Errorf("failed to connect to the %s KV store at %s", kvStoreType, kvStoreAddress)
+	}
+	defer backend.Client.Close(ctx)
+	key := techprofile.TechProfileKVKey(technology, tpID)
+	if err = backend.Put(ctx, key, value); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s: stored as %s/%s\n", file, techprofile.TechProfileKVPrefix, key)
+	return nil
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	gponProfile = "../../pkg/techprofile/4QueueHybridProfileMap1.json"
+	eponProfile = "../../pkg/techprofile/SingleQueueEponProfile.json"
+)
+
+func runTpctl(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestValidate(t *testing.T) {
+	code, stdout, stderr := runTpctl("validate", gponProfile)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, gponProfile+": ok")
+	assert.Contains(t, stderr, "unknown fields are ignored")
+
+	code, stdout, _ = runTpctl("validate", "-technology", "EPON", eponProfile)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, eponProfile+": ok")
+
+	// a GPON profile is not an EPON one
+	code, _, stderr = runTpctl("validate", "-technology", "EPON", gponProfile)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "upstream_queue_attribute_list: has 0 queues, num_gem_ports is 4")
+}
+
+func TestValidateInvalidProfile(t *testing.T) {
+	value, err := os.ReadFile(gponProfile)
+	assert.Nil(t, err)
+	value = bytes.Replace(value, []byte(`"num_gem_ports": 4`), []byte(`"num_gem_ports": 3`), 1)
+	file := filepath.Join(t.TempDir(), "tp.json")
+	assert.Nil(t, os.WriteFile(file, value, 0600))
+
+	code, stdout, stderr := runTpctl("validate", file)
+	assert.Equal(t, 1, code)
+	assert.Empty(t, stdout)
+	assert.Contains(t, stderr, file+": upstream_gem_port_attribute_list: has 4 GEM ports, num_gem_ports is 3")
+}
+
+func TestUsage(t *testing.T) {
+	code, _, stderr := runTpctl()
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "usage: tpctl")
+
+	code, _, _ = runTpctl("unknown")
+	assert.Equal(t, 2, code)
+
+	code, _, stderr = runTpctl("validate", "-technology", "DSL", gponProfile)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `unknown technology "DSL"`)
+
+	code, _, stderr = runTpctl("render", gponProfile, eponProfile)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "expected one tech profile file, got 2")
+}
+
+func TestRender(t *testing.T) {
+	code, stdout, _ := runTpctl("render", "-alloc-id", "2048", "-gem-port-id", "4096", gponProfile)
+	assert.Equal(t, 0, code)
+	tpInst := &tp_pb.TechProfileInstance{}
+	assert.Nil(t, protojson.Unmarshal([]byte(strings.SplitN(stdout, "\n\n", 2)[0]), tpInst))
+	assert.Equal(t, "4QueueHybridProfileMap1", tpInst.Name)
+	assert.Equal(t, sampleUni, tpInst.SubscriberIdentifier)
+	assert.Equal(t, uint32(2048), tpInst.UsScheduler.AllocId)
+	assert.Contains(t, stdout, "pbit  upstream    downstream\n"+
+		"0     4096        4096\n"+
+		"1     4097        4097\n"+
+		"2     4096        4096\n"+
+		"3     4097        4097\n"+
+		"4     4097        4097\n"+
+		"5     4098        4098\n"+
+		"6     4099        4099\n"+
+		"7     4099        4099\n")
+
+	code, stdout, _ = runTpctl("render", "-technology", "EPON", "-uni", "olt-{olt1}/pon-{1}/onu-{2}/uni-{0}", eponProfile)
+	assert.Equal(t, 0, code)
+	eponTpInst := &tp_pb.EponTechProfileInstance{}
+	assert.Nil(t, protojson.Unmarshal([]byte(strings.SplitN(stdout, "\n\n", 2)[0]), eponTpInst))
+	assert.Equal(t, "olt-{olt1}/pon-{1}/onu-{2}/uni-{0}", eponTpInst.SubscriberIdentifier)
+	assert.Equal(t, uint32(1024), eponTpInst.AllocId)
+	assert.Contains(t, stdout, "0     1025        1025\n")
+
+	// the IDs are checked against the limits of the technology, as the tech profile manager does
+	code, _, stderr := runTpctl("render", "-technology", "GPON", "-gem-port-id", "4096", gponProfile)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "gem-port-id-4096-exceeds-4095")
+}
@@ -284,6 +284,30 @@ func (b *Backend) Delete(ctx context.Context, key string) error {
 	return err
 }
 
+// Txn applies several updates in a single transaction, see kvstore.TxnClient for the guarantees of
+// each KV client. It fails if the KV client does not support transactions.
+func (b *Backend) Txn(ctx context.Context, ops []kvstore.TxnOp) error {
+	span, ctx := log.CreateChildSpan(ctx, "kvs-txn")
+	defer span.Finish()
+
+	txnClient, ok := b.Client.(kvstore.TxnClient)
+	if !ok {
+		return fmt.Errorf("kv client %T does not support transactions", b.Client)
+	}
+	formattedOps := make([]kvstore.TxnOp, len(ops))
+	for i, op := range ops {
+		formattedOps[i] = op
+		formattedOps[i].Key = b.makePath(ctx, op.Key)
+	}
+	logger.Debugw(ctx, "applying-txn", log.Fields{"num-ops": len(ops)})
+
+	err := txnClient.Txn(ctx, formattedOps)
+
+	b.updateLiveness(ctx, b.isErrorIndicatingAliveKvstore(err))
+
+	return err
+}
+
 func (b *Backend) DeleteWithPrefix(ctx context.Context, prefixKey string) error {
 	span, ctx := log.CreateChildSpan(ctx, "kvs-delete-with-prefix")
 	defer span.Finish()
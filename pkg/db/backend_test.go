@@ -23,6 +23,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/opencord/voltha-lib-go/v7/pkg/db/kvstore"
 	mocks "github.com/opencord/voltha-lib-go/v7/pkg/mocks/etcd"
 	"github.com/phayes/freeport"
 	"github.com/stretchr/testify/assert"
@@ -339,6 +340,50 @@ func TestDelete_DummyEtcdServer(t *testing.T) {
 	assert.False(t, backend.alive)
 }
 
+// Test Txn applying puts and deletes together
+func TestTxn_EmbeddedEtcdServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	backend := provisionBackendWithEmbeddedEtcdServer(t)
+	err := backend.Put(ctx, "txn/key1", []uint8("value1"))
+	assert.Nil(t, err)
+
+	err = backend.Txn(ctx, []kvstore.TxnOp{
+		{Key: "txn/key1", Delete: true},
+		{Key: "txn/key2", Value: []uint8("value2")},
+		{Key: "txn/key3", Value: "value3"},
+	})
+	assert.Nil(t, err)
+	kvpair, err := backend.Get(ctx, "txn/key1")
+	assert.Nil(t, err)
+	assert.Nil(t, kvpair)
+	kvpairs, err := backend.List(ctx, "txn/")
+	assert.Nil(t, err)
+	assert.Len(t, kvpairs, 2)
+
+	// Assert that nothing is applied when an update is invalid
+	err = backend.Txn(ctx, []kvstore.TxnOp{
+		{Key: "txn/key2", Delete: true},
+		{Key: "txn/key4", Value: 4},
+	})
+	assert.NotNil(t, err)
+	kvpairs, err = backend.List(ctx, "txn/")
+	assert.Nil(t, err)
+	assert.Len(t, kvpairs, 2)
+}
+
+// Txn operation should fail against Dummy Non-existent Etcd Server
+func TestTxn_DummyEtcdServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	backend := provisionBackendWithDummyEtcdServer(t)
+	err := backend.Txn(ctx, []kvstore.TxnOp{{Key: "key1", Value: "value1"}})
+	assert.NotNil(t, err)
+
+	// Assert alive state is still false
+	assert.False(t, backend.alive)
+}
+
 // Test List for series of values under a key path
 func TestList_EmbeddedEtcdServer(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -96,3 +96,20 @@ type Client interface {
 	AcquireLock(ctx context.Context, lockName string, timeout time.Duration) error
 	ReleaseLock(lockName string) error
 }
+
+// TxnOp is an update of a transaction: a put of Value under Key, or the removal of Key if Delete is set
+type TxnOp struct {
+	Key    string
+	Value  interface{}
+	Delete bool
+}
+
+// TxnClient is implemented by the KV clients able to apply several updates in a single transaction.
+// The etcd client applies all the updates or none of them. The redis client queues them in a
+// MULTI/EXEC transaction, which no other client interleaves with but which is not rolled back: an
+// update failing at execution, e.g. on a key holding another type of value, does not prevent the
+// others from being applied.
+type TxnClient interface {
+	// Txn applies the updates in a single transaction. A key must not be updated more than once.
+	Txn(ctx context.Context, ops []TxnOp) error
+}
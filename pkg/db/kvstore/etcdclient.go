@@ -400,6 +400,37 @@ func (c *EtcdClient) DeleteWithPrefix(ctx context.Context, prefixKey string) err
 	return nil
 }
 
+// Txn applies the updates atomically in an etcd transaction
+func (c *EtcdClient) Txn(ctx context.Context, ops []TxnOp) error {
+	etcdOps := make([]clientv3.Op, 0, len(ops))
+	for _, op := range ops {
+		if op.Delete {
+			etcdOps = append(etcdOps, clientv3.OpDelete(op.Key))
+			continue
+		}
+		val, err := ToString(op.Value)
+		if err != nil {
+			return fmt.Errorf("unexpected-type-%T", op.Value)
+		}
+		etcdOps = append(etcdOps, clientv3.OpPut(op.Key, val))
+	}
+
+	client, err := c.pool.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.pool.Put(client)
+
+	txnCtx, cancel := context.WithTimeout(ctx, defaultOperationContextTimeout)
+	defer cancel()
+	if _, err = client.Txn(txnCtx).Then(etcdOps...).Commit(); err != nil {
+		logger.Warnw(ctx, "txn-failed", log.Fields{"num-ops": len(ops), "error": err})
+		return err
+	}
+	logger.Debugw(ctx, "txn-committed", log.Fields{"num-ops": len(ops)})
+	return nil
+}
+
 // Watch provides the watch capability on a given key.  It returns a channel onto which the callee needs to
 // listen to receive Events.
 func (c *EtcdClient) Watch(ctx context.Context, key string, withPrefix bool) chan *Event {
@@ -140,6 +140,43 @@ func (c *RedisClient) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// Txn applies the updates in a MULTI/EXEC transaction. The updates are executed in isolation, but
+// redis does not roll back the transaction when one of them fails at execution: the others are
+// applied nevertheless and the error of the failed one is returned.
+func (c *RedisClient) Txn(ctx context.Context, ops []TxnOp) error {
+	pipe := c.redisAPI.TxPipeline()
+	for _, op := range ops {
+		if op.Delete {
+			pipe.Del(ctx, op.Key)
+			pipe.ZRem(ctx, keysSetName, op.Key)
+			continue
+		}
+		val, err := ToString(op.Value)
+		if err != nil {
+			// the queued commands are dropped with the pipeline
+			return fmt.Errorf("unexpected-type-%T", op.Value)
+		}
+		pipe.Set(ctx, op.Key, val, 0)
+		pipe.ZAdd(ctx, keysSetName, &redis.Z{
+			Score:  0,
+			Member: op.Key,
+		})
+	}
+
+	cmds, err := pipe.Exec(ctx)
+	if err != nil {
+		logger.Warnw(ctx, "redis txn failed", log.Fields{"num-ops": len(ops), "error": err})
+		return err
+	}
+	for _, cmd := range cmds {
+		if cmd.Err() != nil && cmd.Err() != redis.Nil {
+			logger.Warnw(ctx, "redis-pipeline-command-failed", log.Fields{"error": cmd.Err(), "Cmd": cmd.String()})
+			return cmd.Err()
+		}
+	}
+	return nil
+}
+
 func (c *RedisClient) DeleteWithPrefix(ctx context.Context, prefixKey string) error {
 	var keys []string
 	var err error
@@ -0,0 +1,307 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flows
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/log"
+	ofp "github.com/opencord/voltha-protos/v5/go/openflow_13"
+)
+
+// PortTopology maps the logical ports of a logical device onto its OLT and ONUs, see
+// platform.PortTopology for the topology following the platform port numbering
+type PortTopology interface {
+	// OltDeviceID returns the device the NNI and PON ports are on
+	OltDeviceID() string
+	// IsNniPort tells whether a logical port is an NNI port
+	IsNniPort(port uint32) bool
+	// UniPath returns the ONU device of a UNI port and the PON port between the ONU and the OLT
+	UniPath(uniPort uint32) (onuDeviceID string, ponPort uint32, err error)
+}
+
+// RouteHop is the way of a packet through a device, from the port it enters to the port it leaves
+type RouteHop struct {
+	DeviceID string
+	Ingress  uint32
+	Egress   uint32
+}
+
+// getRoute returns the ONU and OLT hops between a UNI and an NNI, in the direction of the packets
+func getRoute(topology PortTopology, inPort uint32, outPort uint32) (RouteHop, RouteHop, error) {
+	uniPort, nniPort, upstream := inPort, outPort, true
+	if topology.IsNniPort(inPort) {
+		uniPort, nniPort, upstream = outPort, inPort, false
+	}
+	if !topology.IsNniPort(nniPort) {
+		return RouteHop{}, RouteHop{}, fmt.Errorf("no-route-from-port-%d-to-port-%d", inPort, outPort)
+	}
+	onuDeviceID, ponPort, err := topology.UniPath(uniPort)
+	if err != nil {
+		return RouteHop{}, RouteHop{}, err
+	}
+	if upstream {
+		return RouteHop{DeviceID: onuDeviceID, Ingress: uniPort, Egress: ponPort},
+			RouteHop{DeviceID: topology.OltDeviceID(), Ingress: ponPort, Egress: nniPort}, nil
+	}
+	return RouteHop{DeviceID: topology.OltDeviceID(), Ingress: nniPort, Egress: ponPort},
+		RouteHop{DeviceID: onuDeviceID, Ingress: ponPort, Egress: uniPort}, nil
+}
+
+// DecomposeRules decomposes the flows of a logical device and merges the flows and groups of each device
+func DecomposeRules(ctx context.Context, topology PortTopology, flows []*ofp.OfpFlowStats, groups map[uint32]*ofp.OfpGroupEntry) (*DeviceRules, error) {
+	deviceRules := NewDeviceRules()
+	for _, flow := range flows {
+		decomposedRules, err := DecomposeFlow(ctx, topology, flow, groups)
+		if err != nil {
+			return nil, err
+		}
+		for deviceID, flowsAndGroups := range decomposedRules.Rules {
+			deviceRules.CreateEntryIfNotExist(deviceID)
+			deviceRules.Rules[deviceID].AddFrom(flowsAndGroups)
+		}
+	}
+	return deviceRules, nil
+}
+
+// DecomposeFlow splits a flow of a logical device into the flows of its OLT and ONUs, following the
+// two table pipeline of ONOS:
+//   - a flow to the controller traps packets at the OLT, with the ONU forwarding them when they come from a UNI
+//   - upstream, table 0 is applied by the ONU and table 1 by the OLT
+//   - downstream, table 0 is applied by the OLT, its write metadata carrying the inner VLAN and the UNI port,
+//     and table 1 by the ONU
+//   - a multicast flow is applied by the OLT alone, along with its group
+//
+// The device flows match the UNI port in the tunnel ID on the PON side.
+func DecomposeFlow(ctx context.Context, topology PortTopology, flow *ofp.OfpFlowStats, groups map[uint32]*ofp.OfpGroupEntry) (*DeviceRules, error) {
+	inPort := GetInPort(flow)
+	outPort := GetOutPort(flow)
+	logger.Debugw(ctx, "decomposing-flow", log.Fields{"flow-id": flow.Id, "in-port": inPort, "out-port": outPort, "table-id": flow.TableId})
+
+	switch {
+	case outPort == uint32(ofp.OfpPortNo_OFPP_CONTROLLER):
+		return decomposeControllerBoundFlow(ctx, topology, inPort, flow)
+	case HasGroup(flow) && flow.TableId == 0:
+		return decomposeMulticastFlow(ctx, topology, inPort, flow, groups)
+	case inPort == 0:
+		return nil, fmt.Errorf("flow-%d-has-no-in-port", flow.Id)
+	case !topology.IsNniPort(inPort):
+		return decomposeUpstreamFlow(ctx, topology, inPort, outPort, flow)
+	case HasNextTable(flow) && flow.TableId == 0:
+		return decomposeDownstreamFlowWithNextTable(ctx, topology, inPort, flow)
+	case flow.TableId == 1 && outPort != 0:
+		return decomposeDownstreamUnicastFlow(ctx, topology, inPort, outPort, flow)
+	}
+	return nil, fmt.Errorf("unsupported-downstream-flow-%d-in-table-%d", flow.Id, flow.TableId)
+}
+
+// deviceFlowArgs returns the flow arguments the device flows keep from the logical flow
+func deviceFlowArgs(ctx context.Context, flow *ofp.OfpFlowStats) OfpFlowModArgs {
+	return OfpFlowModArgs{
+		"priority":       uint64(flow.Priority),
+		"cookie":         flow.Cookie,
+		"meter_id":       uint64(GetMeterIdFromFlow(flow)),
+		"write_metadata": GetMetadataFromWriteMetadataAction(ctx, flow),
+	}
+}
+
+func addDeviceFlow(deviceRules *DeviceRules, deviceID string, fa *FlowArgs) error {
+	fs, err := MkFlowStat(fa)
+	if err != nil {
+		return err
+	}
+	deviceRules.AddFlow(deviceID, fs)
+	return nil
+}
+
+// getOutputAction returns the output action of the flow, which carries the max length of packets to the controller
+func getOutputAction(flow *ofp.OfpFlowStats) *ofp.OfpAction {
+	for _, action := range GetActions(flow) {
+		if action.Type == OUTPUT {
+			return action
+		}
+	}
+	return nil
+}
+
+func decomposeControllerBoundFlow(ctx context.Context, topology PortTopology, inPort uint32, flow *ofp.OfpFlowStats) (*DeviceRules, error) {
+	deviceRules := NewDeviceRules()
+	if topology.IsNniPort(inPort) {
+		logger.Debugw(ctx, "trap-nni", log.Fields{"flow-id": flow.Id, "in-port": inPort})
+		fa := &FlowArgs{
+			KV:          deviceFlowArgs(ctx, flow),
+			MatchFields: append([]*ofp.OfpOxmOfbField{InPort(inPort)}, GetOfbFields(flow, IN_PORT)...),
+			Actions:     GetActions(flow),
+		}
+		if err := addDeviceFlow(deviceRules, topology.OltDeviceID(), fa); err != nil {
+			return nil, err
+		}
+		return deviceRules, nil
+	}
+	if inPort == 0 {
+		return nil, fmt.Errorf("controller-bound-flow-%d-has-no-in-port", flow.Id)
+	}
+
+	logger.Debugw(ctx, "trap-uni", log.Fields{"flow-id": flow.Id, "in-port": inPort})
+	onuHop, err := getOnuHop(topology, inPort)
+	if err != nil {
+		return nil, err
+	}
+	// the OLT sets the VLAN of the packets, the ONU tags them if the flow does
+	setVid, setVidOk := GetSetActionField(ctx, flow, VLAN_VID)
+	setPcp, setPcpOk := GetSetActionField(ctx, flow, VLAN_PCP)
+	var setActions []*ofp.OfpAction
+	if setVidOk {
+		setActions = append(setActions, SetField(VlanVid(setVid)))
+		if setPcpOk {
+			setActions = append(setActions, SetField(VlanPcp(setPcp)))
+		}
+	}
+
+	oltFlow := &FlowArgs{
+		KV:          deviceFlowArgs(ctx, flow),
+		MatchFields: append([]*ofp.OfpOxmOfbField{InPort(onuHop.Egress), TunnelId(uint64(inPort))}, GetOfbFields(flow, IN_PORT)...),
+		Actions:     append(append([]*ofp.OfpAction{}, setActions...), getOutputAction(flow)),
+	}
+	if err = addDeviceFlow(deviceRules, topology.OltDeviceID(), oltFlow); err != nil {
+		return nil, err
+	}
+
+	onuFlow := &FlowArgs{
+		KV:          deviceFlowArgs(ctx, flow),
+		MatchFields: append([]*ofp.OfpOxmOfbField{InPort(onuHop.Ingress), TunnelId(uint64(inPort))}, GetOfbFields(flow, IN_PORT, VLAN_VID)...),
+	}
+	if setVidOk {
+		// the packets leave the UNI untagged
+		onuFlow.MatchFields = append(onuFlow.MatchFields, VlanVid(uint32(ofp.OfpVlanId_OFPVID_NONE)))
+		onuFlow.Actions = append([]*ofp.OfpAction{PushVlan(0x8100)}, setActions...)
+	} else if vid := GetVlanVid(flow); vid != nil {
+		onuFlow.MatchFields = append(onuFlow.MatchFields, VlanVid(*vid))
+	}
+	onuFlow.Actions = append(onuFlow.Actions, Output(onuHop.Egress))
+	if err = addDeviceFlow(deviceRules, onuHop.DeviceID, onuFlow); err != nil {
+		return nil, err
+	}
+	return deviceRules, nil
+}
+
+func decomposeUpstreamFlow(ctx context.Context, topology PortTopology, inPort uint32, outPort uint32, flow *ofp.OfpFlowStats) (*DeviceRules, error) {
+	deviceRules := NewDeviceRules()
+	if HasNextTable(flow) {
+		// table 0 is applied by the ONU, it forwards the packets to the PON
+		onuHop, err := getOnuHop(topology, inPort)
+		if err != nil {
+			return nil, err
+		}
+		fa := &FlowArgs{
+			KV:          deviceFlowArgs(ctx, flow),
+			MatchFields: append([]*ofp.OfpOxmOfbField{InPort(onuHop.Ingress), TunnelId(uint64(inPort))}, GetOfbFields(flow, IN_PORT)...),
+			Actions:     append(GetActions(flow, OUTPUT), Output(onuHop.Egress)),
+		}
+		if err = addDeviceFlow(deviceRules, onuHop.DeviceID, fa); err != nil {
+			return nil, err
+		}
+		return deviceRules, nil
+	}
+
+	_, oltHop, err := getRoute(topology, inPort, outPort)
+	if err != nil {
+		return nil, err
+	}
+	fa := &FlowArgs{
+		KV:          deviceFlowArgs(ctx, flow),
+		MatchFields: append([]*ofp.OfpOxmOfbField{InPort(oltHop.Ingress), TunnelId(uint64(inPort))}, GetOfbFields(flow, IN_PORT)...),
+		Actions:     append(GetActions(flow, OUTPUT), Output(oltHop.Egress)),
+	}
+	if err = addDeviceFlow(deviceRules, oltHop.DeviceID, fa); err != nil {
+		return nil, err
+	}
+	return deviceRules, nil
+}
+
+// getOnuHop returns the hop of the packets from the UNI to the PON
+func getOnuHop(topology PortTopology, uniPort uint32) (RouteHop, error) {
+	onuDeviceID, ponPort, err := topology.UniPath(uniPort)
+	if err != nil {
+		return RouteHop{}, err
+	}
+	return RouteHop{DeviceID: onuDeviceID, Ingress: uniPort, Egress: ponPort}, nil
+}
+
+func decomposeDownstreamFlowWithNextTable(ctx context.Context, topology PortTopology, inPort uint32, flow *ofp.OfpFlowStats) (*DeviceRules, error) {
+	// ONOS writes the inner VLAN and the UNI port the packets go to in the metadata
+	metadata := GetMetadataFromWriteMetadataAction(ctx, flow)
+	uniPort := GetEgressPortNumberFromWriteMetadata(ctx, flow)
+	if uniPort == 0 {
+		return nil, fmt.Errorf("downstream-flow-%d-has-no-uni-port-in-write-metadata", flow.Id)
+	}
+	innerTag := GetInnerTagFromWriteMetaData(ctx, metadata)
+	if innerTag == 0 {
+		return nil, fmt.Errorf("downstream-flow-%d-has-no-inner-tag-in-write-metadata", flow.Id)
+	}
+	oltHop, _, err := getRoute(topology, inPort, uniPort)
+	if err != nil {
+		return nil, err
+	}
+	deviceRules := NewDeviceRules()
+	fa := &FlowArgs{
+		KV: deviceFlowArgs(ctx, flow),
+		MatchFields: append([]*ofp.OfpOxmOfbField{InPort(oltHop.Ingress), Metadata_ofp(uint64(innerTag)), TunnelId(uint64(uniPort))},
+			GetOfbFields(flow, IN_PORT, METADATA)...),
+		Actions: append(GetActions(flow, OUTPUT), Output(oltHop.Egress)),
+	}
+	if err = addDeviceFlow(deviceRules, oltHop.DeviceID, fa); err != nil {
+		return nil, err
+	}
+	return deviceRules, nil
+}
+
+func decomposeDownstreamUnicastFlow(ctx context.Context, topology PortTopology, inPort uint32, outPort uint32, flow *ofp.OfpFlowStats) (*DeviceRules, error) {
+	_, onuHop, err := getRoute(topology, inPort, outPort)
+	if err != nil {
+		return nil, err
+	}
+	deviceRules := NewDeviceRules()
+	fa := &FlowArgs{
+		KV:          deviceFlowArgs(ctx, flow),
+		MatchFields: append([]*ofp.OfpOxmOfbField{InPort(onuHop.Ingress)}, GetOfbFields(flow, IN_PORT)...),
+		Actions:     GetActions(flow),
+	}
+	if err = addDeviceFlow(deviceRules, onuHop.DeviceID, fa); err != nil {
+		return nil, err
+	}
+	return deviceRules, nil
+}
+
+// decomposeMulticastFlow hands the flow and its group to the OLT as is, the ONUs receive the multicast
+// GEM port of their tech profile
+func decomposeMulticastFlow(ctx context.Context, topology PortTopology, inPort uint32, flow *ofp.OfpFlowStats, groups map[uint32]*ofp.OfpGroupEntry) (*DeviceRules, error) {
+	groupID := GetGroup(flow)
+	group, ok := groups[groupID]
+	if !ok {
+		return nil, fmt.Errorf("group-%d-of-flow-%d-not-found", groupID, flow.Id)
+	}
+	if inPort != 0 && !topology.IsNniPort(inPort) {
+		return nil, fmt.Errorf("multicast-flow-%d-in-port-%d-is-not-an-nni", flow.Id, inPort)
+	}
+	logger.Debugw(ctx, "multicast-flow", log.Fields{"flow-id": flow.Id, "group-id": groupID})
+	deviceRules := NewDeviceRules()
+	deviceRules.AddFlow(topology.OltDeviceID(), flow)
+	deviceRules.Rules[topology.OltDeviceID()].AddGroup(group)
+	return deviceRules, nil
+}
@@ -0,0 +1,278 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flows
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	ofp "github.com/opencord/voltha-protos/v5/go/openflow_13"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+// the ports of the test topology, numbered as the platform package does
+const (
+	testNniPort = uint32(1 << 24)
+	testPonPort = uint32(2 << 28)
+	testUniPort = uint32(1 << 8) // PON 0, ONU 1, UNI 0
+	// the UNI of an ONU the topology does not know
+	testUnknownUniPort = uint32(2 << 8)
+	testController     = uint32(ofp.OfpPortNo_OFPP_CONTROLLER)
+)
+
+type testTopology struct{}
+
+func (testTopology) OltDeviceID() string {
+	return "olt"
+}
+
+func (testTopology) IsNniPort(port uint32) bool {
+	return port == testNniPort
+}
+
+func (testTopology) UniPath(uniPort uint32) (string, uint32, error) {
+	if uniPort != testUniPort {
+		return "", 0, fmt.Errorf("unknown-uni-port-%d", uniPort)
+	}
+	return "onu1", testPonPort, nil
+}
+
+// mkTableFlow builds a flow of the table, the table_id argument of FlowArgs being the table to go to
+func mkTableFlow(t *testing.T, tableID uint32, fa *FlowArgs) *ofp.OfpFlowStats {
+	flow, err := MkFlowStat(fa)
+	assert.Nil(t, err)
+	flow.TableId = tableID
+	flow.Id, err = HashFlowStats(flow)
+	assert.Nil(t, err)
+	return flow
+}
+
+func TestDecomposeFlow(t *testing.T) {
+	vid := func(vlan uint32) uint32 { return vlan | uint32(ofp.OfpVlanId_OFPVID_PRESENT) }
+	writeMetadata := uint64(100)<<48 | uint64(64)<<32 | uint64(testUniPort)
+	group := MkGroupStat(&GroupArgs{GroupId: 10, Buckets: []*ofp.OfpBucket{{Actions: []*ofp.OfpAction{PopVlan(), Output(testUniPort)}}}})
+	multicastFlow := mkTableFlow(t, 0, &FlowArgs{
+		KV:          OfpFlowModArgs{"priority": 1000},
+		MatchFields: []*ofp.OfpOxmOfbField{InPort(testNniPort), EthType(0x800), VlanVid(vid(4000)), Ipv4Dst(0xe4010101)},
+		Actions:     []*ofp.OfpAction{Group(10)},
+	})
+
+	tests := []struct {
+		name string
+		flow *ofp.OfpFlowStats
+		// the flows expected per device
+		want map[string][]*FlowArgs
+		// the groups expected per device
+		wantGroups map[string][]*ofp.OfpGroupEntry
+		wantErr    bool
+	}{
+		{
+			name: "trap from nni",
+			flow: mkTableFlow(t, 0, &FlowArgs{
+				KV:          OfpFlowModArgs{"priority": 10000, "cookie": 1},
+				MatchFields: []*ofp.OfpOxmOfbField{InPort(testNniPort), EthType(0x88cc)},
+				Actions:     []*ofp.OfpAction{Output(testController)},
+			}),
+			want: map[string][]*FlowArgs{
+				"olt": {{
+					KV:          OfpFlowModArgs{"priority": 10000, "cookie": 1},
+					MatchFields: []*ofp.OfpOxmOfbField{InPort(testNniPort), EthType(0x88cc)},
+					Actions:     []*ofp.OfpAction{Output(testController)},
+				}},
+			},
+		},
+		{
+			name: "trap from uni",
+			flow: mkTableFlow(t, 0, &FlowArgs{
+				KV:          OfpFlowModArgs{"priority": 10000, "cookie": 2, "meter_id": 1, "write_metadata": writeMetadata},
+				MatchFields: []*ofp.OfpOxmOfbField{InPort(testUniPort), EthType(0x888e)},
+				Actions:     []*ofp.OfpAction{SetField(VlanVid(vid(4091))), Output(testController)},
+			}),
+			want: map[string][]*FlowArgs{
+				"olt": {{
+					KV:          OfpFlowModArgs{"priority": 10000, "cookie": 2, "meter_id": 1, "write_metadata": writeMetadata},
+					MatchFields: []*ofp.OfpOxmOfbField{InPort(testPonPort), TunnelId(uint64(testUniPort)), EthType(0x888e)},
+					Actions:     []*ofp.OfpAction{SetField(VlanVid(vid(4091))), Output(testController)},
+				}},
+				"onu1": {{
+					KV: OfpFlowModArgs{"priority": 10000, "cookie": 2, "meter_id": 1, "write_metadata": writeMetadata},
+					MatchFields: []*ofp.OfpOxmOfbField{InPort(testUniPort), TunnelId(uint64(testUniPort)), EthType(0x888e),
+						VlanVid(uint32(ofp.OfpVlanId_OFPVID_NONE))},
+					Actions: []*ofp.OfpAction{PushVlan(0x8100), SetField(VlanVid(vid(4091))), Output(testPonPort)},
+				}},
+			},
+		},
+		{
+			name: "upstream table 0",
+			flow: mkTableFlow(t, 0, &FlowArgs{
+				KV:          OfpFlowModArgs{"priority": 1000, "table_id": 1, "meter_id": 1, "write_metadata": writeMetadata},
+				MatchFields: []*ofp.OfpOxmOfbField{InPort(testUniPort), VlanVid(vid(0))},
+				Actions:     []*ofp.OfpAction{SetField(VlanVid(vid(100)))},
+			}),
+			want: map[string][]*FlowArgs{
+				"onu1": {{
+					KV:          OfpFlowModArgs{"priority": 1000, "meter_id": 1, "write_metadata": writeMetadata},
+					MatchFields: []*ofp.OfpOxmOfbField{InPort(testUniPort), TunnelId(uint64(testUniPort)), VlanVid(vid(0))},
+					Actions:     []*ofp.OfpAction{SetField(VlanVid(vid(100))), Output(testPonPort)},
+				}},
+			},
+		},
+		{
+			name: "upstream table 1",
+			flow: mkTableFlow(t, 1, &FlowArgs{
+				KV:          OfpFlowModArgs{"priority": 1000, "meter_id": 2, "write_metadata": writeMetadata},
+				MatchFields: []*ofp.OfpOxmOfbField{InPort(testUniPort), VlanVid(vid(100))},
+				Actions:     []*ofp.OfpAction{PushVlan(0x8100), SetField(VlanVid(vid(4000))), Output(testNniPort)},
+			}),
+			want: map[string][]*FlowArgs{
+				"olt": {{
+					KV:          OfpFlowModArgs{"priority": 1000, "meter_id": 2, "write_metadata": writeMetadata},
+					MatchFields: []*ofp.OfpOxmOfbField{InPort(testPonPort), TunnelId(uint64(testUniPort)), VlanVid(vid(100))},
+					Actions:     []*ofp.OfpAction{PushVlan(0x8100), SetField(VlanVid(vid(4000))), Output(testNniPort)},
+				}},
+			},
+		},
+		{
+			name: "downstream table 0",
+			flow: mkTableFlow(t, 0, &FlowArgs{
+				KV:          OfpFlowModArgs{"priority": 1000, "table_id": 1, "meter_id": 3, "write_metadata": writeMetadata},
+				MatchFields: []*ofp.OfpOxmOfbField{InPort(testNniPort), VlanVid(vid(4000)), Metadata_ofp(100)},
+				Actions:     []*ofp.OfpAction{PopVlan()},
+			}),
+			want: map[string][]*FlowArgs{
+				"olt": {{
+					KV:          OfpFlowModArgs{"priority": 1000, "meter_id": 3, "write_metadata": writeMetadata},
+					MatchFields: []*ofp.OfpOxmOfbField{InPort(testNniPort), Metadata_ofp(100), TunnelId(uint64(testUniPort)), VlanVid(vid(4000))},
+					Actions:     []*ofp.OfpAction{PopVlan(), Output(testPonPort)},
+				}},
+			},
+		},
+		{
+			name: "downstream table 1",
+			flow: mkTableFlow(t, 1, &FlowArgs{
+				KV:          OfpFlowModArgs{"priority": 1000, "meter_id": 3},
+				MatchFields: []*ofp.OfpOxmOfbField{InPort(testNniPort), VlanVid(vid(100))},
+				Actions:     []*ofp.OfpAction{SetField(VlanVid(vid(0))), Output(testUniPort)},
+			}),
+			want: map[string][]*FlowArgs{
+				"onu1": {{
+					KV:          OfpFlowModArgs{"priority": 1000, "meter_id": 3},
+					MatchFields: []*ofp.OfpOxmOfbField{InPort(testPonPort), VlanVid(vid(100))},
+					Actions:     []*ofp.OfpAction{SetField(VlanVid(vid(0))), Output(testUniPort)},
+				}},
+			},
+		},
+		{
+			name:       "multicast",
+			flow:       multicastFlow,
+			want:       map[string][]*FlowArgs{"olt": nil},
+			wantGroups: map[string][]*ofp.OfpGroupEntry{"olt": {group}},
+		},
+		{
+			name: "multicast group not found",
+			flow: mkTableFlow(t, 0, &FlowArgs{
+				MatchFields: []*ofp.OfpOxmOfbField{InPort(testNniPort), EthType(0x800)},
+				Actions:     []*ofp.OfpAction{Group(11)},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "downstream table 0 without write metadata",
+			flow: mkTableFlow(t, 0, &FlowArgs{
+				KV:          OfpFlowModArgs{"table_id": 1},
+				MatchFields: []*ofp.OfpOxmOfbField{InPort(testNniPort), VlanVid(vid(4000))},
+				Actions:     []*ofp.OfpAction{PopVlan()},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "unknown onu",
+			flow: mkTableFlow(t, 1, &FlowArgs{
+				MatchFields: []*ofp.OfpOxmOfbField{InPort(testUnknownUniPort), VlanVid(vid(100))},
+				Actions:     []*ofp.OfpAction{Output(testNniPort)},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "no in port",
+			flow: mkTableFlow(t, 1, &FlowArgs{
+				MatchFields: []*ofp.OfpOxmOfbField{VlanVid(vid(100))},
+				Actions:     []*ofp.OfpAction{Output(testNniPort)},
+			}),
+			wantErr: true,
+		},
+	}
+	groups := map[uint32]*ofp.OfpGroupEntry{10: group}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deviceRules, err := DecomposeFlow(context.Background(), testTopology{}, tt.flow, groups)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Len(t, deviceRules.Keys(), len(tt.want))
+			for deviceID, wantFlows := range tt.want {
+				fg, ok := deviceRules.GetRules()[deviceID]
+				if !assert.True(t, ok, deviceID) {
+					continue
+				}
+				var want []*ofp.OfpFlowStats
+				for _, fa := range wantFlows {
+					flow, err := MkFlowStat(fa)
+					assert.Nil(t, err)
+					want = append(want, flow)
+				}
+				if wantFlows == nil {
+					// the flow is handed over as is
+					want = []*ofp.OfpFlowStats{tt.flow}
+				}
+				got := fg.ListFlows()
+				if assert.Len(t, got, len(want), deviceID) {
+					for i := range want {
+						assert.True(t, proto.Equal(want[i], got[i]), "%s: want %v got %v", deviceID, want[i], got[i])
+					}
+				}
+				assert.Equal(t, len(tt.wantGroups[deviceID]), len(fg.ListGroups()))
+			}
+		})
+	}
+}
+
+func TestDecomposeRules(t *testing.T) {
+	trapNni := mkTableFlow(t, 0, &FlowArgs{
+		MatchFields: []*ofp.OfpOxmOfbField{InPort(testNniPort), EthType(0x88cc)},
+		Actions:     []*ofp.OfpAction{Output(testController)},
+	})
+	trapUni := mkTableFlow(t, 0, &FlowArgs{
+		MatchFields: []*ofp.OfpOxmOfbField{InPort(testUniPort), EthType(0x888e)},
+		Actions:     []*ofp.OfpAction{Output(testController)},
+	})
+	deviceRules, err := DecomposeRules(context.Background(), testTopology{}, []*ofp.OfpFlowStats{trapNni, trapUni}, nil)
+	assert.Nil(t, err)
+	assert.Len(t, deviceRules.GetRules()["olt"].ListFlows(), 2)
+	assert.Len(t, deviceRules.GetRules()["onu1"].ListFlows(), 1)
+
+	unknownUni := mkTableFlow(t, 0, &FlowArgs{
+		MatchFields: []*ofp.OfpOxmOfbField{InPort(testUnknownUniPort), EthType(0x888e)},
+		Actions:     []*ofp.OfpAction{Output(testController)},
+	})
+	_, err = DecomposeRules(context.Background(), testTopology{}, []*ofp.OfpFlowStats{trapNni, unknownUni}, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,660 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flows
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	ofp "github.com/opencord/voltha-protos/v5/go/openflow_13"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// The text of a flow follows the syntax of ovs-ofctl, for example
+//
+//	table=0,priority=1000,cookie=0x1,in_port=16,vlan_vid=4196,actions=push_vlan:0x8100,set_field:vlan_vid=4296,output:1,goto_table:1,meter:1
+//
+// Match fields are named after their OXM type and may have a mask, as in vlan_vid=4096/0x1000. The
+// actions list holds the apply actions followed by the other instructions in the order of the flow,
+// which is goto_table, meter and write_metadata for the flows of MkFlowStat, "drop" if there is none.
+// The text of a group is, for example
+//
+//	group_id=10,type=all,bucket=actions=pop_vlan,output:16,bucket=weight:2,watch_port:17,actions=output:17
+
+type fieldKind uint8
+
+const (
+	decimalField fieldKind = iota
+	hexField
+	macField
+	ipv4Field
+	ipv6Field
+)
+
+// fieldSyntax is how the value of a match field is written
+type fieldSyntax struct {
+	kind fieldKind
+	bits int
+	// build returns the field of a value which fits an integer
+	build func(value uint64) *ofp.OfpOxmOfbField
+	// buildBytes returns the field of a value held as bytes, when build is nil
+	buildBytes func(value []byte) *ofp.OfpOxmOfbField
+	// setMask sets the mask of the field, nil if masks are not supported
+	setMask func(field *ofp.OfpOxmOfbField, mask uint64)
+}
+
+func uint32Field(build func(uint32) *ofp.OfpOxmOfbField) func(uint64) *ofp.OfpOxmOfbField {
+	return func(value uint64) *ofp.OfpOxmOfbField { return build(uint32(value)) }
+}
+
+var fieldSyntaxes = map[ofp.OxmOfbFieldTypes]*fieldSyntax{
+	IN_PORT:     {kind: decimalField, bits: 32, build: uint32Field(InPort)},
+	IN_PHY_PORT: {kind: decimalField, bits: 32, build: uint32Field(InPhyPort)},
+	METADATA: {kind: hexField, bits: 64, build: Metadata_ofp, setMask: func(field *ofp.OfpOxmOfbField, mask uint64) {
+		field.Mask = &ofp.OfpOxmOfbField_TableMetadataMask{TableMetadataMask: mask}
+	}},
+	ETH_DST:  {kind: macField, bits: 48, build: EthDst},
+	ETH_SRC:  {kind: macField, bits: 48, build: EthSrc},
+	ETH_TYPE: {kind: hexField, bits: 16, build: uint32Field(EthType)},
+	VLAN_VID: {kind: decimalField, bits: 13, build: uint32Field(VlanVid), setMask: func(field *ofp.OfpOxmOfbField, mask uint64) {
+		field.Mask = &ofp.OfpOxmOfbField_VlanVidMask{VlanVidMask: uint32(mask)}
+	}},
+	VLAN_PCP: {kind: decimalField, bits: 3, build: uint32Field(VlanPcp)},
+	IP_DSCP:  {kind: decimalField, bits: 6, build: uint32Field(IpDscp)},
+	IP_ECN:   {kind: decimalField, bits: 2, build: uint32Field(IpEcn)},
+	IP_PROTO: {kind: decimalField, bits: 8, build: uint32Field(IpProto)},
+	IPV4_SRC: {kind: ipv4Field, bits: 32, build: uint32Field(Ipv4Src), setMask: func(field *ofp.OfpOxmOfbField, mask uint64) {
+		field.Mask = &ofp.OfpOxmOfbField_Ipv4SrcMask{Ipv4SrcMask: uint32(mask)}
+	}},
+	IPV4_DST: {kind: ipv4Field, bits: 32, build: uint32Field(Ipv4Dst), setMask: func(field *ofp.OfpOxmOfbField, mask uint64) {
+		field.Mask = &ofp.OfpOxmOfbField_Ipv4DstMask{Ipv4DstMask: uint32(mask)}
+	}},
+	TCP_SRC:     {kind: decimalField, bits: 16, build: uint32Field(TcpSrc)},
+	TCP_DST:     {kind: decimalField, bits: 16, build: uint32Field(TcpDst)},
+	UDP_SRC:     {kind: decimalField, bits: 16, build: uint32Field(UdpSrc)},
+	UDP_DST:     {kind: decimalField, bits: 16, build: uint32Field(UdpDst)},
+	SCTP_SRC:    {kind: decimalField, bits: 16, build: uint32Field(SctpSrc)},
+	SCTP_DST:    {kind: decimalField, bits: 16, build: uint32Field(SctpDst)},
+	ICMPV4_TYPE: {kind: decimalField, bits: 8, build: uint32Field(Icmpv4Type)},
+	ICMPV4_CODE: {kind: decimalField, bits: 8, build: uint32Field(Icmpv4Code)},
+	ARP_OP:      {kind: decimalField, bits: 16, build: uint32Field(ArpOp)},
+	ARP_SPA: {kind: ipv4Field, bits: 32, build: uint32Field(ArpSpa), setMask: func(field *ofp.OfpOxmOfbField, mask uint64) {
+		field.Mask = &ofp.OfpOxmOfbField_ArpSpaMask{ArpSpaMask: uint32(mask)}
+	}},
+	ARP_TPA: {kind: ipv4Field, bits: 32, build: uint32Field(ArpTpa), setMask: func(field *ofp.OfpOxmOfbField, mask uint64) {
+		field.Mask = &ofp.OfpOxmOfbField_ArpTpaMask{ArpTpaMask: uint32(mask)}
+	}},
+	ARP_SHA:  {kind: macField, buildBytes: ArpSha},
+	ARP_THA:  {kind: macField, buildBytes: ArpTha},
+	IPV6_SRC: {kind: ipv6Field, buildBytes: Ipv6Src},
+	IPV6_DST: {kind: ipv6Field, buildBytes: Ipv6Dst},
+	IPV6_FLABEL: {kind: hexField, bits: 20, build: uint32Field(Ipv6Flabel), setMask: func(field *ofp.OfpOxmOfbField, mask uint64) {
+		field.Mask = &ofp.OfpOxmOfbField_Ipv6FlabelMask{Ipv6FlabelMask: uint32(mask)}
+	}},
+	ICMPV6_TYPE:     {kind: decimalField, bits: 8, build: uint32Field(Icmpv6Type)},
+	ICMPV6_CODE:     {kind: decimalField, bits: 8, build: uint32Field(Icmpv6Code)},
+	IPV6_ND_TARGET:  {kind: ipv6Field, buildBytes: Ipv6NdTarget},
+	OFB_IPV6_ND_SLL: {kind: macField, buildBytes: OfbIpv6NdSll},
+	IPV6_ND_TLL:     {kind: macField, buildBytes: Ipv6NdTll},
+	MPLS_LABEL:      {kind: decimalField, bits: 20, build: uint32Field(MplsLabel)},
+	MPLS_TC:         {kind: decimalField, bits: 3, build: uint32Field(MplsTc)},
+	MPLS_BOS:        {kind: decimalField, bits: 1, build: uint32Field(MplsBos)},
+	PBB_ISID: {kind: decimalField, bits: 24, build: uint32Field(PbbIsid), setMask: func(field *ofp.OfpOxmOfbField, mask uint64) {
+		field.Mask = &ofp.OfpOxmOfbField_PbbIsidMask{PbbIsidMask: uint32(mask)}
+	}},
+	TUNNEL_ID: {kind: decimalField, bits: 64, build: TunnelId, setMask: func(field *ofp.OfpOxmOfbField, mask uint64) {
+		field.Mask = &ofp.OfpOxmOfbField_TunnelIdMask{TunnelIdMask: mask}
+	}},
+	IPV6_EXTHDR: {kind: hexField, bits: 9, build: uint32Field(Ipv6Exthdr), setMask: func(field *ofp.OfpOxmOfbField, mask uint64) {
+		field.Mask = &ofp.OfpOxmOfbField_Ipv6ExthdrMask{Ipv6ExthdrMask: uint32(mask)}
+	}},
+}
+
+// fieldName returns the name of the match field type in the text of flows, e.g. vlan_vid
+func fieldName(fieldType ofp.OxmOfbFieldTypes) string {
+	return strings.ToLower(strings.TrimPrefix(fieldType.String(), "OFPXMT_OFB_"))
+}
+
+var fieldTypesByName = func() map[string]ofp.OxmOfbFieldTypes {
+	types := make(map[string]ofp.OxmOfbFieldTypes, len(fieldSyntaxes))
+	for fieldType := range fieldSyntaxes {
+		types[fieldName(fieldType)] = fieldType
+	}
+	return types
+}()
+
+func formatFieldValue(kind fieldKind, value []byte) string {
+	switch kind {
+	case macField:
+		// EthDst and EthSrc hold the MAC address in the 6 low bytes of a table metadata
+		if len(value) == 8 {
+			value = value[2:]
+		}
+		return net.HardwareAddr(value).String()
+	case ipv4Field, ipv6Field:
+		return net.IP(value).String()
+	}
+	if kind == hexField {
+		return fmt.Sprintf("0x%x", bytesToUint(value))
+	}
+	return strconv.FormatUint(bytesToUint(value), 10)
+}
+
+func bytesToUint(value []byte) uint64 {
+	var number uint64
+	for _, b := range value {
+		number = number<<8 | uint64(b)
+	}
+	return number
+}
+
+func formatOfbField(field *ofp.OfpOxmOfbField) string {
+	text := fieldName(field.Type) + "="
+	value, err := ofbFieldValue(field)
+	if err != nil {
+		return text + "?"
+	}
+	kind := hexField
+	if syntax, ok := fieldSyntaxes[field.Type]; ok {
+		kind = syntax.kind
+	}
+	text += formatFieldValue(kind, value)
+	if field.HasMask {
+		if mask, err := ofbFieldMask(field); err == nil {
+			text += fmt.Sprintf("/0x%x", bytesToUint(mask))
+		}
+	}
+	return text
+}
+
+func formatOxmField(field *ofp.OfpOxmField) string {
+	if ofbField := field.GetOfbField(); ofbField != nil {
+		return formatOfbField(ofbField)
+	}
+	return fmt.Sprintf("experimenter=0x%x", field.GetExperimenterField().GetExperimenter())
+}
+
+func formatPort(port uint32) string {
+	if port >= uint32(ofp.OfpPortNo_OFPP_MAX) {
+		if name, ok := ofp.OfpPortNo_name[int32(port)]; ok {
+			return strings.TrimPrefix(name, "OFPP_")
+		}
+	}
+	return strconv.FormatUint(uint64(port), 10)
+}
+
+func formatAction(action *ofp.OfpAction) string {
+	switch action.Type {
+	case OUTPUT:
+		text := "output:" + formatPort(action.GetOutput().GetPort())
+		if maxLen := action.GetOutput().GetMaxLen(); maxLen != uint32(ofp.OfpControllerMaxLen_OFPCML_MAX) {
+			text += fmt.Sprintf(":%d", maxLen)
+		}
+		return text
+	case GROUP:
+		return fmt.Sprintf("group:%d", action.GetGroup().GetGroupId())
+	case PUSH_VLAN:
+		return fmt.Sprintf("push_vlan:0x%x", action.GetPush().GetEthertype())
+	case POP_VLAN:
+		return "pop_vlan"
+	case POP_MPLS:
+		return fmt.Sprintf("pop_mpls:0x%x", action.GetPopMpls().GetEthertype())
+	case SET_MPLS_TTL:
+		return fmt.Sprintf("set_mpls_ttl:%d", action.GetMplsTtl().GetMplsTtl())
+	case SET_FIELD:
+		if field := action.GetSetField().GetField(); field != nil {
+			return "set_field:" + formatOxmField(field)
+		}
+	}
+	return strings.ToLower(strings.TrimPrefix(action.Type.String(), "OFPAT_"))
+}
+
+func formatActions(actions []*ofp.OfpAction) []string {
+	texts := make([]string, 0, len(actions))
+	for _, action := range actions {
+		texts = append(texts, formatAction(action))
+	}
+	return texts
+}
+
+func formatActionList(texts []string) string {
+	if len(texts) == 0 {
+		return "actions=drop"
+	}
+	return "actions=" + strings.Join(texts, ",")
+}
+
+// FormatFlow returns the text of the flow. ParseFlow reads back the flows MkFlowStat builds, i.e.
+// those with OpenFlow basic match fields and apply actions, goto_table, meter and write_metadata
+// instructions only. Other instructions such as write_actions and clear_actions, experimenter fields
+// and masks of the fields which ParseFlow does not accept masked, e.g. eth_dst or ipv6_src, are
+// written for display only and make ParseFlow fail.
+func FormatFlow(flow *ofp.OfpFlowStats) string {
+	if flow == nil {
+		return ""
+	}
+	texts := []string{fmt.Sprintf("table=%d", flow.TableId), fmt.Sprintf("priority=%d", flow.Priority)}
+	if flow.Cookie != 0 {
+		texts = append(texts, fmt.Sprintf("cookie=0x%x", flow.Cookie))
+	}
+	if flow.IdleTimeout != 0 {
+		texts = append(texts, fmt.Sprintf("idle_timeout=%d", flow.IdleTimeout))
+	}
+	if flow.HardTimeout != 0 {
+		texts = append(texts, fmt.Sprintf("hard_timeout=%d", flow.HardTimeout))
+	}
+	if flow.Flags != 0 {
+		texts = append(texts, fmt.Sprintf("flags=0x%x", flow.Flags))
+	}
+	if flow.Match != nil {
+		for _, field := range flow.Match.OxmFields {
+			texts = append(texts, formatOxmField(field))
+		}
+	}
+
+	actions := make([]string, 0)
+	for _, instruction := range flow.Instructions {
+		switch ofp.OfpInstructionType(instruction.Type) {
+		case APPLY_ACTIONS:
+			actions = append(actions, formatActions(instruction.GetActions().GetActions())...)
+		case METER_ACTION:
+			actions = append(actions, fmt.Sprintf("meter:%d", instruction.GetMeter().GetMeterId()))
+		case WRITE_METADATA:
+			actions = append(actions, fmt.Sprintf("write_metadata:0x%x", instruction.GetWriteMetadata().GetMetadata()))
+		case ofp.OfpInstructionType_OFPIT_GOTO_TABLE:
+			actions = append(actions, fmt.Sprintf("goto_table:%d", instruction.GetGotoTable().GetTableId()))
+		default:
+			actions = append(actions, strings.ToLower(strings.TrimPrefix(ofp.OfpInstructionType(instruction.Type).String(), "OFPIT_")))
+		}
+	}
+	return strings.Join(append(texts, formatActionList(actions)), ",")
+}
+
+// FormatGroup returns the text of the group, which ParseGroup reads back
+func FormatGroup(group *ofp.OfpGroupEntry) string {
+	if group == nil || group.Desc == nil {
+		return ""
+	}
+	groupType := strings.ToLower(strings.TrimPrefix(group.Desc.Type.String(), "OFPGT_"))
+	texts := []string{fmt.Sprintf("group_id=%d", group.Desc.GroupId), "type=" + groupType}
+	for _, bucket := range group.Desc.Buckets {
+		bucketTexts := make([]string, 0)
+		if bucket.Weight != 0 {
+			bucketTexts = append(bucketTexts, fmt.Sprintf("weight:%d", bucket.Weight))
+		}
+		if bucket.WatchPort != 0 && bucket.WatchPort != uint32(ofp.OfpPortNo_OFPP_ANY) {
+			bucketTexts = append(bucketTexts, "watch_port:"+formatPort(bucket.WatchPort))
+		}
+		if bucket.WatchGroup != 0 && bucket.WatchGroup != uint32(ofp.OfpGroup_OFPG_ANY) {
+			bucketTexts = append(bucketTexts, fmt.Sprintf("watch_group:%d", bucket.WatchGroup))
+		}
+		bucketTexts = append(bucketTexts, formatActionList(formatActions(bucket.Actions)))
+		texts = append(texts, "bucket="+strings.Join(bucketTexts, ","))
+	}
+	return strings.Join(texts, ",")
+}
+
+func parseUint(text string, bits int) (uint64, error) {
+	value, err := strconv.ParseUint(text, 0, bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid-number-%q", text)
+	}
+	return value, nil
+}
+
+func parsePort(text string) (uint32, error) {
+	if port, ok := ofp.OfpPortNo_value["OFPP_"+strings.ToUpper(text)]; ok && port >= int32(ofp.OfpPortNo_OFPP_MAX) {
+		return uint32(port), nil
+	}
+	port, err := parseUint(text, 32)
+	return uint32(port), err
+}
+
+func parseOfbField(text string) (*ofp.OfpOxmOfbField, error) {
+	name, valueText, ok := strings.Cut(text, "=")
+	if !ok {
+		return nil, fmt.Errorf("match-field-%q-has-no-value", text)
+	}
+	fieldType, ok := fieldTypesByName[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown-match-field-%q", name)
+	}
+	syntax := fieldSyntaxes[fieldType]
+	valueText, maskText, hasMask := strings.Cut(valueText, "/")
+
+	var field *ofp.OfpOxmOfbField
+	switch syntax.kind {
+	case macField:
+		mac, err := net.ParseMAC(valueText)
+		if err != nil || len(mac) != 6 {
+			return nil, fmt.Errorf("invalid-mac-address-%q", valueText)
+		}
+		if syntax.buildBytes != nil {
+			field = syntax.buildBytes(mac)
+		} else {
+			field = syntax.build(binary.BigEndian.Uint64(append([]byte{0, 0}, mac...)))
+		}
+	case ipv4Field:
+		ip := net.ParseIP(valueText).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid-ipv4-address-%q", valueText)
+		}
+		field = syntax.build(uint64(binary.BigEndian.Uint32(ip)))
+	case ipv6Field:
+		ip := net.ParseIP(valueText)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid-ipv6-address-%q", valueText)
+		}
+		field = syntax.buildBytes(ip.To16())
+	default:
+		value, err := parseUint(valueText, syntax.bits)
+		if err != nil {
+			return nil, err
+		}
+		field = syntax.build(value)
+	}
+
+	if hasMask {
+		if syntax.setMask == nil {
+			return nil, fmt.Errorf("match-field-%s-can-not-have-a-mask", name)
+		}
+		mask, err := parseUint(maskText, syntax.bits)
+		if err != nil {
+			return nil, err
+		}
+		field.HasMask = true
+		syntax.setMask(field, mask)
+	}
+	return field, nil
+}
+
+// instructionArgs are the arguments of MkFlowStat of the instructions in the actions list
+var instructionArgs = map[string]string{"meter": "meter_id", "write_metadata": "write_metadata", "goto_table": "table_id"}
+
+// parseActions parses the actions of the list, the instructions other than apply actions go to kv
+func parseActions(text string, kv OfpFlowModArgs) ([]*ofp.OfpAction, error) {
+	actions := make([]*ofp.OfpAction, 0)
+	if text == "drop" {
+		return actions, nil
+	}
+	for _, actionText := range strings.Split(text, ",") {
+		name, arg, _ := strings.Cut(actionText, ":")
+		switch name {
+		case "output":
+			portText, maxLenText, hasMaxLen := strings.Cut(arg, ":")
+			port, err := parsePort(portText)
+			if err != nil {
+				return nil, err
+			}
+			maxLen := uint64(ofp.OfpControllerMaxLen_OFPCML_MAX)
+			if hasMaxLen {
+				if maxLen, err = parseUint(maxLenText, 16); err != nil {
+					return nil, err
+				}
+			}
+			actions = append(actions, Output(port, ofp.OfpControllerMaxLen(maxLen)))
+		case "group":
+			groupID, err := parseUint(arg, 32)
+			if err != nil {
+				return nil, err
+			}
+			actions = append(actions, Group(uint32(groupID)))
+		case "push_vlan":
+			ethType, err := parseUint(arg, 16)
+			if err != nil {
+				return nil, err
+			}
+			actions = append(actions, PushVlan(uint32(ethType)))
+		case "pop_vlan":
+			actions = append(actions, PopVlan())
+		case "pop_mpls":
+			ethType, err := parseUint(arg, 16)
+			if err != nil {
+				return nil, err
+			}
+			actions = append(actions, PopMpls(uint32(ethType)))
+		case "set_mpls_ttl":
+			ttl, err := parseUint(arg, 8)
+			if err != nil {
+				return nil, err
+			}
+			actions = append(actions, MplsTtl(uint32(ttl)))
+		case "set_field":
+			field, err := parseOfbField(arg)
+			if err != nil {
+				return nil, err
+			}
+			actions = append(actions, SetField(field))
+		case "meter", "write_metadata", "goto_table":
+			if kv == nil {
+				return nil, fmt.Errorf("instruction-%s-not-allowed-in-group-bucket", name)
+			}
+			value, err := parseUint(arg, 64)
+			if err != nil {
+				return nil, err
+			}
+			kv[instructionArgs[name]] = value
+		default:
+			return nil, fmt.Errorf("unsupported-action-%q", actionText)
+		}
+	}
+	return actions, nil
+}
+
+// removeSpaces removes the spaces around the elements of the text
+func removeSpaces(text string) string {
+	return strings.Join(strings.Fields(text), "")
+}
+
+// cutActions splits the text at the actions list
+func cutActions(text string) (string, string, error) {
+	if strings.HasPrefix(text, "actions=") {
+		return "", strings.TrimPrefix(text, "actions="), nil
+	}
+	before, after, ok := strings.Cut(text, ",actions=")
+	if !ok {
+		return "", "", fmt.Errorf("no-actions-in-%q", text)
+	}
+	return before, after, nil
+}
+
+// ParseFlowArgs parses the text of a flow into the arguments of MkFlowStat and the table of the flow.
+// Note that the goto_table instruction is the "table_id" argument of MkFlowStat, which also sets the
+// table of the flow, so the table the text states must be set on the flow made from the arguments.
+// ParseFlow does so.
+func ParseFlowArgs(text string) (*FlowArgs, uint32, error) {
+	fieldsText, actionsText, err := cutActions(removeSpaces(text))
+	if err != nil {
+		return nil, 0, err
+	}
+	fa := &FlowArgs{MatchFields: make([]*ofp.OfpOxmOfbField, 0), KV: make(OfpFlowModArgs)}
+	tableID := uint64(0)
+	for _, fieldText := range strings.Split(fieldsText, ",") {
+		if fieldText == "" {
+			continue
+		}
+		key, valueText, _ := strings.Cut(fieldText, "=")
+		switch key {
+		case "table":
+			if tableID, err = parseUint(valueText, 8); err != nil {
+				return nil, 0, err
+			}
+		case "priority", "idle_timeout", "hard_timeout", "flags":
+			if fa.KV[key], err = parseUint(valueText, 16); err != nil {
+				return nil, 0, err
+			}
+		case "cookie":
+			if fa.KV[key], err = parseUint(valueText, 64); err != nil {
+				return nil, 0, err
+			}
+		default:
+			field, err := parseOfbField(fieldText)
+			if err != nil {
+				return nil, 0, err
+			}
+			fa.MatchFields = append(fa.MatchFields, field)
+		}
+	}
+	if fa.Actions, err = parseActions(actionsText, fa.KV); err != nil {
+		return nil, 0, err
+	}
+	return fa, uint32(tableID), nil
+}
+
+// ParseFlow returns the flow of the text, see FormatFlow
+func ParseFlow(text string) (*ofp.OfpFlowStats, error) {
+	fa, tableID, err := ParseFlowArgs(text)
+	if err != nil {
+		return nil, err
+	}
+	flow, err := MkFlowStat(fa)
+	if err != nil {
+		return nil, err
+	}
+	if flow.TableId != tableID {
+		flow.TableId = tableID
+		if flow.Id, err = HashFlowStats(flow); err != nil {
+			return nil, err
+		}
+	}
+	return flow, nil
+}
+
+func parseBucket(text string) (*ofp.OfpBucket, error) {
+	propertiesText, actionsText, err := cutActions(text)
+	if err != nil {
+		return nil, err
+	}
+	bucket := &ofp.OfpBucket{}
+	for _, propertyText := range strings.Split(propertiesText, ",") {
+		if propertyText == "" {
+			continue
+		}
+		name, valueText, _ := strings.Cut(propertyText, ":")
+		switch name {
+		case "weight":
+			weight, err := parseUint(valueText, 16)
+			if err != nil {
+				return nil, err
+			}
+			bucket.Weight = uint32(weight)
+		case "watch_port":
+			if bucket.WatchPort, err = parsePort(valueText); err != nil {
+				return nil, err
+			}
+		case "watch_group":
+			watchGroup, err := parseUint(valueText, 32)
+			if err != nil {
+				return nil, err
+			}
+			bucket.WatchGroup = uint32(watchGroup)
+		default:
+			return nil, fmt.Errorf("unknown-bucket-property-%q", propertyText)
+		}
+	}
+	if bucket.Actions, err = parseActions(actionsText, nil); err != nil {
+		return nil, err
+	}
+	return bucket, nil
+}
+
+// ParseGroupArgs parses the text of a group into the arguments of MkGroupStat and the type of the
+// group, which MkGroupStat does not set. ParseGroup does so.
+func ParseGroupArgs(text string) (*GroupArgs, ofp.OfpGroupType, error) {
+	bucketTexts := strings.Split(removeSpaces(text), ",bucket=")
+	ga := &GroupArgs{Buckets: make([]*ofp.OfpBucket, 0)}
+	groupType, hasGroupID := ofp.OfpGroupType_OFPGT_ALL, false
+	for _, propertyText := range strings.Split(bucketTexts[0], ",") {
+		key, valueText, _ := strings.Cut(propertyText, "=")
+		switch key {
+		case "group_id":
+			groupID, err := parseUint(valueText, 32)
+			if err != nil {
+				return nil, 0, err
+			}
+			ga.GroupId, hasGroupID = uint32(groupID), true
+		case "type":
+			value, ok := ofp.OfpGroupType_value["OFPGT_"+strings.ToUpper(valueText)]
+			if !ok {
+				return nil, 0, fmt.Errorf("unknown-group-type-%q", valueText)
+			}
+			groupType = ofp.OfpGroupType(value)
+		default:
+			return nil, 0, fmt.Errorf("unknown-group-property-%q", propertyText)
+		}
+	}
+	if !hasGroupID {
+		return nil, 0, fmt.Errorf("no-group-id-in-%q", text)
+	}
+	for _, bucketText := range bucketTexts[1:] {
+		bucket, err := parseBucket(bucketText)
+		if err != nil {
+			return nil, 0, err
+		}
+		ga.Buckets = append(ga.Buckets, bucket)
+	}
+	return ga, groupType, nil
+}
+
+// ParseGroup returns the group of the text, see FormatGroup
+func ParseGroup(text string) (*ofp.OfpGroupEntry, error) {
+	ga, groupType, err := ParseGroupArgs(text)
+	if err != nil {
+		return nil, err
+	}
+	group := MkGroupStat(ga)
+	group.Desc.Type = groupType
+	return group, nil
+}
+
+// FlowToJSON returns the JSON encoding of the flow
+func FlowToJSON(flow *ofp.OfpFlowStats) ([]byte, error) {
+	return protojson.Marshal(flow)
+}
+
+// FlowFromJSON returns the flow of the JSON encoding, the ID of the flow being computed from its
+// match if missing
+func FlowFromJSON(data []byte) (*ofp.OfpFlowStats, error) {
+	flow := &ofp.OfpFlowStats{}
+	if err := protojson.Unmarshal(data, flow); err != nil {
+		return nil, err
+	}
+	if flow.Id == 0 && flow.Match != nil {
+		var err error
+		if flow.Id, err = HashFlowStats(flow); err != nil {
+			return nil, err
+		}
+	}
+	return flow, nil
+}
+
+// GroupToJSON returns the JSON encoding of the group
+func GroupToJSON(group *ofp.OfpGroupEntry) ([]byte, error) {
+	return protojson.Marshal(group)
+}
+
+// GroupFromJSON returns the group of the JSON encoding
+func GroupFromJSON(data []byte) (*ofp.OfpGroupEntry, error) {
+	group := &ofp.OfpGroupEntry{}
+	if err := protojson.Unmarshal(data, group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
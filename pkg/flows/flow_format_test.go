@@ -0,0 +1,168 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flows
+
+import (
+	"testing"
+
+	ofp "github.com/opencord/voltha-protos/v5/go/openflow_13"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFormatAndParseFlow(t *testing.T) {
+	vid := func(vlan uint32) uint32 { return vlan | uint32(ofp.OfpVlanId_OFPVID_PRESENT) }
+	tests := []struct {
+		name string
+		text string
+		// expected is the flow of the text, nil if only the round trip is checked
+		expected *ofp.OfpFlowStats
+	}{
+		{"upstream", "table=0,priority=1000,cookie=0x1,in_port=16,vlan_vid=4196," +
+			"actions=push_vlan:0x8100,set_field:vlan_vid=4296,output:1,goto_table:1,meter:1,write_metadata:0x64000000000010",
+			mkTableFlow(t, 0, &FlowArgs{
+				KV:          OfpFlowModArgs{"priority": 1000, "cookie": 1, "table_id": 1, "meter_id": 1, "write_metadata": 0x64000000000010},
+				MatchFields: []*ofp.OfpOxmOfbField{InPort(16), VlanVid(vid(100))},
+				Actions:     []*ofp.OfpAction{PushVlan(0x8100), SetField(VlanVid(vid(200))), Output(1)},
+			})},
+		{"trap-to-controller", "table=0,priority=10000,in_port=16,eth_type=0x888e,actions=output:CONTROLLER:65535",
+			mkTableFlow(t, 0, &FlowArgs{
+				KV:          OfpFlowModArgs{"priority": 10000},
+				MatchFields: []*ofp.OfpOxmOfbField{InPort(16), EthType(0x888e)},
+				Actions:     []*ofp.OfpAction{Output(uint32(ofp.OfpPortNo_OFPP_CONTROLLER), ofp.OfpControllerMaxLen_OFPCML_NO_BUFFER)},
+			})},
+		{"multicast", "table=1,priority=500,metadata=0x64/0xffff,eth_dst=01:00:5e:01:01:01,vlan_vid=4096/0x1000,ipv4_dst=228.1.1.1,actions=group:10",
+			nil},
+		{"drop", "table=0,priority=0,actions=drop",
+			mkTableFlow(t, 0, &FlowArgs{KV: OfpFlowModArgs{"priority": 0}})},
+		{"byte-fields", "table=3,priority=1,arp_sha=00:11:22:33:44:55,ipv6_src=2001:db8::1,actions=drop",
+			nil},
+		{"timeouts", "table=2,priority=5,cookie=0xffff,idle_timeout=30,hard_timeout=60,flags=0x1,tunnel_id=16,actions=pop_vlan,output:2",
+			nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flow, err := ParseFlow(tt.text)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.text, FormatFlow(flow))
+			if tt.expected != nil {
+				assert.True(t, proto.Equal(tt.expected, flow), "expected %s", FormatFlow(tt.expected))
+			}
+			hash, err := HashFlowStats(flow)
+			assert.Nil(t, err)
+			assert.Equal(t, hash, flow.Id)
+		})
+	}
+
+	fa, tableID, err := ParseFlowArgs(" table=1, priority=10, in_port=1, actions=output:2, goto_table:2 ")
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), tableID)
+	assert.Equal(t, OfpFlowModArgs{"priority": 10, "table_id": 2}, fa.KV)
+	assert.Equal(t, []*ofp.OfpOxmOfbField{InPort(1)}, fa.MatchFields)
+	assert.Equal(t, []*ofp.OfpAction{Output(2)}, fa.Actions)
+}
+
+func TestParseFlowErrors(t *testing.T) {
+	for _, text := range []string{
+		"table=0,in_port=1",
+		"table=0,in_port=1,foo=2,actions=drop",
+		"table=0,in_port=one,actions=drop",
+		"table=0,in_port=1/0x1,actions=drop",
+		"table=0,vlan_vid=8192,actions=drop",
+		"table=0,eth_dst=01:00:5e,actions=drop",
+		"table=0,ipv4_dst=228.1.1,actions=drop",
+		"table=256,actions=drop",
+		"table=0,actions=output:2,flood",
+		"table=0,actions=set_field:foo=1",
+		// FormatFlow writes these for display only
+		"table=0,actions=write_actions",
+		"table=0,actions=clear_actions",
+		"table=0,experimenter=0x1,actions=drop",
+		"table=0,eth_dst=01:00:5e:00:00:00/0xffffff000000,actions=drop",
+		"table=0,ipv6_src=fe80::1/0xffff0000000000000000000000000000,actions=drop",
+	} {
+		_, err := ParseFlow(text)
+		assert.NotNil(t, err, text)
+	}
+}
+
+func TestFormatAndParseGroup(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected *ofp.OfpGroupEntry
+	}{
+		{"multicast", "group_id=10,type=all,bucket=actions=pop_vlan,output:16,bucket=actions=output:17",
+			MkGroupStat(&GroupArgs{GroupId: 10, Buckets: []*ofp.OfpBucket{
+				{Actions: []*ofp.OfpAction{PopVlan(), Output(16)}},
+				{Actions: []*ofp.OfpAction{Output(17)}},
+			}})},
+		{"select", "group_id=11,type=select,bucket=weight:2,watch_port:17,watch_group:3,actions=output:17", nil},
+		{"no-buckets", "group_id=12,type=indirect", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group, err := ParseGroup(tt.text)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.text, FormatGroup(group))
+			if tt.expected != nil {
+				assert.True(t, proto.Equal(tt.expected, group))
+			}
+		})
+	}
+
+	for _, text := range []string{
+		"type=all,bucket=actions=output:1",
+		"group_id=10,type=fanout",
+		"group_id=10,bucket=output:1",
+		"group_id=10,bucket=priority:1,actions=output:1",
+		"group_id=10,bucket=actions=output:1,meter:1",
+	} {
+		_, err := ParseGroup(text)
+		assert.NotNil(t, err, text)
+	}
+}
+
+func TestFlowAndGroupJSON(t *testing.T) {
+	flow, err := ParseFlow("table=0,priority=1000,in_port=16,vlan_vid=4196,actions=output:1")
+	assert.Nil(t, err)
+	data, err := FlowToJSON(flow)
+	assert.Nil(t, err)
+	decoded, err := FlowFromJSON(data)
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(flow, decoded))
+
+	flow.Id = 0
+	data, err = FlowToJSON(flow)
+	assert.Nil(t, err)
+	decoded, err = FlowFromJSON(data)
+	assert.Nil(t, err)
+	assert.Equal(t, mkTableFlow(t, 0, &FlowArgs{
+		KV:          OfpFlowModArgs{"priority": 1000},
+		MatchFields: []*ofp.OfpOxmOfbField{InPort(16), VlanVid(4196)},
+	}).Id, decoded.Id)
+	_, err = FlowFromJSON([]byte("{"))
+	assert.NotNil(t, err)
+
+	group, err := ParseGroup("group_id=10,type=all,bucket=actions=output:16")
+	assert.Nil(t, err)
+	data, err = GroupToJSON(group)
+	assert.Nil(t, err)
+	decodedGroup, err := GroupFromJSON(data)
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(group, decodedGroup))
+}
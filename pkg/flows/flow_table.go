@@ -0,0 +1,442 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flows
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	ofp "github.com/opencord/voltha-protos/v5/go/openflow_13"
+	"google.golang.org/protobuf/proto"
+)
+
+// flowIndex maps a cookie, table, port or group to the IDs of its flows
+type flowIndex map[uint64]map[uint64]bool
+
+func (idx flowIndex) add(key uint64, flowID uint64) {
+	ids, ok := idx[key]
+	if !ok {
+		ids = make(map[uint64]bool)
+		idx[key] = ids
+	}
+	ids[flowID] = true
+}
+
+func (idx flowIndex) remove(key uint64, flowID uint64) {
+	if ids, ok := idx[key]; ok {
+		delete(ids, flowID)
+		if len(ids) == 0 {
+			delete(idx, key)
+		}
+	}
+}
+
+// FlowTable holds the flows of a logical device indexed by ID, cookie, table, in port, out port and
+// group, so that lookups and flow mod matching do not scan all flows. Only the OpenFlow basic match
+// fields are considered when matching, experimenter fields are ignored. The table keeps a copy of
+// the added flows, the flows it returns are the ones it indexes and must not be modified.
+type FlowTable struct {
+	lock      sync.RWMutex
+	flows     map[uint64]*ofp.OfpFlowStats
+	byCookie  flowIndex
+	byTable   flowIndex
+	byInPort  flowIndex
+	byOutPort flowIndex
+	byGroup   flowIndex
+}
+
+// NewFlowTable returns an empty flow table
+func NewFlowTable() *FlowTable {
+	return &FlowTable{
+		flows:     make(map[uint64]*ofp.OfpFlowStats),
+		byCookie:  make(flowIndex),
+		byTable:   make(flowIndex),
+		byInPort:  make(flowIndex),
+		byOutPort: make(flowIndex),
+		byGroup:   make(flowIndex),
+	}
+}
+
+// flowOutputs returns the ports and groups the flow outputs to
+func flowOutputs(flow *ofp.OfpFlowStats) (ports []uint32, groups []uint32) {
+	for _, instruction := range flow.Instructions {
+		if instruction.Type != uint32(ofp.OfpInstructionType_OFPIT_APPLY_ACTIONS) || instruction.GetActions() == nil {
+			continue
+		}
+		for _, action := range instruction.GetActions().Actions {
+			switch action.Type {
+			case ofp.OfpActionType_OFPAT_OUTPUT:
+				if action.GetOutput() != nil {
+					ports = append(ports, action.GetOutput().Port)
+				}
+			case ofp.OfpActionType_OFPAT_GROUP:
+				if action.GetGroup() != nil {
+					groups = append(groups, action.GetGroup().GroupId)
+				}
+			}
+		}
+	}
+	return ports, groups
+}
+
+func (ft *FlowTable) index(flow *ofp.OfpFlowStats) {
+	ft.byCookie.add(flow.Cookie, flow.Id)
+	ft.byTable.add(uint64(flow.TableId), flow.Id)
+	if inPort := GetInPort(flow); inPort != 0 {
+		ft.byInPort.add(uint64(inPort), flow.Id)
+	}
+	ports, groups := flowOutputs(flow)
+	for _, port := range ports {
+		ft.byOutPort.add(uint64(port), flow.Id)
+	}
+	for _, group := range groups {
+		ft.byGroup.add(uint64(group), flow.Id)
+	}
+}
+
+func (ft *FlowTable) unindex(flow *ofp.OfpFlowStats) {
+	ft.byCookie.remove(flow.Cookie, flow.Id)
+	ft.byTable.remove(uint64(flow.TableId), flow.Id)
+	if inPort := GetInPort(flow); inPort != 0 {
+		ft.byInPort.remove(uint64(inPort), flow.Id)
+	}
+	ports, groups := flowOutputs(flow)
+	for _, port := range ports {
+		ft.byOutPort.remove(uint64(port), flow.Id)
+	}
+	for _, group := range groups {
+		ft.byGroup.remove(uint64(group), flow.Id)
+	}
+}
+
+// Add adds a copy of the flow to the table, replacing the flow with the same ID if any
+func (ft *FlowTable) Add(flow *ofp.OfpFlowStats) {
+	if flow == nil {
+		return
+	}
+	flow = proto.Clone(flow).(*ofp.OfpFlowStats)
+	ft.lock.Lock()
+	defer ft.lock.Unlock()
+	if existing, ok := ft.flows[flow.Id]; ok {
+		ft.unindex(existing)
+	}
+	ft.flows[flow.Id] = flow
+	ft.index(flow)
+}
+
+// Remove removes the flow of the ID from the table and returns it, nil if there is no such flow
+func (ft *FlowTable) Remove(flowID uint64) *ofp.OfpFlowStats {
+	ft.lock.Lock()
+	defer ft.lock.Unlock()
+	return ft.remove(flowID)
+}
+
+func (ft *FlowTable) remove(flowID uint64) *ofp.OfpFlowStats {
+	flow, ok := ft.flows[flowID]
+	if !ok {
+		return nil
+	}
+	ft.unindex(flow)
+	delete(ft.flows, flowID)
+	return flow
+}
+
+// RemoveByGroup removes the flows which output to the group and returns them
+func (ft *FlowTable) RemoveByGroup(groupID uint32) []*ofp.OfpFlowStats {
+	ft.lock.Lock()
+	defer ft.lock.Unlock()
+	removed := ft.list(ft.byGroup[uint64(groupID)])
+	for _, flow := range removed {
+		ft.remove(flow.Id)
+	}
+	return removed
+}
+
+// Get returns the flow of the ID, nil if there is no such flow
+func (ft *FlowTable) Get(flowID uint64) *ofp.OfpFlowStats {
+	ft.lock.RLock()
+	defer ft.lock.RUnlock()
+	return ft.flows[flowID]
+}
+
+// Len returns the number of flows in the table
+func (ft *FlowTable) Len() int {
+	ft.lock.RLock()
+	defer ft.lock.RUnlock()
+	return len(ft.flows)
+}
+
+// list returns the flows of the IDs ordered by ID
+func (ft *FlowTable) list(ids map[uint64]bool) []*ofp.OfpFlowStats {
+	flows := make([]*ofp.OfpFlowStats, 0, len(ids))
+	for id := range ids {
+		flows = append(flows, ft.flows[id])
+	}
+	sort.Slice(flows, func(i, j int) bool { return flows[i].Id < flows[j].Id })
+	return flows
+}
+
+// List returns all the flows ordered by ID
+func (ft *FlowTable) List() []*ofp.OfpFlowStats {
+	ft.lock.RLock()
+	defer ft.lock.RUnlock()
+	flows := make([]*ofp.OfpFlowStats, 0, len(ft.flows))
+	for _, flow := range ft.flows {
+		flows = append(flows, flow)
+	}
+	sort.Slice(flows, func(i, j int) bool { return flows[i].Id < flows[j].Id })
+	return flows
+}
+
+// ListByCookie returns the flows with the cookie ordered by ID
+func (ft *FlowTable) ListByCookie(cookie uint64) []*ofp.OfpFlowStats {
+	ft.lock.RLock()
+	defer ft.lock.RUnlock()
+	return ft.list(ft.byCookie[cookie])
+}
+
+// ListByTable returns the flows of the table ordered by ID
+func (ft *FlowTable) ListByTable(tableID uint32) []*ofp.OfpFlowStats {
+	ft.lock.RLock()
+	defer ft.lock.RUnlock()
+	return ft.list(ft.byTable[uint64(tableID)])
+}
+
+// ListByInPort returns the flows matching the in port ordered by ID
+func (ft *FlowTable) ListByInPort(port uint32) []*ofp.OfpFlowStats {
+	ft.lock.RLock()
+	defer ft.lock.RUnlock()
+	return ft.list(ft.byInPort[uint64(port)])
+}
+
+// ListByOutPort returns the flows which output to the port ordered by ID
+func (ft *FlowTable) ListByOutPort(port uint32) []*ofp.OfpFlowStats {
+	ft.lock.RLock()
+	defer ft.lock.RUnlock()
+	return ft.list(ft.byOutPort[uint64(port)])
+}
+
+// ListByGroup returns the flows which output to the group ordered by ID
+func (ft *FlowTable) ListByGroup(groupID uint32) []*ofp.OfpFlowStats {
+	ft.lock.RLock()
+	defer ft.lock.RUnlock()
+	return ft.list(ft.byGroup[uint64(groupID)])
+}
+
+// candidates returns the smallest set of flow IDs among the given index entries, all the flows if
+// there is none
+func (ft *FlowTable) candidates(sets ...map[uint64]bool) map[uint64]bool {
+	if len(sets) > 0 {
+		smallest := sets[0]
+		for _, ids := range sets[1:] {
+			if len(ids) < len(smallest) {
+				smallest = ids
+			}
+		}
+		return smallest
+	}
+	all := make(map[uint64]bool, len(ft.flows))
+	for id := range ft.flows {
+		all[id] = true
+	}
+	return all
+}
+
+// FindOverlapping returns the flows overlapping with the flow added by the flow mod, ordered by ID.
+// This is the check done for a flow mod with the OFPFF_CHECK_OVERLAP flag.
+func (ft *FlowTable) FindOverlapping(mod *ofp.OfpFlowMod) []*ofp.OfpFlowStats {
+	if mod == nil {
+		return nil
+	}
+	ft.lock.RLock()
+	defer ft.lock.RUnlock()
+	overlapping := make(map[uint64]bool)
+	for id := range ft.byTable[uint64(mod.TableId)] {
+		if FlowOverlapsMod(ft.flows[id], mod) {
+			overlapping[id] = true
+		}
+	}
+	return ft.list(overlapping)
+}
+
+// FindMatching returns the flows selected by the modify or delete flow mod, ordered by ID. With
+// strict the flows must have the priority and match of the flow mod, otherwise the flows whose match
+// is the same as or more specific than the match of the flow mod are selected.
+func (ft *FlowTable) FindMatching(mod *ofp.OfpFlowMod, strict bool) []*ofp.OfpFlowStats {
+	if mod == nil {
+		return nil
+	}
+	ft.lock.RLock()
+	defer ft.lock.RUnlock()
+
+	sets := make([]map[uint64]bool, 0)
+	if mod.TableId != uint32(ofp.OfpTable_OFPTT_ALL) {
+		sets = append(sets, ft.byTable[uint64(mod.TableId)])
+	}
+	if mod.CookieMask == ^uint64(0) {
+		sets = append(sets, ft.byCookie[mod.Cookie])
+	}
+	if inPort, ok := modInPort(mod); ok {
+		sets = append(sets, ft.byInPort[uint64(inPort)])
+	}
+	if (mod.OutPort & 0x7fffffff) != uint32(ofp.OfpPortNo_OFPP_ANY) {
+		sets = append(sets, ft.byOutPort[uint64(mod.OutPort)])
+	}
+	if (mod.OutGroup & 0x7fffffff) != uint32(ofp.OfpGroup_OFPG_ANY) {
+		sets = append(sets, ft.byGroup[uint64(mod.OutGroup)])
+	}
+
+	matching := make(map[uint64]bool)
+	for id := range ft.candidates(sets...) {
+		flow := ft.flows[id]
+		if strict && flowStrictlyMatchesMod(flow, mod) || !strict && FlowMatchesMod(flow, mod) {
+			matching[id] = true
+		}
+	}
+	return ft.list(matching)
+}
+
+// modInPort returns the in port the flow mod matches exactly, if any
+func modInPort(mod *ofp.OfpFlowMod) (uint32, bool) {
+	if mod.Match == nil {
+		return 0, false
+	}
+	for _, field := range mod.Match.OxmFields {
+		if ofbField := field.GetOfbField(); ofbField != nil && ofbField.Type == IN_PORT && !ofbField.HasMask {
+			return ofbField.GetPort(), true
+		}
+	}
+	return 0, false
+}
+
+// maskedField is the value and mask of a match field in network byte order, a missing mask being
+// all ones
+type maskedField struct {
+	value []byte
+	mask  []byte
+}
+
+// matchFields returns the OpenFlow basic fields of the match by type, nil if a field can not be read
+func matchFields(match *ofp.OfpMatch) map[ofp.OxmOfbFieldTypes]maskedField {
+	fields := make(map[ofp.OxmOfbFieldTypes]maskedField)
+	if match == nil {
+		return fields
+	}
+	for _, field := range match.OxmFields {
+		ofbField := field.GetOfbField()
+		if ofbField == nil {
+			continue
+		}
+		value, err := ofbFieldValue(ofbField)
+		if err != nil {
+			return nil
+		}
+		mask := bytes.Repeat([]byte{0xff}, len(value))
+		if ofbField.HasMask {
+			if mask, err = ofbFieldMask(ofbField); err != nil || len(mask) != len(value) {
+				return nil
+			}
+		}
+		fields[ofbField.Type] = maskedField{value: value, mask: mask}
+	}
+	return fields
+}
+
+// covers returns true if every packet matching the other field matches the field
+func (f maskedField) covers(other maskedField) bool {
+	if len(f.value) != len(other.value) {
+		return false
+	}
+	for i := range f.value {
+		if other.mask[i]&f.mask[i] != f.mask[i] || other.value[i]&f.mask[i] != f.value[i]&f.mask[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// overlaps returns true if a packet may match both fields
+func (f maskedField) overlaps(other maskedField) bool {
+	if len(f.value) != len(other.value) {
+		return false
+	}
+	for i := range f.value {
+		mask := f.mask[i] & other.mask[i]
+		if f.value[i]&mask != other.value[i]&mask {
+			return false
+		}
+	}
+	return true
+}
+
+// equals returns true if the fields match the same packets
+func (f maskedField) equals(other maskedField) bool {
+	return f.covers(other) && other.covers(f)
+}
+
+// matchCovers returns true if the match of the flow mod is the same as or less specific than the
+// match of the flow
+func matchCovers(mod *ofp.OfpFlowMod, flow *ofp.OfpFlowStats) bool {
+	modFields, flowFields := matchFields(mod.Match), matchFields(flow.Match)
+	if modFields == nil || flowFields == nil {
+		return false
+	}
+	for fieldType, modField := range modFields {
+		flowField, ok := flowFields[fieldType]
+		if !ok || !modField.covers(flowField) {
+			return false
+		}
+	}
+	return true
+}
+
+// flowStrictlyMatchesMod returns true if the flow is selected by the strict modify or delete flow mod
+func flowStrictlyMatchesMod(flow *ofp.OfpFlowStats, mod *ofp.OfpFlowMod) bool {
+	if flow == nil || mod == nil || flow.Priority != mod.Priority || !FlowMatchesMod(flow, mod) {
+		return false
+	}
+	modFields, flowFields := matchFields(mod.Match), matchFields(flow.Match)
+	if len(modFields) != len(flowFields) {
+		return false
+	}
+	for fieldType, modField := range modFields {
+		if flowField, ok := flowFields[fieldType]; !ok || !modField.equals(flowField) {
+			return false
+		}
+	}
+	return true
+}
+
+// FlowOverlapsMod returns true if the flow and the flow added by the flow mod are in the same table,
+// have the same priority and a packet may match both
+func FlowOverlapsMod(flow *ofp.OfpFlowStats, mod *ofp.OfpFlowMod) bool {
+	if flow == nil || mod == nil || flow.TableId != mod.TableId || flow.Priority != mod.Priority {
+		return false
+	}
+	modFields, flowFields := matchFields(mod.Match), matchFields(flow.Match)
+	if modFields == nil || flowFields == nil {
+		return false
+	}
+	// a field missing from one of the matches is a wildcard that overlaps with any value
+	for fieldType, modField := range modFields {
+		if flowField, ok := flowFields[fieldType]; ok && !modField.overlaps(flowField) {
+			return false
+		}
+	}
+	return true
+}
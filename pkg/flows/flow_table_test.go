@@ -0,0 +1,203 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flows
+
+import (
+	"sort"
+	"testing"
+
+	ofp "github.com/opencord/voltha-protos/v5/go/openflow_13"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func vlanVidMasked(vid, mask uint32) *ofp.OfpOxmOfbField {
+	return &ofp.OfpOxmOfbField{Type: VLAN_VID, HasMask: true,
+		Value: &ofp.OfpOxmOfbField_VlanVid{VlanVid: vid}, Mask: &ofp.OfpOxmOfbField_VlanVidMask{VlanVidMask: mask}}
+}
+
+// mkFlowMod returns a flow mod which does not filter on cookie, out port and out group
+func mkFlowMod(tableID, priority uint32, fields ...*ofp.OfpOxmOfbField) *ofp.OfpFlowMod {
+	return &ofp.OfpFlowMod{TableId: tableID, Priority: priority,
+		OutPort: uint32(ofp.OfpPortNo_OFPP_ANY), OutGroup: uint32(ofp.OfpGroup_OFPG_ANY),
+		Match: &ofp.OfpMatch{Type: ofp.OfpMatchType_OFPMT_OXM, OxmFields: ToOfpOxmField(fields)}}
+}
+
+func flowIDs(flows []*ofp.OfpFlowStats) []uint64 {
+	ids := make([]uint64, 0, len(flows))
+	for _, flow := range flows {
+		ids = append(ids, flow.Id)
+	}
+	return ids
+}
+
+func newTestFlowTable(t *testing.T) (*FlowTable, []*ofp.OfpFlowStats) {
+	vid := uint32(ofp.OfpVlanId_OFPVID_PRESENT) | 10
+	flows := []*ofp.OfpFlowStats{
+		mkTableFlow(t, 0, &FlowArgs{
+			KV:          OfpFlowModArgs{"priority": 100, "cookie": 1},
+			MatchFields: []*ofp.OfpOxmOfbField{InPort(1), VlanVid(vid)},
+			Actions:     []*ofp.OfpAction{Output(2)},
+		}),
+		mkTableFlow(t, 0, &FlowArgs{
+			KV:          OfpFlowModArgs{"priority": 100, "cookie": 1},
+			MatchFields: []*ofp.OfpOxmOfbField{InPort(1), VlanVid(vid), EthType(0x800)},
+			Actions:     []*ofp.OfpAction{Output(3)},
+		}),
+		mkTableFlow(t, 0, &FlowArgs{
+			KV:          OfpFlowModArgs{"priority": 200, "cookie": 2},
+			MatchFields: []*ofp.OfpOxmOfbField{InPort(2), VlanVid(vid)},
+			Actions:     []*ofp.OfpAction{Group(10)},
+		}),
+		mkTableFlow(t, 1, &FlowArgs{
+			KV:          OfpFlowModArgs{"priority": 100, "cookie": 3},
+			MatchFields: []*ofp.OfpOxmOfbField{InPort(1)},
+			Actions:     []*ofp.OfpAction{Output(2), Group(10)},
+		}),
+	}
+	table := NewFlowTable()
+	for _, flow := range flows {
+		table.Add(flow)
+	}
+	return table, flows
+}
+
+func TestFlowTableIndexes(t *testing.T) {
+	table, flows := newTestFlowTable(t)
+	ids := func(indexes ...int) []uint64 {
+		selected := make([]uint64, 0, len(indexes))
+		for _, i := range indexes {
+			selected = append(selected, flows[i].Id)
+		}
+		sort.Slice(selected, func(i, j int) bool { return selected[i] < selected[j] })
+		return selected
+	}
+
+	assert.Equal(t, 4, table.Len())
+	assert.True(t, proto.Equal(flows[2], table.Get(flows[2].Id)))
+	assert.Equal(t, ids(0, 1, 2, 3), flowIDs(table.List()))
+	assert.Equal(t, ids(0, 1), flowIDs(table.ListByCookie(1)))
+	assert.Equal(t, ids(0, 1, 2), flowIDs(table.ListByTable(0)))
+	assert.Equal(t, ids(0, 1, 3), flowIDs(table.ListByInPort(1)))
+	assert.Equal(t, ids(0, 3), flowIDs(table.ListByOutPort(2)))
+	assert.Equal(t, ids(2, 3), flowIDs(table.ListByGroup(10)))
+	assert.Empty(t, table.ListByGroup(11))
+
+	// replacing a flow updates the indexes
+	replacement := &ofp.OfpFlowStats{Id: flows[0].Id, TableId: 2, Cookie: 4, Match: flows[0].Match}
+	table.Add(replacement)
+	assert.Equal(t, 4, table.Len())
+	assert.Equal(t, ids(1), flowIDs(table.ListByCookie(1)))
+	assert.Equal(t, ids(0), flowIDs(table.ListByTable(2)))
+	assert.Equal(t, ids(3), flowIDs(table.ListByOutPort(2)))
+
+	// the table keeps a copy, modifying the added flow does not corrupt the indexes
+	replacement.Cookie = 5
+	assert.Equal(t, ids(0), flowIDs(table.ListByCookie(4)))
+	assert.Equal(t, uint64(4), table.Get(flows[0].Id).Cookie)
+	replacement.Cookie = 4
+
+	assert.True(t, proto.Equal(replacement, table.Remove(flows[0].Id)))
+	assert.Nil(t, table.Remove(flows[0].Id))
+	assert.Nil(t, table.Get(flows[0].Id))
+	assert.Empty(t, table.ListByTable(2))
+
+	assert.Equal(t, ids(2, 3), flowIDs(table.RemoveByGroup(10)))
+	assert.Equal(t, ids(1), flowIDs(table.List()))
+	assert.Equal(t, ids(1), flowIDs(table.ListByInPort(1)))
+	assert.Empty(t, table.ListByGroup(10))
+}
+
+func TestFlowTableFindOverlapping(t *testing.T) {
+	vid := uint32(ofp.OfpVlanId_OFPVID_PRESENT) | 10
+	table, flows := newTestFlowTable(t)
+	tests := []struct {
+		name     string
+		mod      *ofp.OfpFlowMod
+		expected []*ofp.OfpFlowStats
+	}{
+		{"identical-match", mkFlowMod(0, 100, InPort(1), VlanVid(vid)), []*ofp.OfpFlowStats{flows[0], flows[1]}},
+		{"wildcard-match", mkFlowMod(0, 100), []*ofp.OfpFlowStats{flows[0], flows[1]}},
+		{"field-missing-from-flow", mkFlowMod(0, 100, InPort(1), EthType(0x88cc)), []*ofp.OfpFlowStats{flows[0]}},
+		{"other-in-port", mkFlowMod(0, 100, InPort(2)), []*ofp.OfpFlowStats{}},
+		{"other-priority", mkFlowMod(0, 300, InPort(2)), []*ofp.OfpFlowStats{}},
+		{"other-table", mkFlowMod(1, 200, InPort(2)), []*ofp.OfpFlowStats{}},
+		{"masked-vid-overlaps", mkFlowMod(0, 200, vlanVidMasked(uint32(ofp.OfpVlanId_OFPVID_PRESENT), 0x1000)), []*ofp.OfpFlowStats{flows[2]}},
+		{"masked-vid-disjoint", mkFlowMod(0, 200, vlanVidMasked(11, 0xf)), []*ofp.OfpFlowStats{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ElementsMatch(t, flowIDs(tt.expected), flowIDs(table.FindOverlapping(tt.mod)))
+			assert.ElementsMatch(t, flowIDs(tt.expected), flowIDs(FindOverlappingFlows(flows, tt.mod)))
+		})
+	}
+}
+
+func TestFlowTableFindMatching(t *testing.T) {
+	vid := uint32(ofp.OfpVlanId_OFPVID_PRESENT) | 10
+	table, flows := newTestFlowTable(t)
+	withCookie := func(mod *ofp.OfpFlowMod, cookie, mask uint64) *ofp.OfpFlowMod {
+		mod.Cookie, mod.CookieMask = cookie, mask
+		return mod
+	}
+	withOutPort := func(mod *ofp.OfpFlowMod, port uint32) *ofp.OfpFlowMod {
+		mod.OutPort = port
+		return mod
+	}
+	withOutGroup := func(mod *ofp.OfpFlowMod, group uint32) *ofp.OfpFlowMod {
+		mod.OutGroup = group
+		return mod
+	}
+	all := uint32(ofp.OfpTable_OFPTT_ALL)
+	tests := []struct {
+		name     string
+		mod      *ofp.OfpFlowMod
+		strict   bool
+		expected []*ofp.OfpFlowStats
+	}{
+		{"all-tables", mkFlowMod(all, 0), false, flows},
+		{"one-table", mkFlowMod(1, 0), false, []*ofp.OfpFlowStats{flows[3]}},
+		{"in-port", mkFlowMod(all, 0, InPort(1)), false, []*ofp.OfpFlowStats{flows[0], flows[1], flows[3]}},
+		{"more-specific-flows", mkFlowMod(0, 0, InPort(1), VlanVid(vid)), false, []*ofp.OfpFlowStats{flows[0], flows[1]}},
+		{"less-specific-flow", mkFlowMod(0, 0, InPort(1), VlanVid(vid), EthType(0x800)), false, []*ofp.OfpFlowStats{flows[1]}},
+		{"masked-vid", mkFlowMod(0, 0, vlanVidMasked(uint32(ofp.OfpVlanId_OFPVID_PRESENT), 0x1000)), false, flows[:3]},
+		{"cookie", withCookie(mkFlowMod(all, 0), 1, ^uint64(0)), false, flows[:2]},
+		{"cookie-mask", withCookie(mkFlowMod(all, 0), 2, 0x2), false, []*ofp.OfpFlowStats{flows[2], flows[3]}},
+		{"out-port", withOutPort(mkFlowMod(all, 0), 2), false, []*ofp.OfpFlowStats{flows[0], flows[3]}},
+		{"out-group", withOutGroup(mkFlowMod(all, 0, InPort(1)), 10), false, []*ofp.OfpFlowStats{flows[3]}},
+		{"strict", mkFlowMod(0, 100, InPort(1), VlanVid(vid)), true, []*ofp.OfpFlowStats{flows[0]}},
+		{"strict-other-priority", mkFlowMod(0, 200, InPort(1), VlanVid(vid)), true, []*ofp.OfpFlowStats{}},
+		{"strict-all-tables", mkFlowMod(all, 100, InPort(1)), true, []*ofp.OfpFlowStats{flows[3]}},
+		{"strict-other-mask", mkFlowMod(0, 200, InPort(2), vlanVidMasked(vid, 0x1fff)), true, []*ofp.OfpFlowStats{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ElementsMatch(t, flowIDs(tt.expected), flowIDs(table.FindMatching(tt.mod, tt.strict)))
+			if !tt.strict {
+				// the table selects the flows a scan with FlowMatchesMod would
+				matching := make([]*ofp.OfpFlowStats, 0)
+				for _, flow := range flows {
+					if FlowMatchesMod(flow, tt.mod) {
+						matching = append(matching, flow)
+					}
+				}
+				assert.ElementsMatch(t, flowIDs(tt.expected), flowIDs(matching))
+			}
+		})
+	}
+	assert.Nil(t, table.FindMatching(nil, false))
+}
@@ -801,162 +801,168 @@ func HashFlowStats(flow *ofp.OfpFlowStats) (uint64, error) {
 }
 
 func hashWriteOfbField(md5Hash hash.Hash, field *ofp.OfpOxmOfbField) error {
-	var tmp [8]byte
+	var tmp [4]byte
 	binary.BigEndian.PutUint32(tmp[:4], uint32(field.Type)) // type
 	_, _ = md5Hash.Write(tmp[:4])
 
-	// value
-	valType, val32, val64, valSlice := uint8(0), uint32(0), uint64(0), []byte(nil)
+	value, err := ofbFieldValue(field)
+	if err != nil {
+		return err
+	}
+	_, _ = md5Hash.Write(value)
+
+	if !field.HasMask {
+		tmp[0] = 0x00
+		_, _ = md5Hash.Write(tmp[:1]) // match hasMask = false
+		return nil
+	}
+	tmp[0] = 0x01
+	_, _ = md5Hash.Write(tmp[:1]) // match hasMask = true
+	mask, err := ofbFieldMask(field)
+	if err != nil {
+		return err
+	}
+	_, _ = md5Hash.Write(mask)
+	return nil
+}
+
+func uint32Bytes(val uint32) []byte {
+	var tmp [4]byte
+	binary.BigEndian.PutUint32(tmp[:], val)
+	return tmp[:]
+}
+
+func uint64Bytes(val uint64) []byte {
+	var tmp [8]byte
+	binary.BigEndian.PutUint64(tmp[:], val)
+	return tmp[:]
+}
+
+// ofbFieldValue returns the value of the match field in network byte order
+func ofbFieldValue(field *ofp.OfpOxmOfbField) ([]byte, error) {
 	switch val := field.Value.(type) {
 	case *ofp.OfpOxmOfbField_Port:
-		valType, val32 = 4, val.Port
+		return uint32Bytes(val.Port), nil
 	case *ofp.OfpOxmOfbField_PhysicalPort:
-		valType, val32 = 4, val.PhysicalPort
+		return uint32Bytes(val.PhysicalPort), nil
 	case *ofp.OfpOxmOfbField_TableMetadata:
-		valType, val64 = 8, val.TableMetadata
+		return uint64Bytes(val.TableMetadata), nil
 	case *ofp.OfpOxmOfbField_EthDst:
-		valType, valSlice = 1, val.EthDst
+		return val.EthDst, nil
 	case *ofp.OfpOxmOfbField_EthSrc:
-		valType, valSlice = 1, val.EthSrc
+		return val.EthSrc, nil
 	case *ofp.OfpOxmOfbField_EthType:
-		valType, val32 = 4, val.EthType
+		return uint32Bytes(val.EthType), nil
 	case *ofp.OfpOxmOfbField_VlanVid:
-		valType, val32 = 4, val.VlanVid
+		return uint32Bytes(val.VlanVid), nil
 	case *ofp.OfpOxmOfbField_VlanPcp:
-		valType, val32 = 4, val.VlanPcp
+		return uint32Bytes(val.VlanPcp), nil
 	case *ofp.OfpOxmOfbField_IpDscp:
-		valType, val32 = 4, val.IpDscp
+		return uint32Bytes(val.IpDscp), nil
 	case *ofp.OfpOxmOfbField_IpEcn:
-		valType, val32 = 4, val.IpEcn
+		return uint32Bytes(val.IpEcn), nil
 	case *ofp.OfpOxmOfbField_IpProto:
-		valType, val32 = 4, val.IpProto
+		return uint32Bytes(val.IpProto), nil
 	case *ofp.OfpOxmOfbField_Ipv4Src:
-		valType, val32 = 4, val.Ipv4Src
+		return uint32Bytes(val.Ipv4Src), nil
 	case *ofp.OfpOxmOfbField_Ipv4Dst:
-		valType, val32 = 4, val.Ipv4Dst
+		return uint32Bytes(val.Ipv4Dst), nil
 	case *ofp.OfpOxmOfbField_TcpSrc:
-		valType, val32 = 4, val.TcpSrc
+		return uint32Bytes(val.TcpSrc), nil
 	case *ofp.OfpOxmOfbField_TcpDst:
-		valType, val32 = 4, val.TcpDst
+		return uint32Bytes(val.TcpDst), nil
 	case *ofp.OfpOxmOfbField_UdpSrc:
-		valType, val32 = 4, val.UdpSrc
+		return uint32Bytes(val.UdpSrc), nil
 	case *ofp.OfpOxmOfbField_UdpDst:
-		valType, val32 = 4, val.UdpDst
+		return uint32Bytes(val.UdpDst), nil
 	case *ofp.OfpOxmOfbField_SctpSrc:
-		valType, val32 = 4, val.SctpSrc
+		return uint32Bytes(val.SctpSrc), nil
 	case *ofp.OfpOxmOfbField_SctpDst:
-		valType, val32 = 4, val.SctpDst
+		return uint32Bytes(val.SctpDst), nil
 	case *ofp.OfpOxmOfbField_Icmpv4Type:
-		valType, val32 = 4, val.Icmpv4Type
+		return uint32Bytes(val.Icmpv4Type), nil
 	case *ofp.OfpOxmOfbField_Icmpv4Code:
-		valType, val32 = 4, val.Icmpv4Code
+		return uint32Bytes(val.Icmpv4Code), nil
 	case *ofp.OfpOxmOfbField_ArpOp:
-		valType, val32 = 4, val.ArpOp
+		return uint32Bytes(val.ArpOp), nil
 	case *ofp.OfpOxmOfbField_ArpSpa:
-		valType, val32 = 4, val.ArpSpa
+		return uint32Bytes(val.ArpSpa), nil
 	case *ofp.OfpOxmOfbField_ArpTpa:
-		valType, val32 = 4, val.ArpTpa
+		return uint32Bytes(val.ArpTpa), nil
 	case *ofp.OfpOxmOfbField_ArpSha:
-		valType, valSlice = 1, val.ArpSha
+		return val.ArpSha, nil
 	case *ofp.OfpOxmOfbField_ArpTha:
-		valType, valSlice = 1, val.ArpTha
+		return val.ArpTha, nil
 	case *ofp.OfpOxmOfbField_Ipv6Src:
-		valType, valSlice = 1, val.Ipv6Src
+		return val.Ipv6Src, nil
 	case *ofp.OfpOxmOfbField_Ipv6Dst:
-		valType, valSlice = 1, val.Ipv6Dst
+		return val.Ipv6Dst, nil
 	case *ofp.OfpOxmOfbField_Ipv6Flabel:
-		valType, val32 = 4, val.Ipv6Flabel
+		return uint32Bytes(val.Ipv6Flabel), nil
 	case *ofp.OfpOxmOfbField_Icmpv6Type:
-		valType, val32 = 4, val.Icmpv6Type
+		return uint32Bytes(val.Icmpv6Type), nil
 	case *ofp.OfpOxmOfbField_Icmpv6Code:
-		valType, val32 = 4, val.Icmpv6Code
+		return uint32Bytes(val.Icmpv6Code), nil
 	case *ofp.OfpOxmOfbField_Ipv6NdTarget:
-		valType, valSlice = 1, val.Ipv6NdTarget
+		return val.Ipv6NdTarget, nil
 	case *ofp.OfpOxmOfbField_Ipv6NdSsl:
-		valType, valSlice = 1, val.Ipv6NdSsl
+		return val.Ipv6NdSsl, nil
 	case *ofp.OfpOxmOfbField_Ipv6NdTll:
-		valType, valSlice = 1, val.Ipv6NdTll
+		return val.Ipv6NdTll, nil
 	case *ofp.OfpOxmOfbField_MplsLabel:
-		valType, val32 = 4, val.MplsLabel
+		return uint32Bytes(val.MplsLabel), nil
 	case *ofp.OfpOxmOfbField_MplsTc:
-		valType, val32 = 4, val.MplsTc
+		return uint32Bytes(val.MplsTc), nil
 	case *ofp.OfpOxmOfbField_MplsBos:
-		valType, val32 = 4, val.MplsBos
+		return uint32Bytes(val.MplsBos), nil
 	case *ofp.OfpOxmOfbField_PbbIsid:
-		valType, val32 = 4, val.PbbIsid
+		return uint32Bytes(val.PbbIsid), nil
 	case *ofp.OfpOxmOfbField_TunnelId:
-		valType, val64 = 8, val.TunnelId
+		return uint64Bytes(val.TunnelId), nil
 	case *ofp.OfpOxmOfbField_Ipv6Exthdr:
-		valType, val32 = 4, val.Ipv6Exthdr
+		return uint32Bytes(val.Ipv6Exthdr), nil
 	default:
-		return fmt.Errorf("unknown OfpOxmField value type: %T", val)
-	}
-	switch valType {
-	case 1: // slice
-		_, _ = md5Hash.Write(valSlice)
-	case 4: // uint32
-		binary.BigEndian.PutUint32(tmp[:4], val32)
-		_, _ = md5Hash.Write(tmp[:4])
-	case 8: // uint64
-		binary.BigEndian.PutUint64(tmp[:8], val64)
-		_, _ = md5Hash.Write(tmp[:8])
-	}
-
-	// mask
-	if !field.HasMask {
-		tmp[0] = 0x00
-		_, _ = md5Hash.Write(tmp[:1]) // match hasMask = false
-	} else {
-		tmp[0] = 0x01
-		_, _ = md5Hash.Write(tmp[:1]) // match hasMask = true
-
-		maskType, mask32, mask64, maskSlice := uint8(0), uint32(0), uint64(0), []byte(nil)
-		switch mask := field.Mask.(type) {
-		case *ofp.OfpOxmOfbField_TableMetadataMask:
-			maskType, mask64 = 8, mask.TableMetadataMask
-		case *ofp.OfpOxmOfbField_EthDstMask:
-			maskType, maskSlice = 1, mask.EthDstMask
-		case *ofp.OfpOxmOfbField_EthSrcMask:
-			maskType, maskSlice = 1, mask.EthSrcMask
-		case *ofp.OfpOxmOfbField_VlanVidMask:
-			maskType, mask32 = 4, mask.VlanVidMask
-		case *ofp.OfpOxmOfbField_Ipv4SrcMask:
-			maskType, mask32 = 4, mask.Ipv4SrcMask
-		case *ofp.OfpOxmOfbField_Ipv4DstMask:
-			maskType, mask32 = 4, mask.Ipv4DstMask
-		case *ofp.OfpOxmOfbField_ArpSpaMask:
-			maskType, mask32 = 4, mask.ArpSpaMask
-		case *ofp.OfpOxmOfbField_ArpTpaMask:
-			maskType, mask32 = 4, mask.ArpTpaMask
-		case *ofp.OfpOxmOfbField_Ipv6SrcMask:
-			maskType, maskSlice = 1, mask.Ipv6SrcMask
-		case *ofp.OfpOxmOfbField_Ipv6DstMask:
-			maskType, maskSlice = 1, mask.Ipv6DstMask
-		case *ofp.OfpOxmOfbField_Ipv6FlabelMask:
-			maskType, mask32 = 4, mask.Ipv6FlabelMask
-		case *ofp.OfpOxmOfbField_PbbIsidMask:
-			maskType, mask32 = 4, mask.PbbIsidMask
-		case *ofp.OfpOxmOfbField_TunnelIdMask:
-			maskType, mask64 = 8, mask.TunnelIdMask
-		case *ofp.OfpOxmOfbField_Ipv6ExthdrMask:
-			maskType, mask32 = 4, mask.Ipv6ExthdrMask
-		case nil:
-			return fmt.Errorf("hasMask set to true, but no mask present")
-		default:
-			return fmt.Errorf("unknown OfpOxmField mask type: %T", mask)
-		}
-		switch maskType {
-		case 1: // slice
-			_, _ = md5Hash.Write(maskSlice)
-		case 4: // uint32
-			binary.BigEndian.PutUint32(tmp[:4], mask32)
-			_, _ = md5Hash.Write(tmp[:4])
-		case 8: // uint64
-			binary.BigEndian.PutUint64(tmp[:8], mask64)
-			_, _ = md5Hash.Write(tmp[:8])
-		}
+		return nil, fmt.Errorf("unknown OfpOxmField value type: %T", val)
+	}
+}
+
+// ofbFieldMask returns the mask of the match field in network byte order, an error if the field has
+// no mask
+func ofbFieldMask(field *ofp.OfpOxmOfbField) ([]byte, error) {
+	switch mask := field.Mask.(type) {
+	case *ofp.OfpOxmOfbField_TableMetadataMask:
+		return uint64Bytes(mask.TableMetadataMask), nil
+	case *ofp.OfpOxmOfbField_EthDstMask:
+		return mask.EthDstMask, nil
+	case *ofp.OfpOxmOfbField_EthSrcMask:
+		return mask.EthSrcMask, nil
+	case *ofp.OfpOxmOfbField_VlanVidMask:
+		return uint32Bytes(mask.VlanVidMask), nil
+	case *ofp.OfpOxmOfbField_Ipv4SrcMask:
+		return uint32Bytes(mask.Ipv4SrcMask), nil
+	case *ofp.OfpOxmOfbField_Ipv4DstMask:
+		return uint32Bytes(mask.Ipv4DstMask), nil
+	case *ofp.OfpOxmOfbField_ArpSpaMask:
+		return uint32Bytes(mask.ArpSpaMask), nil
+	case *ofp.OfpOxmOfbField_ArpTpaMask:
+		return uint32Bytes(mask.ArpTpaMask), nil
+	case *ofp.OfpOxmOfbField_Ipv6SrcMask:
+		return mask.Ipv6SrcMask, nil
+	case *ofp.OfpOxmOfbField_Ipv6DstMask:
+		return mask.Ipv6DstMask, nil
+	case *ofp.OfpOxmOfbField_Ipv6FlabelMask:
+		return uint32Bytes(mask.Ipv6FlabelMask), nil
+	case *ofp.OfpOxmOfbField_PbbIsidMask:
+		return uint32Bytes(mask.PbbIsidMask), nil
+	case *ofp.OfpOxmOfbField_TunnelIdMask:
+		return uint64Bytes(mask.TunnelIdMask), nil
+	case *ofp.OfpOxmOfbField_Ipv6ExthdrMask:
+		return uint32Bytes(mask.Ipv6ExthdrMask), nil
+	case nil:
+		return nil, fmt.Errorf("hasMask set to true, but no mask present")
+	default:
+		return nil, fmt.Errorf("unknown OfpOxmField mask type: %T", mask)
 	}
-	return nil
 }
 
 // flowStatsEntryFromFlowModMessage maps an ofp_flow_mod message to an ofp_flow_stats message
@@ -1462,7 +1468,13 @@ func (dr *DeviceRules) CreateEntryIfNotExist(deviceId string) {
 
 // FindOverlappingFlows return a list of overlapping flow(s) where mod is the flow request
 func FindOverlappingFlows(flows []*ofp.OfpFlowStats, mod *ofp.OfpFlowMod) []*ofp.OfpFlowStats {
-	return nil //TODO - complete implementation
+	overlapping := make([]*ofp.OfpFlowStats, 0)
+	for _, flow := range flows {
+		if FlowOverlapsMod(flow, mod) {
+			overlapping = append(overlapping, flow)
+		}
+	}
+	return overlapping
 }
 
 // FindFlowById returns the index of the flow in the flows array if present. Otherwise, it returns -1
@@ -1524,9 +1536,9 @@ func FlowMatchesMod(flow *ofp.OfpFlowStats, mod *ofp.OfpFlowMod) bool {
 	if (mod.Match == nil) || (mod.Match.OxmFields == nil) || (len(mod.Match.OxmFields) == 0) {
 		//If we got this far and the match is empty in the flow spec, than the flow matches
 		return true
-	} // TODO : implement the flow match analysis
-	return false
-
+	}
+	//Otherwise the flow must match the same or a subset of the packets the flow_mod matches
+	return matchCovers(mod, flow)
 }
 
 // FlowHasOutPort returns True if flow has a output command with the given out_port
@@ -257,14 +257,14 @@ func TestFlowsAndGroups_String(t *testing.T) {
 	group := MkGroupStat(ga)
 	fg.AddGroup(group)
 
-	str = fg.String()
-	time.Sleep(2 * time.Millisecond) // Added sleep to address potential timing issues
-	assert.True(t, strings.Contains(str, "id:  11819684229970388353"))
-	assert.True(t, strings.Contains(str, "group_id:  10"))
-	assert.True(t, strings.Contains(str, "oxm_class:  OFPXMC_OPENFLOW_BASIC"))
-	assert.True(t, strings.Contains(str, "type:  OFPXMT_OFB_VLAN_VID"))
-	assert.True(t, strings.Contains(str, "vlan_vid:  4096"))
-	assert.True(t, strings.Contains(str, "buckets:  {"))
+	// prototext randomly adds spaces to its output, compare with single spaces
+	str = strings.Join(strings.Fields(fg.String()), " ")
+	assert.True(t, strings.Contains(str, "id: 11819684229970388353"))
+	assert.True(t, strings.Contains(str, "group_id: 10"))
+	assert.True(t, strings.Contains(str, "oxm_class: OFPXMC_OPENFLOW_BASIC"))
+	assert.True(t, strings.Contains(str, "type: OFPXMT_OFB_VLAN_VID"))
+	assert.True(t, strings.Contains(str, "vlan_vid: 4096"))
+	assert.True(t, strings.Contains(str, "buckets: {"))
 }
 
 func TestFlowsAndGroups_AddFrom(t *testing.T) {
@@ -0,0 +1,345 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/log"
+	"github.com/opencord/voltha-lib-go/v7/pkg/probe"
+	"github.com/opencord/voltha-lib-go/v7/pkg/stats"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CircuitState is the state of the circuit breaker of a single grpc method
+type CircuitState byte
+
+const (
+	// CircuitClosed lets requests through and tracks their outcome
+	CircuitClosed = CircuitState(iota)
+	// CircuitOpen fails requests immediately with codes.Unavailable
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of probe requests through to test the remote end
+	CircuitHalfOpen
+)
+
+const (
+	DefaultCircuitBreakerFailureRateThreshold = 0.5
+	DefaultCircuitBreakerSlowCallThreshold    = 5 * time.Second
+	DefaultCircuitBreakerSlowCallRate         = 0.8
+	DefaultCircuitBreakerWindowSize           = 20
+	DefaultCircuitBreakerMinimumCalls         = 10
+	DefaultCircuitBreakerOpenTimeout          = 10 * time.Second
+	DefaultCircuitBreakerHalfOpenProbes       = 3
+)
+
+// String converts CircuitState values to strings
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "Closed"
+	case CircuitOpen:
+		return "Open"
+	case CircuitHalfOpen:
+		return "HalfOpen"
+	default:
+		return "Unknown"
+	}
+}
+
+// CircuitBreakerConfig holds the parameters of a CircuitBreaker. The error and latency rates are
+// computed, per grpc method, over the last WindowSize completed requests.
+type CircuitBreakerConfig struct {
+	// FailureRateThreshold is the fraction (0..1] of failed requests in the window that opens the circuit
+	FailureRateThreshold float64
+	// SlowCallThreshold is the duration above which a request is considered slow. Zero disables latency tracking.
+	SlowCallThreshold time.Duration
+	// SlowCallRateThreshold is the fraction (0..1] of slow requests in the window that opens the circuit
+	SlowCallRateThreshold float64
+	// WindowSize is the number of most recent requests used to compute the rates
+	WindowSize int
+	// MinimumCalls is the number of requests needed in the window before the rates are evaluated
+	MinimumCalls int
+	// OpenTimeout is how long the circuit stays open before probe requests are let through
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of consecutive successful probe requests needed to close the circuit
+	HalfOpenProbes int
+	// FailureCodes are the grpc codes counted as failures. Other errors are treated as application
+	// errors from a healthy remote end.
+	FailureCodes []codes.Code
+}
+
+// DefaultCircuitBreakerConfig returns a configuration with the default values
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureRateThreshold:  DefaultCircuitBreakerFailureRateThreshold,
+		SlowCallThreshold:     DefaultCircuitBreakerSlowCallThreshold,
+		SlowCallRateThreshold: DefaultCircuitBreakerSlowCallRate,
+		WindowSize:            DefaultCircuitBreakerWindowSize,
+		MinimumCalls:          DefaultCircuitBreakerMinimumCalls,
+		OpenTimeout:           DefaultCircuitBreakerOpenTimeout,
+		HalfOpenProbes:        DefaultCircuitBreakerHalfOpenProbes,
+		FailureCodes: []codes.Code{
+			codes.Unavailable,
+			codes.DeadlineExceeded,
+			codes.ResourceExhausted,
+			codes.Aborted,
+			codes.Internal,
+			codes.Unknown,
+		},
+	}
+}
+
+func (cfg *CircuitBreakerConfig) validate() error {
+	if cfg.FailureRateThreshold <= 0 || cfg.FailureRateThreshold > 1 {
+		return fmt.Errorf("circuit breaker failure rate threshold %v is not in (0,1]", cfg.FailureRateThreshold)
+	}
+	if cfg.SlowCallThreshold > 0 && (cfg.SlowCallRateThreshold <= 0 || cfg.SlowCallRateThreshold > 1) {
+		return fmt.Errorf("circuit breaker slow call rate threshold %v is not in (0,1]", cfg.SlowCallRateThreshold)
+	}
+	if cfg.WindowSize <= 0 {
+		return fmt.Errorf("circuit breaker window size %d must be positive", cfg.WindowSize)
+	}
+	if cfg.MinimumCalls <= 0 || cfg.MinimumCalls > cfg.WindowSize {
+		return fmt.Errorf("circuit breaker minimum calls %d is not in [1,%d]", cfg.MinimumCalls, cfg.WindowSize)
+	}
+	if cfg.OpenTimeout <= 0 {
+		return fmt.Errorf("circuit breaker open timeout %v must be positive", cfg.OpenTimeout)
+	}
+	if cfg.HalfOpenProbes <= 0 {
+		return fmt.Errorf("circuit breaker half-open probes %d must be positive", cfg.HalfOpenProbes)
+	}
+	return nil
+}
+
+type callOutcome struct {
+	failed bool
+	slow   bool
+}
+
+// methodCircuit tracks the state of a single grpc method
+type methodCircuit struct {
+	state CircuitState
+	// generation is bumped on every state change so that outcomes of requests admitted
+	// in a previous state are not accounted in the new one
+	generation uint64
+	window     []callOutcome
+	next       int
+	count      int
+	failures   int
+	slow       int
+	openedAt   time.Time
+	probes     int
+	successes  int
+}
+
+func (m *methodCircuit) resetWindow() {
+	m.next, m.count, m.failures, m.slow = 0, 0, 0, 0
+}
+
+func (m *methodCircuit) add(o callOutcome) {
+	if m.count == len(m.window) {
+		old := m.window[m.next]
+		if old.failed {
+			m.failures--
+		}
+		if old.slow {
+			m.slow--
+		}
+	} else {
+		m.count++
+	}
+	m.window[m.next] = o
+	m.next = (m.next + 1) % len(m.window)
+	if o.failed {
+		m.failures++
+	}
+	if o.slow {
+		m.slow++
+	}
+}
+
+// CircuitBreaker fails requests to a remote endpoint fast when the endpoint is unhealthy. It keeps a
+// circuit per grpc method which opens when the error or slow call rate goes above the configured
+// thresholds. The circuit state changes are published as details of the k8s probe, shown by its
+// detailz endpoint, and as gauges of the stats server, when these are attached. An open circuit
+// does not make the component unready.
+type CircuitBreaker struct {
+	endpoint     string
+	config       CircuitBreakerConfig
+	failureCodes map[codes.Code]struct{}
+	lock         sync.Mutex
+	methods      map[string]*methodCircuit
+	probe        *probe.Probe
+	statsServer  *stats.PromStatsServer
+	now          func() time.Time
+}
+
+// NewCircuitBreaker creates a circuit breaker for requests sent to the given endpoint
+func NewCircuitBreaker(endpoint string, config CircuitBreakerConfig) (*CircuitBreaker, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	cb := &CircuitBreaker{
+		endpoint:     endpoint,
+		config:       config,
+		failureCodes: make(map[codes.Code]struct{}, len(config.FailureCodes)),
+		methods:      make(map[string]*methodCircuit),
+		now:          time.Now,
+	}
+	for _, code := range config.FailureCodes {
+		cb.failureCodes[code] = struct{}{}
+	}
+	return cb, nil
+}
+
+// SetProbe attaches a k8s probe on which the circuit states are published as details named
+// "circuit-breaker:<endpoint><method>"
+func (cb *CircuitBreaker) SetProbe(p *probe.Probe) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	cb.probe = p
+}
+
+// SetStatsServer attaches a stats server on which the circuit states are published
+func (cb *CircuitBreaker) SetStatsServer(statsServer *stats.PromStatsServer) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	cb.statsServer = statsServer
+}
+
+// State returns the circuit state of a grpc method, e.g. "/core_service.CoreService/GetDevice"
+func (cb *CircuitBreaker) State(method string) CircuitState {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	if m, ok := cb.methods[method]; ok {
+		return m.state
+	}
+	return CircuitClosed
+}
+
+// States returns the circuit states of all the grpc methods invoked so far
+func (cb *CircuitBreaker) States() map[string]CircuitState {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	states := make(map[string]CircuitState, len(cb.methods))
+	for method, m := range cb.methods {
+		states[method] = m.state
+	}
+	return states
+}
+
+// setState must be called with the lock held
+func (cb *CircuitBreaker) setState(ctx context.Context, method string, m *methodCircuit, newState CircuitState) {
+	logger.Warnw(ctx, "circuit-breaker-state-changed", log.Fields{"api-endpoint": cb.endpoint, "method": method, "curr-state": m.state, "new-state": newState})
+	m.state = newState
+	m.generation++
+	m.probes, m.successes = 0, 0
+	switch newState {
+	case CircuitOpen:
+		m.openedAt = cb.now()
+	case CircuitClosed:
+		m.resetWindow()
+	}
+	if cb.probe != nil {
+		cb.probe.UpdateDetail(ctx, "circuit-breaker:"+cb.endpoint+method, newState.String())
+	}
+	if cb.statsServer != nil {
+		cb.statsServer.SetCircuitBreakerState(cb.endpoint, method, uint8(newState))
+	}
+}
+
+// admit returns the generation under which the request was let through or an Unavailable error if
+// the circuit is open
+func (cb *CircuitBreaker) admit(ctx context.Context, method string) (uint64, error) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	m, ok := cb.methods[method]
+	if !ok {
+		m = &methodCircuit{state: CircuitClosed, window: make([]callOutcome, cb.config.WindowSize)}
+		cb.methods[method] = m
+	}
+	if m.state == CircuitOpen && cb.now().Sub(m.openedAt) >= cb.config.OpenTimeout {
+		cb.setState(ctx, method, m, CircuitHalfOpen)
+	}
+	switch m.state {
+	case CircuitOpen:
+		return 0, status.Errorf(codes.Unavailable, "circuit-breaker-open: %s%s", cb.endpoint, method)
+	case CircuitHalfOpen:
+		if m.probes >= cb.config.HalfOpenProbes {
+			return 0, status.Errorf(codes.Unavailable, "circuit-breaker-half-open: %s%s", cb.endpoint, method)
+		}
+		m.probes++
+	}
+	return m.generation, nil
+}
+
+// record accounts the outcome of a request admitted under the given generation
+func (cb *CircuitBreaker) record(ctx context.Context, method string, generation uint64, err error, latency time.Duration) {
+	outcome := callOutcome{slow: cb.config.SlowCallThreshold > 0 && latency > cb.config.SlowCallThreshold}
+	if err != nil {
+		_, outcome.failed = cb.failureCodes[status.Code(err)]
+	}
+
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	m := cb.methods[method]
+	if m == nil || m.generation != generation {
+		return
+	}
+	switch m.state {
+	case CircuitHalfOpen:
+		if outcome.failed || outcome.slow {
+			cb.setState(ctx, method, m, CircuitOpen)
+			return
+		}
+		m.successes++
+		if m.successes >= cb.config.HalfOpenProbes {
+			cb.setState(ctx, method, m, CircuitClosed)
+		}
+	case CircuitClosed:
+		m.add(outcome)
+		if m.count < cb.config.MinimumCalls {
+			return
+		}
+		failureRate := float64(m.failures) / float64(m.count)
+		slowRate := float64(m.slow) / float64(m.count)
+		if failureRate >= cb.config.FailureRateThreshold ||
+			(cb.config.SlowCallThreshold > 0 && slowRate >= cb.config.SlowCallRateThreshold) {
+			logger.Errorw(ctx, "circuit-breaker-tripped", log.Fields{"api-endpoint": cb.endpoint, "method": method, "failure-rate": failureRate, "slow-call-rate": slowRate})
+			cb.setState(ctx, method, m, CircuitOpen)
+		}
+	}
+}
+
+// UnaryClientInterceptor returns the interceptor to add to the grpc client chain
+func (cb *CircuitBreaker) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		generation, err := cb.admit(ctx, method)
+		if err != nil {
+			return err
+		}
+		start := cb.now()
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		cb.record(ctx, method, generation, err, cb.now().Sub(start))
+		return err
+	}
+}
@@ -0,0 +1,214 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/probe"
+	"github.com/opencord/voltha-lib-go/v7/pkg/stats"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testMethod = "/core_service.CoreService/GetDevice"
+
+type fakeClock struct {
+	current time.Time
+}
+
+func (f *fakeClock) now() time.Time {
+	return f.current
+}
+
+func newTestCircuitBreaker(t *testing.T) (*CircuitBreaker, *fakeClock) {
+	config := DefaultCircuitBreakerConfig()
+	config.WindowSize = 4
+	config.MinimumCalls = 4
+	config.SlowCallThreshold = time.Second
+	config.OpenTimeout = 10 * time.Second
+	config.HalfOpenProbes = 2
+	cb, err := NewCircuitBreaker("127.0.0.1:1234", config)
+	assert.Nil(t, err)
+	clock := &fakeClock{current: time.Now()}
+	cb.now = clock.now
+	return cb, clock
+}
+
+func invokerWith(clock *fakeClock, latency time.Duration, err error) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		clock.current = clock.current.Add(latency)
+		return err
+	}
+}
+
+func TestCircuitBreakerConfigValidation(t *testing.T) {
+	config := DefaultCircuitBreakerConfig()
+	config.MinimumCalls = config.WindowSize + 1
+	_, err := NewCircuitBreaker("endpoint", config)
+	assert.NotNil(t, err)
+
+	config = DefaultCircuitBreakerConfig()
+	config.FailureRateThreshold = 0
+	_, err = NewCircuitBreaker("endpoint", config)
+	assert.NotNil(t, err)
+
+	_, err = NewCircuitBreaker("endpoint", DefaultCircuitBreakerConfig())
+	assert.Nil(t, err)
+}
+
+func TestCircuitBreakerTripsOnErrorRate(t *testing.T) {
+	cb, clock := newTestCircuitBreaker(t)
+	interceptor := cb.UnaryClientInterceptor()
+	ctx := context.Background()
+
+	success := invokerWith(clock, time.Millisecond, nil)
+	failure := invokerWith(clock, time.Millisecond, status.Error(codes.Unavailable, "down"))
+	appError := invokerWith(clock, time.Millisecond, status.Error(codes.NotFound, "no device"))
+
+	// Application errors do not count as failures
+	for i := 0; i < 4; i++ {
+		assert.NotNil(t, interceptor(ctx, testMethod, nil, nil, nil, appError))
+	}
+	assert.Equal(t, CircuitClosed, cb.State(testMethod))
+
+	assert.Nil(t, interceptor(ctx, testMethod, nil, nil, nil, success))
+	assert.NotNil(t, interceptor(ctx, testMethod, nil, nil, nil, failure))
+	assert.Equal(t, CircuitClosed, cb.State(testMethod))
+	assert.NotNil(t, interceptor(ctx, testMethod, nil, nil, nil, failure))
+	assert.Equal(t, CircuitOpen, cb.State(testMethod))
+
+	// An open circuit fails fast without invoking the remote end
+	invoked := false
+	err := interceptor(ctx, testMethod, nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		invoked = true
+		return nil
+	})
+	assert.False(t, invoked)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	// Other methods are not affected
+	assert.Nil(t, interceptor(ctx, "/core_service.CoreService/GetPorts", nil, nil, nil, success))
+	assert.Equal(t, CircuitClosed, cb.State("/core_service.CoreService/GetPorts"))
+}
+
+func TestCircuitBreakerTripsOnLatency(t *testing.T) {
+	cb, clock := newTestCircuitBreaker(t)
+	interceptor := cb.UnaryClientInterceptor()
+	slow := invokerWith(clock, 2*time.Second, nil)
+
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, interceptor(context.Background(), testMethod, nil, nil, nil, slow))
+	}
+	assert.Equal(t, CircuitOpen, cb.State(testMethod))
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	cb, clock := newTestCircuitBreaker(t)
+	interceptor := cb.UnaryClientInterceptor()
+	ctx := context.Background()
+	success := invokerWith(clock, time.Millisecond, nil)
+	failure := invokerWith(clock, time.Millisecond, status.Error(codes.DeadlineExceeded, "timeout"))
+
+	for i := 0; i < 4; i++ {
+		_ = interceptor(ctx, testMethod, nil, nil, nil, failure)
+	}
+	assert.Equal(t, CircuitOpen, cb.State(testMethod))
+
+	// A failed probe re-opens the circuit
+	clock.current = clock.current.Add(10 * time.Second)
+	assert.NotNil(t, interceptor(ctx, testMethod, nil, nil, nil, failure))
+	assert.Equal(t, CircuitOpen, cb.State(testMethod))
+
+	// Successful probes close it
+	clock.current = clock.current.Add(10 * time.Second)
+	assert.Nil(t, interceptor(ctx, testMethod, nil, nil, nil, success))
+	assert.Equal(t, CircuitHalfOpen, cb.State(testMethod))
+	assert.Nil(t, interceptor(ctx, testMethod, nil, nil, nil, success))
+	assert.Equal(t, CircuitClosed, cb.State(testMethod))
+	assert.Equal(t, map[string]CircuitState{testMethod: CircuitClosed}, cb.States())
+}
+
+func TestCircuitBreakerHalfOpenLimitsProbes(t *testing.T) {
+	cb, clock := newTestCircuitBreaker(t)
+	interceptor := cb.UnaryClientInterceptor()
+	ctx := context.Background()
+	failure := invokerWith(clock, time.Millisecond, status.Error(codes.Unavailable, "down"))
+	for i := 0; i < 4; i++ {
+		_ = interceptor(ctx, testMethod, nil, nil, nil, failure)
+	}
+	clock.current = clock.current.Add(10 * time.Second)
+
+	// Hold the probes in flight and verify that a third request is rejected
+	release := make(chan struct{})
+	blocked := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		<-release
+		return nil
+	}
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { done <- interceptor(ctx, testMethod, nil, nil, nil, blocked) }()
+	}
+	assert.Eventually(t, func() bool {
+		cb.lock.Lock()
+		defer cb.lock.Unlock()
+		return cb.methods[testMethod].probes == 2
+	}, time.Second, 5*time.Millisecond)
+
+	err := interceptor(ctx, testMethod, nil, nil, nil, invokerWith(clock, 0, nil))
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	close(release)
+	assert.Nil(t, <-done)
+	assert.Nil(t, <-done)
+	assert.Equal(t, CircuitClosed, cb.State(testMethod))
+}
+
+func TestCircuitBreakerPublishesStates(t *testing.T) {
+	cb, clock := newTestCircuitBreaker(t)
+	ctx := context.Background()
+	p := &probe.Probe{}
+	p.RegisterService(ctx, "127.0.0.1:1234")
+	p.UpdateStatus(ctx, "127.0.0.1:1234", probe.ServiceStatusRunning)
+	cb.SetProbe(p)
+
+	interceptor := cb.UnaryClientInterceptor()
+	failure := invokerWith(clock, time.Millisecond, status.Error(codes.Unavailable, "down"))
+	for i := 0; i < 4; i++ {
+		_ = interceptor(ctx, testMethod, nil, nil, nil, failure)
+	}
+	assert.Equal(t, CircuitOpen, cb.State(testMethod))
+	assert.Equal(t, "Open", p.GetDetail("circuit-breaker:127.0.0.1:1234"+testMethod))
+	// an open circuit does not make the component unready
+	assert.True(t, p.IsReady())
+
+	clock.current = clock.current.Add(10 * time.Second)
+	assert.Nil(t, interceptor(ctx, testMethod, nil, nil, nil, invokerWith(clock, 0, nil)))
+	assert.Equal(t, "HalfOpen", p.GetDetail("circuit-breaker:127.0.0.1:1234"+testMethod))
+}
+
+func TestClientCircuitBreakerOptions(t *testing.T) {
+	statsServer := &stats.PromStatsServer{}
+	c, err := NewClient("client", "127.0.0.1:1234", "remote", nil,
+		ClientCircuitBreaker(DefaultCircuitBreakerConfig()), ClientStatsServer(statsServer))
+	assert.Nil(t, err)
+	assert.NotNil(t, c.GetCircuitBreaker())
+	assert.Equal(t, statsServer, c.GetCircuitBreaker().statsServer)
+}
@@ -17,6 +17,7 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -30,6 +31,7 @@ import (
 	"github.com/jhump/protoreflect/grpcreflect"
 	"github.com/opencord/voltha-lib-go/v7/pkg/log"
 	"github.com/opencord/voltha-lib-go/v7/pkg/probe"
+	"github.com/opencord/voltha-lib-go/v7/pkg/stats"
 	"github.com/opencord/voltha-protos/v5/go/adapter_service"
 	"github.com/opencord/voltha-protos/v5/go/common"
 	"github.com/opencord/voltha-protos/v5/go/core_service"
@@ -42,7 +44,6 @@ import (
 )
 
 type event byte
-type state byte
 type GetServiceClient func(context.Context, *grpc.ClientConn) interface{}
 type RestartedHandler func(ctx context.Context, endPoint string) error
 
@@ -73,13 +74,72 @@ const (
 	eventDisconnected
 	eventStopped
 	eventError
+)
+
+// ConnectionState is the state of the connection of a Client to its remote endpoint
+type ConnectionState byte
 
-	stateConnected = state(iota)
+const (
+	stateConnected = ConnectionState(iota)
 	stateValidatingConnection
 	stateConnecting
 	stateDisconnected
 )
 
+const (
+	// ConnectionStateConnected the keep alive stream with the remote endpoint is established
+	ConnectionStateConnected = stateConnected
+	// ConnectionStateValidating a grpc connection exists and the keep alive stream is being established
+	ConnectionStateValidating = stateValidatingConnection
+	// ConnectionStateConnecting a grpc connection to the remote endpoint is being set up
+	ConnectionStateConnecting = stateConnecting
+	// ConnectionStateDisconnected there is no connection to the remote endpoint
+	ConnectionStateDisconnected = stateDisconnected
+)
+
+// String converts ConnectionState values to strings
+func (s ConnectionState) String() string {
+	switch s {
+	case stateConnected:
+		return "Connected"
+	case stateValidatingConnection:
+		return "Validating"
+	case stateConnecting:
+		return "Connecting"
+	case stateDisconnected:
+		return "Disconnected"
+	default:
+		return "Unknown"
+	}
+}
+
+// StateTransition describes a change of the connection state of a Client
+type StateTransition struct {
+	Previous  ConnectionState
+	Current   ConnectionState
+	Timestamp time.Time
+	// Cause is the error that triggered the transition, if any
+	Cause error
+}
+
+// ConnectionStats are the connection counters of a Client
+type ConnectionStats struct {
+	State ConnectionState
+	// Since is the time of the last state transition
+	Since time.Time
+	// ReconnectAttempts is the number of connection attempts made after the first one
+	ReconnectAttempts uint64
+	// Disconnections is the number of times an established connection was lost
+	Disconnections uint64
+	// DisconnectedTime is the total time spent without an established connection, including the
+	// ongoing period, since the client was started
+	DisconnectedTime time.Duration
+}
+
+// stateWatcherBufferSize is the number of transitions buffered per watcher, further transitions
+// are dropped until the watcher catches up
+const stateWatcherBufferSize = 16
+
 type Client struct {
 	clientEndpoint         string
 	clientContextData      string
@@ -88,7 +148,14 @@ type Client struct {
 	connection             *grpc.ClientConn
 	connectionLock         sync.RWMutex
 	stateLock              sync.RWMutex
-	state                  state
+	state                  ConnectionState
+	stateSince             time.Time
+	disconnectCause        error
+	connectAttempts        uint64
+	disconnections         uint64
+	disconnectedTime       time.Duration
+	watchersLock           sync.Mutex
+	watchers               map[chan StateTransition]struct{}
 	service                interface{}
 	events                 chan event
 	onRestart              RestartedHandler
@@ -99,6 +166,9 @@ type Client struct {
 	done                   bool
 	livenessLock           sync.RWMutex
 	livenessCallback       func(timestamp time.Time)
+	breakerConfig          *CircuitBreakerConfig
+	breaker                *CircuitBreaker
+	statsServer            *stats.PromStatsServer
 }
 
 type ClientOption func(*Client)
@@ -109,6 +179,24 @@ func ClientContextData(data string) ClientOption {
 	}
 }
 
+// ClientCircuitBreaker adds a circuit breaker to the unary interceptor chain of the client. Its
+// circuit states are published on the k8s probe of the context given to Start, if any.
+func ClientCircuitBreaker(config CircuitBreakerConfig) ClientOption {
+	return func(args *Client) {
+		args.breakerConfig = &config
+	}
+}
+
+// ClientStatsServer publishes the circuit states of the circuit breaker of the client on the stats server
+func ClientStatsServer(statsServer *stats.PromStatsServer) ClientOption {
+	return func(args *Client) {
+		args.statsServer = statsServer
+	}
+}
+
+// NewClient creates a client of the remote service served at serverEndpoint, which is either a
+// "host:port" address, a unix domain socket ("unix:///path/to/socket") or an in-process
+// endpoint ("inmem://name") served by a GrpcServer of the same process.
 func NewClient(clientEndpoint, serverEndpoint, remoteServiceName string, onRestart RestartedHandler,
 	opts ...ClientOption) (*Client, error) {
 	c := &Client{
@@ -118,6 +206,7 @@ func NewClient(clientEndpoint, serverEndpoint, remoteServiceName string, onResta
 		onRestart:              onRestart,
 		events:                 make(chan event, 5),
 		state:                  stateDisconnected,
+		watchers:               make(map[chan StateTransition]struct{}),
 		backoffInitialInterval: DefaultBackoffInitialInterval,
 		backoffMaxInterval:     DefaultBackoffMaxInterval,
 		backoffMaxElapsedTime:  DefaultBackoffMaxElapsedTime,
@@ -151,11 +240,26 @@ func NewClient(clientEndpoint, serverEndpoint, remoteServiceName string, onResta
 		return nil, fmt.Errorf("initial retry delay %v is greater than maximum retry delay %v", c.backoffInitialInterval, c.backoffMaxInterval)
 	}
 
+	if c.breakerConfig != nil {
+		var err error
+		if c.breaker, err = NewCircuitBreaker(c.serverEndPoint, *c.breakerConfig); err != nil {
+			return nil, err
+		}
+		if c.statsServer != nil {
+			c.breaker.SetStatsServer(c.statsServer)
+		}
+	}
+
 	grpc.EnableTracing = true
 
 	return c, nil
 }
 
+// GetCircuitBreaker returns the circuit breaker of the client, nil if none was configured
+func (c *Client) GetCircuitBreaker() *CircuitBreaker {
+	return c.breaker
+}
+
 func (c *Client) GetClient() (interface{}, error) {
 	c.connectionLock.RLock()
 	defer c.connectionLock.RUnlock()
@@ -230,11 +334,106 @@ func (c *Client) Reset(ctx context.Context) {
 	c.stateLock.Lock()
 	defer c.stateLock.Unlock()
 	if c.state == stateConnected {
-		c.state = stateDisconnected
+		c.setState(ctx, stateDisconnected, errors.New("connection reset"))
 		c.events <- eventDisconnected
 	}
 }
 
+// State returns the current connection state of the client
+func (c *Client) State() ConnectionState {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	return c.state
+}
+
+// Stats returns the connection counters of the client
+func (c *Client) Stats() ConnectionStats {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	stats := ConnectionStats{
+		State:            c.state,
+		Since:            c.stateSince,
+		Disconnections:   c.disconnections,
+		DisconnectedTime: c.disconnectedTime,
+	}
+	if c.connectAttempts > 1 {
+		stats.ReconnectAttempts = c.connectAttempts - 1
+	}
+	if c.state != stateConnected && !c.stateSince.IsZero() {
+		stats.DisconnectedTime += time.Since(c.stateSince)
+	}
+	return stats
+}
+
+// WatchState returns a channel on which the connection state transitions of the client are
+// sent until the context is done or the client stops, at which point the channel is closed
+func (c *Client) WatchState(ctx context.Context) <-chan StateTransition {
+	ch := make(chan StateTransition, stateWatcherBufferSize)
+	c.watchersLock.Lock()
+	if c.watchers == nil {
+		// The client has stopped
+		close(ch)
+		c.watchersLock.Unlock()
+		return ch
+	}
+	c.watchers[ch] = struct{}{}
+	c.watchersLock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		c.watchersLock.Lock()
+		defer c.watchersLock.Unlock()
+		if _, ok := c.watchers[ch]; ok {
+			delete(c.watchers, ch)
+			close(ch)
+		}
+	}()
+	return ch
+}
+
+// closeWatchers closes the channels of all the state watchers, once the client has stopped
+func (c *Client) closeWatchers() {
+	c.watchersLock.Lock()
+	defer c.watchersLock.Unlock()
+	for ch := range c.watchers {
+		close(ch)
+	}
+	c.watchers = nil
+}
+
+// setState records a state transition and notifies the watchers. It must be called with the
+// stateLock held.
+func (c *Client) setState(ctx context.Context, newState ConnectionState, cause error) {
+	if c.state == newState {
+		return
+	}
+	now := time.Now()
+	transition := StateTransition{Previous: c.state, Current: newState, Timestamp: now, Cause: cause}
+
+	if c.state != stateConnected && !c.stateSince.IsZero() {
+		c.disconnectedTime += now.Sub(c.stateSince)
+	}
+	if c.state == stateConnected {
+		c.disconnections++
+	}
+	if newState == stateConnecting {
+		c.connectAttempts++
+	}
+	c.state = newState
+	c.stateSince = now
+
+	logger.Debugw(ctx, "connection-state-changed", log.Fields{"api-endpoint": c.serverEndPoint, "client": c.clientEndpoint, "curr-state": transition.Previous, "new-state": newState, "cause": cause})
+	c.watchersLock.Lock()
+	defer c.watchersLock.Unlock()
+	for ch := range c.watchers {
+		select {
+		case ch <- transition:
+		default:
+			logger.Warnw(ctx, "state-watcher-full-dropping-transition", log.Fields{"api-endpoint": c.serverEndPoint, "client": c.clientEndpoint, "transition": transition})
+		}
+	}
+}
+
 // executeWithTimeout runs a sending function (sf) along with a receiving one(rf) and returns an error, if any.
 // If the deadline  elapses first, it returns a grpc DeadlineExceeded error instead.
 func (c *Client) executeWithTimeout(sf func(*common.Connection) error, rf func() (interface{}, error), conn *common.Connection, d time.Duration) error {
@@ -264,13 +463,20 @@ func (c *Client) executeWithTimeout(sf func(*common.Connection) error, rf func()
 func (c *Client) monitorConnection(ctx context.Context) {
 	logger.Debugw(ctx, "monitor-connection-started", log.Fields{"qpi-endpoint": c.serverEndPoint, "client": c.clientEndpoint})
 
+	// The error that ended the monitoring, reported as the cause of the disconnection
+	var cause error
+
 	// If we exit, assume disconnected
 	defer func() {
 		c.stateLock.Lock()
 		if !c.done && (c.state == stateConnected || c.state == stateValidatingConnection) {
+			if cause == nil {
+				cause = errors.New("connection monitoring ended")
+			}
+			c.disconnectCause = cause
 			// Handle only connected state here.  We need the validating state to know if we need to backoff before a retry
 			if c.state == stateConnected {
-				c.state = stateDisconnected
+				c.setState(ctx, stateDisconnected, cause)
 			}
 			logger.Warnw(ctx, "sending-disconnect-event", log.Fields{"api-endpoint": c.serverEndPoint, "client": c.clientEndpoint, "curr-state": stateConnected, "new-state": c.state})
 			c.events <- eventDisconnected
@@ -286,6 +492,7 @@ func (c *Client) monitorConnection(ctx context.Context) {
 	c.connectionLock.RUnlock()
 	if conn == nil {
 		logger.Errorw(ctx, "connection-nil", log.Fields{"api-endpoint": c.serverEndPoint, "client": c.clientEndpoint})
+		cause = errors.New("no grpc connection")
 		return
 	}
 
@@ -294,6 +501,7 @@ func (c *Client) monitorConnection(ctx context.Context) {
 	grpcReflectClient := grpcreflect.NewClientAuto(ctx, conn)
 	if grpcReflectClient == nil {
 		logger.Errorw(ctx, "grpc-reflect-client-nil", log.Fields{"api-endpoint": c.serverEndPoint, "client": c.clientEndpoint})
+		cause = errors.New("no grpc reflection client")
 		return
 	}
 
@@ -301,6 +509,7 @@ func (c *Client) monitorConnection(ctx context.Context) {
 	services, err := grpcReflectClient.ListServices()
 	if err != nil {
 		logger.Errorw(ctx, "list-services-error", log.Fields{"api-endpoint": c.serverEndPoint, "client": c.clientEndpoint, "error": err})
+		cause = err
 		return
 	}
 
@@ -315,6 +524,7 @@ func (c *Client) monitorConnection(ctx context.Context) {
 	}
 	if serviceOfInterest == "" {
 		logger.Errorw(ctx, "no-service-found", log.Fields{"api-endpoint": c.serverEndPoint, "client": c.clientEndpoint, "services": services, "expected-remote-service": c.remoteServiceName})
+		cause = fmt.Errorf("remote service %s not found", c.remoteServiceName)
 		return
 	}
 
@@ -322,6 +532,7 @@ func (c *Client) monitorConnection(ctx context.Context) {
 	resolvedService, err := grpcReflectClient.ResolveService(serviceOfInterest)
 	if err != nil {
 		logger.Errorw(ctx, "service-error", log.Fields{"api-endpoint": c.serverEndPoint, "client": c.clientEndpoint, "service": resolvedService, "error": err})
+		cause = err
 		return
 	}
 
@@ -329,6 +540,7 @@ func (c *Client) monitorConnection(ctx context.Context) {
 	method := resolvedService.FindMethodByName("GetHealthStatus")
 	if method == nil {
 		logger.Errorw(ctx, "nil-method", log.Fields{"api-endpoint": c.serverEndPoint, "client": c.clientEndpoint, "service": resolvedService})
+		cause = errors.New("method GetHealthStatus not found")
 		return
 	}
 	logger.Debugw(ctx, "resolved-to-method", log.Fields{"service": resolvedService.GetName(), "method": method.GetName()})
@@ -342,6 +554,7 @@ func (c *Client) monitorConnection(ctx context.Context) {
 	stream, err := dynamicConn.InvokeRpcBidiStream(streamCtx, method)
 	if err != nil {
 		logger.Errorw(ctx, "stream-error", log.Fields{"api-endpoint": c.serverEndPoint, "client": c.clientEndpoint, "service": resolvedService, "error": err})
+		cause = err
 		return
 	}
 
@@ -364,6 +577,7 @@ loop:
 		if err != nil {
 			// Any error means the far end is gone
 			logger.Errorw(ctx, "sending-stream-error", log.Fields{"error": err, "api-endpoint": c.serverEndPoint, "client": c.clientEndpoint, "context": stream.Context().Err()})
+			cause = err
 			break loop
 		}
 		// Send a connect event
@@ -385,9 +599,11 @@ loop:
 		select {
 		case <-ctx.Done():
 			logger.Warnw(ctx, "context-done", log.Fields{"api-endpont": c.serverEndPoint, "client": c.clientEndpoint})
+			cause = ctx.Err()
 			break loop
 		case <-stream.Context().Done():
 			logger.Debugw(ctx, "stream-context-done", log.Fields{"api-endpoint": c.serverEndPoint, "stream-info": stream.Context(), "client": c.clientEndpoint})
+			cause = stream.Context().Err()
 			break loop
 		case <-keepAliveTimer.C:
 			continue
@@ -408,8 +624,16 @@ func (c *Client) Start(ctx context.Context, handler GetServiceClient, retry_inte
 	p := probe.GetProbeFromContext(ctx)
 	if p != nil {
 		p.RegisterService(ctx, c.serverEndPoint)
+		if c.breaker != nil {
+			c.breaker.SetProbe(p)
+		}
 	}
 
+	c.stateLock.Lock()
+	c.stateSince = time.Now()
+	c.stateLock.Unlock()
+	defer c.closeWatchers()
+
 	var monitorConnectionCtx context.Context
 	var monitorConnectionDone func()
 
@@ -445,10 +669,10 @@ loop:
 				c.stateLock.Lock()
 				logger.Debugw(ctx, "connection-start", log.Fields{"api-endpoint": c.serverEndPoint, "attempts": attempt, "curr-state": c.state, "client": c.clientEndpoint})
 				if c.state == stateConnected {
-					c.state = stateDisconnected
+					c.setState(ctx, stateDisconnected, nil)
 				}
 				if c.state != stateConnecting {
-					c.state = stateConnecting
+					c.setState(ctx, stateConnecting, nil)
 					go func() {
 						var err error
 						if len(retry_interceptor) > 0 {
@@ -459,7 +683,7 @@ loop:
 
 						if err != nil {
 							c.stateLock.Lock()
-							c.state = stateDisconnected
+							c.setState(ctx, stateDisconnected, err)
 							c.stateLock.Unlock()
 							logger.Errorw(ctx, "connection-failed", log.Fields{"api-endpoint": c.serverEndPoint, "attempt": attempt, "client": c.clientEndpoint, "error": err})
 
@@ -484,7 +708,7 @@ loop:
 				logger.Debugw(ctx, "connection-validation", log.Fields{"api-endpoint": c.serverEndPoint, "client": c.clientEndpoint})
 				c.stateLock.Lock()
 				if c.state != stateConnected {
-					c.state = stateValidatingConnection
+					c.setState(ctx, stateValidatingConnection, nil)
 				}
 				c.stateLock.Unlock()
 				monitorConnectionCtx, monitorConnectionDone = context.WithCancel(context.Background())
@@ -515,7 +739,8 @@ loop:
 						c.events <- eventDisconnected
 					}
 					cancel()
-					c.state = stateConnected
+					c.disconnectCause = nil
+					c.setState(ctx, stateConnected, nil)
 					if initialConnection {
 						logger.Debugw(ctx, "initial-endpoint-connection", log.Fields{"api-endpoint": c.serverEndPoint, "client": c.clientEndpoint})
 						initialConnection = false
@@ -541,7 +766,7 @@ loop:
 				logger.Debugw(ctx, "endpoint-disconnected", log.Fields{"api-endpoint": c.serverEndPoint, "curr-state": c.state, "client": c.clientEndpoint})
 				if c.state == stateValidatingConnection {
 					connectionValidationFail = true
-					c.state = stateDisconnected
+					c.setState(ctx, stateDisconnected, c.disconnectCause)
 				}
 				c.stateLock.Unlock()
 
@@ -611,25 +836,31 @@ func (c *Client) connectToEndpoint(ctx context.Context, p *probe.Probe, retry_in
 	// Use Interceptors to:
 	// 1. automatically inject
 	// 2. publish Open Tracing Spans by this GRPC Client
-	// 3. detect connection failure on client calls such that the reconnection process can begin
+	// 3. fail fast on an unhealthy endpoint, when a circuit breaker is configured
+	// 4. detect connection failure on client calls such that the reconnection process can begin
 	interceptor_opts := []grpc.UnaryClientInterceptor{
 		grpc_opentracing.UnaryClientInterceptor(grpc_opentracing.WithTracer(log.ActiveTracerProxy{})),
 		grpc_prometheus.UnaryClientInterceptor,
 	}
+	// The breaker sits before the retry interceptors so that an open circuit is not retried
+	if c.breaker != nil {
+		interceptor_opts = append(interceptor_opts, c.breaker.UnaryClientInterceptor())
+	}
 
 	grpc_prometheus.EnableClientHandlingTimeHistogram()
 	if len(retry_interceptor) > 0 {
 		interceptor_opts = append(interceptor_opts, retry_interceptor...)
 	}
-	conn, err := grpc.NewClient(c.serverEndPoint,
+	target, transportOpts := clientTarget(c.serverEndPoint)
+	conn, err := grpc.NewClient(target, append([]grpc.DialOption{
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(grpcRecvMsgSizeLimit*1024*1024)),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(grpcRecvMsgSizeLimit * 1024 * 1024)),
 		grpc.WithStreamInterceptor(grpc_middleware.ChainStreamClient(
 			grpc_opentracing.StreamClientInterceptor(grpc_opentracing.WithTracer(log.ActiveTracerProxy{})),
 			grpc_prometheus.StreamClientInterceptor,
 		)),
 		grpc.WithUnaryInterceptor(grpc_middleware.ChainUnaryClient(interceptor_opts...)),
-	)
+	}, transportOpts...)...)
 
 	if err == nil {
 		c.connection = conn
@@ -19,6 +19,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"testing"
@@ -84,7 +85,7 @@ func (s *testCoreServer) start(ctx context.Context, t *testing.T) {
 
 	s.probe.UpdateStatus(ctx, testGrpcServer, probe.ServiceStatusRunning)
 	s.coreService.Start()
-	s.server.Start(ctx)
+	assert.Nil(t, s.server.Start(ctx))
 	s.probe.UpdateStatus(ctx, testGrpcServer, probe.ServiceStatusStopped)
 }
 
@@ -269,15 +270,12 @@ func livessness(timestamp time.Time) {
 	logger.Debugw(context.Background(), "received-liveness", log.Fields{"timestamp": timestamp})
 }
 
-func serverRestarts(t *testing.T, numRestartRuns int) {
+func serverRestarts(t *testing.T, apiEndpoint string, numRestartRuns int) {
 	// Setup
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Create and start the test server
-	grpcPort, err := freeport.GetFreePort()
-	assert.Nil(t, err)
-	apiEndpoint := "127.0.0.1:" + strconv.Itoa(grpcPort)
 	ts := newTestCoreServer(apiEndpoint)
 	ts.registerService(ctx, t)
 	go ts.start(ctx, t)
@@ -294,7 +292,7 @@ func serverRestarts(t *testing.T, numRestartRuns int) {
 	var servicesReady isConditionSatisfied = func() bool {
 		return ts.probe.IsReady() && tc.probe.IsReady()
 	}
-	err = waitUntilCondition(timeout, servicesReady)
+	err := waitUntilCondition(timeout, servicesReady)
 	assert.Nil(t, err)
 
 	// Test 2: Verify we get a valid client and can make grpc requests with it
@@ -576,6 +574,83 @@ func testServerLimit(t *testing.T) {
 	}
 }
 
+func waitForState(t *testing.T, transitions <-chan vgrpc.StateTransition, state vgrpc.ConnectionState) vgrpc.StateTransition {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case transition, ok := <-transitions:
+			if !ok {
+				t.Fatalf("state channel closed while waiting for %s", state)
+			}
+			if transition.Current == state {
+				return transition
+			}
+		case <-timer.C:
+			t.Fatalf("timeout waiting for %s", state)
+		}
+	}
+}
+
+func testStateWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	apiEndpoint := "inmem://state-watch"
+	ts := newTestCoreServer(apiEndpoint)
+	ts.registerService(ctx, t)
+	go ts.start(ctx, t)
+
+	tc := newTestClient(apiEndpoint, serverRestarted)
+	assert.NotNil(t, tc)
+	assert.Equal(t, vgrpc.ConnectionStateDisconnected, tc.client.State())
+	transitions := tc.client.WatchState(ctx)
+	go tc.start(ctx, t, getCoreServiceHandler)
+
+	// Test 1: the client goes through the connection states
+	waitForState(t, transitions, vgrpc.ConnectionStateConnecting)
+	waitForState(t, transitions, vgrpc.ConnectionStateValidating)
+	connected := waitForState(t, transitions, vgrpc.ConnectionStateConnected)
+	assert.Equal(t, vgrpc.ConnectionStateValidating, connected.Previous)
+	assert.False(t, connected.Timestamp.IsZero())
+	assert.Equal(t, vgrpc.ConnectionStateConnected, tc.client.State())
+	assert.Equal(t, uint64(0), tc.client.Stats().Disconnections)
+
+	// Test 2: a server failure is reported with its cause
+	ts.stop()
+	disconnected := waitForState(t, transitions, vgrpc.ConnectionStateDisconnected)
+	assert.Equal(t, vgrpc.ConnectionStateConnected, disconnected.Previous)
+	assert.NotNil(t, disconnected.Cause)
+
+	// Test 3: the reconnection is counted
+	ts = newTestCoreServer(apiEndpoint)
+	ts.registerService(ctx, t)
+	go ts.start(ctx, t)
+	waitForState(t, transitions, vgrpc.ConnectionStateConnected)
+	stats := tc.client.Stats()
+	assert.Equal(t, vgrpc.ConnectionStateConnected, stats.State)
+	assert.Equal(t, uint64(1), stats.Disconnections)
+	assert.GreaterOrEqual(t, stats.ReconnectAttempts, uint64(1))
+	assert.Greater(t, stats.DisconnectedTime, time.Duration(0))
+
+	// Test 4: the channel is closed once the client stops
+	tc.client.Stop(context.Background())
+	err := waitUntilCondition(timeout, func() bool {
+		for {
+			select {
+			case _, ok := <-transitions:
+				if !ok {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	})
+	assert.Nil(t, err)
+	ts.stop()
+}
+
 func TestSuite(t *testing.T) {
 	// Setup
 	log.SetAllLogLevel(volthaTestLogLevel)
@@ -587,7 +662,13 @@ func TestSuite(t *testing.T) {
 	clientStartsFirstTest(t)
 
 	// Test server restarts
-	serverRestarts(t, 10)
+	grpcPort, err := freeport.GetFreePort()
+	assert.Nil(t, err)
+	serverRestarts(t, "127.0.0.1:"+strconv.Itoa(grpcPort), 10)
+
+	// Test server restarts over a unix domain socket and over the in-process transport
+	serverRestarts(t, "unix://"+filepath.Join(t.TempDir(), "core.sock"), 3)
+	serverRestarts(t, "inmem://core", 3)
 
 	// Test that the client test the grpc connection on no activity
 	testKeepAlive(t)
@@ -595,6 +676,9 @@ func TestSuite(t *testing.T) {
 	// Test client queueing with server limit
 	testServerLimit(t)
 
+	// Test the connection state notifications
+	testStateWatch(t)
+
 	// // Test the scenario where a client restarts
 	testClientFailure(t, 10)
 }
@@ -17,11 +17,15 @@ package grpc
 
 import (
 	"context"
-	"net"
+	"fmt"
+	"sync"
+	"sync/atomic"
 
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpc_opentracing "github.com/grpc-ecosystem/go-grpc-middleware/tracing/opentracing"
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/opencord/voltha-lib-go/v7/pkg/log"
+	"github.com/opencord/voltha-lib-go/v7/pkg/probe"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
@@ -32,7 +36,9 @@ import (
 /*
 To add a GRPC server to your existing component simply follow these steps:
 
-1. Create a server instance by passing the host and port where it should run and optionally add certificate information
+1. Create a server instance by passing the host and port where it should run and optionally add certificate information.
+   The address can also be a unix domain socket, "unix:///path/to/socket", or an in-process endpoint, "inmem://name",
+   that vgrpc clients of the same process can connect to.
 
 	e.g.
 	s.server = server.NewGrpcServer(s.config.GrpcHost, s.config.GrpcPort, nil, false)
@@ -52,11 +58,30 @@ To add a GRPC server to your existing component simply follow these steps:
     e.g.
 	s.server.AddService(f)
 
-4. Start the server
+4. Optionally add interceptors, e.g. an access log and a concurrency limit per method
 
-	s.server.Start(ctx)
+    e.g.
+	s.server.AddUnaryInterceptor(
+		server.AccessLogUnaryServerInterceptor(logger),
+		server.NewConcurrencyLimiter(100, nil).UnaryServerInterceptor(),
+	)
+
+5. Start the server
+
+	if err := s.server.Start(ctx); err != nil {
+		...
+	}
+
+6. On shutdown, drain the in-flight requests for up to a deadline
+
+	e.g.
+	drainCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	s.server.GracefulStop(drainCtx)
 */
 
+var enableHandlingTimeHistogram sync.Once
+
 // Interface allows probes to be attached to server
 // A probe must support the IsReady() method
 type ReadyProbe interface {
@@ -65,10 +90,17 @@ type ReadyProbe interface {
 
 type GrpcServer struct {
 	gs       *grpc.Server
+	gsLock   sync.RWMutex
+	stopping atomic.Bool
 	address  string
 	secure   bool
 	services []func(*grpc.Server)
 	probe    ReadyProbe // optional
+	// optional, notified of the status changes of the server during a graceful stop
+	statusCallback func(ctx context.Context, status probe.ServiceStatus)
+
+	unaryInterceptors  []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
 
 	*GrpcSecurity
 }
@@ -92,47 +124,82 @@ func NewGrpcServer(
 }
 
 /*
-Start prepares the GRPC server and starts servicing requests
+Start prepares the GRPC server and starts servicing requests. It returns once the
+server is stopped, or with an error if the server could not be started.
 */
-func (s *GrpcServer) Start(ctx context.Context, opts ...grpc.ServerOption) {
+func (s *GrpcServer) Start(ctx context.Context, opts ...grpc.ServerOption) error {
+
+	// Use Intercepters to:
+	// 1. automatically inject and publish Open Tracing Spans by this GRPC server
+	// 2. publish the server metrics to prometheus
+	// 3. recover from a panic in a handler
+	// 4. reject requests while the probe is not ready or the server is stopping, and streams
+	//    while the server is stopping
+	// 5. run the interceptors added to the server, which thus see the span of the request
+	//    and are not called for the rejected requests
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		grpc_opentracing.StreamServerInterceptor(grpc_opentracing.WithTracer(log.ActiveTracerProxy{})),
+		grpc_prometheus.StreamServerInterceptor,
+		RecoveryStreamServerInterceptor(),
+		mkStreamServerInterceptor(s),
+	}
+	streamInterceptors = append(streamInterceptors, s.streamInterceptors...)
 
-	lis, err := net.Listen("tcp", s.address)
-	if err != nil {
-		logger.Fatalf(ctx, "failed to listen: %v", err)
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		grpc_opentracing.UnaryServerInterceptor(grpc_opentracing.WithTracer(log.ActiveTracerProxy{})),
+		grpc_prometheus.UnaryServerInterceptor,
+		RecoveryUnaryServerInterceptor(),
+		mkServerInterceptor(s),
 	}
+	unaryInterceptors = append(unaryInterceptors, s.unaryInterceptors...)
 
-	// Use Intercepters to automatically inject and publish Open Tracing Spans by this GRPC server
+	// The histogram belongs to the default metrics shared by all the servers of the process
+	enableHandlingTimeHistogram.Do(func() { grpc_prometheus.EnableHandlingTimeHistogram() })
 	serverOptions := []grpc.ServerOption{
-		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(
-			grpc_opentracing.StreamServerInterceptor(grpc_opentracing.WithTracer(log.ActiveTracerProxy{})),
-		)),
-		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
-			grpc_opentracing.UnaryServerInterceptor(grpc_opentracing.WithTracer(log.ActiveTracerProxy{})),
-			mkServerInterceptor(s),
-		))}
+		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(streamInterceptors...)),
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(unaryInterceptors...)),
+	}
 
 	if s.secure && s.GrpcSecurity != nil {
 		creds, err := credentials.NewServerTLSFromFile(s.CertFile, s.KeyFile)
 		if err != nil {
-			logger.Fatalf(ctx, "could not load TLS keys: %s", err)
+			logger.Errorw(ctx, "could-not-load-tls-keys", log.Fields{"error": err})
+			return fmt.Errorf("could not load TLS keys: %w", err)
 		}
-
 		serverOptions = append(serverOptions, grpc.Creds(creds))
-		s.gs = grpc.NewServer(append(serverOptions, opts...)...)
 	} else {
 		logger.Info(ctx, "starting-insecure-grpc-server")
-		s.gs = grpc.NewServer(append(serverOptions, opts...)...)
 	}
 
+	lis, err := listen(s.address)
+	if err != nil {
+		logger.Errorw(ctx, "failed-to-listen", log.Fields{"address": s.address, "error": err})
+		return fmt.Errorf("failed to listen on %s: %w", s.address, err)
+	}
+
+	gs := grpc.NewServer(append(serverOptions, opts...)...)
+
 	// Register all required services
 	for _, service := range s.services {
-		service(s.gs)
+		service(gs)
+	}
+	reflection.Register(gs)
+	grpc_prometheus.Register(gs)
+
+	s.gsLock.Lock()
+	if s.stopping.Load() {
+		s.gsLock.Unlock()
+		_ = lis.Close()
+		return fmt.Errorf("grpc server on %s is stopping", s.address)
 	}
-	reflection.Register(s.gs)
+	s.gs = gs
+	s.gsLock.Unlock()
 
-	if err := s.gs.Serve(lis); err != nil {
-		logger.Fatalf(ctx, "failed to serve: %v\n", err)
+	if err := gs.Serve(lis); err != nil {
+		logger.Errorw(ctx, "failed-to-serve", log.Fields{"address": s.address, "error": err})
+		return fmt.Errorf("failed to serve on %s: %w", s.address, err)
 	}
+	return nil
 }
 
 // Make a serverInterceptor for the given GrpcServer
@@ -154,6 +221,11 @@ func mkServerInterceptor(s *GrpcServer) func(ctx context.Context,
 			return nil, status.Error(codes.Unavailable, "system is not ready")
 		}
 
+		if s.stopping.Load() {
+			logger.Warnf(ctx, "Grpc request received while stopping %v", req)
+			return nil, status.Error(codes.Unavailable, "system is stopping")
+		}
+
 		// Calls the handler
 		h, err := handler(ctx, req)
 
@@ -161,15 +233,68 @@ func mkServerInterceptor(s *GrpcServer) func(ctx context.Context,
 	}
 }
 
+// Make a stream serverInterceptor for the given GrpcServer
+// This interceptor rejects the streams opened while the server is
+// stopping with an UNAVAILABLE response.
+func mkStreamServerInterceptor(s *GrpcServer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if s.stopping.Load() {
+			logger.Warnf(ss.Context(), "Grpc stream opened while stopping %v", info.FullMethod)
+			return status.Error(codes.Unavailable, "system is stopping")
+		}
+		return handler(srv, ss)
+	}
+}
+
 /*
 Stop servicing GRPC requests
 */
 func (s *GrpcServer) Stop() {
+	s.gsLock.RLock()
+	defer s.gsLock.RUnlock()
 	if s.gs != nil {
 		s.gs.Stop()
 	}
 }
 
+/*
+GracefulStop stops servicing GRPC requests without aborting the in-flight ones.
+New requests and streams are first rejected with codes.Unavailable and the status
+callback, if any, is notified that the server is NotReady. The in-flight requests
+and streams are then drained until the context is done, at which point the server
+is stopped abruptly and the context error is returned. The abrupt stop cancels the
+context of the pending handlers and only completes once they return. The status
+callback is notified that the server is Stopped once it is.
+*/
+func (s *GrpcServer) GracefulStop(ctx context.Context) error {
+	s.stopping.Store(true)
+	s.notifyStatus(ctx, probe.ServiceStatusNotReady)
+	defer s.notifyStatus(ctx, probe.ServiceStatusStopped)
+
+	s.gsLock.RLock()
+	gs := s.gs
+	s.gsLock.RUnlock()
+	if gs == nil {
+		return nil
+	}
+
+	logger.Infow(ctx, "draining-grpc-server", log.Fields{"address": s.address})
+	drained := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		logger.Infow(ctx, "grpc-server-drained", log.Fields{"address": s.address})
+		return nil
+	case <-ctx.Done():
+		logger.Warnw(ctx, "grpc-server-drain-timeout-forcing-stop", log.Fields{"address": s.address, "error": ctx.Err()})
+		gs.Stop()
+		return ctx.Err()
+	}
+}
+
 /*
 AddService appends a generic service request function
 */
@@ -178,3 +303,47 @@ func (s *GrpcServer) AddService(
 ) {
 	s.services = append(s.services, registerFunction)
 }
+
+/*
+SetStatusCallback sets the callback notified of the status changes of the server
+during a graceful stop, e.g. to report them on a probe:
+
+	s.server.SetStatusCallback(func(ctx context.Context, status probe.ServiceStatus) {
+		p.UpdateStatus(ctx, "grpc-server", status)
+	})
+
+Must be called before GracefulStop.
+*/
+func (s *GrpcServer) SetStatusCallback(callback func(ctx context.Context, serviceStatus probe.ServiceStatus)) {
+	s.statusCallback = callback
+}
+
+func (s *GrpcServer) notifyStatus(ctx context.Context, serviceStatus probe.ServiceStatus) {
+	if s.statusCallback != nil {
+		s.statusCallback(ctx, serviceStatus)
+	}
+}
+
+/*
+AddUnaryInterceptor appends interceptors to the unary chain of the server. They
+run in the order added, after the tracing, metrics, panic recovery and readiness
+ones, and are thus not called for the requests rejected while not ready.
+Must be called before Start.
+*/
+func (s *GrpcServer) AddUnaryInterceptor(
+	interceptors ...grpc.UnaryServerInterceptor,
+) {
+	s.unaryInterceptors = append(s.unaryInterceptors, interceptors...)
+}
+
+/*
+AddStreamInterceptor appends interceptors to the stream chain of the server. They
+run in the order added, after the tracing, metrics, panic recovery and stopping
+ones.
+Must be called before Start.
+*/
+func (s *GrpcServer) AddStreamInterceptor(
+	interceptors ...grpc.StreamServerInterceptor,
+) {
+	s.streamInterceptors = append(s.streamInterceptors, interceptors...)
+}
@@ -0,0 +1,183 @@
+/*
+* Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+
+* http://www.apache.org/licenses/LICENSE-2.0
+
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package grpc
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RecoveryUnaryServerInterceptor converts a panic in a unary handler into a codes.Internal error
+// instead of letting it terminate the process
+func RecoveryUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recoverFrom(ctx, info.FullMethod, r)
+				resp = nil
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamServerInterceptor converts a panic in a stream handler into a codes.Internal error
+// instead of letting it terminate the process
+func RecoveryStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recoverFrom(ss.Context(), info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func recoverFrom(ctx context.Context, method string, r interface{}) error {
+	logger.Errorw(ctx, "grpc-handler-panic", log.Fields{"grpc-method": method, "panic": r, "stack": string(debug.Stack())})
+	log.MarkSpanError(ctx, status.Errorf(codes.Internal, "panic: %v", r))
+	return status.Errorf(codes.Internal, "internal error while handling %s", method)
+}
+
+// AccessLogUnaryServerInterceptor logs every unary request served along with its grpc code and
+// duration. When log correlation is enabled the entries carry the op-id of the request, provided
+// the interceptor runs after the opentracing one, which GrpcServer ensures.
+func AccessLogUnaryServerInterceptor(l log.CLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logAccess(ctx, l, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// AccessLogStreamServerInterceptor logs every stream served, once the stream ends
+func AccessLogStreamServerInterceptor(l log.CLogger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logAccess(ss.Context(), l, info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logAccess(ctx context.Context, l log.CLogger, method string, start time.Time, err error) {
+	fields := log.Fields{
+		"grpc-method": method,
+		"grpc-code":   status.Code(err).String(),
+		"duration":    time.Since(start).String(),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields["peer"] = p.Addr.String()
+	}
+	if err != nil {
+		fields["error"] = err
+		l.Warnw(ctx, "grpc-request-failed", fields)
+		return
+	}
+	l.Infow(ctx, "grpc-request-served", fields)
+}
+
+// ConcurrencyLimiter bounds the number of requests handled concurrently per grpc method. Requests
+// above the limit are rejected with codes.ResourceExhausted rather than queued.
+type ConcurrencyLimiter struct {
+	defaultLimit int
+	methodLimits map[string]int
+	lock         sync.Mutex
+	inFlight     map[string]int
+}
+
+// NewConcurrencyLimiter creates a limiter allowing defaultLimit concurrent requests per method,
+// unless overridden for a method (full name, e.g. "/core_service.CoreService/GetDevice") in
+// methodLimits. A limit of 0 means unlimited.
+func NewConcurrencyLimiter(defaultLimit int, methodLimits map[string]int) *ConcurrencyLimiter {
+	limits := make(map[string]int, len(methodLimits))
+	for method, limit := range methodLimits {
+		limits[method] = limit
+	}
+	return &ConcurrencyLimiter{
+		defaultLimit: defaultLimit,
+		methodLimits: limits,
+		inFlight:     make(map[string]int),
+	}
+}
+
+func (cl *ConcurrencyLimiter) limit(method string) int {
+	if limit, ok := cl.methodLimits[method]; ok {
+		return limit
+	}
+	return cl.defaultLimit
+}
+
+func (cl *ConcurrencyLimiter) acquire(ctx context.Context, method string) error {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	limit := cl.limit(method)
+	if limit > 0 && cl.inFlight[method] >= limit {
+		logger.Warnw(ctx, "grpc-request-rejected-concurrency-limit", log.Fields{"grpc-method": method, "limit": limit})
+		return status.Errorf(codes.ResourceExhausted, "too many concurrent requests for %s", method)
+	}
+	cl.inFlight[method]++
+	return nil
+}
+
+func (cl *ConcurrencyLimiter) release(method string) {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	cl.inFlight[method]--
+	if cl.inFlight[method] <= 0 {
+		delete(cl.inFlight, method)
+	}
+}
+
+// InFlight returns the number of requests of a method currently being handled
+func (cl *ConcurrencyLimiter) InFlight(method string) int {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	return cl.inFlight[method]
+}
+
+// UnaryServerInterceptor returns the interceptor enforcing the limits on unary requests
+func (cl *ConcurrencyLimiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := cl.acquire(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		defer cl.release(info.FullMethod)
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns the interceptor enforcing the limits on streams, which are
+// accounted for their whole lifetime
+func (cl *ConcurrencyLimiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := cl.acquire(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		defer cl.release(info.FullMethod)
+		return handler(srv, ss)
+	}
+}
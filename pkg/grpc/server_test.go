@@ -17,10 +17,24 @@ package grpc
 
 import (
 	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/opencord/voltha-lib-go/v7/pkg/probe"
+	"github.com/opencord/voltha-protos/v5/go/common"
+	"github.com/opencord/voltha-protos/v5/go/core_service"
+	"github.com/opencord/voltha-protos/v5/go/voltha"
+	"github.com/phayes/freeport"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 // A Mock Probe that returns the Ready member using the IsReady() func
@@ -103,3 +117,283 @@ func TestMkServerInterceptorNotReady(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Nil(t, result)
 }
+
+func TestRecoveryUnaryServerInterceptor(t *testing.T) {
+	f := RecoveryUnaryServerInterceptor()
+	serverInfo := grpc.UnaryServerInfo{Server: nil, FullMethod: "somemethod"}
+
+	result, err := f(context.Background(), "SomeRequest", &serverInfo,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			panic("handler failure")
+		})
+	assert.Nil(t, result)
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	result, err = f(context.Background(), "SomeRequest", &serverInfo, MockUnaryHandler)
+	assert.Nil(t, err)
+	assert.Equal(t, "SomeRequest", result)
+}
+
+func TestAccessLogUnaryServerInterceptor(t *testing.T) {
+	f := AccessLogUnaryServerInterceptor(logger)
+	serverInfo := grpc.UnaryServerInfo{Server: nil, FullMethod: "somemethod"}
+
+	result, err := f(context.Background(), "SomeRequest", &serverInfo, MockUnaryHandler)
+	assert.Nil(t, err)
+	assert.Equal(t, "SomeRequest", result)
+
+	handlerErr := errors.New("some-error")
+	_, err = f(context.Background(), "SomeRequest", &serverInfo,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, handlerErr
+		})
+	assert.Equal(t, handlerErr, err)
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1, map[string]int{"unlimited": 0})
+	f := limiter.UnaryServerInterceptor()
+	serverInfo := grpc.UnaryServerInfo{Server: nil, FullMethod: "somemethod"}
+
+	// Issue a request from within a request to exceed the limit of the method
+	result, err := f(context.Background(), "SomeRequest", &serverInfo,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			assert.Equal(t, 1, limiter.InFlight("somemethod"))
+			_, err := f(ctx, req, &serverInfo, MockUnaryHandler)
+			assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+			// Other methods are not limited by it
+			otherInfo := grpc.UnaryServerInfo{Server: nil, FullMethod: "unlimited"}
+			return f(ctx, req, &otherInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return f(ctx, req, &otherInfo, MockUnaryHandler)
+			})
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, "SomeRequest", result)
+	assert.Equal(t, 0, limiter.InFlight("somemethod"))
+
+	// The slot is released once the request completes
+	result, err = f(context.Background(), "SomeRequest", &serverInfo, MockUnaryHandler)
+	assert.Nil(t, err)
+	assert.Equal(t, "SomeRequest", result)
+}
+
+func TestAddInterceptors(t *testing.T) {
+	server := NewGrpcServer("127.0.0.1:1234", nil, false, nil)
+	server.AddUnaryInterceptor(RecoveryUnaryServerInterceptor(), AccessLogUnaryServerInterceptor(logger))
+	server.AddStreamInterceptor(RecoveryStreamServerInterceptor())
+	assert.Equal(t, 2, len(server.unaryInterceptors))
+	assert.Equal(t, 1, len(server.streamInterceptors))
+}
+
+// handledCount returns the number of requests of the method completed with the code, as counted
+// by the grpc prometheus server metrics
+func handledCount(t *testing.T, method string, code codes.Code) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	assert.Nil(t, err)
+	for _, family := range families {
+		if family.GetName() != "grpc_server_handled_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["grpc_method"] == method && labels["grpc_code"] == code.String() {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+// A probe whose readiness can be changed while the server is running
+type atomicReadyProbe struct {
+	ready atomic.Bool
+}
+
+func (a *atomicReadyProbe) IsReady() bool {
+	return a.ready.Load()
+}
+
+// A core service whose GetDevice requests return the device of the requested id
+type echoCoreService struct {
+	core_service.UnimplementedCoreServiceServer
+}
+
+func (e *echoCoreService) GetDevice(ctx context.Context, id *common.ID) (*voltha.Device, error) {
+	return &voltha.Device{Id: id.Id}, nil
+}
+
+func TestInterceptorChain(t *testing.T) {
+	port, err := freeport.GetFreePort()
+	assert.Nil(t, err)
+	address := "127.0.0.1:" + strconv.Itoa(port)
+
+	p := &atomicReadyProbe{}
+	server := NewGrpcServer(address, nil, false, p)
+	server.AddService(func(gs *grpc.Server) {
+		core_service.RegisterCoreServiceServer(gs, &echoCoreService{})
+	})
+	var lock sync.Mutex
+	var calls []string
+	getCalls := func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, calls...)
+	}
+	recordCall := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			lock.Lock()
+			calls = append(calls, name)
+			lock.Unlock()
+			return handler(ctx, req)
+		}
+	}
+	server.AddUnaryInterceptor(recordCall("first"), recordCall("second"))
+	go func() { assert.Nil(t, server.Start(context.Background())) }()
+	defer server.Stop()
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := core_service.NewCoreServiceClient(conn)
+
+	unavailable := handledCount(t, "GetDevice", codes.Unavailable)
+	ok := handledCount(t, "GetDevice", codes.OK)
+
+	// The requests rejected while not ready do not reach the added interceptors but are counted
+	_, err = client.GetDevice(context.Background(), &common.ID{Id: "1234"}, grpc.WaitForReady(true))
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Empty(t, getCalls())
+	assert.Equal(t, unavailable+1, handledCount(t, "GetDevice", codes.Unavailable))
+
+	// The added interceptors run in the order added
+	p.ready.Store(true)
+	device, err := client.GetDevice(context.Background(), &common.ID{Id: "1234"})
+	assert.Nil(t, err)
+	assert.Equal(t, "1234", device.Id)
+	assert.Equal(t, []string{"first", "second"}, getCalls())
+	assert.Equal(t, ok+1, handledCount(t, "GetDevice", codes.OK))
+}
+
+// A core service whose GetDevice requests block until released
+type blockingCoreService struct {
+	core_service.UnimplementedCoreServiceServer
+	received chan struct{}
+	release  chan struct{}
+}
+
+func (b *blockingCoreService) GetDevice(ctx context.Context, id *common.ID) (*voltha.Device, error) {
+	b.received <- struct{}{}
+	select {
+	case <-b.release:
+		return &voltha.Device{Id: id.Id}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func startBlockingServer(t *testing.T, p ReadyProbe) (*GrpcServer, *blockingCoreService, core_service.CoreServiceClient, chan error) {
+	port, err := freeport.GetFreePort()
+	assert.Nil(t, err)
+	address := "127.0.0.1:" + strconv.Itoa(port)
+
+	svc := &blockingCoreService{received: make(chan struct{}, 1), release: make(chan struct{})}
+	server := NewGrpcServer(address, nil, false, p)
+	server.AddService(func(gs *grpc.Server) {
+		core_service.RegisterCoreServiceServer(gs, svc)
+	})
+	go func() { assert.Nil(t, server.Start(context.Background())) }()
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	client := core_service.NewCoreServiceClient(conn)
+
+	// Issue a request that stays in flight until released
+	result := make(chan error, 1)
+	go func() {
+		_, err := client.GetDevice(context.Background(), &common.ID{Id: "1234"}, grpc.WaitForReady(true))
+		result <- err
+	}()
+	select {
+	case <-svc.received:
+	case <-time.After(10 * time.Second):
+		t.Fatal("request not received by the server")
+	}
+	return server, svc, client, result
+}
+
+func TestStartFailure(t *testing.T) {
+	server := NewGrpcServer("256.0.0.1:1234", nil, false, nil)
+	assert.NotNil(t, server.Start(context.Background()))
+}
+
+// A server stream which only provides its context
+type mockServerStream struct {
+	grpc.ServerStream
+}
+
+func (m *mockServerStream) Context() context.Context {
+	return context.Background()
+}
+
+func TestMkServerInterceptorStopping(t *testing.T) {
+	server := NewGrpcServer("127.0.0.1:1234", nil, false, nil)
+	assert.Nil(t, server.GracefulStop(context.Background()))
+
+	f := mkServerInterceptor(server)
+	serverInfo := grpc.UnaryServerInfo{Server: nil, FullMethod: "somemethod"}
+	result, err := f(context.Background(), "SomeRequest", &serverInfo, MockUnaryHandler)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Nil(t, result)
+
+	sf := mkStreamServerInterceptor(server)
+	streamInfo := grpc.StreamServerInfo{FullMethod: "somemethod", IsServerStream: true}
+	handled := false
+	err = sf(nil, &mockServerStream{}, &streamInfo, func(srv interface{}, stream grpc.ServerStream) error {
+		handled = true
+		return nil
+	})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.False(t, handled)
+
+	// A stopped server cannot be started
+	assert.NotNil(t, server.Start(context.Background()))
+}
+
+func TestGracefulStopDrains(t *testing.T) {
+	p := &probe.Probe{}
+	p.UpdateStatus(context.Background(), "test-service", probe.ServiceStatusRunning)
+	server, svc, _, result := startBlockingServer(t, p)
+	server.SetStatusCallback(func(ctx context.Context, serviceStatus probe.ServiceStatus) {
+		p.UpdateStatus(ctx, "grpc-server", serviceStatus)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() { stopped <- server.GracefulStop(ctx) }()
+
+	assert.Eventually(t, func() bool {
+		return p.GetStatus("grpc-server") == probe.ServiceStatusNotReady
+	}, time.Second, 5*time.Millisecond)
+
+	// The in-flight request completes before the server stops
+	close(svc.release)
+	assert.Nil(t, <-result)
+	assert.Nil(t, <-stopped)
+	assert.Equal(t, probe.ServiceStatusStopped, p.GetStatus("grpc-server"))
+}
+
+func TestGracefulStopTimeout(t *testing.T) {
+	server, svc, _, result := startBlockingServer(t, nil)
+	defer close(svc.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, server.GracefulStop(ctx))
+	assert.Equal(t, codes.Unavailable, status.Code(<-result))
+}
@@ -0,0 +1,170 @@
+/*
+* Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+
+* http://www.apache.org/licenses/LICENSE-2.0
+
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Endpoints are either a "host:port" TCP address, a unix domain socket given as
+// "unix:///absolute/path" or "unix:relative/path", or an in-process endpoint
+// given as "inmem://name". An in-process endpoint can only be reached from the
+// process the server runs in; it is meant for co-located components and tests.
+const (
+	unixScheme     = "unix:"
+	inMemoryScheme = "inmem://"
+
+	staleSocketDialTimeout = time.Second
+)
+
+// IsInMemoryEndpoint returns true if the endpoint designates an in-process transport
+func IsInMemoryEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, inMemoryScheme)
+}
+
+// IsUnixEndpoint returns true if the endpoint designates a unix domain socket
+func IsUnixEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, unixScheme)
+}
+
+func unixSocketPath(endpoint string) string {
+	path := strings.TrimPrefix(endpoint, unixScheme)
+	if strings.HasPrefix(path, "//") {
+		path = strings.TrimPrefix(path, "//")
+	}
+	return path
+}
+
+// listen opens the listener of a server endpoint
+func listen(endpoint string) (net.Listener, error) {
+	switch {
+	case IsInMemoryEndpoint(endpoint):
+		return newInMemoryListener(endpoint)
+	case IsUnixEndpoint(endpoint):
+		path := unixSocketPath(endpoint)
+		if err := removeStaleSocket(path); err != nil {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	default:
+		return net.Listen("tcp", endpoint)
+	}
+}
+
+// removeStaleSocket removes a socket left behind by a server that did not exit cleanly. A socket
+// still accepting connections belongs to a live server and is left in place.
+func removeStaleSocket(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+	conn, err := net.DialTimeout("unix", path, staleSocketDialTimeout)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("unix socket %s is in use", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return nil
+	}
+	return os.Remove(path)
+}
+
+// clientTarget returns the grpc target and the dial options needed to reach an endpoint
+func clientTarget(endpoint string) (string, []grpc.DialOption) {
+	if IsInMemoryEndpoint(endpoint) {
+		return "passthrough:///" + strings.TrimPrefix(endpoint, inMemoryScheme),
+			[]grpc.DialOption{grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return dialInMemory(ctx, endpoint)
+			})}
+	}
+	// Unix domain sockets are natively supported by grpc
+	return endpoint, nil
+}
+
+// The in-process transport relies on bufconn, the grpc buffered in-memory connections, rather
+// than on net.Pipe whose unbuffered writes block until the other end reads.
+const inMemoryBufferSize = 1024 * 1024
+
+var (
+	inMemoryListenersLock sync.Mutex
+	inMemoryListeners     = make(map[string]*inMemoryListener)
+)
+
+type inMemoryAddr string
+
+func (a inMemoryAddr) Network() string { return "inmem" }
+func (a inMemoryAddr) String() string  { return string(a) }
+
+// inMemoryListener is a bufconn listener registered under its endpoint until closed
+type inMemoryListener struct {
+	*bufconn.Listener
+	endpoint  string
+	closeOnce sync.Once
+}
+
+func newInMemoryListener(endpoint string) (*inMemoryListener, error) {
+	inMemoryListenersLock.Lock()
+	defer inMemoryListenersLock.Unlock()
+	if _, ok := inMemoryListeners[endpoint]; ok {
+		return nil, fmt.Errorf("in-memory endpoint %s already in use", endpoint)
+	}
+	l := &inMemoryListener{
+		Listener: bufconn.Listen(inMemoryBufferSize),
+		endpoint: endpoint,
+	}
+	inMemoryListeners[endpoint] = l
+	return l, nil
+}
+
+func (l *inMemoryListener) Close() error {
+	l.closeOnce.Do(func() {
+		inMemoryListenersLock.Lock()
+		if inMemoryListeners[l.endpoint] == l {
+			delete(inMemoryListeners, l.endpoint)
+		}
+		inMemoryListenersLock.Unlock()
+	})
+	return l.Listener.Close()
+}
+
+func (l *inMemoryListener) Addr() net.Addr {
+	return inMemoryAddr(l.endpoint)
+}
+
+func dialInMemory(ctx context.Context, endpoint string) (net.Conn, error) {
+	inMemoryListenersLock.Lock()
+	l, ok := inMemoryListeners[endpoint]
+	inMemoryListenersLock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no server listening on %s", endpoint)
+	}
+	conn, err := l.DialContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("no server listening on %s: %w", endpoint, err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,96 @@
+/*
+* Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+
+* http://www.apache.org/licenses/LICENSE-2.0
+
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package grpc
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnixSocketPath(t *testing.T) {
+	assert.True(t, IsUnixEndpoint("unix:///var/run/core.sock"))
+	assert.Equal(t, "/var/run/core.sock", unixSocketPath("unix:///var/run/core.sock"))
+	assert.Equal(t, "run/core.sock", unixSocketPath("unix:run/core.sock"))
+	assert.False(t, IsUnixEndpoint("127.0.0.1:50057"))
+}
+
+func TestUnixListener(t *testing.T) {
+	endpoint := "unix://" + filepath.Join(t.TempDir(), "test.sock")
+	l, err := listen(endpoint)
+	assert.Nil(t, err)
+	assert.Equal(t, "unix", l.Addr().Network())
+	assert.Nil(t, l.Close())
+
+	// The socket can be listened on again once closed
+	l, err = listen(endpoint)
+	assert.Nil(t, err)
+
+	// A live socket is not taken over
+	_, err = listen(endpoint)
+	assert.NotNil(t, err)
+	assert.Nil(t, l.Close())
+
+	// A stale socket, left behind by a listener that did not unlink it, is replaced
+	stale, err := net.Listen("unix", unixSocketPath(endpoint))
+	assert.Nil(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	assert.Nil(t, stale.Close())
+	l, err = listen(endpoint)
+	assert.Nil(t, err)
+	assert.Nil(t, l.Close())
+}
+
+func TestInMemoryListener(t *testing.T) {
+	endpoint := "inmem://test-listener"
+	assert.True(t, IsInMemoryEndpoint(endpoint))
+
+	_, err := dialInMemory(context.Background(), endpoint)
+	assert.NotNil(t, err)
+
+	l, err := listen(endpoint)
+	assert.Nil(t, err)
+	assert.Equal(t, endpoint, l.Addr().String())
+
+	// Only one listener per endpoint
+	_, err = listen(endpoint)
+	assert.NotNil(t, err)
+
+	accepted := make(chan error, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			buf := make([]byte, 4)
+			_, err = conn.Read(buf)
+			assert.Equal(t, "ping", string(buf))
+		}
+		accepted <- err
+	}()
+	conn, err := dialInMemory(context.Background(), endpoint)
+	assert.Nil(t, err)
+	_, err = conn.Write([]byte("ping"))
+	assert.Nil(t, err)
+	assert.Nil(t, <-accepted)
+
+	assert.Nil(t, l.Close())
+	_, err = l.Accept()
+	assert.NotNil(t, err)
+	_, err = dialInMemory(context.Background(), endpoint)
+	assert.NotNil(t, err)
+}
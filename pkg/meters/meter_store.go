@@ -0,0 +1,388 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package meters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/db"
+	"github.com/opencord/voltha-lib-go/v7/pkg/db/kvstore"
+	"github.com/opencord/voltha-lib-go/v7/pkg/flows"
+	"github.com/opencord/voltha-lib-go/v7/pkg/log"
+	ofp "github.com/opencord/voltha-protos/v5/go/openflow_13"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// meterPathPrefix holds the meter entries, keyed by meter ID
+	meterPathPrefix = "meters/"
+	// meterRefPathPrefix holds the flow references of the meters, keyed by <meterID>/<deviceID>/<flowID>
+	meterRefPathPrefix = "meter_refs/"
+)
+
+// The errors of the meter store, returned wrapped with the meter ID
+var (
+	ErrUnknownMeter = errors.New("unknown-meter")
+	ErrMeterExists  = errors.New("meter-exists")
+	ErrMeterInUse   = errors.New("meter-in-use")
+)
+
+// meterRef is a flow of a device using a meter. A logical flow is decomposed into flows of the OLT
+// and of the ONU, which reference the meter separately.
+type meterRef struct {
+	deviceID string
+	flowID   uint64
+}
+
+// MeterStore keeps the meters of a logical device along with the flows using them. The backend
+// is dedicated to the logical device, e.g. with the path prefix service/voltha/meters/<logicalDeviceID>.
+type MeterStore struct {
+	lock    sync.RWMutex
+	backend *db.Backend
+	meters  map[uint32]*ofp.OfpMeterEntry
+	refs    map[uint32]map[meterRef]bool
+}
+
+// NewMeterStore returns a store loaded with the meters and references found in the backend
+func NewMeterStore(ctx context.Context, backend *db.Backend) (*MeterStore, error) {
+	s := &MeterStore{
+		backend: backend,
+		meters:  make(map[uint32]*ofp.OfpMeterEntry),
+		refs:    make(map[uint32]map[meterRef]bool),
+	}
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *MeterStore) load(ctx context.Context) error {
+	kvPairs, err := s.backend.GetWithPrefix(ctx, meterPathPrefix)
+	if err != nil {
+		logger.Errorw(ctx, "failed-to-get-meters", log.Fields{"err": err})
+		return err
+	}
+	for keyPath, kvPair := range kvPairs {
+		value, err := kvstore.ToByte(kvPair.Value)
+		if err != nil {
+			logger.Errorw(ctx, "error-converting-kv-pair-value-to-byte", log.Fields{"err": err, "keyPath": keyPath})
+			continue
+		}
+		meter := &ofp.OfpMeterEntry{}
+		if err = proto.Unmarshal(value, meter); err != nil || meter.Config == nil {
+			logger.Errorw(ctx, "error-unmarshal-kv-pair", log.Fields{"err": err, "keyPath": keyPath})
+			continue
+		}
+		if meter.Stats == nil {
+			meter.Stats = &ofp.OfpMeterStats{MeterId: meter.Config.MeterId}
+		}
+		s.meters[meter.Config.MeterId] = meter
+	}
+
+	refKeys, err := s.backend.GetWithPrefixKeysOnly(ctx, meterRefPathPrefix)
+	if err != nil {
+		logger.Errorw(ctx, "failed-to-get-meter-refs", log.Fields{"err": err})
+		return err
+	}
+	refPathPrefix := fmt.Sprintf("%s/%s", s.backend.PathPrefix, meterRefPathPrefix)
+	for _, keyPath := range refKeys {
+		parts := strings.Split(strings.TrimPrefix(keyPath, refPathPrefix), "/")
+		if len(parts) != 3 {
+			logger.Warnw(ctx, "ignoring-invalid-meter-ref-key", log.Fields{"keyPath": keyPath})
+			continue
+		}
+		meterID, meterErr := strconv.ParseUint(parts[0], 10, 32)
+		flowID, flowErr := strconv.ParseUint(parts[2], 10, 64)
+		if meterErr != nil || flowErr != nil {
+			logger.Warnw(ctx, "ignoring-invalid-meter-ref-key", log.Fields{"keyPath": keyPath})
+			continue
+		}
+		if _, ok := s.meters[uint32(meterID)]; !ok {
+			logger.Warnw(ctx, "ignoring-ref-to-unknown-meter", log.Fields{"keyPath": keyPath})
+			continue
+		}
+		s.addRef(uint32(meterID), meterRef{deviceID: parts[1], flowID: flowID})
+	}
+	// the references are authoritative for the flow count
+	for meterID, meter := range s.meters {
+		meter.Stats.FlowCount = s.flowCount(meterID)
+	}
+	logger.Infow(ctx, "loaded-meters", log.Fields{"meters": len(s.meters), "meter-refs": len(refKeys)})
+	return nil
+}
+
+func meterKey(meterID uint32) string {
+	return fmt.Sprintf("%s%d", meterPathPrefix, meterID)
+}
+
+func meterRefKey(meterID uint32, ref meterRef) string {
+	return fmt.Sprintf("%s%d/%s/%d", meterRefPathPrefix, meterID, ref.deviceID, ref.flowID)
+}
+
+func (s *MeterStore) addRef(meterID uint32, ref meterRef) {
+	if s.refs[meterID] == nil {
+		s.refs[meterID] = make(map[meterRef]bool)
+	}
+	s.refs[meterID][ref] = true
+}
+
+// flowCount is the number of logical flows using the meter, whatever the devices they are on
+func (s *MeterStore) flowCount(meterID uint32) uint32 {
+	flowIDs := make(map[uint64]bool)
+	for ref := range s.refs[meterID] {
+		flowIDs[ref.flowID] = true
+	}
+	return uint32(len(flowIDs))
+}
+
+// putMeter must be called with the lock held
+func (s *MeterStore) putMeter(ctx context.Context, meter *ofp.OfpMeterEntry) error {
+	value, err := proto.Marshal(meter)
+	if err != nil {
+		return err
+	}
+	if err = s.backend.Put(ctx, meterKey(meter.Config.MeterId), value); err != nil {
+		logger.Errorw(ctx, "failed-to-put-meter", log.Fields{"err": err, "meter-id": meter.Config.MeterId})
+		return err
+	}
+	return nil
+}
+
+// ApplyMeterMod adds, modifies or deletes a meter. A meter is only deleted once no flow uses it,
+// and OFPM_ALL deletes all the meters no flow uses.
+func (s *MeterStore) ApplyMeterMod(ctx context.Context, meterMod *ofp.OfpMeterMod) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// the entry must not share the bands of the caller
+	meterMod = proto.Clone(meterMod).(*ofp.OfpMeterMod)
+	if meterMod.Command == ofp.OfpMeterModCommand_OFPMC_DELETE && meterMod.MeterId == uint32(ofp.OfpMeter_OFPM_ALL) {
+		return s.deleteAllMeters(ctx)
+	}
+	if meterMod.MeterId == 0 || meterMod.MeterId > uint32(ofp.OfpMeter_OFPM_MAX) {
+		return fmt.Errorf("%w: meter-id-%d", ErrInvalidMeter, meterMod.MeterId)
+	}
+	existing, exists := s.meters[meterMod.MeterId]
+	switch meterMod.Command {
+	case ofp.OfpMeterModCommand_OFPMC_ADD:
+		if exists {
+			return fmt.Errorf("%w: meter-%d", ErrMeterExists, meterMod.MeterId)
+		}
+		meter := flows.MeterEntryFromMeterMod(ctx, meterMod)
+		if err := s.putMeter(ctx, meter); err != nil {
+			return err
+		}
+		s.meters[meterMod.MeterId] = meter
+		logger.Debugw(ctx, "added-meter", log.Fields{"meter-id": meterMod.MeterId})
+	case ofp.OfpMeterModCommand_OFPMC_MODIFY:
+		if !exists {
+			return fmt.Errorf("%w: meter-%d", ErrUnknownMeter, meterMod.MeterId)
+		}
+		// the band stats start over with the new bands, the flows using the meter are kept
+		meter := flows.MeterEntryFromMeterMod(ctx, meterMod)
+		meter.Stats.FlowCount = existing.Stats.FlowCount
+		if err := s.putMeter(ctx, meter); err != nil {
+			return err
+		}
+		s.meters[meterMod.MeterId] = meter
+		logger.Debugw(ctx, "modified-meter", log.Fields{"meter-id": meterMod.MeterId})
+	case ofp.OfpMeterModCommand_OFPMC_DELETE:
+		if !exists {
+			return fmt.Errorf("%w: meter-%d", ErrUnknownMeter, meterMod.MeterId)
+		}
+		return s.deleteMeter(ctx, meterMod.MeterId)
+	default:
+		return fmt.Errorf("unsupported-meter-mod-command-%d", meterMod.Command)
+	}
+	return nil
+}
+
+// deleteMeter must be called with the lock held
+func (s *MeterStore) deleteMeter(ctx context.Context, meterID uint32) error {
+	if flowCount := s.flowCount(meterID); flowCount > 0 {
+		return fmt.Errorf("%w: meter-%d-used-by-%d-flows", ErrMeterInUse, meterID, flowCount)
+	}
+	if err := s.backend.Delete(ctx, meterKey(meterID)); err != nil {
+		logger.Errorw(ctx, "failed-to-delete-meter", log.Fields{"err": err, "meter-id": meterID})
+		return err
+	}
+	delete(s.meters, meterID)
+	delete(s.refs, meterID)
+	logger.Debugw(ctx, "deleted-meter", log.Fields{"meter-id": meterID})
+	return nil
+}
+
+// deleteAllMeters must be called with the lock held
+func (s *MeterStore) deleteAllMeters(ctx context.Context) error {
+	var inUse int
+	for meterID := range s.meters {
+		if err := s.deleteMeter(ctx, meterID); err != nil {
+			if !errors.Is(err, ErrMeterInUse) {
+				return err
+			}
+			inUse++
+		}
+	}
+	if inUse > 0 {
+		return fmt.Errorf("%w: %d-meters-kept", ErrMeterInUse, inUse)
+	}
+	return nil
+}
+
+// GetMeter returns a copy of the meter, nil if it does not exist
+func (s *MeterStore) GetMeter(meterID uint32) *ofp.OfpMeterEntry {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if meter, ok := s.meters[meterID]; ok {
+		return proto.Clone(meter).(*ofp.OfpMeterEntry)
+	}
+	return nil
+}
+
+// ListMeters returns a copy of the meters sorted by ID
+func (s *MeterStore) ListMeters() []*ofp.OfpMeterEntry {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	meters := make([]*ofp.OfpMeterEntry, 0, len(s.meters))
+	for _, meter := range s.meters {
+		meters = append(meters, proto.Clone(meter).(*ofp.OfpMeterEntry))
+	}
+	sort.Slice(meters, func(i, j int) bool { return meters[i].Config.MeterId < meters[j].Config.MeterId })
+	return meters
+}
+
+// GetDeviceRefCounts returns the number of flows using the meter per device
+func (s *MeterStore) GetDeviceRefCounts(meterID uint32) map[string]uint32 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	counts := make(map[string]uint32)
+	for ref := range s.refs[meterID] {
+		counts[ref.deviceID]++
+	}
+	return counts
+}
+
+// AddFlowRef records that a flow of a device uses the meter, which must exist. Adding the same
+// reference again has no effect.
+func (s *MeterStore) AddFlowRef(ctx context.Context, meterID uint32, flowID uint64, deviceID string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	meter, ok := s.meters[meterID]
+	if !ok {
+		return fmt.Errorf("%w: meter-%d", ErrUnknownMeter, meterID)
+	}
+	ref := meterRef{deviceID: deviceID, flowID: flowID}
+	if s.refs[meterID][ref] {
+		return nil
+	}
+	if err := s.backend.Put(ctx, meterRefKey(meterID, ref), ""); err != nil {
+		logger.Errorw(ctx, "failed-to-put-meter-ref", log.Fields{"err": err, "meter-id": meterID, "flow-id": flowID, "device-id": deviceID})
+		return err
+	}
+	s.addRef(meterID, ref)
+	return s.updateFlowCount(ctx, meter)
+}
+
+// RemoveFlowRef forgets that a flow of a device uses the meter
+func (s *MeterStore) RemoveFlowRef(ctx context.Context, meterID uint32, flowID uint64, deviceID string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.removeFlowRef(ctx, meterID, meterRef{deviceID: deviceID, flowID: flowID})
+}
+
+// removeFlowRef must be called with the lock held
+func (s *MeterStore) removeFlowRef(ctx context.Context, meterID uint32, ref meterRef) error {
+	if !s.refs[meterID][ref] {
+		return nil
+	}
+	if err := s.backend.Delete(ctx, meterRefKey(meterID, ref)); err != nil {
+		logger.Errorw(ctx, "failed-to-delete-meter-ref", log.Fields{"err": err, "meter-id": meterID, "flow-id": ref.flowID, "device-id": ref.deviceID})
+		return err
+	}
+	delete(s.refs[meterID], ref)
+	return s.updateFlowCount(ctx, s.meters[meterID])
+}
+
+// updateFlowCount must be called with the lock held
+func (s *MeterStore) updateFlowCount(ctx context.Context, meter *ofp.OfpMeterEntry) error {
+	flowCount := s.flowCount(meter.Config.MeterId)
+	if meter.Stats.FlowCount == flowCount {
+		return nil
+	}
+	meter.Stats.FlowCount = flowCount
+	return s.putMeter(ctx, meter)
+}
+
+// flowMeterID returns the meter of the meter instruction of the flow. Without meter instruction, e.g.
+// for the flows the ONOS OLT pipeline hands to the ONU, the meter is taken from the lower 4 bytes of
+// the write metadata instruction. These hold a port number on other flows, so they are only taken as
+// a meter ID if the store knows such a meter.
+func (s *MeterStore) flowMeterID(ctx context.Context, flow *ofp.OfpFlowStats) uint32 {
+	if meterID := flows.GetMeterIdFromFlow(flow); meterID != 0 {
+		return meterID
+	}
+	meterID := flows.GetMeterIdFromWriteMetadata(ctx, flow)
+	if meterID == 0 {
+		return 0
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if _, ok := s.meters[meterID]; !ok {
+		return 0
+	}
+	return meterID
+}
+
+// AddFlow records the meter of the flow, if any, see flowMeterID. Callers knowing the meter of a
+// flow better, e.g. from the logical flow it was decomposed from, use AddFlowRef instead.
+func (s *MeterStore) AddFlow(ctx context.Context, flow *ofp.OfpFlowStats, deviceID string) error {
+	if meterID := s.flowMeterID(ctx, flow); meterID != 0 {
+		return s.AddFlowRef(ctx, meterID, flow.Id, deviceID)
+	}
+	return nil
+}
+
+// RemoveFlow forgets the meter of the flow, if any, see flowMeterID
+func (s *MeterStore) RemoveFlow(ctx context.Context, flow *ofp.OfpFlowStats, deviceID string) error {
+	if meterID := s.flowMeterID(ctx, flow); meterID != 0 {
+		return s.RemoveFlowRef(ctx, meterID, flow.Id, deviceID)
+	}
+	return nil
+}
+
+// RemoveDeviceRefs forgets the flows of a device, e.g. when it is deleted
+func (s *MeterStore) RemoveDeviceRefs(ctx context.Context, deviceID string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for meterID, refs := range s.refs {
+		for ref := range refs {
+			if ref.deviceID != deviceID {
+				continue
+			}
+			if err := s.removeFlowRef(ctx, meterID, ref); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package meters
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/db"
+	"github.com/opencord/voltha-lib-go/v7/pkg/flows"
+	mock_kvstore "github.com/opencord/voltha-lib-go/v7/pkg/mocks/kvstore"
+	ofp "github.com/opencord/voltha-protos/v5/go/openflow_13"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMeterStore(t *testing.T, ctx context.Context, kv *mock_kvstore.KVClient) *MeterStore {
+	store, err := NewMeterStore(ctx, &db.Backend{Client: kv, PathPrefix: "service/voltha/meters/ld1"})
+	assert.Nil(t, err)
+	return store
+}
+
+func meterMod(command ofp.OfpMeterModCommand, meterID uint32, rateBursts ...uint32) *ofp.OfpMeterMod {
+	return &ofp.OfpMeterMod{Command: command, MeterId: meterID,
+		Flags: uint32(ofp.OfpMeterFlags_OFPMF_KBPS | ofp.OfpMeterFlags_OFPMF_BURST), Bands: bands(rateBursts...)}
+}
+
+func TestMeterStoreMeterMods(t *testing.T) {
+	ctx := context.Background()
+	store := newTestMeterStore(t, ctx, mock_kvstore.NewKVClient())
+
+	assert.Nil(t, store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_ADD, 1, 10000, 1000)))
+	assert.Nil(t, store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_ADD, 2, 20000, 0)))
+	err := store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_ADD, 1, 10000, 1000))
+	assert.True(t, errors.Is(err, ErrMeterExists))
+	err = store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_ADD, 0, 10000, 1000))
+	assert.True(t, errors.Is(err, ErrInvalidMeter))
+
+	assert.Nil(t, store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_MODIFY, 1, 10000, 1000, 30000, 3000)))
+	meter := store.GetMeter(1)
+	assert.Len(t, meter.Config.Bands, 2)
+	assert.Len(t, meter.Stats.BandStats, 2)
+	err = store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_MODIFY, 3, 10000, 1000))
+	assert.True(t, errors.Is(err, ErrUnknownMeter))
+
+	meters := store.ListMeters()
+	assert.Len(t, meters, 2)
+	assert.Equal(t, uint32(1), meters[0].Config.MeterId)
+	assert.Equal(t, uint32(2), meters[1].Config.MeterId)
+
+	assert.Nil(t, store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_DELETE, 2)))
+	assert.Nil(t, store.GetMeter(2))
+	err = store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_DELETE, 2))
+	assert.True(t, errors.Is(err, ErrUnknownMeter))
+}
+
+func TestMeterStoreRefCounts(t *testing.T) {
+	ctx := context.Background()
+	kv := mock_kvstore.NewKVClient()
+	store := newTestMeterStore(t, ctx, kv)
+	assert.Nil(t, store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_ADD, 1, 10000, 1000)))
+	assert.Nil(t, store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_ADD, 2, 20000, 0)))
+
+	// a logical flow decomposed on the OLT and the ONU counts once
+	flow := &ofp.OfpFlowStats{Id: 100, Instructions: []*ofp.OfpInstruction{{
+		Type: uint32(flows.METER_ACTION), Data: &ofp.OfpInstruction_Meter{Meter: &ofp.OfpInstructionMeter{MeterId: 1}}}}}
+	assert.Nil(t, store.AddFlow(ctx, flow, "olt"))
+	assert.Nil(t, store.AddFlow(ctx, flow, "onu1"))
+	assert.Nil(t, store.AddFlow(ctx, flow, "onu1"))
+	assert.Nil(t, store.AddFlowRef(ctx, 1, 101, "onu1"))
+	assert.Equal(t, uint32(2), store.GetMeter(1).Stats.FlowCount)
+	assert.Equal(t, map[string]uint32{"olt": 1, "onu1": 2}, store.GetDeviceRefCounts(1))
+	assert.True(t, errors.Is(store.AddFlowRef(ctx, 3, 102, "onu1"), ErrUnknownMeter))
+	// flows without meter are ignored
+	assert.Nil(t, store.AddFlow(ctx, &ofp.OfpFlowStats{Id: 103}, "olt"))
+
+	err := store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_DELETE, 1))
+	assert.True(t, errors.Is(err, ErrMeterInUse))
+	err = store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_DELETE, uint32(ofp.OfpMeter_OFPM_ALL)))
+	assert.True(t, errors.Is(err, ErrMeterInUse))
+	assert.NotNil(t, store.GetMeter(1))
+	assert.Nil(t, store.GetMeter(2))
+
+	// modifying a meter keeps its flows
+	assert.Nil(t, store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_MODIFY, 1, 20000, 2000)))
+	assert.Equal(t, uint32(2), store.GetMeter(1).Stats.FlowCount)
+
+	// a new store reads the meters and references back
+	reloaded := newTestMeterStore(t, ctx, kv)
+	assert.Equal(t, uint32(2), reloaded.GetMeter(1).Stats.FlowCount)
+	assert.Equal(t, uint32(20000), reloaded.GetMeter(1).Config.Bands[0].Rate)
+	assert.Equal(t, map[string]uint32{"olt": 1, "onu1": 2}, reloaded.GetDeviceRefCounts(1))
+
+	assert.Nil(t, store.RemoveDeviceRefs(ctx, "onu1"))
+	assert.Equal(t, uint32(1), store.GetMeter(1).Stats.FlowCount)
+	assert.Nil(t, store.RemoveFlow(ctx, flow, "olt"))
+	assert.Equal(t, uint32(0), store.GetMeter(1).Stats.FlowCount)
+	assert.Nil(t, store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_DELETE, 1)))
+	keys, _ := kv.GetWithPrefixKeysOnly(ctx, "")
+	assert.Empty(t, keys)
+}
+
+func TestMeterStoreWriteMetadataMeter(t *testing.T) {
+	ctx := context.Background()
+	store := newTestMeterStore(t, ctx, mock_kvstore.NewKVClient())
+	assert.Nil(t, store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_ADD, 1, 10000, 1000)))
+
+	writeMetadata := func(flowID uint64, metadata uint64) *ofp.OfpFlowStats {
+		return &ofp.OfpFlowStats{Id: flowID, Instructions: []*ofp.OfpInstruction{{
+			Type: uint32(flows.WRITE_METADATA), Data: &ofp.OfpInstruction_WriteMetadata{WriteMetadata: &ofp.OfpInstructionWriteMetadata{Metadata: metadata}}}}}
+	}
+	// the write metadata holds the meter, or a port number that is not a meter
+	assert.Nil(t, store.AddFlow(ctx, writeMetadata(100, 0x0064004000000001), "onu1"))
+	assert.Nil(t, store.AddFlow(ctx, writeMetadata(101, 0x0064004000000010), "onu1"))
+	assert.Equal(t, uint32(1), store.GetMeter(1).Stats.FlowCount)
+	assert.Equal(t, map[string]uint32{"onu1": 1}, store.GetDeviceRefCounts(1))
+
+	assert.Nil(t, store.RemoveFlow(ctx, writeMetadata(100, 0x0064004000000001), "onu1"))
+	assert.Equal(t, uint32(0), store.GetMeter(1).Stats.FlowCount)
+}
+
+func TestMeterStoreKVFailure(t *testing.T) {
+	ctx := context.Background()
+	kv := mock_kvstore.NewKVClient()
+	store := newTestMeterStore(t, ctx, kv)
+	assert.Nil(t, store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_ADD, 1, 10000, 1000)))
+
+	kv.SetFailPut(func(key string) bool { return strings.Contains(key, "/meter_refs/") })
+	assert.NotNil(t, store.AddFlowRef(ctx, 1, 100, "olt"))
+	assert.Equal(t, uint32(0), store.GetMeter(1).Stats.FlowCount)
+	assert.Nil(t, store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_DELETE, 1)))
+
+	kv.SetFailPut(func(key string) bool { return true })
+	assert.NotNil(t, store.ApplyMeterMod(ctx, meterMod(ofp.OfpMeterModCommand_OFPMC_ADD, 2, 10000, 1000)))
+	assert.Nil(t, store.GetMeter(2))
+}
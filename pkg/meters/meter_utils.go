@@ -24,9 +24,29 @@ import (
 	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
 )
 
-// GetTrafficShapingInfo returns CIR,PIR and GIR values
+// GetTrafficShapingInfo returns CIR,PIR and GIR values. The bands are told apart by their burst size,
+// a band without burst size being the GIR, and the resulting rates are validated. Only the GIR band of
+// a 3 band meter may have a zero rate.
 func GetTrafficShapingInfo(ctx context.Context, meterConfig *ofp.OfpMeterConfig) (*tp_pb.TrafficShapingInfo, error) {
+	shapingInfo, err := getTrafficShapingInfo(meterConfig)
+	if err == nil {
+		err = ValidateTrafficShapingInfo(shapingInfo)
+	}
+	if err != nil {
+		logger.Errorw(ctx, "invalid-meter-config", log.Fields{"meter-config": meterConfig, "err": err})
+		return nil, fmt.Errorf("%w: meter-%d", err, meterConfig.MeterId)
+	}
+	return shapingInfo, nil
+}
+
+func getTrafficShapingInfo(meterConfig *ofp.OfpMeterConfig) (*tp_pb.TrafficShapingInfo, error) {
 	meterBandSize := len(meterConfig.Bands)
+	for i, band := range meterConfig.Bands {
+		// the GIR band of a 3 band meter may have no rate, the GIR is then 0
+		if band.Rate == 0 && !(meterBandSize == 3 && band.BurstSize == 0) {
+			return nil, fmt.Errorf("%w: band-%d", ErrZeroRate, i)
+		}
+	}
 	switch meterBandSize {
 	case 1:
 		band := meterConfig.Bands[0]
@@ -41,11 +61,12 @@ func GetTrafficShapingInfo(ctx context.Context, meterConfig *ofp.OfpMeterConfig)
 			return &tp_pb.TrafficShapingInfo{Pir: firstBand.Rate, Gir: secondBand.Rate}, nil
 		}
 		if firstBand.BurstSize > 0 && secondBand.BurstSize > 0 { // PIR, CIR, tcont type 2 or 3
-			if firstBand.Rate > secondBand.Rate { // always PIR >= CIR
+			if firstBand.Rate >= secondBand.Rate { // always PIR >= CIR, PIR first on equal rates
 				return &tp_pb.TrafficShapingInfo{Pir: firstBand.Rate, Pbs: firstBand.BurstSize, Cir: secondBand.Rate, Cbs: secondBand.BurstSize}, nil
 			}
 			return &tp_pb.TrafficShapingInfo{Pir: secondBand.Rate, Pbs: secondBand.BurstSize, Cir: firstBand.Rate, Cbs: firstBand.BurstSize}, nil
 		}
+		return nil, fmt.Errorf("%w: 2-bands-need-either-both-or-no-burst-size", ErrAmbiguousBands)
 	case 3: // PIR,CIR,GIR, tcont type 5
 		var count, girIndex int
 		for i, band := range meterConfig.Bands {
@@ -59,14 +80,12 @@ func GetTrafficShapingInfo(ctx context.Context, meterConfig *ofp.OfpMeterConfig)
 			copy(bands, meterConfig.Bands)
 			pirCirBands := append(bands[:girIndex], bands[girIndex+1:]...)
 			firstBand, secondBand := pirCirBands[0], pirCirBands[1]
-			if firstBand.Rate > secondBand.Rate {
+			if firstBand.Rate >= secondBand.Rate {
 				return &tp_pb.TrafficShapingInfo{Pir: firstBand.Rate, Pbs: firstBand.BurstSize, Cir: secondBand.Rate, Cbs: secondBand.BurstSize, Gir: meterConfig.Bands[girIndex].Rate}, nil
 			}
 			return &tp_pb.TrafficShapingInfo{Pir: secondBand.Rate, Pbs: secondBand.BurstSize, Cir: firstBand.Rate, Cbs: firstBand.BurstSize, Gir: meterConfig.Bands[girIndex].Rate}, nil
 		}
-	default:
-		logger.Errorw(ctx, "invalid-meter-config", log.Fields{"meter-config": meterConfig})
-		return nil, fmt.Errorf("invalid-meter-config: %v", meterConfig)
+		return nil, fmt.Errorf("%w: 3-bands-need-one-gir-band-got-%d", ErrAmbiguousBands, count)
 	}
-	return nil, fmt.Errorf("invalid-meter-config: %v", meterConfig)
+	return nil, fmt.Errorf("%w: %d", ErrUnsupportedBandCount, meterBandSize)
 }
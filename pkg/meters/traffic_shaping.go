@@ -0,0 +1,169 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package meters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/log"
+	ofp "github.com/opencord/voltha-protos/v5/go/openflow_13"
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+)
+
+// ErrInvalidMeter is wrapped by every error about a meter or traffic shaping that cannot be used
+var ErrInvalidMeter = errors.New("invalid-meter-config")
+
+// The specific problems found with a meter, errors.Is matches them as well as ErrInvalidMeter
+var (
+	ErrUnsupportedBandCount = fmt.Errorf("%w: unsupported-number-of-bands", ErrInvalidMeter)
+	ErrAmbiguousBands       = fmt.Errorf("%w: bands-match-no-tcont-type", ErrInvalidMeter)
+	ErrZeroRate             = fmt.Errorf("%w: zero-rate", ErrInvalidMeter)
+	ErrZeroBurstSize        = fmt.Errorf("%w: zero-burst-size", ErrInvalidMeter)
+	ErrGirAboveCir          = fmt.Errorf("%w: gir-above-cir", ErrInvalidMeter)
+	ErrGirAbovePir          = fmt.Errorf("%w: gir-above-pir", ErrInvalidMeter)
+	ErrCirAbovePir          = fmt.Errorf("%w: cir-above-pir", ErrInvalidMeter)
+	ErrAdditionalBWMismatch = fmt.Errorf("%w: additional-bw-mismatch", ErrInvalidMeter)
+)
+
+// TcontType is the T-CONT type (G.983.4) a traffic shaping corresponds to
+type TcontType uint32
+
+const (
+	// TcontType1 is fixed bandwidth only, GIR = PIR
+	TcontType1 TcontType = 1
+	// TcontType2 is assured bandwidth only, CIR = PIR
+	TcontType2 TcontType = 2
+	// TcontType3 is assured bandwidth plus non-assured bandwidth up to PIR
+	TcontType3 TcontType = 3
+	// TcontType4 is best effort bandwidth only, up to PIR
+	TcontType4 TcontType = 4
+	// TcontType5 is fixed and assured bandwidth plus additional bandwidth up to PIR
+	TcontType5 TcontType = 5
+)
+
+func (t TcontType) String() string {
+	return fmt.Sprintf("tcont-type-%d", uint32(t))
+}
+
+// ValidateTrafficShapingInfo checks the relationship of the rates: PIR is set, and the GIR is part of
+// the CIR unless the T-CONT is of type 1, where the CIR is not set and GIR = PIR
+func ValidateTrafficShapingInfo(shapingInfo *tp_pb.TrafficShapingInfo) error {
+	if shapingInfo.Pir == 0 {
+		return fmt.Errorf("%w: pir", ErrZeroRate)
+	}
+	if shapingInfo.Cir > shapingInfo.Pir {
+		return fmt.Errorf("%w: cir-%d-pir-%d", ErrCirAbovePir, shapingInfo.Cir, shapingInfo.Pir)
+	}
+	if shapingInfo.Gir > shapingInfo.Pir {
+		return fmt.Errorf("%w: gir-%d-pir-%d", ErrGirAbovePir, shapingInfo.Gir, shapingInfo.Pir)
+	}
+	if shapingInfo.Gir > shapingInfo.Cir && !(shapingInfo.Cir == 0 && shapingInfo.Gir == shapingInfo.Pir) {
+		return fmt.Errorf("%w: gir-%d-cir-%d", ErrGirAboveCir, shapingInfo.Gir, shapingInfo.Cir)
+	}
+	return nil
+}
+
+// GetTcontType returns the T-CONT type of a valid traffic shaping
+func GetTcontType(shapingInfo *tp_pb.TrafficShapingInfo) (TcontType, error) {
+	if err := ValidateTrafficShapingInfo(shapingInfo); err != nil {
+		return 0, err
+	}
+	switch {
+	case shapingInfo.Cir == 0 && shapingInfo.Gir == 0:
+		return TcontType4, nil
+	case shapingInfo.Cir == 0:
+		return TcontType1, nil
+	case shapingInfo.Gir > 0:
+		return TcontType5, nil
+	case shapingInfo.Cir == shapingInfo.Pir:
+		return TcontType2, nil
+	}
+	return TcontType3, nil
+}
+
+// GetMeterConfig returns the meter GetTrafficShapingInfo maps back to the traffic shaping, with a
+// band per rate in kb/s: the GIR band has no burst size, the others need one. The burst sizes of a
+// type 1 T-CONT are not carried.
+func GetMeterConfig(meterID uint32, shapingInfo *tp_pb.TrafficShapingInfo) (*ofp.OfpMeterConfig, error) {
+	tcontType, err := GetTcontType(shapingInfo)
+	if err != nil {
+		return nil, err
+	}
+	meterConfig := &ofp.OfpMeterConfig{
+		Flags:   uint32(ofp.OfpMeterFlags_OFPMF_KBPS | ofp.OfpMeterFlags_OFPMF_BURST),
+		MeterId: meterID,
+	}
+	if tcontType == TcontType1 {
+		meterConfig.Bands = append(meterConfig.Bands, newDropBand(shapingInfo.Gir, 0))
+		return meterConfig, nil
+	}
+	if shapingInfo.Pbs == 0 {
+		return nil, fmt.Errorf("%w: pbs-of-%s", ErrZeroBurstSize, tcontType)
+	}
+	meterConfig.Bands = append(meterConfig.Bands, newDropBand(shapingInfo.Pir, shapingInfo.Pbs))
+	if shapingInfo.Cir > 0 {
+		if shapingInfo.Cbs == 0 {
+			return nil, fmt.Errorf("%w: cbs-of-%s", ErrZeroBurstSize, tcontType)
+		}
+		meterConfig.Bands = append(meterConfig.Bands, newDropBand(shapingInfo.Cir, shapingInfo.Cbs))
+	}
+	if shapingInfo.Gir > 0 {
+		meterConfig.Bands = append(meterConfig.Bands, newDropBand(shapingInfo.Gir, 0))
+	}
+	return meterConfig, nil
+}
+
+func newDropBand(rate uint32, burstSize uint32) *ofp.OfpMeterBandHeader {
+	return &ofp.OfpMeterBandHeader{
+		Type:      ofp.OfpMeterBandType_OFPMBT_DROP,
+		Rate:      rate,
+		BurstSize: burstSize,
+		Data:      &ofp.OfpMeterBandHeader_Drop{Drop: &ofp.OfpMeterBandDrop{}},
+	}
+}
+
+// additionalBWTcontTypes are the T-CONT types that fit the additional bandwidth of an upstream
+// scheduler. Type 5 combines assured with either kind of additional bandwidth.
+var additionalBWTcontTypes = map[tp_pb.AdditionalBW][]TcontType{
+	tp_pb.AdditionalBW_AdditionalBW_None:       {TcontType1, TcontType2},
+	tp_pb.AdditionalBW_AdditionalBW_NA:         {TcontType3, TcontType5},
+	tp_pb.AdditionalBW_AdditionalBW_BestEffort: {TcontType4, TcontType5},
+	tp_pb.AdditionalBW_AdditionalBW_Auto:       {TcontType1, TcontType2, TcontType3, TcontType4, TcontType5},
+}
+
+// CheckAdditionalBW returns an error if the meter cannot shape the traffic of the upstream scheduler
+// of a TP instance, i.e. its T-CONT type does not fit the additional bandwidth of the scheduler
+func CheckAdditionalBW(ctx context.Context, meterConfig *ofp.OfpMeterConfig, usScheduler *tp_pb.SchedulerAttributes) error {
+	if usScheduler.Direction != tp_pb.Direction_UPSTREAM {
+		return fmt.Errorf("scheduler-direction-%s-is-not-upstream", usScheduler.Direction)
+	}
+	shapingInfo, err := GetTrafficShapingInfo(ctx, meterConfig)
+	if err != nil {
+		return err
+	}
+	tcontType, _ := GetTcontType(shapingInfo)
+	for _, compatible := range additionalBWTcontTypes[usScheduler.AdditionalBw] {
+		if tcontType == compatible {
+			return nil
+		}
+	}
+	logger.Errorw(ctx, "meter-does-not-fit-additional-bw", log.Fields{"meter-id": meterConfig.MeterId,
+		"tcont-type": tcontType, "additional-bw": usScheduler.AdditionalBw})
+	return fmt.Errorf("%w: meter-%d-%s-with-%s", ErrAdditionalBWMismatch, meterConfig.MeterId, tcontType, usScheduler.AdditionalBw)
+}
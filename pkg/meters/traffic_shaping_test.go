@@ -0,0 +1,162 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package meters
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	ofp "github.com/opencord/voltha-protos/v5/go/openflow_13"
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func bands(rateBursts ...uint32) []*ofp.OfpMeterBandHeader {
+	var headers []*ofp.OfpMeterBandHeader
+	for i := 0; i < len(rateBursts); i += 2 {
+		headers = append(headers, &ofp.OfpMeterBandHeader{Rate: rateBursts[i], BurstSize: rateBursts[i+1]})
+	}
+	return headers
+}
+
+func TestGetTrafficShapingInfoErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		bands []*ofp.OfpMeterBandHeader
+		err   error
+	}{
+		{"no band", nil, ErrUnsupportedBandCount},
+		{"four bands", bands(1000, 0, 2000, 100, 3000, 100, 4000, 100), ErrUnsupportedBandCount},
+		{"zero rate", bands(0, 100), ErrZeroRate},
+		{"zero gir rate of 1 band", bands(0, 0), ErrZeroRate},
+		{"zero cir rate of 3 bands", bands(10000, 0, 0, 4000, 30000, 5000), ErrZeroRate},
+		{"gir and pir only", bands(10000, 0, 20000, 4000), ErrAmbiguousBands},
+		{"two gir bands", bands(10000, 0, 20000, 0, 30000, 5000), ErrAmbiguousBands},
+		{"gir above cir", bands(25000, 0, 20000, 4000, 30000, 5000), ErrGirAboveCir},
+		{"gir above pir", bands(40000, 0, 20000, 4000, 30000, 5000), ErrGirAbovePir},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shapingInfo, err := GetTrafficShapingInfo(context.Background(), &ofp.OfpMeterConfig{MeterId: 1, Bands: tt.bands})
+			assert.Nil(t, shapingInfo)
+			assert.True(t, errors.Is(err, tt.err), "got %v", err)
+			assert.True(t, errors.Is(err, ErrInvalidMeter))
+		})
+	}
+}
+
+func TestGetTrafficShapingInfoZeroGir(t *testing.T) {
+	shapingInfo, err := GetTrafficShapingInfo(context.Background(), &ofp.OfpMeterConfig{MeterId: 1, Bands: bands(20000, 4000, 0, 0, 30000, 5000)})
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(&tp_pb.TrafficShapingInfo{Pir: 30000, Pbs: 5000, Cir: 20000, Cbs: 4000}, shapingInfo), "got %v", shapingInfo)
+}
+
+func TestValidateTrafficShapingInfo(t *testing.T) {
+	assert.Nil(t, ValidateTrafficShapingInfo(&tp_pb.TrafficShapingInfo{Pir: 10000, Gir: 10000}))
+	assert.Nil(t, ValidateTrafficShapingInfo(&tp_pb.TrafficShapingInfo{Pir: 30000, Cir: 20000, Gir: 20000}))
+	assert.True(t, errors.Is(ValidateTrafficShapingInfo(&tp_pb.TrafficShapingInfo{}), ErrZeroRate))
+	assert.True(t, errors.Is(ValidateTrafficShapingInfo(&tp_pb.TrafficShapingInfo{Pir: 10000, Cir: 20000}), ErrCirAbovePir))
+	assert.True(t, errors.Is(ValidateTrafficShapingInfo(&tp_pb.TrafficShapingInfo{Pir: 20000, Gir: 10000}), ErrGirAboveCir))
+}
+
+func TestTrafficShapingRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		shapingInfo *tp_pb.TrafficShapingInfo
+		tcontType   TcontType
+		numBands    int
+	}{
+		{"type 1", &tp_pb.TrafficShapingInfo{Pir: 10000, Gir: 10000}, TcontType1, 1},
+		{"type 2", &tp_pb.TrafficShapingInfo{Pir: 20000, Pbs: 3000, Cir: 20000, Cbs: 2000}, TcontType2, 2},
+		{"type 3", &tp_pb.TrafficShapingInfo{Pir: 30000, Pbs: 3000, Cir: 10000, Cbs: 2000}, TcontType3, 2},
+		{"type 4", &tp_pb.TrafficShapingInfo{Pir: 10000, Pbs: 1000}, TcontType4, 1},
+		{"type 5", &tp_pb.TrafficShapingInfo{Pir: 30000, Pbs: 5000, Cir: 20000, Cbs: 4000, Gir: 10000}, TcontType5, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tcontType, err := GetTcontType(tt.shapingInfo)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.tcontType, tcontType)
+
+			meterConfig, err := GetMeterConfig(7, tt.shapingInfo)
+			assert.Nil(t, err)
+			assert.Equal(t, uint32(7), meterConfig.MeterId)
+			assert.Equal(t, uint32(ofp.OfpMeterFlags_OFPMF_KBPS|ofp.OfpMeterFlags_OFPMF_BURST), meterConfig.Flags)
+			assert.Len(t, meterConfig.Bands, tt.numBands)
+
+			shapingInfo, err := GetTrafficShapingInfo(context.Background(), meterConfig)
+			assert.Nil(t, err)
+			assert.True(t, proto.Equal(tt.shapingInfo, shapingInfo), "got %v", shapingInfo)
+		})
+	}
+
+	// without burst size the PIR and CIR bands would be read back as GIR bands
+	_, err := GetMeterConfig(7, &tp_pb.TrafficShapingInfo{Pir: 10000})
+	assert.True(t, errors.Is(err, ErrZeroBurstSize))
+	_, err = GetMeterConfig(7, &tp_pb.TrafficShapingInfo{Pir: 30000, Pbs: 3000, Cir: 10000})
+	assert.True(t, errors.Is(err, ErrZeroBurstSize))
+}
+
+func TestCheckAdditionalBW(t *testing.T) {
+	meterOfType := map[TcontType]*ofp.OfpMeterConfig{
+		TcontType1: {MeterId: 1, Bands: bands(10000, 0)},
+		TcontType2: {MeterId: 2, Bands: bands(20000, 2000, 20000, 3000)},
+		TcontType3: {MeterId: 3, Bands: bands(10000, 2000, 30000, 3000)},
+		TcontType4: {MeterId: 4, Bands: bands(10000, 1000)},
+		TcontType5: {MeterId: 5, Bands: bands(10000, 0, 20000, 4000, 30000, 5000)},
+	}
+	tests := []struct {
+		additionalBw tp_pb.AdditionalBW
+		compatible   []TcontType
+	}{
+		{tp_pb.AdditionalBW_AdditionalBW_None, []TcontType{TcontType1, TcontType2}},
+		{tp_pb.AdditionalBW_AdditionalBW_NA, []TcontType{TcontType3, TcontType5}},
+		{tp_pb.AdditionalBW_AdditionalBW_BestEffort, []TcontType{TcontType4, TcontType5}},
+		{tp_pb.AdditionalBW_AdditionalBW_Auto, []TcontType{TcontType1, TcontType2, TcontType3, TcontType4, TcontType5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.additionalBw.String(), func(t *testing.T) {
+			usScheduler := &tp_pb.SchedulerAttributes{Direction: tp_pb.Direction_UPSTREAM, AdditionalBw: tt.additionalBw}
+			for tcontType, meterConfig := range meterOfType {
+				err := CheckAdditionalBW(context.Background(), meterConfig, usScheduler)
+				if containsTcontType(tt.compatible, tcontType) {
+					assert.Nil(t, err, tcontType.String())
+				} else {
+					assert.True(t, errors.Is(err, ErrAdditionalBWMismatch), tcontType.String())
+				}
+			}
+		})
+	}
+
+	err := CheckAdditionalBW(context.Background(), meterOfType[TcontType1],
+		&tp_pb.SchedulerAttributes{Direction: tp_pb.Direction_DOWNSTREAM})
+	assert.NotNil(t, err)
+	err = CheckAdditionalBW(context.Background(), &ofp.OfpMeterConfig{MeterId: 6},
+		&tp_pb.SchedulerAttributes{Direction: tp_pb.Direction_UPSTREAM})
+	assert.True(t, errors.Is(err, ErrUnsupportedBandCount))
+}
+
+func containsTcontType(tcontTypes []TcontType, tcontType TcontType) bool {
+	for _, t := range tcontTypes {
+		if t == tcontType {
+			return true
+		}
+	}
+	return false
+}
@@ -74,7 +74,9 @@ func (s *MockGRPCServer) AddAdapterService(ctx context.Context, srv adapter_serv
 
 func (s *MockGRPCServer) Start(ctx context.Context) {
 	s.probe.UpdateStatus(ctx, mockGrpcServer, probe.ServiceStatusRunning)
-	s.server.Start(ctx)
+	if err := s.server.Start(ctx); err != nil {
+		logger.Errorw(ctx, "mock-grpc-server-start-failed", log.Fields{"endpoint": s.ApiEndpoint, "error": err})
+	}
 	s.probe.UpdateStatus(ctx, mockGrpcServer, probe.ServiceStatusStopped)
 }
 
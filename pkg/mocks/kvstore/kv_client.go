@@ -0,0 +1,227 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kvstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/db/kvstore"
+)
+
+// static check to ensure KVClient implements kvstore.Client and kvstore.TxnClient
+var _ kvstore.Client = &KVClient{}
+var _ kvstore.TxnClient = &KVClient{}
+
+// KVClient is a map backed kvstore.Client behaving like etcd for the calls used by the library:
+// values are stored as []byte, List matches on key prefix and watches see the puts and deletes
+// done after they were created. Reservations and locks are not supported.
+type KVClient struct {
+	lock     sync.RWMutex
+	data     map[string][]byte
+	watchers map[chan *kvstore.Event]kvWatcher
+	// when set, Put and Txn fail for the keys it returns true for
+	failPut func(key string) bool
+}
+
+type kvWatcher struct {
+	key        string
+	withPrefix bool
+}
+
+// NewKVClient returns an empty in-memory KV client
+func NewKVClient() *KVClient {
+	return &KVClient{data: make(map[string][]byte), watchers: make(map[chan *kvstore.Event]kvWatcher)}
+}
+
+// SetFailPut makes Put, and the transactions putting them, fail for the keys failPut returns true
+// for. A nil failPut makes them succeed again.
+func (c *KVClient) SetFailPut(failPut func(key string) bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.failPut = failPut
+}
+
+func toBytes(value interface{}) []byte {
+	switch v := value.(type) {
+	case []byte:
+		return append([]byte(nil), v...)
+	case string:
+		return []byte(v)
+	}
+	return nil
+}
+
+// notifyWatchers must be called with the lock held so that watches are not closed meanwhile
+func (c *KVClient) notifyWatchers(eventType int, key string, value []byte) {
+	for ch, w := range c.watchers {
+		if key == w.key || (w.withPrefix && strings.HasPrefix(key, w.key)) {
+			ch <- kvstore.NewEvent(eventType, []byte(key), value, 1)
+		}
+	}
+}
+
+func (c *KVClient) List(ctx context.Context, key string) (map[string]*kvstore.KVPair, error) {
+	return c.GetWithPrefix(ctx, key)
+}
+
+func (c *KVClient) KeyExists(ctx context.Context, key string) (bool, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	_, ok := c.data[key]
+	return ok, nil
+}
+
+func (c *KVClient) Get(ctx context.Context, key string) (*kvstore.KVPair, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if value, ok := c.data[key]; ok {
+		return kvstore.NewKVPair(key, append([]byte(nil), value...), "", 0, 1), nil
+	}
+	return nil, nil
+}
+
+func (c *KVClient) GetWithPrefix(ctx context.Context, prefixKey string) (map[string]*kvstore.KVPair, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	pairs := make(map[string]*kvstore.KVPair)
+	for key, value := range c.data {
+		if strings.HasPrefix(key, prefixKey) {
+			pairs[key] = kvstore.NewKVPair(key, append([]byte(nil), value...), "", 0, 1)
+		}
+	}
+	return pairs, nil
+}
+
+func (c *KVClient) GetWithPrefixKeysOnly(ctx context.Context, prefixKey string) ([]string, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	var keys []string
+	for key := range c.data {
+		if strings.HasPrefix(key, prefixKey) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (c *KVClient) Put(ctx context.Context, key string, value interface{}) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.failPut != nil && c.failPut(key) {
+		return errors.New("put-failed")
+	}
+	c.data[key] = toBytes(value)
+	c.notifyWatchers(kvstore.PUT, key, toBytes(value))
+	return nil
+}
+
+// Txn applies all the operations or, when one of its puts fails, none of them
+func (c *KVClient) Txn(ctx context.Context, ops []kvstore.TxnOp) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, op := range ops {
+		if !op.Delete && c.failPut != nil && c.failPut(op.Key) {
+			return errors.New("txn-failed")
+		}
+	}
+	for _, op := range ops {
+		if op.Delete {
+			if _, ok := c.data[op.Key]; ok {
+				delete(c.data, op.Key)
+				c.notifyWatchers(kvstore.DELETE, op.Key, nil)
+			}
+		} else {
+			c.data[op.Key] = toBytes(op.Value)
+			c.notifyWatchers(kvstore.PUT, op.Key, toBytes(op.Value))
+		}
+	}
+	return nil
+}
+
+func (c *KVClient) Delete(ctx context.Context, key string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.data[key]; ok {
+		delete(c.data, key)
+		c.notifyWatchers(kvstore.DELETE, key, nil)
+	}
+	return nil
+}
+
+func (c *KVClient) DeleteWithPrefix(ctx context.Context, prefixKey string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for key := range c.data {
+		if strings.HasPrefix(key, prefixKey) {
+			delete(c.data, key)
+			c.notifyWatchers(kvstore.DELETE, key, nil)
+		}
+	}
+	return nil
+}
+
+func (c *KVClient) Watch(ctx context.Context, key string, withPrefix bool) chan *kvstore.Event {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ch := make(chan *kvstore.Event, 100)
+	c.watchers[ch] = kvWatcher{key: key, withPrefix: withPrefix}
+	return ch
+}
+
+func (c *KVClient) IsConnectionUp(ctx context.Context) bool {
+	return true
+}
+
+func (c *KVClient) CloseWatch(ctx context.Context, key string, ch chan *kvstore.Event) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.watchers[ch]; ok {
+		delete(c.watchers, ch)
+		close(ch)
+	}
+}
+
+func (c *KVClient) Close(ctx context.Context) {
+}
+
+func (c *KVClient) Reserve(ctx context.Context, key string, value interface{}, ttl time.Duration) (interface{}, error) {
+	return nil, errors.New("not-supported")
+}
+
+func (c *KVClient) ReleaseReservation(ctx context.Context, key string) error {
+	return nil
+}
+
+func (c *KVClient) ReleaseAllReservations(ctx context.Context) error {
+	return nil
+}
+
+func (c *KVClient) RenewReservation(ctx context.Context, key string) error {
+	return nil
+}
+
+func (c *KVClient) AcquireLock(ctx context.Context, lockName string, timeout time.Duration) error {
+	return nil
+}
+
+func (c *KVClient) ReleaseLock(lockName string) error {
+	return nil
+}
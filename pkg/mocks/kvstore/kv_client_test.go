@@ -0,0 +1,63 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kvstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/db/kvstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKVClient(t *testing.T) {
+	ctx := context.Background()
+	c := NewKVClient()
+	ch := c.Watch(ctx, "a/", true)
+
+	assert.Nil(t, c.Put(ctx, "a/1", "one"))
+	assert.Nil(t, c.Put(ctx, "b/1", []byte("other")))
+	pair, err := c.Get(ctx, "a/1")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("one"), pair.Value)
+	pairs, err := c.List(ctx, "a/")
+	assert.Nil(t, err)
+	assert.Len(t, pairs, 1)
+
+	event := <-ch
+	assert.Equal(t, kvstore.PUT, event.EventType)
+	assert.Equal(t, []byte("a/1"), event.Key)
+
+	// A transaction with a failing put applies none of its operations
+	c.SetFailPut(func(key string) bool { return key == "a/3" })
+	assert.NotNil(t, c.Txn(ctx, []kvstore.TxnOp{{Key: "a/2", Value: "two"}, {Key: "a/3", Value: "three"}}))
+	assert.NotNil(t, c.Put(ctx, "a/3", "three"))
+	exists, _ := c.KeyExists(ctx, "a/2")
+	assert.False(t, exists)
+
+	c.SetFailPut(nil)
+	assert.Nil(t, c.Txn(ctx, []kvstore.TxnOp{{Key: "a/2", Value: "two"}, {Key: "a/1", Delete: true}}))
+	keys, err := c.GetWithPrefixKeysOnly(ctx, "a/")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a/2"}, keys)
+	assert.Equal(t, kvstore.PUT, (<-ch).EventType)
+	assert.Equal(t, kvstore.DELETE, (<-ch).EventType)
+
+	c.CloseWatch(ctx, "a/", ch)
+	_, open := <-ch
+	assert.False(t, open)
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package platform
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/flows"
+	"github.com/opencord/voltha-protos/v5/go/voltha"
+)
+
+// PortTopology is the topology of a logical device whose ports follow the platform numbering: the
+// NNI ports are numbered by IntfIDToPortNo, the UNI ports by MkUniPortNum. The PON port between an
+// ONU and the OLT is the PON OLT port number, which the ONU adapters also use for the PON port of
+// the ONU.
+type PortTopology struct {
+	lock        sync.RWMutex
+	oltDeviceID string
+	nniPorts    map[uint32]bool
+	// onus are keyed by the port number of their first UNI
+	onus map[uint32]string
+}
+
+// NewPortTopology returns the topology of an OLT without ports yet
+func NewPortTopology(oltDeviceID string) *PortTopology {
+	return &PortTopology{
+		oltDeviceID: oltDeviceID,
+		nniPorts:    make(map[uint32]bool),
+		onus:        make(map[uint32]string),
+	}
+}
+
+var _ flows.PortTopology = &PortTopology{}
+
+func onuKey(intfID, onuID uint32) uint32 {
+	return (intfID << (bitsForUniID + bitsForONUID)) | (onuID << bitsForUniID)
+}
+
+// AddNniPort adds the NNI of the interface
+func (t *PortTopology) AddNniPort(intfID uint32) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.nniPorts[IntfIDToPortNo(intfID, voltha.Port_ETHERNET_NNI)] = true
+}
+
+// AddOnu adds the ONU device of the ONU ID on the PON interface, all its UNIs included
+func (t *PortTopology) AddOnu(intfID, onuID uint32, onuDeviceID string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.onus[onuKey(intfID, onuID)] = onuDeviceID
+}
+
+// RemoveOnu removes the ONU of the ONU ID on the PON interface
+func (t *PortTopology) RemoveOnu(intfID, onuID uint32) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.onus, onuKey(intfID, onuID))
+}
+
+func (t *PortTopology) OltDeviceID() string {
+	return t.oltDeviceID
+}
+
+// NniPorts returns the NNI ports in ascending order
+func (t *PortTopology) NniPorts() []uint32 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	ports := make([]uint32, 0, len(t.nniPorts))
+	for port := range t.nniPorts {
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+	return ports
+}
+
+func (t *PortTopology) IsNniPort(port uint32) bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.nniPorts[port]
+}
+
+func (t *PortTopology) UniPath(uniPort uint32) (string, uint32, error) {
+	if IntfIDToPortTypeName(uniPort) != voltha.Port_ETHERNET_UNI {
+		return "", 0, fmt.Errorf("port-%d-is-not-a-uni-port", uniPort)
+	}
+	intfID, onuID := IntfIDFromUniPortNum(uniPort), OnuIDFromUniPortNum(uniPort)
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	onuDeviceID, ok := t.onus[onuKey(intfID, onuID)]
+	if !ok {
+		return "", 0, fmt.Errorf("no-onu-%d-on-pon-%d-for-uni-port-%d", onuID, intfID, uniPort)
+	}
+	return onuDeviceID, IntfIDToPortNo(intfID, voltha.Port_PON_OLT), nil
+}
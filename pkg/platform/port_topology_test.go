@@ -0,0 +1,67 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package platform
+
+import (
+	"context"
+	"testing"
+
+	fu "github.com/opencord/voltha-lib-go/v7/pkg/flows"
+	ofp "github.com/opencord/voltha-protos/v5/go/openflow_13"
+	"github.com/opencord/voltha-protos/v5/go/voltha"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPortTopology(t *testing.T) {
+	ctx := context.Background()
+	topology := NewPortTopology("olt")
+	topology.AddNniPort(1)
+	topology.AddNniPort(0)
+	topology.AddOnu(2, 3, "onu")
+
+	nniPort := IntfIDToPortNo(0, voltha.Port_ETHERNET_NNI)
+	assert.Equal(t, []uint32{nniPort, IntfIDToPortNo(1, voltha.Port_ETHERNET_NNI)}, topology.NniPorts())
+	assert.True(t, topology.IsNniPort(nniPort))
+
+	uniPort := MkUniPortNum(ctx, 2, 3, 1)
+	onuDeviceID, ponPort, err := topology.UniPath(uniPort)
+	assert.Nil(t, err)
+	assert.Equal(t, "onu", onuDeviceID)
+	assert.Equal(t, IntfIDToPortNo(2, voltha.Port_PON_OLT), ponPort)
+	_, _, err = topology.UniPath(nniPort)
+	assert.NotNil(t, err)
+	_, _, err = topology.UniPath(MkUniPortNum(ctx, 2, 4, 1))
+	assert.NotNil(t, err)
+
+	flow, err := fu.MkFlowStat(&fu.FlowArgs{
+		MatchFields: []*ofp.OfpOxmOfbField{fu.InPort(uniPort), fu.VlanVid(uint32(ofp.OfpVlanId_OFPVID_PRESENT) | 100)},
+		Actions:     []*ofp.OfpAction{fu.Output(nniPort)},
+	})
+	assert.Nil(t, err)
+	flow.TableId = 1
+	deviceRules, err := fu.DecomposeFlow(ctx, topology, flow, nil)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"olt"}, deviceRules.Keys())
+	oltFlow := deviceRules.GetRules()["olt"].ListFlows()[0]
+	assert.Equal(t, ponPort, fu.GetInPort(oltFlow))
+	assert.Equal(t, uniPort, fu.GetChildPortFromTunnelId(oltFlow))
+	assert.Equal(t, nniPort, fu.GetOutPort(oltFlow))
+
+	topology.RemoveOnu(2, 3)
+	_, err = fu.DecomposeFlow(ctx, topology, flow, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ponresourcemanager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var errResourceExhausted = errors.New("resource-exhausted--no-free-id-in-the-pool")
+
+// AllocationStrategy selects the ID handed out among the free IDs of a resource pool.
+// The state a strategy needs across allocations must be kept in the pool, which is persisted
+// in the KV store.
+type AllocationStrategy interface {
+	// Next returns the index in the pool of the free ID to allocate
+	Next(Pool *ResourcePool) (int, error)
+	// Released records that the ID at index Idx of the pool has been released
+	Released(Pool *ResourcePool, Idx int)
+}
+
+// LowestFreeStrategy allocates the lowest free ID of the pool. This is the default strategy.
+type LowestFreeStrategy struct{}
+
+// Next implements AllocationStrategy
+func (LowestFreeStrategy) Next(Pool *ResourcePool) (int, error) {
+	Capacity := Pool.Capacity()
+	for Idx := 0; Idx < Capacity; Idx++ {
+		if !Pool.IsSet(Idx) {
+			return Idx, nil
+		}
+	}
+	return 0, errResourceExhausted
+}
+
+// Released implements AllocationStrategy
+func (LowestFreeStrategy) Released(Pool *ResourcePool, Idx int) {}
+
+// RoundRobinStrategy allocates the first free ID following the last one allocated, wrapping
+// around at the end of the pool, so that released IDs are reused as late as possible.
+type RoundRobinStrategy struct{}
+
+// Next implements AllocationStrategy
+func (RoundRobinStrategy) Next(Pool *ResourcePool) (int, error) {
+	Capacity := Pool.Capacity()
+	if Capacity <= 0 {
+		return 0, errResourceExhausted
+	}
+	Start := int(Pool.NextIdx) % Capacity
+	for i := 0; i < Capacity; i++ {
+		Idx := (Start + i) % Capacity
+		if !Pool.IsSet(Idx) {
+			Pool.NextIdx = uint32((Idx + 1) % Capacity)
+			return Idx, nil
+		}
+	}
+	return 0, errResourceExhausted
+}
+
+// Released implements AllocationStrategy
+func (RoundRobinStrategy) Released(Pool *ResourcePool, Idx int) {}
+
+// QuarantineStrategy allocates the lowest free ID that was not released during the last HoldDown
+// period, giving the devices time to tear down the configuration of the ID before it is reused.
+// The zero value has no hold-down period.
+type QuarantineStrategy struct {
+	HoldDown time.Duration
+	now      func() time.Time
+}
+
+// NewQuarantineStrategy creates a quarantine strategy with the given hold-down period
+func NewQuarantineStrategy(HoldDown time.Duration) *QuarantineStrategy {
+	return &QuarantineStrategy{HoldDown: HoldDown, now: time.Now}
+}
+
+// clock returns the current time, which tests may override
+func (qs *QuarantineStrategy) clock() time.Time {
+	if qs.now == nil {
+		return time.Now()
+	}
+	return qs.now()
+}
+
+// purge removes the IDs whose hold-down is over from the quarantine of the pool
+func (qs *QuarantineStrategy) purge(Pool *ResourcePool) {
+	Now := qs.clock().UnixMilli()
+	for Idx, ReleasedAt := range Pool.Quarantine {
+		if Now-ReleasedAt >= qs.HoldDown.Milliseconds() {
+			delete(Pool.Quarantine, Idx)
+		}
+	}
+}
+
+// Next implements AllocationStrategy
+func (qs *QuarantineStrategy) Next(Pool *ResourcePool) (int, error) {
+	qs.purge(Pool)
+	Capacity := Pool.Capacity()
+	for Idx := 0; Idx < Capacity; Idx++ {
+		if Pool.IsSet(Idx) {
+			continue
+		}
+		if _, ok := Pool.Quarantine[uint32(Idx)]; !ok {
+			return Idx, nil
+		}
+	}
+	if len(Pool.Quarantine) > 0 {
+		return 0, fmt.Errorf("resource-exhausted--%d-free-ids-in-hold-down", len(Pool.Quarantine))
+	}
+	return 0, errResourceExhausted
+}
+
+// Released implements AllocationStrategy
+func (qs *QuarantineStrategy) Released(Pool *ResourcePool, Idx int) {
+	qs.purge(Pool)
+	if Pool.Quarantine == nil {
+		Pool.Quarantine = make(map[uint32]int64)
+	}
+	Pool.Quarantine[uint32(Idx)] = qs.clock().UnixMilli()
+}
+
+// InHoldDown returns true if the free ID at index Idx of the pool was released during the last
+// HoldDown period
+func (qs *QuarantineStrategy) InHoldDown(Pool *ResourcePool, Idx int) bool {
+	qs.purge(Pool)
+	_, ok := Pool.Quarantine[uint32(Idx)]
+	return ok
+}
+
+// holdDownStrategy is implemented by the strategies holding released IDs down, whose IDs must
+// not be reserved either while in hold-down
+type holdDownStrategy interface {
+	InHoldDown(Pool *ResourcePool, Idx int) bool
+}
+
+// SetAllocationStrategy sets the strategy used by GetResourceID to allocate IDs of a resource type.
+// When the pools of the resource type are shared, the strategy of the manager owning them applies.
+// A QuarantineStrategy also prevents ReserveResourceIDs from reserving the IDs in hold-down.
+func (PONRMgr *PONResourceManager) SetAllocationStrategy(ResourceType string, Strategy AllocationStrategy) error {
+	if !checkValidResourceType(ResourceType) {
+		return fmt.Errorf("invalid resource type: %s", ResourceType)
+	}
+	if Strategy == nil {
+		return fmt.Errorf("nil allocation strategy for resource type %s", ResourceType)
+	}
+	PONRMgr.strategiesLock.Lock()
+	defer PONRMgr.strategiesLock.Unlock()
+	if PONRMgr.AllocationStrategies == nil {
+		PONRMgr.AllocationStrategies = make(map[string]AllocationStrategy)
+	}
+	PONRMgr.AllocationStrategies[ResourceType] = Strategy
+	return nil
+}
+
+func (PONRMgr *PONResourceManager) allocationStrategy(ResourceType string) AllocationStrategy {
+	PONRMgr.strategiesLock.RLock()
+	defer PONRMgr.strategiesLock.RUnlock()
+	if Strategy, ok := PONRMgr.AllocationStrategies[ResourceType]; ok {
+		return Strategy
+	}
+	return LowestFreeStrategy{}
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ponresourcemanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/db"
+	mock_kvstore "github.com/opencord/voltha-lib-go/v7/pkg/mocks/kvstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func allocate(t *testing.T, ctx context.Context, PONRMgr *PONResourceManager, ResourceType string) uint32 {
+	ids, err := PONRMgr.GetResourceID(ctx, 0, ResourceType, 1)
+	assert.Nil(t, err)
+	assert.Len(t, ids, 1)
+	if len(ids) == 0 {
+		return 0
+	}
+	return ids[0]
+}
+
+func TestLowestFreeStrategy(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, _ := newTestPONResourceManager(ctx)
+	initTestPools(t, ctx, PONRMgr)
+
+	assert.Equal(t, uint32(1024), allocate(t, ctx, PONRMgr, GEMPORT_ID))
+	assert.Equal(t, uint32(1025), allocate(t, ctx, PONRMgr, GEMPORT_ID))
+	assert.Nil(t, PONRMgr.FreeResourceID(ctx, 0, GEMPORT_ID, []uint32{1024}))
+	assert.Equal(t, uint32(1024), allocate(t, ctx, PONRMgr, GEMPORT_ID))
+
+	// IDs above the end of the pool are never handed out
+	_, err := PONRMgr.GetResourceID(ctx, 0, GEMPORT_ID, 6)
+	assert.Nil(t, err)
+	_, err = PONRMgr.GetResourceID(ctx, 0, GEMPORT_ID, 1)
+	assert.NotNil(t, err)
+}
+
+func TestRoundRobinStrategy(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, _ := newTestPONResourceManager(ctx)
+	initTestPools(t, ctx, PONRMgr)
+	assert.Nil(t, PONRMgr.SetAllocationStrategy(ALLOC_ID, RoundRobinStrategy{}))
+
+	assert.Equal(t, uint32(1024), allocate(t, ctx, PONRMgr, ALLOC_ID))
+	assert.Equal(t, uint32(1025), allocate(t, ctx, PONRMgr, ALLOC_ID))
+	assert.Nil(t, PONRMgr.FreeResourceID(ctx, 0, ALLOC_ID, []uint32{1024}))
+	assert.Equal(t, uint32(1026), allocate(t, ctx, PONRMgr, ALLOC_ID))
+
+	ids, err := PONRMgr.GetResourceID(ctx, 0, ALLOC_ID, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{1027, 1028, 1029, 1030, 1031}, ids)
+	// wraps around to the released ID
+	assert.Equal(t, uint32(1024), allocate(t, ctx, PONRMgr, ALLOC_ID))
+	_, err = PONRMgr.GetResourceID(ctx, 0, ALLOC_ID, 1)
+	assert.NotNil(t, err)
+
+	// other resource types keep the default strategy
+	assert.Equal(t, uint32(1024), allocate(t, ctx, PONRMgr, GEMPORT_ID))
+}
+
+func TestQuarantineStrategy(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, _ := newTestPONResourceManager(ctx)
+	initTestPools(t, ctx, PONRMgr)
+	current := time.Now()
+	quarantine := NewQuarantineStrategy(time.Minute)
+	quarantine.now = func() time.Time { return current }
+	assert.Nil(t, PONRMgr.SetAllocationStrategy(GEMPORT_ID, quarantine))
+
+	ids, err := PONRMgr.GetResourceID(ctx, 0, GEMPORT_ID, 8)
+	assert.Nil(t, err)
+	assert.Nil(t, PONRMgr.FreeResourceID(ctx, 0, GEMPORT_ID, ids[:2]))
+
+	// the released IDs are held down
+	_, err = PONRMgr.GetResourceID(ctx, 0, GEMPORT_ID, 1)
+	assert.NotNil(t, err)
+
+	current = current.Add(30 * time.Second)
+	assert.Nil(t, PONRMgr.FreeResourceID(ctx, 0, GEMPORT_ID, ids[2:3]))
+	current = current.Add(30 * time.Second)
+	assert.Equal(t, uint32(1024), allocate(t, ctx, PONRMgr, GEMPORT_ID))
+	assert.Equal(t, uint32(1025), allocate(t, ctx, PONRMgr, GEMPORT_ID))
+	_, err = PONRMgr.GetResourceID(ctx, 0, GEMPORT_ID, 1)
+	assert.NotNil(t, err)
+
+	current = current.Add(30 * time.Second)
+	assert.Equal(t, uint32(1026), allocate(t, ctx, PONRMgr, GEMPORT_ID))
+}
+
+func TestQuarantineStrategyLiteral(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, _ := newTestPONResourceManager(ctx)
+	initTestPools(t, ctx, PONRMgr)
+	// a strategy built without NewQuarantineStrategy uses the wall clock
+	assert.Nil(t, PONRMgr.SetAllocationStrategy(GEMPORT_ID, &QuarantineStrategy{HoldDown: time.Minute}))
+
+	id := allocate(t, ctx, PONRMgr, GEMPORT_ID)
+	assert.Nil(t, PONRMgr.FreeResourceID(ctx, 0, GEMPORT_ID, []uint32{id}))
+	assert.NotEqual(t, id, allocate(t, ctx, PONRMgr, GEMPORT_ID))
+}
+
+func TestResourceManagerLiteral(t *testing.T) {
+	ctx := context.Background()
+	// the locks and the allocation strategies need no initialization
+	PONRMgr := &PONResourceManager{
+		DeviceID:           "olt1",
+		KVStore:            &db.Backend{Client: mock_kvstore.NewKVClient(), PathPrefix: "service/voltha/resource_manager/{xgspon}"},
+		KVStoreForConfig:   &db.Backend{Client: mock_kvstore.NewKVClient(), PathPrefix: "service/voltha/resource_manager/config"},
+		PonResourceRanges:  make(map[string]interface{}),
+		SharedResourceMgrs: make(map[string]*PONResourceManager),
+		SharedIdxByType: map[string]string{
+			ONU_ID:     ONU_ID_SHARED_IDX,
+			ALLOC_ID:   ALLOC_ID_SHARED_IDX,
+			GEMPORT_ID: GEMPORT_ID_SHARED_IDX,
+			FLOW_ID:    FLOW_ID_SHARED_IDX,
+		},
+	}
+	initTestPools(t, ctx, PONRMgr)
+	assert.Nil(t, PONRMgr.SetAllocationStrategy(GEMPORT_ID, RoundRobinStrategy{}))
+
+	first := allocate(t, ctx, PONRMgr, GEMPORT_ID)
+	assert.Nil(t, PONRMgr.FreeResourceID(ctx, 0, GEMPORT_ID, []uint32{first}))
+	assert.NotEqual(t, first, allocate(t, ctx, PONRMgr, GEMPORT_ID))
+}
+
+func TestSetAllocationStrategy(t *testing.T) {
+	PONRMgr, _ := newTestPONResourceManager(context.Background())
+	assert.NotNil(t, PONRMgr.SetAllocationStrategy("UNKNOWN", RoundRobinStrategy{}))
+	assert.NotNil(t, PONRMgr.SetAllocationStrategy(ONU_ID, nil))
+	assert.Nil(t, PONRMgr.SetAllocationStrategy(ONU_ID, NewQuarantineStrategy(time.Second)))
+}
@@ -0,0 +1,345 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ponresourcemanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/db"
+	"github.com/opencord/voltha-lib-go/v7/pkg/log"
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"google.golang.org/protobuf/proto"
+)
+
+// AuditFindingKind classifies an inconsistency found by AuditResources
+type AuditFindingKind string
+
+const (
+	// The ID is allocated in the pool but owned by no ONU: it leaked
+	AuditOrphan AuditFindingKind = "orphan"
+	// The ID is owned by an ONU but free in the pool: it may be handed out again
+	AuditMissing AuditFindingKind = "missing"
+	// The ID is owned by more than one ONU
+	AuditConflict AuditFindingKind = "conflict"
+	// The ID owned by an ONU is not in the range of the pool
+	AuditOutOfRange AuditFindingKind = "out-of-range"
+)
+
+// RESERVED_IDS_OWNER is the owner reported for the IDs reserved by ReserveResourceIDs
+const RESERVED_IDS_OWNER = "reserved"
+
+var perOnuMapPathRegexp = regexp.MustCompile(`{([^{}]*)}/(alloc_ids|gemport_ids|flow_ids)$`)
+var tpInstancePathRegexp = regexp.MustCompile(`/olt-{([^{}]*)}/pon-{([0-9]+)}/onu-{([0-9]+)}/uni-{([0-9]+)}$`)
+
+// AuditOptions configures AuditResources
+type AuditOptions struct {
+	// Repair fixes the orphan and missing IDs in the pools. Conflicts and out of range IDs are only reported.
+	Repair bool
+	// TpInstanceKVStore is the KV store of the tech profile resource instances, whose alloc and gem port IDs
+	// are cross-checked as well when set. It is required to repair, as the IDs only referenced by the
+	// instances would otherwise be released.
+	TpInstanceKVStore *db.Backend
+}
+
+// AuditFinding is an inconsistency between the resource pools and the owners of the IDs
+type AuditFinding struct {
+	Kind         AuditFindingKind
+	IntfID       uint32
+	ResourceType string
+	ID           uint32
+	// the per-ONU maps and tech profile instances referencing the ID
+	Owners   []string
+	Repaired bool
+}
+
+// AuditReport is the result of AuditResources
+type AuditReport struct {
+	Findings []AuditFinding
+	Repaired int
+}
+
+// idOwners maps the IDs of a pool to the references of their owners, indexed by ONU
+type idOwners map[uint32]map[string][]string
+
+type poolAudit struct {
+	IntfID       uint32
+	ResourceType string
+	Owners       idOwners
+}
+
+// AuditResources cross-checks the alloc, gem port and flow ID pools against the per-ONU maps and, if
+// configured, the tech profile resource instances. The IDs reserved by ReserveResourceIDs count as owned.
+// ONU IDs are not audited as an ONU may hold its ID before any resource is recorded for it. With
+// opts.Repair, the orphan IDs are released and the missing ones allocated in the pools. The pool lock is
+// held from the collection of the owners to the repair, so that no ID is allocated or released meanwhile.
+func (PONRMgr *PONResourceManager) AuditResources(ctx context.Context, opts AuditOptions) (*AuditReport, error) {
+	if opts.Repair && opts.TpInstanceKVStore == nil {
+		return nil, errors.New("resource audit repair requires the tech profile instance kv store")
+	}
+	PONRMgr.poolLock.Lock()
+	defer PONRMgr.poolLock.Unlock()
+
+	pools := make(map[string]*poolAudit)
+	for _, ResourceType := range []string{ALLOC_ID, GEMPORT_ID, FLOW_ID} {
+		for _, Intf := range PONRMgr.IntfIDs {
+			Path := PONRMgr.GetPath(ctx, Intf, ResourceType)
+			if Path == "" {
+				continue
+			}
+			if _, ok := pools[Path]; !ok {
+				pools[Path] = &poolAudit{IntfID: PONRMgr.poolIntfID(Intf, ResourceType), ResourceType: ResourceType, Owners: make(idOwners)}
+			}
+		}
+	}
+	addOwner := func(IntfID uint32, ResourceType string, ID uint32, Onu string, Ref string) {
+		pool, ok := pools[PONRMgr.GetPath(ctx, IntfID, ResourceType)]
+		if !ok {
+			logger.Warnw(ctx, "audit-id-owned-on-unknown-pool", log.Fields{"intf-id": IntfID, "resource-type": ResourceType, "id": ID, "owner": Ref})
+			return
+		}
+		if pool.Owners[ID] == nil {
+			pool.Owners[ID] = make(map[string][]string)
+		}
+		pool.Owners[ID][Onu] = append(pool.Owners[ID][Onu], Ref)
+	}
+
+	if err := PONRMgr.collectPerOnuMapOwners(ctx, addOwner); err != nil {
+		return nil, err
+	}
+	if opts.TpInstanceKVStore != nil {
+		if err := PONRMgr.collectTpInstanceOwners(ctx, opts.TpInstanceKVStore, addOwner); err != nil {
+			return nil, err
+		}
+	}
+	// the reserved gem ports are excluded from the pools when they are created
+	if reservedGemPortIds, defined := PONRMgr.getReservedGemPortIdsFromKVStore(ctx); defined {
+		for _, pool := range pools {
+			if pool.ResourceType != GEMPORT_ID {
+				continue
+			}
+			for _, ID := range reservedGemPortIds {
+				if pool.Owners[ID] == nil {
+					pool.Owners[ID] = make(map[string][]string)
+				}
+				pool.Owners[ID][RESERVED_GEMPORT_IDS_PATH] = []string{RESERVED_GEMPORT_IDS_PATH}
+			}
+		}
+	}
+
+	report := &AuditReport{}
+	Paths := make([]string, 0, len(pools))
+	for Path := range pools {
+		Paths = append(Paths, Path)
+	}
+	sort.Strings(Paths)
+	for _, Path := range Paths {
+		findings, err := PONRMgr.auditPool(ctx, Path, pools[Path], opts.Repair)
+		if err != nil {
+			return nil, err
+		}
+		for _, finding := range findings {
+			if finding.Repaired {
+				report.Repaired++
+			}
+		}
+		report.Findings = append(report.Findings, findings...)
+	}
+	logger.Infow(ctx, "resource-audit-completed", log.Fields{"device-id": PONRMgr.DeviceID, "findings": len(report.Findings), "repaired": report.Repaired})
+	return report, nil
+}
+
+// collectPerOnuMapOwners reads the IDs recorded by UpdateAllocIdsForOnu, UpdateGEMPortIDsForOnu and UpdateFlowIDForOnu
+func (PONRMgr *PONResourceManager) collectPerOnuMapOwners(ctx context.Context, addOwner func(uint32, string, uint32, string, string)) error {
+	KvPairs, err := PONRMgr.KVStore.List(ctx, fmt.Sprintf("{%s}/", PONRMgr.DeviceID))
+	if err != nil {
+		logger.Errorw(ctx, "failed-to-list-per-onu-maps", log.Fields{"device-id": PONRMgr.DeviceID, "error": err})
+		return err
+	}
+	for Key, KvPair := range KvPairs {
+		match := perOnuMapPathRegexp.FindStringSubmatch(Key)
+		if match == nil {
+			continue
+		}
+		IntfID, OnuID, err := parseIntfONUID(match[1])
+		if err != nil {
+			logger.Warnw(ctx, "audit-skipping-per-onu-map", log.Fields{"key": Key, "error": err})
+			continue
+		}
+		var ResourceType string
+		switch match[2] {
+		case "alloc_ids":
+			ResourceType = ALLOC_ID
+		case "gemport_ids":
+			ResourceType = GEMPORT_ID
+		case "flow_ids":
+			ResourceType = FLOW_ID
+		}
+		Value, err := ToByte(KvPair.Value)
+		if err != nil || len(Value) == 0 {
+			continue
+		}
+		var IDs []uint32
+		if err = json.Unmarshal(Value, &IDs); err != nil {
+			logger.Warnw(ctx, "audit-skipping-per-onu-map", log.Fields{"key": Key, "error": err})
+			continue
+		}
+		Onu := fmt.Sprintf("%d,%d", IntfID, OnuID)
+		Ref := fmt.Sprintf("{%s}/%s", match[1], match[2])
+		for _, ID := range IDs {
+			addOwner(IntfID, ResourceType, ID, Onu, Ref)
+		}
+	}
+	return nil
+}
+
+// collectTpInstanceOwners reads the alloc and gem port IDs of the tech profile resource instances of the device
+func (PONRMgr *PONResourceManager) collectTpInstanceOwners(ctx context.Context, TpInstanceKVStore *db.Backend, addOwner func(uint32, string, uint32, string, string)) error {
+	KvPairs, err := TpInstanceKVStore.GetWithPrefix(ctx, PONRMgr.Technology+"/")
+	if err != nil {
+		logger.Errorw(ctx, "failed-to-list-tp-instances", log.Fields{"device-id": PONRMgr.DeviceID, "error": err})
+		return err
+	}
+	for Key, KvPair := range KvPairs {
+		match := tpInstancePathRegexp.FindStringSubmatch(Key)
+		if match == nil || match[1] != PONRMgr.DeviceID {
+			continue
+		}
+		IntfID, _ := strconv.ParseUint(match[2], 10, 32)
+		OnuID, _ := strconv.ParseUint(match[3], 10, 32)
+		Value, err := ToByte(KvPair.Value)
+		if err != nil {
+			continue
+		}
+		var resInst tp_pb.ResourceInstance
+		if err = proto.Unmarshal(Value, &resInst); err != nil {
+			logger.Warnw(ctx, "audit-skipping-tp-instance", log.Fields{"key": Key, "error": err})
+			continue
+		}
+		Onu := fmt.Sprintf("%d,%d", IntfID, OnuID)
+		Ref := Key[strings.LastIndex(Key, PONRMgr.Technology+"/"):]
+		addOwner(uint32(IntfID), ALLOC_ID, resInst.AllocId, Onu, Ref)
+		for _, GemPortID := range resInst.GemportIds {
+			addOwner(uint32(IntfID), GEMPORT_ID, GemPortID, Onu, Ref)
+		}
+	}
+	return nil
+}
+
+func (PONRMgr *PONResourceManager) auditPool(ctx context.Context, Path string, pool *poolAudit, Repair bool) ([]AuditFinding, error) {
+	Resource, err := PONRMgr.GetResource(ctx, Path)
+	if err != nil {
+		return nil, err
+	}
+	if Resource == nil {
+		logger.Debugw(ctx, "audit-skipping-missing-pool", log.Fields{"path": Path})
+		return nil, nil
+	}
+	StartID := Resource.StartIdx
+	Capacity := Resource.Capacity()
+	for _, ID := range Resource.Reserved {
+		if pool.Owners[ID] == nil {
+			pool.Owners[ID] = map[string][]string{RESERVED_IDS_OWNER: {RESERVED_IDS_OWNER}}
+		}
+	}
+
+	var findings []AuditFinding
+	newFinding := func(Kind AuditFindingKind, ID uint32, Owners map[string][]string) AuditFinding {
+		finding := AuditFinding{Kind: Kind, IntfID: pool.IntfID, ResourceType: pool.ResourceType, ID: ID}
+		for _, Refs := range Owners {
+			finding.Owners = append(finding.Owners, Refs...)
+		}
+		sort.Strings(finding.Owners)
+		return finding
+	}
+
+	IDs := make([]uint32, 0, len(pool.Owners))
+	for ID := range pool.Owners {
+		IDs = append(IDs, ID)
+	}
+	sort.Slice(IDs, func(i, j int) bool { return IDs[i] < IDs[j] })
+	for _, ID := range IDs {
+		Owners := pool.Owners[ID]
+		if !Resource.Contains(ID) {
+			findings = append(findings, newFinding(AuditOutOfRange, ID, Owners))
+			continue
+		}
+		if len(Owners) > 1 {
+			findings = append(findings, newFinding(AuditConflict, ID, Owners))
+		}
+		if !Resource.IsSet(int(ID - StartID)) {
+			finding := newFinding(AuditMissing, ID, Owners)
+			if Repair {
+				Resource.set(int(ID-StartID), true)
+				finding.Repaired = true
+			}
+			findings = append(findings, finding)
+		}
+	}
+	for Idx := 0; Idx < Capacity; Idx++ {
+		ID := StartID + uint32(Idx)
+		if Resource.IsSet(Idx) && pool.Owners[ID] == nil {
+			finding := newFinding(AuditOrphan, ID, nil)
+			if Repair {
+				Resource.set(Idx, false)
+				finding.Repaired = true
+			}
+			findings = append(findings, finding)
+		}
+	}
+
+	for _, finding := range findings {
+		logger.Warnw(ctx, "resource-audit-finding", log.Fields{
+			"device-id":     PONRMgr.DeviceID,
+			"kind":          finding.Kind,
+			"intf-id":       finding.IntfID,
+			"resource-type": finding.ResourceType,
+			"id":            finding.ID,
+			"owners":        finding.Owners,
+			"repaired":      finding.Repaired,
+		})
+	}
+	if Repair && len(findings) > 0 {
+		if err := PONRMgr.UpdateResource(ctx, Path, Resource); err != nil {
+			return nil, err
+		}
+	}
+	return findings, nil
+}
+
+// parseIntfONUID reads the PON interface and ONU IDs of a per-ONU map reference, "<intf>,<onu>[,<uni>]"
+func parseIntfONUID(IntfONUID string) (uint32, uint32, error) {
+	parts := strings.Split(IntfONUID, ",")
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("invalid pon interface and onu reference %s", IntfONUID)
+	}
+	IntfID, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	OnuID, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint32(IntfID), uint32(OnuID), nil
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ponresourcemanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/db"
+	mock_kvstore "github.com/opencord/voltha-lib-go/v7/pkg/mocks/kvstore"
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func findingsOfKind(report *AuditReport, Kind AuditFindingKind) []AuditFinding {
+	var findings []AuditFinding
+	for _, finding := range report.Findings {
+		if finding.Kind == Kind {
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+func TestAuditResources(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, _ := newTestPONResourceManager(ctx)
+	initTestPools(t, ctx, PONRMgr)
+
+	// ONU 0,1 owns alloc 1024 and gem ports 1024, 1025
+	allocIDs, err := PONRMgr.GetResourceID(ctx, 0, ALLOC_ID, 1)
+	assert.Nil(t, err)
+	gemPortIDs, err := PONRMgr.GetResourceID(ctx, 0, GEMPORT_ID, 2)
+	assert.Nil(t, err)
+	assert.Nil(t, PONRMgr.UpdateAllocIdsForOnu(ctx, "0,1", allocIDs))
+	assert.Nil(t, PONRMgr.UpdateGEMPortIDsForOnu(ctx, "0,1", gemPortIDs))
+
+	// alloc 1025 leaked
+	_, err = PONRMgr.GetResourceID(ctx, 0, ALLOC_ID, 1)
+	assert.Nil(t, err)
+	// ONU 0,2 claims gem port 1025 as well, and a gem port that is not allocated
+	assert.Nil(t, PONRMgr.UpdateGEMPortIDsForOnu(ctx, "0,2", []uint32{1025, 1030}))
+	// flow 3 is recorded but free in the pool
+	assert.Nil(t, PONRMgr.UpdateFlowIDForOnu(ctx, "0,2", 3, true))
+	// and an ID out of the range of the pool
+	assert.Nil(t, PONRMgr.UpdateAllocIdsForOnu(ctx, "1,1", []uint32{2048}))
+
+	// the tech profile instance of ONU 0,3 owns alloc 1026 and gem port 1026, free in the pools
+	tpInstances := mock_kvstore.NewKVClient()
+	resInst, _ := proto.Marshal(&tp_pb.ResourceInstance{TpId: 64, AllocId: 1026, GemportIds: []uint32{1026}})
+	assert.Nil(t, tpInstances.Put(ctx, "service/voltha/technology_profiles/xgspon/64/olt-{olt1}/pon-{0}/onu-{3}/uni-{0}", resInst))
+	// instances of other OLTs are ignored
+	assert.Nil(t, tpInstances.Put(ctx, "service/voltha/technology_profiles/xgspon/64/olt-{olt2}/pon-{0}/onu-{3}/uni-{0}", resInst))
+	opts := AuditOptions{TpInstanceKVStore: &db.Backend{Client: tpInstances, PathPrefix: "service/voltha/technology_profiles"}}
+
+	report, err := PONRMgr.AuditResources(ctx, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, report.Repaired)
+	orphans := findingsOfKind(report, AuditOrphan)
+	assert.Len(t, orphans, 1)
+	assert.Equal(t, AuditFinding{Kind: AuditOrphan, IntfID: 0, ResourceType: ALLOC_ID, ID: 1025}, orphans[0])
+	conflicts := findingsOfKind(report, AuditConflict)
+	assert.Len(t, conflicts, 1)
+	assert.Equal(t, uint32(1025), conflicts[0].ID)
+	assert.Equal(t, []string{"{0,1}/gemport_ids", "{0,2}/gemport_ids"}, conflicts[0].Owners)
+	missing := findingsOfKind(report, AuditMissing)
+	assert.Len(t, missing, 4)
+	outOfRange := findingsOfKind(report, AuditOutOfRange)
+	assert.Len(t, outOfRange, 1)
+	assert.Equal(t, uint32(1), outOfRange[0].IntfID)
+
+	// the audit alone changes nothing
+	pu, err := PONRMgr.GetPoolUtilization(ctx, 0, ALLOC_ID)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), pu.Used)
+
+	opts.Repair = true
+	report, err = PONRMgr.AuditResources(ctx, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, report.Repaired)
+
+	// only the conflict and the out of range ID remain
+	report, err = PONRMgr.AuditResources(ctx, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, report.Repaired)
+	assert.Len(t, report.Findings, 2)
+
+	// alloc 1025 was released and 1026 allocated
+	allocIDs, err = PONRMgr.GetResourceID(ctx, 0, ALLOC_ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{1025}, allocIDs)
+	flowIDs, err := PONRMgr.GetResourceID(ctx, 0, FLOW_ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{1}, flowIDs)
+	gemPortIDs, err = PONRMgr.GetResourceID(ctx, 0, GEMPORT_ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{1027}, gemPortIDs)
+}
+
+func TestAuditResourcesReservedIDs(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, _ := newTestPONResourceManager(ctx)
+	initTestPools(t, ctx, PONRMgr)
+	opts := AuditOptions{Repair: true}
+
+	// repairing without the tech profile instances would release the IDs they own
+	_, err := PONRMgr.AuditResources(ctx, opts)
+	assert.NotNil(t, err)
+
+	// the reserved IDs have no other owner but are not orphans
+	assert.Nil(t, PONRMgr.ReserveResourceIDs(ctx, 0, ALLOC_ID, []uint32{1030}))
+	opts.TpInstanceKVStore = &db.Backend{Client: mock_kvstore.NewKVClient(), PathPrefix: "service/voltha/technology_profiles"}
+	report, err := PONRMgr.AuditResources(ctx, opts)
+	assert.Nil(t, err)
+	assert.Empty(t, report.Findings)
+	allocated, err := PONRMgr.IsAllocated(ctx, 0, ALLOC_ID, 1030)
+	assert.Nil(t, err)
+	assert.True(t, allocated)
+
+	// until they are released
+	assert.Nil(t, PONRMgr.FreeResourceID(ctx, 0, ALLOC_ID, []uint32{1030}))
+	report, err = PONRMgr.AuditResources(ctx, opts)
+	assert.Nil(t, err)
+	assert.Empty(t, report.Findings)
+}
+
+func TestParseIntfONUID(t *testing.T) {
+	IntfID, OnuID, err := parseIntfONUID("3,17")
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), IntfID)
+	assert.Equal(t, uint32(17), OnuID)
+	_, _, err = parseIntfONUID("3, 17, 0")
+	assert.Nil(t, err)
+	_, _, err = parseIntfONUID("3")
+	assert.NotNil(t, err)
+	_, _, err = parseIntfONUID("a,b")
+	assert.NotNil(t, err)
+}
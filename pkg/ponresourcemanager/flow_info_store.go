@@ -0,0 +1,282 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ponresourcemanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/db/kvstore"
+	"github.com/opencord/voltha-lib-go/v7/pkg/log"
+)
+
+const (
+	//Indexed flow info: the flow info is stored under its flow id and referenced by index entries
+	//keyed by cookie, gem port and uni. The value of an index entry is the path of the flow info.
+	//Format: <device_id>/flow_info/<(pon_intf_id, onu_id)>
+	FLOW_INFO_PATH_INTF_ONU_PREFIX = "{%s}/flow_info/{%s}"
+	//Format: <device_id>/flow_info/<(pon_intf_id, onu_id)>/<flow_id>
+	FLOW_INFO_PATH = FLOW_INFO_PATH_INTF_ONU_PREFIX + "/{%d}"
+	//Format: <device_id>/flow_info_index/cookie/<cookie>
+	FLOW_INFO_COOKIE_INDEX_PREFIX = "{%s}/flow_info_index/cookie/{%d}"
+	//Format: <device_id>/flow_info_index/gemport/<pon_intf_id>/<gemport_id>
+	FLOW_INFO_GEMPORT_INDEX_PREFIX = "{%s}/flow_info_index/gemport/{%d}/{%d}"
+	//Format: <device_id>/flow_info_index/uni/<(pon_intf_id, onu_id, uni_id)>
+	FLOW_INFO_UNI_INDEX_PREFIX = "{%s}/flow_info_index/uni/{%s}"
+	//Format: <index prefix>/<(pon_intf_id, onu_id)>/<flow_id>
+	FLOW_INFO_INDEX_ENTRY = "%s/{%s}/{%d}"
+)
+
+// FlowInfo is the metadata of a flow, indexed by cookie, GEM port and UNI
+type FlowInfo struct {
+	IntfID uint32 `json:"intf_id"`
+	OnuID  uint32 `json:"onu_id"`
+	UniID  uint32 `json:"uni_id"`
+	FlowID uint32 `json:"flow_id"`
+	Cookie uint64 `json:"cookie"`
+	// GEM port of the flow, 0 if the flow has none, in which case it is not indexed by GEM port
+	GemPortID uint32 `json:"gemport_id,omitempty"`
+	// metadata of the adapter, stored as is
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+func (fi *FlowInfo) intfONUID() string {
+	return fmt.Sprintf("%d,%d", fi.IntfID, fi.OnuID)
+}
+
+func (PONRMgr *PONResourceManager) flowInfoPath(IntfID uint32, OnuID uint32, FlowID uint32) string {
+	return fmt.Sprintf(FLOW_INFO_PATH, PONRMgr.DeviceID, fmt.Sprintf("%d,%d", IntfID, OnuID), FlowID)
+}
+
+// flowInfoIndexPaths returns the paths of the index entries of a flow info
+func (PONRMgr *PONResourceManager) flowInfoIndexPaths(Info *FlowInfo) []string {
+	Prefixes := []string{
+		fmt.Sprintf(FLOW_INFO_COOKIE_INDEX_PREFIX, PONRMgr.DeviceID, Info.Cookie),
+		fmt.Sprintf(FLOW_INFO_UNI_INDEX_PREFIX, PONRMgr.DeviceID, fmt.Sprintf("%d,%d,%d", Info.IntfID, Info.OnuID, Info.UniID)),
+	}
+	if Info.GemPortID != 0 {
+		Prefixes = append(Prefixes, fmt.Sprintf(FLOW_INFO_GEMPORT_INDEX_PREFIX, PONRMgr.DeviceID, Info.IntfID, Info.GemPortID))
+	}
+	Paths := make([]string, 0, len(Prefixes))
+	for _, Prefix := range Prefixes {
+		Paths = append(Paths, fmt.Sprintf(FLOW_INFO_INDEX_ENTRY, Prefix, Info.intfONUID(), Info.FlowID))
+	}
+	return Paths
+}
+
+func (PONRMgr *PONResourceManager) getFlowInfo(ctx context.Context, Path string) (*FlowInfo, error) {
+	KvPair, err := PONRMgr.KVStore.Get(ctx, Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get flow info %s: %w", Path, err)
+	}
+	if KvPair == nil {
+		return nil, nil
+	}
+	Value, err := ToByte(KvPair.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get flow info %s: %w", Path, err)
+	}
+	Info := &FlowInfo{}
+	if err = json.Unmarshal(Value, Info); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal flow info %s: %w", Path, err)
+	}
+	return Info, nil
+}
+
+func (PONRMgr *PONResourceManager) UpdateFlowInfo(ctx context.Context, Info *FlowInfo) error {
+	/*
+	   Store the info of a flow and update its index entries in a single transaction. The index
+	   entries of the previous info of the flow are removed.
+	   :param info: flow info, identified by its pon interface id, onu id and flow id
+	*/
+	if Info == nil {
+		return fmt.Errorf("nil flow info")
+	}
+	Value, err := json.Marshal(Info)
+	if err != nil {
+		logger.Error(ctx, "failed to Marshal")
+		return err
+	}
+	Path := PONRMgr.flowInfoPath(Info.IntfID, Info.OnuID, Info.FlowID)
+
+	PONRMgr.flowInfoLock.Lock()
+	defer PONRMgr.flowInfoLock.Unlock()
+	Existing, err := PONRMgr.getFlowInfo(ctx, Path)
+	if err != nil {
+		logger.Error(ctx, err.Error())
+		return err
+	}
+	IndexPaths := PONRMgr.flowInfoIndexPaths(Info)
+	var Ops []kvstore.TxnOp
+	if Existing != nil {
+		Current := make(map[string]bool, len(IndexPaths))
+		for _, IndexPath := range IndexPaths {
+			Current[IndexPath] = true
+		}
+		for _, IndexPath := range PONRMgr.flowInfoIndexPaths(Existing) {
+			if !Current[IndexPath] {
+				Ops = append(Ops, kvstore.TxnOp{Key: IndexPath, Delete: true})
+			}
+		}
+	}
+	Ops = append(Ops, kvstore.TxnOp{Key: Path, Value: Value})
+	for _, IndexPath := range IndexPaths {
+		Ops = append(Ops, kvstore.TxnOp{Key: IndexPath, Value: Path})
+	}
+	if err = PONRMgr.KVStore.Txn(ctx, Ops); err != nil {
+		logger.Errorw(ctx, "failed-to-update-flow-info", log.Fields{"path": Path, "error": err})
+		return err
+	}
+	return nil
+}
+
+func (PONRMgr *PONResourceManager) GetFlowInfo(ctx context.Context, IntfID uint32, OnuID uint32, FlowID uint32) (*FlowInfo, error) {
+	/*
+	   Get the info of a flow
+	   :param pon_intf_id: OLT PON interface id
+	   :param onu_id: ONU id
+	   :param flow_id: flow id
+	   :return flow info: nil if not found
+	*/
+	return PONRMgr.getFlowInfo(ctx, PONRMgr.flowInfoPath(IntfID, OnuID, FlowID))
+}
+
+func (PONRMgr *PONResourceManager) RemoveFlowInfo(ctx context.Context, IntfID uint32, OnuID uint32, FlowID uint32) error {
+	/*
+	   Remove the info of a flow and its index entries in a single transaction
+	   :param pon_intf_id: OLT PON interface id
+	   :param onu_id: ONU id
+	   :param flow_id: flow id
+	*/
+	PONRMgr.flowInfoLock.Lock()
+	defer PONRMgr.flowInfoLock.Unlock()
+	return PONRMgr.removeFlowInfo(ctx, PONRMgr.flowInfoPath(IntfID, OnuID, FlowID))
+}
+
+func (PONRMgr *PONResourceManager) removeFlowInfo(ctx context.Context, Path string) error {
+	Existing, err := PONRMgr.getFlowInfo(ctx, Path)
+	if err != nil {
+		logger.Error(ctx, err.Error())
+		return err
+	}
+	if Existing == nil {
+		return nil
+	}
+	Ops := []kvstore.TxnOp{{Key: Path, Delete: true}}
+	for _, IndexPath := range PONRMgr.flowInfoIndexPaths(Existing) {
+		Ops = append(Ops, kvstore.TxnOp{Key: IndexPath, Delete: true})
+	}
+	if err = PONRMgr.KVStore.Txn(ctx, Ops); err != nil {
+		logger.Errorw(ctx, "failed-to-remove-flow-info", log.Fields{"path": Path, "error": err})
+		return err
+	}
+	return nil
+}
+
+func (PONRMgr *PONResourceManager) RemoveAllFlowInfoForOnu(ctx context.Context, IntfID uint32, OnuID uint32) error {
+	/*
+	   Remove the info of all the flows of an ONU, one transaction per flow
+	   :param pon_intf_id: OLT PON interface id
+	   :param onu_id: ONU id
+	*/
+	PONRMgr.flowInfoLock.Lock()
+	defer PONRMgr.flowInfoLock.Unlock()
+	Prefix := fmt.Sprintf(FLOW_INFO_PATH_INTF_ONU_PREFIX, PONRMgr.DeviceID, fmt.Sprintf("%d,%d", IntfID, OnuID))
+	Entries, err := PONRMgr.KVStore.List(ctx, Prefix+"/")
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list flow info %s", Prefix)
+		return err
+	}
+	for _, Entry := range Entries {
+		Info := &FlowInfo{}
+		Value, err := ToByte(Entry.Value)
+		if err == nil {
+			err = json.Unmarshal(Value, Info)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal flow info %s: %w", Entry.Key, err)
+		}
+		if err = PONRMgr.removeFlowInfo(ctx, PONRMgr.flowInfoPath(Info.IntfID, Info.OnuID, Info.FlowID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getFlowInfosByIndex returns the flow info referenced by the index entries under a prefix, ordered
+// by pon interface id, onu id and flow id
+func (PONRMgr *PONResourceManager) getFlowInfosByIndex(ctx context.Context, Prefix string) ([]*FlowInfo, error) {
+	Entries, err := PONRMgr.KVStore.List(ctx, Prefix+"/")
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list flow info index %s", Prefix)
+		return nil, err
+	}
+	Infos := make([]*FlowInfo, 0, len(Entries))
+	for _, Entry := range Entries {
+		Path, err := ToString(Entry.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid flow info index entry %s: %w", Entry.Key, err)
+		}
+		Info, err := PONRMgr.getFlowInfo(ctx, Path)
+		if err != nil {
+			return nil, err
+		}
+		// the entries are updated with the flow info, a missing one is being removed concurrently
+		if Info != nil {
+			Infos = append(Infos, Info)
+		}
+	}
+	sort.Slice(Infos, func(i, j int) bool {
+		if Infos[i].IntfID != Infos[j].IntfID {
+			return Infos[i].IntfID < Infos[j].IntfID
+		}
+		if Infos[i].OnuID != Infos[j].OnuID {
+			return Infos[i].OnuID < Infos[j].OnuID
+		}
+		return Infos[i].FlowID < Infos[j].FlowID
+	})
+	return Infos, nil
+}
+
+func (PONRMgr *PONResourceManager) GetFlowInfosByCookie(ctx context.Context, Cookie uint64) ([]*FlowInfo, error) {
+	/*
+	   Get the info of the flows with a given cookie
+	   :param cookie: flow cookie
+	*/
+	return PONRMgr.getFlowInfosByIndex(ctx, fmt.Sprintf(FLOW_INFO_COOKIE_INDEX_PREFIX, PONRMgr.DeviceID, Cookie))
+}
+
+func (PONRMgr *PONResourceManager) GetFlowInfosByGemPort(ctx context.Context, IntfID uint32, GemPortID uint32) ([]*FlowInfo, error) {
+	/*
+	   Get the info of the flows using a gem port
+	   :param pon_intf_id: OLT PON interface id
+	   :param gemport_id: gem port id
+	*/
+	return PONRMgr.getFlowInfosByIndex(ctx, fmt.Sprintf(FLOW_INFO_GEMPORT_INDEX_PREFIX, PONRMgr.DeviceID, IntfID, GemPortID))
+}
+
+func (PONRMgr *PONResourceManager) GetFlowInfosByUni(ctx context.Context, IntfID uint32, OnuID uint32, UniID uint32) ([]*FlowInfo, error) {
+	/*
+	   Get the info of the flows of a UNI
+	   :param pon_intf_id: OLT PON interface id
+	   :param onu_id: ONU id
+	   :param uni_id: UNI id
+	*/
+	return PONRMgr.getFlowInfosByIndex(ctx, fmt.Sprintf(FLOW_INFO_UNI_INDEX_PREFIX, PONRMgr.DeviceID, fmt.Sprintf("%d,%d,%d", IntfID, OnuID, UniID)))
+}
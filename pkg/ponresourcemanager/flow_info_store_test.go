@@ -0,0 +1,112 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ponresourcemanager
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func flowIDsOf(infos []*FlowInfo) []uint32 {
+	ids := []uint32{}
+	for _, info := range infos {
+		ids = append(ids, info.FlowID)
+	}
+	return ids
+}
+
+func TestFlowInfoIndexes(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, kv := newTestPONResourceManager(ctx)
+
+	flows := []*FlowInfo{
+		{IntfID: 0, OnuID: 1, UniID: 0, FlowID: 1, Cookie: 100, GemPortID: 1024, Data: json.RawMessage(`{"pbit":3}`)},
+		{IntfID: 0, OnuID: 1, UniID: 0, FlowID: 2, Cookie: 100, GemPortID: 1025},
+		{IntfID: 0, OnuID: 1, UniID: 1, FlowID: 3, Cookie: 1000, GemPortID: 1024},
+		// trap flow without gem port
+		{IntfID: 0, OnuID: 2, UniID: 0, FlowID: 4, Cookie: 10},
+		// same gem port on another pon interface
+		{IntfID: 1, OnuID: 1, UniID: 0, FlowID: 1, Cookie: 100, GemPortID: 1024},
+	}
+	for _, flow := range flows {
+		assert.Nil(t, PONRMgr.UpdateFlowInfo(ctx, flow))
+	}
+
+	info, err := PONRMgr.GetFlowInfo(ctx, 0, 1, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, flows[0], info)
+	info, err = PONRMgr.GetFlowInfo(ctx, 0, 1, 9)
+	assert.Nil(t, err)
+	assert.Nil(t, info)
+
+	byCookie, err := PONRMgr.GetFlowInfosByCookie(ctx, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, []*FlowInfo{flows[0], flows[1], flows[4]}, byCookie)
+	byCookie, err = PONRMgr.GetFlowInfosByCookie(ctx, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{4}, flowIDsOf(byCookie))
+	byGem, err := PONRMgr.GetFlowInfosByGemPort(ctx, 0, 1024)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{1, 3}, flowIDsOf(byGem))
+	byUni, err := PONRMgr.GetFlowInfosByUni(ctx, 0, 1, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{1, 2}, flowIDsOf(byUni))
+
+	// the stale index entries are replaced with the flow info
+	moved := &FlowInfo{IntfID: 0, OnuID: 1, UniID: 1, FlowID: 2, Cookie: 200, GemPortID: 1026}
+	assert.Nil(t, PONRMgr.UpdateFlowInfo(ctx, moved))
+	byCookie, err = PONRMgr.GetFlowInfosByCookie(ctx, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, []*FlowInfo{flows[0], flows[4]}, byCookie)
+	byGem, err = PONRMgr.GetFlowInfosByGemPort(ctx, 0, 1025)
+	assert.Nil(t, err)
+	assert.Empty(t, byGem)
+	byUni, err = PONRMgr.GetFlowInfosByUni(ctx, 0, 1, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{2, 3}, flowIDsOf(byUni))
+
+	// a failed update changes neither the flow info nor its index entries
+	kv.SetFailPut(func(key string) bool { return strings.Contains(key, "/gemport/") })
+	assert.NotNil(t, PONRMgr.UpdateFlowInfo(ctx, &FlowInfo{IntfID: 0, OnuID: 1, UniID: 1, FlowID: 2, Cookie: 300, GemPortID: 1027}))
+	kv.SetFailPut(nil)
+	info, err = PONRMgr.GetFlowInfo(ctx, 0, 1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, moved, info)
+	byCookie, err = PONRMgr.GetFlowInfosByCookie(ctx, 300)
+	assert.Nil(t, err)
+	assert.Empty(t, byCookie)
+
+	assert.Nil(t, PONRMgr.RemoveFlowInfo(ctx, 0, 1, 1))
+	assert.Nil(t, PONRMgr.RemoveFlowInfo(ctx, 0, 1, 1))
+	byGem, err = PONRMgr.GetFlowInfosByGemPort(ctx, 0, 1024)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{3}, flowIDsOf(byGem))
+
+	assert.Nil(t, PONRMgr.RemoveAllFlowInfoForOnu(ctx, 0, 1))
+	byUni, err = PONRMgr.GetFlowInfosByUni(ctx, 0, 1, 1)
+	assert.Nil(t, err)
+	assert.Empty(t, byUni)
+	// only the flows of the other ONUs are left, without dangling index entries
+	remaining, err := PONRMgr.KVStore.List(ctx, "{olt1}/flow_info_index/")
+	assert.Nil(t, err)
+	assert.Len(t, remaining, 5)
+	assert.NotNil(t, PONRMgr.UpdateFlowInfo(ctx, nil))
+}
@@ -0,0 +1,322 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ponresourcemanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/db/kvstore"
+	"github.com/opencord/voltha-lib-go/v7/pkg/log"
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+)
+
+const (
+	//Path on the KV store for storing the resource bundle of a given ONU
+	//Format: <device_id>/<(pon_intf_id, onu_id)>/onu_resources
+	ONU_RESOURCE_BUNDLE_PATH = "{%s}/{%s}/onu_resources"
+)
+
+// OnuResourceRequest describes the resources to allocate to an ONU
+type OnuResourceRequest struct {
+	IntfID uint32
+	// ONU ID already allocated to the ONU, 0 to allocate one from the ONU ID pool
+	OnuID       uint32
+	NumAllocIDs uint32
+	NumGemPorts uint32
+	NumFlowIDs  uint32
+}
+
+// AddTpInstance adds the resources needed by a tech profile instance to the request: an alloc ID
+// for the upstream scheduler and the GEM ports of the profile.
+func (req *OnuResourceRequest) AddTpInstance(TpInstance *tp_pb.TechProfileInstance) error {
+	if TpInstance == nil {
+		return fmt.Errorf("nil tech profile instance")
+	}
+	if TpInstance.NumGemPorts == 0 {
+		return fmt.Errorf("tech profile instance %s has no gem port", TpInstance.Name)
+	}
+	req.NumAllocIDs++
+	req.NumGemPorts += TpInstance.NumGemPorts
+	return nil
+}
+
+// OnuResourceBundle is the set of resources allocated to an ONU, stored in the KV store as a whole
+type OnuResourceBundle struct {
+	IntfID uint32 `json:"intf_id"`
+	OnuID  uint32 `json:"onu_id"`
+	// set when the ONU ID was allocated from the ONU ID pool along with the bundle, which then owns it
+	OnuIDAllocated bool     `json:"onu_id_allocated,omitempty"`
+	AllocIDs       []uint32 `json:"alloc_ids,omitempty"`
+	GemPortIDs     []uint32 `json:"gemport_ids,omitempty"`
+	FlowIDs        []uint32 `json:"flow_ids,omitempty"`
+}
+
+// IntfONUID returns the reference of the PON interface id and onu id used as key of the per ONU maps
+func (b *OnuResourceBundle) IntfONUID() string {
+	return fmt.Sprintf("%d,%d", b.IntfID, b.OnuID)
+}
+
+// bundleIDs are the IDs of a resource type in an ONU resource bundle
+type bundleIDs struct {
+	ResourceType string
+	IDs          []uint32
+}
+
+// resourceIDs returns the IDs owned by the bundle by resource type, in release order. The ONU ID
+// is only listed when allocated along with the bundle.
+func (b *OnuResourceBundle) resourceIDs() []bundleIDs {
+	var OnuIDs []uint32
+	if b.OnuIDAllocated {
+		OnuIDs = []uint32{b.OnuID}
+	}
+	return []bundleIDs{
+		{FLOW_ID, b.FlowIDs},
+		{GEMPORT_ID, b.GemPortIDs},
+		{ALLOC_ID, b.AllocIDs},
+		{ONU_ID, OnuIDs},
+	}
+}
+
+// withoutResource returns a copy of the bundle without the IDs of a resource type
+func (b *OnuResourceBundle) withoutResource(ResourceType string) *OnuResourceBundle {
+	Bundle := *b
+	switch ResourceType {
+	case ALLOC_ID:
+		Bundle.AllocIDs = nil
+	case GEMPORT_ID:
+		Bundle.GemPortIDs = nil
+	case FLOW_ID:
+		Bundle.FlowIDs = nil
+	}
+	return &Bundle
+}
+
+func (PONRMgr *PONResourceManager) onuResourceBundlePath(IntfONUID string) string {
+	return fmt.Sprintf(ONU_RESOURCE_BUNDLE_PATH, PONRMgr.DeviceID, IntfONUID)
+}
+
+// onuResourceMapPath returns the path of the per ONU map of the alloc, gem port or flow IDs
+func (PONRMgr *PONResourceManager) onuResourceMapPath(IntfONUID string, ResourceType string) string {
+	switch ResourceType {
+	case ALLOC_ID:
+		return fmt.Sprintf(ALLOC_ID_RESOURCE_MAP_PATH, PONRMgr.DeviceID, IntfONUID)
+	case GEMPORT_ID:
+		return fmt.Sprintf(GEMPORT_ID_RESOURCE_MAP_PATH, PONRMgr.DeviceID, IntfONUID)
+	}
+	return fmt.Sprintf(FLOW_ID_RESOURCE_MAP_PATH, PONRMgr.DeviceID, IntfONUID)
+}
+
+func (PONRMgr *PONResourceManager) AllocateOnuResources(ctx context.Context, Request OnuResourceRequest) (*OnuResourceBundle, error) {
+	/*
+	   Allocate the onu/alloc/gemport/flow ids of an ONU in one step. Either all the ids are allocated
+	   and the bundle is stored in the KV store, or the ids allocated so far are released.
+	   :param request: the resources to allocate, see OnuResourceRequest.AddTpInstance
+	   :return bundle: the ids allocated to the ONU
+	*/
+
+	logger.Debugw(ctx, "allocating-onu-resources", log.Fields{
+		"intf-id":       Request.IntfID,
+		"onu-id":        Request.OnuID,
+		"num-alloc-ids": Request.NumAllocIDs,
+		"num-gem-ports": Request.NumGemPorts,
+		"num-flow-ids":  Request.NumFlowIDs,
+	})
+
+	Bundle := &OnuResourceBundle{IntfID: Request.IntfID, OnuID: Request.OnuID}
+	rollback := func(err error) (*OnuResourceBundle, error) {
+		logger.Errorw(ctx, "onu-resources-allocation-failed", log.Fields{"intf-id": Request.IntfID, "error": err})
+		for _, Resource := range Bundle.resourceIDs() {
+			if len(Resource.IDs) == 0 {
+				continue
+			}
+			if FreeErr := PONRMgr.FreeResourceID(ctx, Request.IntfID, Resource.ResourceType, Resource.IDs); FreeErr != nil {
+				logger.Errorw(ctx, "failed-to-release-onu-resources", log.Fields{
+					"intf-id":       Request.IntfID,
+					"resource-type": Resource.ResourceType,
+					"ids":           Resource.IDs,
+					"error":         FreeErr,
+				})
+			}
+		}
+		return nil, err
+	}
+
+	if Bundle.OnuID == 0 {
+		OnuIDs, err := PONRMgr.GetResourceID(ctx, Request.IntfID, ONU_ID, 1)
+		if err != nil {
+			return rollback(fmt.Errorf("failed to allocate onu id: %w", err))
+		}
+		Bundle.OnuID = OnuIDs[0]
+		Bundle.OnuIDAllocated = true
+	}
+	Path := PONRMgr.onuResourceBundlePath(Bundle.IntfONUID())
+	if Existing, err := PONRMgr.KVStore.Get(ctx, Path); err != nil {
+		return rollback(fmt.Errorf("failed to read onu resources %s: %w", Path, err))
+	} else if Existing != nil {
+		return rollback(fmt.Errorf("resources already allocated to onu %s", Bundle.IntfONUID()))
+	}
+
+	var err error
+	if Request.NumAllocIDs > 0 {
+		if Bundle.AllocIDs, err = PONRMgr.GetResourceID(ctx, Request.IntfID, ALLOC_ID, Request.NumAllocIDs); err != nil {
+			Bundle.AllocIDs = nil
+			return rollback(fmt.Errorf("failed to allocate %d alloc ids: %w", Request.NumAllocIDs, err))
+		}
+	}
+	if Request.NumGemPorts > 0 {
+		if Bundle.GemPortIDs, err = PONRMgr.GetResourceID(ctx, Request.IntfID, GEMPORT_ID, Request.NumGemPorts); err != nil {
+			Bundle.GemPortIDs = nil
+			return rollback(fmt.Errorf("failed to allocate %d gem ports: %w", Request.NumGemPorts, err))
+		}
+	}
+	// flow IDs are handed out one at a time by GetResourceID
+	for i := uint32(0); i < Request.NumFlowIDs; i++ {
+		FlowIDs, err := PONRMgr.GetResourceID(ctx, Request.IntfID, FLOW_ID, 1)
+		if err != nil {
+			return rollback(fmt.Errorf("failed to allocate %d flow ids: %w", Request.NumFlowIDs, err))
+		}
+		Bundle.FlowIDs = append(Bundle.FlowIDs, FlowIDs...)
+	}
+
+	// The bundle is the record of the allocation. It is written along with the per ONU maps, kept up
+	// to date for the callers of GetCurrentAllocIDForOnu and the like, in a single transaction.
+	Value, err := json.Marshal(Bundle)
+	if err != nil {
+		return rollback(fmt.Errorf("failed to marshal onu resources: %w", err))
+	}
+	Ops := []kvstore.TxnOp{{Key: Path, Value: Value}}
+	for _, Resource := range Bundle.resourceIDs() {
+		if Resource.ResourceType == ONU_ID || len(Resource.IDs) == 0 {
+			continue
+		}
+		if Value, err = json.Marshal(Resource.IDs); err != nil {
+			return rollback(fmt.Errorf("failed to marshal onu resources: %w", err))
+		}
+		Ops = append(Ops, kvstore.TxnOp{Key: PONRMgr.onuResourceMapPath(Bundle.IntfONUID(), Resource.ResourceType), Value: Value})
+	}
+	if err = PONRMgr.KVStore.Txn(ctx, Ops); err != nil {
+		return rollback(fmt.Errorf("failed to store onu resources %s: %w", Path, err))
+	}
+
+	logger.Infow(ctx, "onu-resources-allocated", log.Fields{
+		"intf-id":      Bundle.IntfID,
+		"onu-id":       Bundle.OnuID,
+		"alloc-ids":    Bundle.AllocIDs,
+		"gemport-ids":  Bundle.GemPortIDs,
+		"flow-ids":     Bundle.FlowIDs,
+		"onu-resource": Path,
+	})
+	return Bundle, nil
+}
+
+func (PONRMgr *PONResourceManager) GetOnuResources(ctx context.Context, IntfID uint32, OnuID uint32) (*OnuResourceBundle, error) {
+	/*
+	   Get the resource bundle of an ONU
+	   :param pon_intf_id: OLT PON interface id
+	   :param onu_id: ONU id
+	   :return bundle: the ids allocated to the ONU, nil if none was allocated by AllocateOnuResources
+	*/
+	Path := PONRMgr.onuResourceBundlePath(fmt.Sprintf("%d,%d", IntfID, OnuID))
+	Value, err := PONRMgr.KVStore.Get(ctx, Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read onu resources %s: %w", Path, err)
+	}
+	if Value == nil {
+		return nil, nil
+	}
+	Data, err := ToByte(Value.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read onu resources %s: %w", Path, err)
+	}
+	Bundle := &OnuResourceBundle{}
+	if err = json.Unmarshal(Data, Bundle); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal onu resources %s: %w", Path, err)
+	}
+	return Bundle, nil
+}
+
+func (PONRMgr *PONResourceManager) FreeOnuResources(ctx context.Context, IntfID uint32, OnuID uint32) error {
+	/*
+	   Release all the resources of an ONU allocated by AllocateOnuResources, including its onu id
+	   when allocated there, and remove its per ONU maps and flow info. Each resource type is dropped
+	   from the stored bundle, and its per ONU map removed, in the same transaction as the release of
+	   its IDs, so that retrying a failed release never releases IDs handed out to another ONU since.
+	   The bundle is removed last, along with the release of the onu id if owned by the bundle.
+	   :param pon_intf_id: OLT PON interface id
+	   :param onu_id: ONU id
+	*/
+	Bundle, err := PONRMgr.GetOnuResources(ctx, IntfID, OnuID)
+	if err != nil {
+		logger.Error(ctx, err.Error())
+		return err
+	}
+	if Bundle == nil {
+		return fmt.Errorf("no resources allocated to onu %d,%d", IntfID, OnuID)
+	}
+	logger.Debugw(ctx, "freeing-onu-resources", log.Fields{
+		"intf-id":     IntfID,
+		"onu-id":      OnuID,
+		"alloc-ids":   Bundle.AllocIDs,
+		"gemport-ids": Bundle.GemPortIDs,
+		"flow-ids":    Bundle.FlowIDs,
+	})
+
+	// the flow info references the flow and gem port IDs, it is removed first
+	if err = PONRMgr.RemoveAllFlowInfoForOnu(ctx, IntfID, Bundle.OnuID); err != nil {
+		return fmt.Errorf("failed to remove flow info of onu %s: %w", Bundle.IntfONUID(), err)
+	}
+	if !PONRMgr.RemoveAllFlowIDInfo(ctx, Bundle.IntfONUID()) {
+		return fmt.Errorf("failed to remove flow id info of onu %s", Bundle.IntfONUID())
+	}
+
+	Path := PONRMgr.onuResourceBundlePath(Bundle.IntfONUID())
+	for _, Resource := range Bundle.resourceIDs() {
+		if len(Resource.IDs) == 0 || Resource.ResourceType == ONU_ID {
+			continue
+		}
+		Bundle = Bundle.withoutResource(Resource.ResourceType)
+		Value, err := json.Marshal(Bundle)
+		if err != nil {
+			return fmt.Errorf("failed to marshal onu resources: %w", err)
+		}
+		Ops := []kvstore.TxnOp{
+			{Key: Path, Value: Value},
+			{Key: PONRMgr.onuResourceMapPath(Bundle.IntfONUID(), Resource.ResourceType), Delete: true},
+		}
+		if err = PONRMgr.freeResourceID(ctx, IntfID, Resource.ResourceType, Resource.IDs, Ops); err != nil {
+			err = fmt.Errorf("failed to release %s of onu %s: %w", Resource.ResourceType, Bundle.IntfONUID(), err)
+			logger.Error(ctx, err.Error())
+			return err
+		}
+	}
+
+	// an onu id given by the caller of AllocateOnuResources is left allocated
+	Ops := []kvstore.TxnOp{{Key: Path, Delete: true}}
+	if Bundle.OnuIDAllocated {
+		err = PONRMgr.freeResourceID(ctx, IntfID, ONU_ID, []uint32{Bundle.OnuID}, Ops)
+	} else {
+		err = PONRMgr.KVStore.Txn(ctx, Ops)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to release %s of onu %s: %w", ONU_ID, Bundle.IntfONUID(), err)
+		logger.Error(ctx, err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ponresourcemanager
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"github.com/stretchr/testify/assert"
+)
+
+func assertPoolsUsed(t *testing.T, ctx context.Context, PONRMgr *PONResourceManager, expected map[string]uint32) {
+	for ResourceType, Used := range expected {
+		pu, err := PONRMgr.GetPoolUtilization(ctx, 0, ResourceType)
+		assert.Nil(t, err)
+		assert.Equal(t, Used, pu.Used, ResourceType)
+	}
+}
+
+func TestAllocateOnuResources(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, _ := newTestPONResourceManager(ctx)
+	initTestPools(t, ctx, PONRMgr)
+
+	req := OnuResourceRequest{IntfID: 0, NumFlowIDs: 2}
+	assert.Nil(t, req.AddTpInstance(&tp_pb.TechProfileInstance{Name: "tp64", NumGemPorts: 2}))
+	assert.Nil(t, req.AddTpInstance(&tp_pb.TechProfileInstance{Name: "tp65", NumGemPorts: 1}))
+	assert.NotNil(t, req.AddTpInstance(&tp_pb.TechProfileInstance{Name: "tp66"}))
+	assert.NotNil(t, req.AddTpInstance(nil))
+
+	bundle, err := PONRMgr.AllocateOnuResources(ctx, req)
+	assert.Nil(t, err)
+	assert.Equal(t, &OnuResourceBundle{IntfID: 0, OnuID: 1, OnuIDAllocated: true, AllocIDs: []uint32{1024, 1025},
+		GemPortIDs: []uint32{1024, 1025, 1026}, FlowIDs: []uint32{1, 2}}, bundle)
+
+	stored, err := PONRMgr.GetOnuResources(ctx, 0, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, bundle, stored)
+	assert.Equal(t, []uint32{1024, 1025}, PONRMgr.GetCurrentAllocIDForOnu(ctx, "0,1"))
+	assert.Equal(t, []uint32{1024, 1025, 1026}, PONRMgr.GetCurrentGEMPortIDsForOnu(ctx, "0,1"))
+	assert.Equal(t, []uint32{1, 2}, PONRMgr.GetCurrentFlowIDsForOnu(ctx, "0,1"))
+
+	// an ONU ID already allocated is used as is, and a bundle is allocated only once per ONU
+	assert.Nil(t, PONRMgr.ReserveResourceIDs(ctx, 0, ONU_ID, []uint32{5}))
+	bundle, err = PONRMgr.AllocateOnuResources(ctx, OnuResourceRequest{IntfID: 0, OnuID: 5, NumAllocIDs: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(5), bundle.OnuID)
+	assert.False(t, bundle.OnuIDAllocated)
+	_, err = PONRMgr.AllocateOnuResources(ctx, OnuResourceRequest{IntfID: 0, OnuID: 5, NumAllocIDs: 1})
+	assert.NotNil(t, err)
+	assertPoolsUsed(t, ctx, PONRMgr, map[string]uint32{ONU_ID: 2, ALLOC_ID: 3, GEMPORT_ID: 3, FLOW_ID: 2})
+
+	assert.Nil(t, PONRMgr.FreeOnuResources(ctx, 0, 1))
+	assertPoolsUsed(t, ctx, PONRMgr, map[string]uint32{ONU_ID: 1, ALLOC_ID: 1, GEMPORT_ID: 0, FLOW_ID: 0})
+	stored, err = PONRMgr.GetOnuResources(ctx, 0, 1)
+	assert.Nil(t, err)
+	assert.Nil(t, stored)
+	assert.Nil(t, PONRMgr.GetCurrentGEMPortIDsForOnu(ctx, "0,1"))
+	assert.NotNil(t, PONRMgr.FreeOnuResources(ctx, 0, 1))
+
+	// the ONU ID given by the caller is not released with the bundle
+	assert.Nil(t, PONRMgr.FreeOnuResources(ctx, 0, 5))
+	assertPoolsUsed(t, ctx, PONRMgr, map[string]uint32{ONU_ID: 1, ALLOC_ID: 0, GEMPORT_ID: 0, FLOW_ID: 0})
+	stored, err = PONRMgr.GetOnuResources(ctx, 0, 5)
+	assert.Nil(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestAllocateOnuResourcesRollback(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, kv := newTestPONResourceManager(ctx)
+	initTestPools(t, ctx, PONRMgr)
+
+	// the gem port pool holds 8 IDs
+	_, err := PONRMgr.AllocateOnuResources(ctx, OnuResourceRequest{IntfID: 0, NumAllocIDs: 1, NumGemPorts: 9})
+	assert.NotNil(t, err)
+	assertPoolsUsed(t, ctx, PONRMgr, map[string]uint32{ONU_ID: 0, ALLOC_ID: 0, GEMPORT_ID: 0, FLOW_ID: 0})
+
+	// nothing is left behind when the per ONU maps cannot be stored
+	kv.SetFailPut(func(key string) bool { return strings.HasSuffix(key, "/flow_ids") })
+	_, err = PONRMgr.AllocateOnuResources(ctx, OnuResourceRequest{IntfID: 0, NumAllocIDs: 1, NumGemPorts: 2, NumFlowIDs: 1})
+	assert.NotNil(t, err)
+	assertPoolsUsed(t, ctx, PONRMgr, map[string]uint32{ONU_ID: 0, ALLOC_ID: 0, GEMPORT_ID: 0, FLOW_ID: 0})
+	stored, err := PONRMgr.GetOnuResources(ctx, 0, 1)
+	assert.Nil(t, err)
+	assert.Nil(t, stored)
+	assert.Nil(t, PONRMgr.GetCurrentAllocIDForOnu(ctx, "0,1"))
+
+	// a given ONU ID is not released by the rollback
+	kv.SetFailPut(nil)
+	assert.Nil(t, PONRMgr.ReserveResourceIDs(ctx, 0, ONU_ID, []uint32{3}))
+	_, err = PONRMgr.AllocateOnuResources(ctx, OnuResourceRequest{IntfID: 0, OnuID: 3, NumAllocIDs: 9})
+	assert.NotNil(t, err)
+	allocated, err := PONRMgr.IsAllocated(ctx, 0, ONU_ID, 3)
+	assert.Nil(t, err)
+	assert.True(t, allocated)
+}
+
+func TestFreeOnuResourcesRetry(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, kv := newTestPONResourceManager(ctx)
+	initTestPools(t, ctx, PONRMgr)
+
+	bundle, err := PONRMgr.AllocateOnuResources(ctx, OnuResourceRequest{IntfID: 0, NumAllocIDs: 1, NumGemPorts: 2, NumFlowIDs: 1})
+	assert.Nil(t, err)
+	assert.Nil(t, PONRMgr.UpdateFlowInfo(ctx, &FlowInfo{IntfID: 0, OnuID: bundle.OnuID, FlowID: bundle.FlowIDs[0], Cookie: 10, GemPortID: bundle.GemPortIDs[0]}))
+
+	// the release fails once the flow IDs are released
+	kv.SetFailPut(func(key string) bool { return strings.Contains(key, "gemport_id_pool") })
+	assert.NotNil(t, PONRMgr.FreeOnuResources(ctx, 0, bundle.OnuID))
+	stored, err := PONRMgr.GetOnuResources(ctx, 0, bundle.OnuID)
+	assert.Nil(t, err)
+	assert.Nil(t, stored.FlowIDs)
+	assert.Equal(t, bundle.GemPortIDs, stored.GemPortIDs)
+	assert.Nil(t, PONRMgr.GetCurrentFlowIDsForOnu(ctx, bundle.IntfONUID()))
+	infos, err := PONRMgr.GetFlowInfosByCookie(ctx, 10)
+	assert.Nil(t, err)
+	assert.Empty(t, infos)
+
+	// the flow ID handed out to another ONU meanwhile is not released by the retry
+	kv.SetFailPut(nil)
+	flowIDs, err := PONRMgr.GetResourceID(ctx, 0, FLOW_ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, bundle.FlowIDs, flowIDs)
+	assert.Nil(t, PONRMgr.FreeOnuResources(ctx, 0, bundle.OnuID))
+	assertPoolsUsed(t, ctx, PONRMgr, map[string]uint32{ONU_ID: 0, ALLOC_ID: 0, GEMPORT_ID: 0, FLOW_ID: 1})
+	stored, err = PONRMgr.GetOnuResources(ctx, 0, bundle.OnuID)
+	assert.Nil(t, err)
+	assert.Nil(t, stored)
+}
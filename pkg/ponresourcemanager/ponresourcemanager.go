@@ -18,13 +18,12 @@ package ponresourcemanager
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
-	bitmap "github.com/boljen/go-bitmap"
 	"github.com/opencord/voltha-lib-go/v7/pkg/db"
 	"github.com/opencord/voltha-lib-go/v7/pkg/db/kvstore"
 	"github.com/opencord/voltha-lib-go/v7/pkg/log"
@@ -151,6 +150,20 @@ type PONResourceManager struct {
 	SharedIdxByType    map[string]string
 	IntfIDs            []uint32 // list of pon interface IDs
 	Globalorlocal      string
+	// ranges overridden per PON technology and per PON interface, see SetRangeProfiles
+	RangeProfiles *RangeProfiles
+	// strategy used to allocate the IDs of each resource type, lowest free ID if not set.
+	// Must only be updated through SetAllocationStrategy.
+	AllocationStrategies map[string]AllocationStrategy
+
+	// reporting of the utilization of the resource pools, see SetUtilizationReporting
+	utilization *utilizationMonitor
+	// serializes the updates of the resource pools owned by this manager
+	poolLock sync.Mutex
+	// protects AllocationStrategies
+	strategiesLock sync.RWMutex
+	// serializes the updates of the indexed flow info, see UpdateFlowInfo
+	flowInfoLock sync.Mutex
 }
 
 func newKVClient(ctx context.Context, storeType string, address string, timeout time.Duration) (kvstore.Client, error) {
@@ -234,8 +247,10 @@ func NewPONResourceManager(ctx context.Context, Technology string, DeviceType st
 	PONMgr.SharedIdxByType[ALLOC_ID] = ALLOC_ID_SHARED_IDX
 	PONMgr.SharedIdxByType[GEMPORT_ID] = GEMPORT_ID_SHARED_IDX
 	PONMgr.SharedIdxByType[FLOW_ID] = FLOW_ID_SHARED_IDX
+	PONMgr.AllocationStrategies = make(map[string]AllocationStrategy)
 	PONMgr.IntfIDs = make([]uint32, NUM_OF_PON_INTF)
 	PONMgr.OLTModel = DeviceType
+	PONMgr.utilization = newUtilizationMonitor()
 	return &PONMgr, nil
 }
 
@@ -273,10 +288,15 @@ func (PONRMgr *PONResourceManager) InitResourceRangesFromKVStore(ctx context.Con
 		logger.Error(ctx, "Failed to convert kvpair to byte string")
 		return false
 	}
-	if err := json.Unmarshal(Value, &PONRMgr.PonResourceRanges); err != nil {
-		logger.Error(ctx, "Failed to Unmarshal json byte")
+	Ranges, Profiles, err := decodeResourceRanges(Value)
+	if err != nil {
+		logger.Errorw(ctx, "Failed to decode the resource ranges", log.Fields{"path": Path, "error": err})
 		return false
 	}
+	for Key, Range := range Ranges {
+		PONRMgr.PonResourceRanges[Key] = Range
+	}
+	PONRMgr.RangeProfiles = Profiles
 	logger.Debug(ctx, "Init resource ranges from kvstore success")
 	return true
 }
@@ -372,14 +392,16 @@ func (PONRMgr *PONResourceManager) InitDeviceResourcePool(ctx context.Context) e
 	logger.Debug(ctx, "Init resource ranges")
 
 	var err error
+	if err = PONRMgr.validateIntfResourceRanges(ctx); err != nil {
+		return err
+	}
 	for _, Intf := range PONRMgr.IntfIDs {
+		StartID, EndID := PONRMgr.GetIntfResourceRanges(Intf).Range(ONU_ID)
 		SharedPoolID := PONRMgr.PonResourceRanges[ONU_ID_SHARED_IDX].(uint32)
 		if SharedPoolID != 0 {
 			Intf = SharedPoolID
 		}
-		if err = PONRMgr.InitResourceIDPool(ctx, Intf, ONU_ID,
-			PONRMgr.PonResourceRanges[ONU_ID_START_IDX].(uint32),
-			PONRMgr.PonResourceRanges[ONU_ID_END_IDX].(uint32)); err != nil {
+		if err = PONRMgr.InitResourceIDPool(ctx, Intf, ONU_ID, StartID, EndID); err != nil {
 			logger.Error(ctx, "Failed to init ONU ID resource pool")
 			return err
 		}
@@ -389,13 +411,12 @@ func (PONRMgr *PONResourceManager) InitDeviceResourcePool(ctx context.Context) e
 	}
 
 	for _, Intf := range PONRMgr.IntfIDs {
+		StartID, EndID := PONRMgr.GetIntfResourceRanges(Intf).Range(ALLOC_ID)
 		SharedPoolID := PONRMgr.PonResourceRanges[ALLOC_ID_SHARED_IDX].(uint32)
 		if SharedPoolID != 0 {
 			Intf = SharedPoolID
 		}
-		if err = PONRMgr.InitResourceIDPool(ctx, Intf, ALLOC_ID,
-			PONRMgr.PonResourceRanges[ALLOC_ID_START_IDX].(uint32),
-			PONRMgr.PonResourceRanges[ALLOC_ID_END_IDX].(uint32)); err != nil {
+		if err = PONRMgr.InitResourceIDPool(ctx, Intf, ALLOC_ID, StartID, EndID); err != nil {
 			logger.Error(ctx, "Failed to init ALLOC ID resource pool ")
 			return err
 		}
@@ -404,13 +425,12 @@ func (PONRMgr *PONResourceManager) InitDeviceResourcePool(ctx context.Context) e
 		}
 	}
 	for _, Intf := range PONRMgr.IntfIDs {
+		StartID, EndID := PONRMgr.GetIntfResourceRanges(Intf).Range(GEMPORT_ID)
 		SharedPoolID := PONRMgr.PonResourceRanges[GEMPORT_ID_SHARED_IDX].(uint32)
 		if SharedPoolID != 0 {
 			Intf = SharedPoolID
 		}
-		if err = PONRMgr.InitResourceIDPool(ctx, Intf, GEMPORT_ID,
-			PONRMgr.PonResourceRanges[GEMPORT_ID_START_IDX].(uint32),
-			PONRMgr.PonResourceRanges[GEMPORT_ID_END_IDX].(uint32)); err != nil {
+		if err = PONRMgr.InitResourceIDPool(ctx, Intf, GEMPORT_ID, StartID, EndID); err != nil {
 			logger.Error(ctx, "Failed to init GEMPORT ID resource pool")
 			return err
 		}
@@ -420,13 +440,12 @@ func (PONRMgr *PONResourceManager) InitDeviceResourcePool(ctx context.Context) e
 	}
 
 	for _, Intf := range PONRMgr.IntfIDs {
+		StartID, EndID := PONRMgr.GetIntfResourceRanges(Intf).Range(FLOW_ID)
 		SharedPoolID := PONRMgr.PonResourceRanges[FLOW_ID_SHARED_IDX].(uint32)
 		if SharedPoolID != 0 {
 			Intf = SharedPoolID
 		}
-		if err = PONRMgr.InitResourceIDPool(ctx, Intf, FLOW_ID,
-			PONRMgr.PonResourceRanges[FLOW_ID_START_IDX].(uint32),
-			PONRMgr.PonResourceRanges[FLOW_ID_END_IDX].(uint32)); err != nil {
+		if err = PONRMgr.InitResourceIDPool(ctx, Intf, FLOW_ID, StartID, EndID); err != nil {
 			logger.Error(ctx, "Failed to init FLOW ID resource pool")
 			return err
 		}
@@ -442,31 +461,28 @@ func (PONRMgr *PONResourceManager) InitDeviceResourcePoolForIntf(ctx context.Con
 	logger.Debug(ctx, "Init resource ranges for intf %d", intfID)
 
 	var err error
+	Ranges := PONRMgr.GetIntfResourceRanges(intfID)
 
-	if err = PONRMgr.InitResourceIDPool(ctx, intfID, ONU_ID,
-		PONRMgr.PonResourceRanges[ONU_ID_START_IDX].(uint32),
-		PONRMgr.PonResourceRanges[ONU_ID_END_IDX].(uint32)); err != nil {
+	StartID, EndID := Ranges.Range(ONU_ID)
+	if err = PONRMgr.InitResourceIDPool(ctx, intfID, ONU_ID, StartID, EndID); err != nil {
 		logger.Error(ctx, "Failed to init ONU ID resource pool")
 		return err
 	}
 
-	if err = PONRMgr.InitResourceIDPool(ctx, intfID, ALLOC_ID,
-		PONRMgr.PonResourceRanges[ALLOC_ID_START_IDX].(uint32),
-		PONRMgr.PonResourceRanges[ALLOC_ID_END_IDX].(uint32)); err != nil {
+	StartID, EndID = Ranges.Range(ALLOC_ID)
+	if err = PONRMgr.InitResourceIDPool(ctx, intfID, ALLOC_ID, StartID, EndID); err != nil {
 		logger.Error(ctx, "Failed to init ALLOC ID resource pool ")
 		return err
 	}
 
-	if err = PONRMgr.InitResourceIDPool(ctx, intfID, GEMPORT_ID,
-		PONRMgr.PonResourceRanges[GEMPORT_ID_START_IDX].(uint32),
-		PONRMgr.PonResourceRanges[GEMPORT_ID_END_IDX].(uint32)); err != nil {
+	StartID, EndID = Ranges.Range(GEMPORT_ID)
+	if err = PONRMgr.InitResourceIDPool(ctx, intfID, GEMPORT_ID, StartID, EndID); err != nil {
 		logger.Error(ctx, "Failed to init GEMPORT ID resource pool")
 		return err
 	}
 
-	if err = PONRMgr.InitResourceIDPool(ctx, intfID, FLOW_ID,
-		PONRMgr.PonResourceRanges[FLOW_ID_START_IDX].(uint32),
-		PONRMgr.PonResourceRanges[FLOW_ID_END_IDX].(uint32)); err != nil {
+	StartID, EndID = Ranges.Range(FLOW_ID)
+	if err = PONRMgr.InitResourceIDPool(ctx, intfID, FLOW_ID, StartID, EndID); err != nil {
 		logger.Error(ctx, "Failed to init FLOW ID resource pool")
 		return err
 	}
@@ -655,19 +671,10 @@ func (PONRMgr *PONResourceManager) FormatResource(ctx context.Context, IntfID ui
 	   :return dictionary: resource formatted as map
 	*/
 	// Format resource as json to be stored in backend store
-	Resource := make(map[string]interface{})
-	Resource[PON_INTF_ID] = IntfID
-	Resource[START_IDX] = StartIDx
-	Resource[END_IDX] = EndIDx
-	/*
-	   Resource pool stored in backend store as binary string.
-	   Tracking the resource allocation will be done by setting the bits \
-	   in the byte array. The index set will be the resource number allocated.
-	*/
-	var TSData *bitmap.Threadsafe
-	if TSData = bitmap.NewTS(int(EndIDx)); TSData == nil {
-		logger.Error(ctx, "Failed to create a bitmap")
-		return nil, errors.New("failed to create bitmap")
+	Resource, err := NewResourcePool(IntfID, StartIDx, EndIDx)
+	if err != nil {
+		logger.Errorw(ctx, "Failed to create resource pool", log.Fields{"error": err})
+		return nil, err
 	}
 	for _, excludedID := range Excluded {
 		if excludedID < StartIDx || excludedID > EndIDx {
@@ -675,18 +682,17 @@ func (PONRMgr *PONResourceManager) FormatResource(ctx context.Context, IntfID ui
 				StartIDx, EndIDx)
 			continue
 		}
-		PONRMgr.reserveID(ctx, TSData, StartIDx, excludedID)
+		PONRMgr.reserveID(ctx, Resource, excludedID)
 	}
-	Resource[POOL] = TSData.Data(false) //we pass false so as the TSData lib api does not do a copy of the data and return
 
-	Value, err := json.Marshal(Resource)
+	Value, err := Resource.Marshal()
 	if err != nil {
 		logger.Errorf(ctx, "Failed to marshall resource")
 		return nil, err
 	}
 	return Value, err
 }
-func (PONRMgr *PONResourceManager) GetResource(ctx context.Context, Path string) (map[string]interface{}, error) {
+func (PONRMgr *PONResourceManager) GetResource(ctx context.Context, Path string) (*ResourcePool, error) {
 	/*
 	   Get resource from kv store.
 
@@ -694,46 +700,24 @@ func (PONRMgr *PONResourceManager) GetResource(ctx context.Context, Path string)
 	   :return: resource if resource present in kv store else None
 	*/
 	//get resource from kv store
-
-	var Value []byte
-	Result := make(map[string]interface{})
-	var Str string
-
 	Resource, err := PONRMgr.KVStore.Get(ctx, Path)
 	if (err != nil) || (Resource == nil) {
 		logger.Debugf(ctx, "Resource  unavailable at %s", Path)
 		return nil, err
 	}
 
-	Value, err = ToByte(Resource.Value)
+	Value, err := ToByte(Resource.Value)
 	if err != nil {
 		return nil, err
 	}
 
-	// decode resource fetched from backend store to dictionary
-	err = json.Unmarshal(Value, &Result)
-	if err != nil {
-		logger.Error(ctx, "Failed to decode resource")
-		return Result, err
-	}
-	/*
-	   resource pool in backend store stored as binary string whereas to
-	   access the pool to generate/release IDs it need to be converted
-	   as BitArray
-	*/
-	Str, err = ToString(Result[POOL])
-	if err != nil {
-		logger.Error(ctx, "Failed to conver to kv pair to string")
-		return Result, err
-	}
-	Decode64, _ := base64.StdEncoding.DecodeString(Str)
-	Result[POOL], err = ToByte(Decode64)
+	// decode resource fetched from backend store, migrating the legacy layout
+	Result, err := UnmarshalResourcePool(Value)
 	if err != nil {
-		logger.Error(ctx, "Failed to convert resource pool to byte")
-		return Result, err
+		logger.Errorw(ctx, "Failed to decode resource", log.Fields{"path": Path, "error": err})
+		return nil, err
 	}
-
-	return Result, err
+	return Result, nil
 }
 
 func (PONRMgr *PONResourceManager) GetPath(ctx context.Context, IntfID uint32, ResourceType string) string {
@@ -802,25 +786,28 @@ func (PONRMgr *PONResourceManager) GetResourceID(ctx context.Context, IntfID uin
 		return nil, fmt.Errorf("failed to get path for resource type %s", ResourceType)
 	}
 	logger.Debugf(ctx, "Get resource for type %s on path %s", ResourceType, Path)
+	PONRMgr.poolLock.Lock()
+	defer PONRMgr.poolLock.Unlock()
 	var Result []uint32
 	var NextID uint32
+	Strategy := PONRMgr.allocationStrategy(ResourceType)
 	Resource, err := PONRMgr.GetResource(ctx, Path)
 	if (err == nil) && (ResourceType == ONU_ID) || (ResourceType == FLOW_ID) {
-		if NextID, err = PONRMgr.GenerateNextID(ctx, Resource); err != nil {
+		if NextID, err = PONRMgr.generateNextID(ctx, Strategy, Resource); err != nil {
 			logger.Error(ctx, "Failed to Generate ID")
 			return Result, err
 		}
 		Result = append(Result, NextID)
 	} else if (err == nil) && ((ResourceType == GEMPORT_ID) || (ResourceType == ALLOC_ID)) {
 		if NumIDs == 1 {
-			if NextID, err = PONRMgr.GenerateNextID(ctx, Resource); err != nil {
+			if NextID, err = PONRMgr.generateNextID(ctx, Strategy, Resource); err != nil {
 				logger.Error(ctx, "Failed to Generate ID")
 				return Result, err
 			}
 			Result = append(Result, NextID)
 		} else {
 			for NumIDs > 0 {
-				if NextID, err = PONRMgr.GenerateNextID(ctx, Resource); err != nil {
+				if NextID, err = PONRMgr.generateNextID(ctx, Strategy, Resource); err != nil {
 					logger.Error(ctx, "Failed to Generate ID")
 					return Result, err
 				}
@@ -838,6 +825,7 @@ func (PONRMgr *PONResourceManager) GetResourceID(ctx context.Context, IntfID uin
 		logger.Errorf(ctx, "Failed to update resource %s", Path)
 		return nil, fmt.Errorf("failed to update resource %s", Path)
 	}
+	PONRMgr.checkUtilization(ctx, IntfID, ResourceType, Path, Resource)
 	return Result, nil
 }
 
@@ -877,10 +865,23 @@ func (PONRMgr *PONResourceManager) FreeResourceID(ctx context.Context, IntfID ui
 		logger.Debug(ctx, err.Error())
 		return err
 	}
+	return PONRMgr.freeResourceID(ctx, IntfID, ResourceType, ReleaseContent, nil)
+}
+
+// freeResourceID releases the IDs and applies the Ops to the KV store of the manager in the same
+// transaction as the update of the pool. When the pool is owned by another manager, the Ops are
+// applied once the pool is updated.
+func (PONRMgr *PONResourceManager) freeResourceID(ctx context.Context, IntfID uint32, ResourceType string, ReleaseContent []uint32, Ops []kvstore.TxnOp) error {
 	// delegate to the master instance if sharing enabled across instances
 	SharedResourceMgr := PONRMgr.SharedResourceMgrs[PONRMgr.SharedIdxByType[ResourceType]]
 	if SharedResourceMgr != nil && PONRMgr != SharedResourceMgr {
-		return SharedResourceMgr.FreeResourceID(ctx, IntfID, ResourceType, ReleaseContent)
+		if err := SharedResourceMgr.FreeResourceID(ctx, IntfID, ResourceType, ReleaseContent); err != nil {
+			return err
+		}
+		if len(Ops) > 0 {
+			return PONRMgr.KVStore.Txn(ctx, Ops)
+		}
+		return nil
 	}
 	Path := PONRMgr.GetPath(ctx, IntfID, ResourceType)
 	if Path == "" {
@@ -888,36 +889,162 @@ func (PONRMgr *PONResourceManager) FreeResourceID(ctx context.Context, IntfID ui
 		logger.Error(ctx, err.Error())
 		return err
 	}
+	PONRMgr.poolLock.Lock()
+	defer PONRMgr.poolLock.Unlock()
 	Resource, err := PONRMgr.GetResource(ctx, Path)
 	if err != nil {
 		logger.Error(ctx, err.Error())
 		return err
 	}
+	Strategy := PONRMgr.allocationStrategy(ResourceType)
 	for _, Val := range ReleaseContent {
-		PONRMgr.ReleaseID(ctx, Resource, Val)
+		PONRMgr.releaseID(ctx, Strategy, Resource, Val)
 	}
-	if PONRMgr.UpdateResource(ctx, Path, Resource) != nil {
+	if PONRMgr.updateResource(ctx, Path, Resource, Ops) != nil {
 		err := fmt.Errorf("free resource for %s failed", Path)
 		logger.Errorf(ctx, err.Error())
 		return err
 	}
+	PONRMgr.checkUtilization(ctx, IntfID, ResourceType, Path, Resource)
+	return nil
+}
+
+func (PONRMgr *PONResourceManager) ReserveResourceIDs(ctx context.Context, IntfID uint32, ResourceType string, IDs []uint32) error {
+	/*
+	   Reserve specific alloc/gemport/onu/flow ids for given OLT PON interface, e.g. the ids found in use
+	   on the OLT hardware. Either all the ids are reserved or none is.
+	   :param pon_intf_id: OLT PON interface id
+	   :param resource_type: String to identify type of resource
+	   :param ids: ids to reserve
+	   :return error: if any id is already allocated or out of the boundaries of the pool
+	*/
+
+	logger.Debugw(ctx, "reserving-resource-ids", log.Fields{
+		"intf-id":       IntfID,
+		"resource-type": ResourceType,
+		"ids":           IDs,
+	})
+
+	if !checkValidResourceType(ResourceType) {
+		err := fmt.Errorf("invalid resource type: %s", ResourceType)
+		logger.Error(ctx, err.Error())
+		return err
+	}
+	if len(IDs) == 0 {
+		return fmt.Errorf("nothing to reserve")
+	}
+	// delegate to the master instance if sharing enabled across instances
+	SharedResourceMgr := PONRMgr.SharedResourceMgrs[PONRMgr.SharedIdxByType[ResourceType]]
+	if SharedResourceMgr != nil && PONRMgr != SharedResourceMgr {
+		return SharedResourceMgr.ReserveResourceIDs(ctx, IntfID, ResourceType, IDs)
+	}
+	Path := PONRMgr.GetPath(ctx, IntfID, ResourceType)
+	if Path == "" {
+		err := fmt.Errorf("failed to get path for IntfId %d and ResourceType %s", IntfID, ResourceType)
+		logger.Error(ctx, err.Error())
+		return err
+	}
+	PONRMgr.poolLock.Lock()
+	defer PONRMgr.poolLock.Unlock()
+	Resource, err := PONRMgr.GetResource(ctx, Path)
+	if err != nil {
+		logger.Error(ctx, err.Error())
+		return err
+	}
+	if Resource == nil {
+		return fmt.Errorf("resource pool %s not found", Path)
+	}
+	// check all the ids before reserving any
+	HoldDown, _ := PONRMgr.allocationStrategy(ResourceType).(holdDownStrategy)
+	Requested := make(map[uint32]bool, len(IDs))
+	for _, ID := range IDs {
+		if !Resource.Contains(ID) {
+			return fmt.Errorf("id %d is out of the boundaries of the pool %s", ID, Path)
+		}
+		if Requested[ID] {
+			return fmt.Errorf("id %d requested more than once", ID)
+		}
+		if Resource.IsSet(int(ID - Resource.StartIdx)) {
+			return fmt.Errorf("id %d is already allocated in the pool %s", ID, Path)
+		}
+		if HoldDown != nil && HoldDown.InHoldDown(Resource, int(ID-Resource.StartIdx)) {
+			return fmt.Errorf("id %d is in hold-down in the pool %s", ID, Path)
+		}
+		Requested[ID] = true
+	}
+	for _, ID := range IDs {
+		Resource.set(int(ID-Resource.StartIdx), true)
+		Resource.setReserved(ID, true)
+	}
+
+	if PONRMgr.UpdateResource(ctx, Path, Resource) != nil {
+		err := fmt.Errorf("reserve resource for %s failed", Path)
+		logger.Error(ctx, err.Error())
+		return err
+	}
+	PONRMgr.checkUtilization(ctx, IntfID, ResourceType, Path, Resource)
 	return nil
 }
 
-func (PONRMgr *PONResourceManager) UpdateResource(ctx context.Context, Path string, Resource map[string]interface{}) error {
+func (PONRMgr *PONResourceManager) IsAllocated(ctx context.Context, IntfID uint32, ResourceType string, ID uint32) (bool, error) {
+	/*
+	   Check whether an alloc/gemport/onu/flow id is allocated for given OLT PON interface.
+	   :param pon_intf_id: OLT PON interface id
+	   :param resource_type: String to identify type of resource
+	   :param id: id to check
+	   :return boolean: True if the id is allocated or reserved
+	*/
+	if !checkValidResourceType(ResourceType) {
+		return false, fmt.Errorf("invalid resource type: %s", ResourceType)
+	}
+	// delegate to the master instance if sharing enabled across instances
+	SharedResourceMgr := PONRMgr.SharedResourceMgrs[PONRMgr.SharedIdxByType[ResourceType]]
+	if SharedResourceMgr != nil && PONRMgr != SharedResourceMgr {
+		return SharedResourceMgr.IsAllocated(ctx, IntfID, ResourceType, ID)
+	}
+	Path := PONRMgr.GetPath(ctx, IntfID, ResourceType)
+	if Path == "" {
+		return false, fmt.Errorf("failed to get path for IntfId %d and ResourceType %s", IntfID, ResourceType)
+	}
+	Resource, err := PONRMgr.GetResource(ctx, Path)
+	if err != nil {
+		return false, err
+	}
+	if Resource == nil {
+		return false, fmt.Errorf("resource pool %s not found", Path)
+	}
+	if !Resource.Contains(ID) {
+		return false, fmt.Errorf("id %d is out of the boundaries of the pool %s", ID, Path)
+	}
+	return Resource.IsSet(int(ID - Resource.StartIdx)), nil
+}
+
+func (PONRMgr *PONResourceManager) UpdateResource(ctx context.Context, Path string, Resource *ResourcePool) error {
 	/*
 	   Update resource in resource kv store.
 	   :param path: path to update resource
 	   :param resource: resource need to be updated
 	   :return boolean: True if resource updated in kv store else False
 	*/
-	// TODO resource[POOL] = resource[POOL].bin
-	Value, err := json.Marshal(Resource)
+	return PONRMgr.updateResource(ctx, Path, Resource, nil)
+}
+
+// updateResource stores the resource along with the Ops in a single transaction
+func (PONRMgr *PONResourceManager) updateResource(ctx context.Context, Path string, Resource *ResourcePool, Ops []kvstore.TxnOp) error {
+	if err := Resource.Validate(); err != nil {
+		logger.Errorw(ctx, "invalid resource", log.Fields{"path": Path, "error": err})
+		return err
+	}
+	Value, err := Resource.Marshal()
 	if err != nil {
 		logger.Error(ctx, "failed to Marshal")
 		return err
 	}
-	err = PONRMgr.KVStore.Put(ctx, Path, Value)
+	if len(Ops) > 0 {
+		err = PONRMgr.KVStore.Txn(ctx, append([]kvstore.TxnOp{{Key: Path, Value: Value}}, Ops...))
+	} else {
+		err = PONRMgr.KVStore.Put(ctx, Path, Value)
+	}
 	if err != nil {
 		logger.Error(ctx, "failed to put data to kv store %s", Path)
 		return err
@@ -950,7 +1077,7 @@ func (PONRMgr *PONResourceManager) ClearResourceIDPool(ctx context.Context, cont
 	return true
 }
 
-func (PONRMgr PONResourceManager) InitResourceMap(ctx context.Context, PONIntfONUID string) {
+func (PONRMgr *PONResourceManager) InitResourceMap(ctx context.Context, PONIntfONUID string) {
 	/*
 	   Initialize resource map
 	   :param pon_intf_onu_id: reference of PON interface id and onu id
@@ -973,7 +1100,7 @@ func (PONRMgr PONResourceManager) InitResourceMap(ctx context.Context, PONIntfON
 	}
 }
 
-func (PONRMgr PONResourceManager) RemoveResourceMap(ctx context.Context, PONIntfONUID string) bool {
+func (PONRMgr *PONResourceManager) RemoveResourceMap(ctx context.Context, PONIntfONUID string) bool {
 	/*
 	   Remove resource map
 	   :param pon_intf_onu_id: reference of PON interface id and onu id
@@ -1285,61 +1412,50 @@ func (PONRMgr *PONResourceManager) UpdateFlowIDInfoForOnu(ctx context.Context, I
 	return err
 }
 
-func (PONRMgr *PONResourceManager) GenerateNextID(ctx context.Context, Resource map[string]interface{}) (uint32, error) {
+func (PONRMgr *PONResourceManager) GenerateNextID(ctx context.Context, Resource *ResourcePool) (uint32, error) {
 	/*
-	   Generate unique id having OFFSET as start
+	   Generate unique id having OFFSET as start, using the lowest free id
 	   :param resource: resource used to generate ID
 	   :return uint32: generated id
 	*/
-	ByteArray, err := ToByte(Resource[POOL])
-	if err != nil {
-		return 0, err
-	}
-	Data := bitmap.TSFromData(ByteArray, false)
-	if Data == nil {
-		return 0, errors.New("failed to get data from byte array")
-	}
+	return PONRMgr.generateNextID(ctx, LowestFreeStrategy{}, Resource)
+}
 
-	Len := Data.Len()
-	var Idx int
-	for Idx = 0; Idx < Len; Idx++ {
-		if !Data.Get(Idx) {
-			break
-		}
+func (PONRMgr *PONResourceManager) generateNextID(ctx context.Context, Strategy AllocationStrategy, Resource *ResourcePool) (uint32, error) {
+	if Resource == nil {
+		return 0, errors.New("nil resource pool")
 	}
-	if Idx == Len {
-		return 0, errors.New("resource-exhausted--no-free-id-in-the-pool")
+	Idx, err := Strategy.Next(Resource)
+	if err != nil {
+		return 0, err
 	}
-	Data.Set(Idx, true)
-	res := uint32(Resource[START_IDX].(float64))
-	Resource[POOL] = Data.Data(false)
-	logger.Debugf(ctx, "Generated ID for %d", (uint32(Idx) + res))
-	return (uint32(Idx) + res), err
+	Resource.set(Idx, true)
+	logger.Debugf(ctx, "Generated ID for %d", (uint32(Idx) + Resource.StartIdx))
+	return (uint32(Idx) + Resource.StartIdx), nil
 }
 
-func (PONRMgr *PONResourceManager) ReleaseID(ctx context.Context, Resource map[string]interface{}, Id uint32) bool {
+func (PONRMgr *PONResourceManager) ReleaseID(ctx context.Context, Resource *ResourcePool, Id uint32) bool {
 	/*
 	   Release unique id having OFFSET as start index.
 	   :param resource: resource used to release ID
 	   :param unique_id: id need to be released
 	*/
-	ByteArray, err := ToByte(Resource[POOL])
-	if err != nil {
-		logger.Error(ctx, "Failed to convert resource to byte array")
-		return false
-	}
-	Data := bitmap.TSFromData(ByteArray, false)
-	if Data == nil {
+	return PONRMgr.releaseID(ctx, LowestFreeStrategy{}, Resource, Id)
+}
+
+func (PONRMgr *PONResourceManager) releaseID(ctx context.Context, Strategy AllocationStrategy, Resource *ResourcePool, Id uint32) bool {
+	if Resource == nil {
 		logger.Error(ctx, "Failed to get resource pool")
 		return false
 	}
-	Idx := Id - uint32(Resource[START_IDX].(float64))
-	if Idx >= uint32(Data.Len()) {
+	if !Resource.Contains(Id) {
 		logger.Errorf(ctx, "ID %d is out of the boundaries of the pool", Id)
 		return false
 	}
-	Data.Set(int(Idx), false)
-	Resource[POOL] = Data.Data(false)
+	Idx := int(Id - Resource.StartIdx)
+	Resource.set(Idx, false)
+	Resource.setReserved(Id, false)
+	Strategy.Released(Resource, Idx)
 
 	return true
 }
@@ -1350,18 +1466,12 @@ func (PONRMgr *PONResourceManager) ReleaseID(ctx context.Context, Resource map[s
 :param Resource: resource used to reserve ID
 :param Id: ID to be reserved
 */
-func (PONRMgr *PONResourceManager) reserveID(ctx context.Context, TSData *bitmap.Threadsafe, StartIndex uint32, Id uint32) bool {
-	Data := bitmap.TSFromData(TSData.Data(false), false)
-	if Data == nil {
-		logger.Error(ctx, "Failed to get resource pool")
-		return false
-	}
-	Idx := Id - StartIndex
-	if Idx >= uint32(Data.Len()) {
+func (PONRMgr *PONResourceManager) reserveID(ctx context.Context, Resource *ResourcePool, Id uint32) bool {
+	if !Resource.Contains(Id) {
 		logger.Errorf(ctx, "Reservation failed. ID %d is out of the boundaries of the pool", Id)
 		return false
 	}
-	Data.Set(int(Idx), true)
+	Resource.set(int(Id-Resource.StartIdx), true)
 	return true
 }
 
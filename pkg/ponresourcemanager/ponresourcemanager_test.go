@@ -24,11 +24,10 @@ import (
 	"testing"
 	"time"
 
-	"github.com/boljen/go-bitmap"
-
 	"github.com/opencord/voltha-lib-go/v7/pkg/db"
 	"github.com/opencord/voltha-lib-go/v7/pkg/db/kvstore"
 	"github.com/opencord/voltha-lib-go/v7/pkg/log"
+	mock_kvstore "github.com/opencord/voltha-lib-go/v7/pkg/mocks/kvstore"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -37,6 +36,15 @@ const (
 	RESERVED_GEM_PORT_ID = uint32(5)
 )
 
+// newTestPONResourceManager returns a manager whose KV stores are backed by in-memory clients
+func newTestPONResourceManager(ctx context.Context) (*PONResourceManager, *mock_kvstore.KVClient) {
+	kv := mock_kvstore.NewKVClient()
+	PONRMgr, _ := NewPONResourceManager(ctx, "xgspon", "openolt", "olt1", "etcd", "1:1", "service/voltha",
+		&db.Backend{Client: kv, PathPrefix: "service/voltha/resource_manager/{xgspon}"},
+		&db.Backend{Client: mock_kvstore.NewKVClient(), PathPrefix: "service/voltha/resource_manager/config"})
+	return PONRMgr, kv
+}
+
 // MockKVClient mocks the AdapterProxy interface.
 type MockResKVClient struct {
 	resourceMap map[string]interface{}
@@ -385,17 +393,7 @@ func TestPONResourceManager_ReserveInvalidID(t *testing.T) {
 		t.Error("Failed to get resource from gem port id pool", err)
 		return
 	}
-	ByteArray, err := ToByte(resource[POOL])
-	if err != nil {
-		t.Error(ctx, "Failed to convert resource to byte array")
-		return
-	}
-	Data := bitmap.TSFromData(ByteArray, false)
-	if Data == nil {
-		t.Error(ctx, "Failed to get resource pool")
-		return
-	}
 	//try to reserve an ID whose value is out of the boundaries of the pool and expect false
-	reserved := PONRMgr.reserveID(ctx, Data, StartIndex, EndIndex+1)
+	reserved := PONRMgr.reserveID(ctx, resource, EndIndex+1)
 	assert.Equal(t, false, reserved)
 }
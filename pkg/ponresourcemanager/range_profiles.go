@@ -0,0 +1,352 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ponresourcemanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/log"
+)
+
+const (
+	/*Keys of the range profiles in the resource ranges of an OLT model. The interfaces are referenced
+	  by their ID or by a range of IDs "<first>-<last>", e.g.
+	    "intf_technology": {"0-7": "xgspon", "8-15": "gpon"},
+	    "technology_ranges": {"gpon": {"onu_id_end": 127, "gemport_id_end": 4095}},
+	    "intf_ranges": {"15": {"alloc_id_start": 2048}}
+	  The ranges of an interface are the ranges of the OLT model, overridden by the ranges of the
+	  technology of the interface, overridden in turn by the ranges of the interface.
+	*/
+	INTF_TECHNOLOGY   = "intf_technology"
+	TECHNOLOGY_RANGES = "technology_ranges"
+	INTF_RANGES       = "intf_ranges"
+)
+
+// ResourceRanges are the ranges of the IDs allocated on a PON interface. Zero values are not set
+// and inherit the range of the enclosing profile.
+type ResourceRanges struct {
+	OnuIDStart     uint32 `json:"onu_id_start,omitempty"`
+	OnuIDEnd       uint32 `json:"onu_id_end,omitempty"`
+	AllocIDStart   uint32 `json:"alloc_id_start,omitempty"`
+	AllocIDEnd     uint32 `json:"alloc_id_end,omitempty"`
+	GemportIDStart uint32 `json:"gemport_id_start,omitempty"`
+	GemportIDEnd   uint32 `json:"gemport_id_end,omitempty"`
+	FlowIDStart    uint32 `json:"flow_id_start,omitempty"`
+	FlowIDEnd      uint32 `json:"flow_id_end,omitempty"`
+}
+
+// RangeProfiles are the resource ranges overridden per PON technology and per PON interface
+type RangeProfiles struct {
+	IntfTechnology   map[string]string         `json:"intf_technology,omitempty"`
+	TechnologyRanges map[string]ResourceRanges `json:"technology_ranges,omitempty"`
+	IntfRanges       map[string]ResourceRanges `json:"intf_ranges,omitempty"`
+}
+
+// rangeKeysByType are the keys of the start and end IDs of the resource types in the ranges of an OLT model
+var rangeKeysByType = map[string][2]string{
+	ONU_ID:     {ONU_ID_START_IDX, ONU_ID_END_IDX},
+	ALLOC_ID:   {ALLOC_ID_START_IDX, ALLOC_ID_END_IDX},
+	GEMPORT_ID: {GEMPORT_ID_START_IDX, GEMPORT_ID_END_IDX},
+	FLOW_ID:    {FLOW_ID_START_IDX, FLOW_ID_END_IDX},
+}
+
+// intfIDRange is a range of interface IDs referenced in the range profiles
+type intfIDRange struct {
+	Key         string
+	First, Last uint32
+}
+
+func (r intfIDRange) contains(IntfID uint32) bool {
+	return IntfID >= r.First && IntfID <= r.Last
+}
+
+// Range returns the start and end IDs of a resource type
+func (r ResourceRanges) Range(ResourceType string) (uint32, uint32) {
+	switch ResourceType {
+	case ONU_ID:
+		return r.OnuIDStart, r.OnuIDEnd
+	case ALLOC_ID:
+		return r.AllocIDStart, r.AllocIDEnd
+	case GEMPORT_ID:
+		return r.GemportIDStart, r.GemportIDEnd
+	case FLOW_ID:
+		return r.FlowIDStart, r.FlowIDEnd
+	}
+	return 0, 0
+}
+
+func (r *ResourceRanges) setRange(ResourceType string, Start uint32, End uint32) {
+	switch ResourceType {
+	case ONU_ID:
+		r.OnuIDStart, r.OnuIDEnd = Start, End
+	case ALLOC_ID:
+		r.AllocIDStart, r.AllocIDEnd = Start, End
+	case GEMPORT_ID:
+		r.GemportIDStart, r.GemportIDEnd = Start, End
+	case FLOW_ID:
+		r.FlowIDStart, r.FlowIDEnd = Start, End
+	}
+}
+
+// override replaces the ranges by the ones set in Other, except for the resource types in Skip
+func (r *ResourceRanges) override(Other ResourceRanges, Skip map[string]bool) {
+	for _, ResourceType := range []string{ONU_ID, ALLOC_ID, GEMPORT_ID, FLOW_ID} {
+		if Skip[ResourceType] {
+			continue
+		}
+		Start, End := r.Range(ResourceType)
+		OtherStart, OtherEnd := Other.Range(ResourceType)
+		if OtherStart != 0 {
+			Start = OtherStart
+		}
+		if OtherEnd != 0 {
+			End = OtherEnd
+		}
+		r.setRange(ResourceType, Start, End)
+	}
+}
+
+// validate checks that the ranges set in the profile are not inverted
+func (r ResourceRanges) validate() error {
+	for _, ResourceType := range []string{ONU_ID, ALLOC_ID, GEMPORT_ID, FLOW_ID} {
+		if Start, End := r.Range(ResourceType); Start != 0 && End != 0 && Start > End {
+			return fmt.Errorf("invalid %s range [%d, %d]", ResourceType, Start, End)
+		}
+	}
+	return nil
+}
+
+// parseIntfIDRange parses an interface ID, or a range of interface IDs "<first>-<last>"
+func parseIntfIDRange(Key string) (intfIDRange, error) {
+	Bounds := strings.SplitN(Key, "-", 2)
+	First, err := strconv.ParseUint(strings.TrimSpace(Bounds[0]), 10, 32)
+	if err != nil {
+		return intfIDRange{}, fmt.Errorf("invalid interface id %s", Key)
+	}
+	Last := First
+	if len(Bounds) == 2 {
+		if Last, err = strconv.ParseUint(strings.TrimSpace(Bounds[1]), 10, 32); err != nil || Last < First {
+			return intfIDRange{}, fmt.Errorf("invalid interface id range %s", Key)
+		}
+	}
+	return intfIDRange{Key: Key, First: uint32(First), Last: uint32(Last)}, nil
+}
+
+// parseIntfIDRanges parses the keys of a map of interface IDs and rejects the overlapping ones
+func parseIntfIDRanges(Keys []string) ([]intfIDRange, error) {
+	Ranges := make([]intfIDRange, 0, len(Keys))
+	for _, Key := range Keys {
+		Range, err := parseIntfIDRange(Key)
+		if err != nil {
+			return nil, err
+		}
+		Ranges = append(Ranges, Range)
+	}
+	sort.Slice(Ranges, func(i, j int) bool { return Ranges[i].First < Ranges[j].First })
+	for i := 1; i < len(Ranges); i++ {
+		if Ranges[i].First <= Ranges[i-1].Last {
+			return nil, fmt.Errorf("overlapping interface id ranges %s and %s", Ranges[i-1].Key, Ranges[i].Key)
+		}
+	}
+	return Ranges, nil
+}
+
+// Validate checks the consistency of the range profiles: the interface IDs must be referenced once,
+// the technologies of the interfaces must have ranges and the ranges must not be inverted.
+func (rp *RangeProfiles) Validate() error {
+	TechnologyKeys := make([]string, 0, len(rp.IntfTechnology))
+	for Key, Technology := range rp.IntfTechnology {
+		if _, ok := rp.TechnologyRanges[Technology]; !ok {
+			return fmt.Errorf("no ranges for technology %s of interfaces %s", Technology, Key)
+		}
+		TechnologyKeys = append(TechnologyKeys, Key)
+	}
+	if _, err := parseIntfIDRanges(TechnologyKeys); err != nil {
+		return err
+	}
+	for Technology, Ranges := range rp.TechnologyRanges {
+		if err := Ranges.validate(); err != nil {
+			return fmt.Errorf("technology %s: %w", Technology, err)
+		}
+	}
+	IntfKeys := make([]string, 0, len(rp.IntfRanges))
+	for Key, Ranges := range rp.IntfRanges {
+		if err := Ranges.validate(); err != nil {
+			return fmt.Errorf("interfaces %s: %w", Key, err)
+		}
+		IntfKeys = append(IntfKeys, Key)
+	}
+	_, err := parseIntfIDRanges(IntfKeys)
+	return err
+}
+
+// technology returns the PON technology of an interface, empty if not set
+func (rp *RangeProfiles) technology(IntfID uint32) string {
+	for Key, Technology := range rp.IntfTechnology {
+		if Range, err := parseIntfIDRange(Key); err == nil && Range.contains(IntfID) {
+			return Technology
+		}
+	}
+	return ""
+}
+
+// intfRanges returns the ranges set for an interface
+func (rp *RangeProfiles) intfRanges(IntfID uint32) (ResourceRanges, bool) {
+	for Key, Ranges := range rp.IntfRanges {
+		if Range, err := parseIntfIDRange(Key); err == nil && Range.contains(IntfID) {
+			return Ranges, true
+		}
+	}
+	return ResourceRanges{}, false
+}
+
+func (PONRMgr *PONResourceManager) SetRangeProfiles(ctx context.Context, Profiles *RangeProfiles) error {
+	/*
+	   Set the resource ranges overridden per PON technology and per PON interface. The profiles
+	   apply to the pools initialized afterwards.
+	   :param profiles: the range profiles, nil to use the ranges of the OLT model on all interfaces
+	*/
+	if Profiles != nil {
+		if err := Profiles.Validate(); err != nil {
+			logger.Errorw(ctx, "invalid-range-profiles", log.Fields{"error": err})
+			return err
+		}
+	}
+	PONRMgr.RangeProfiles = Profiles
+	return nil
+}
+
+// GetPONTechnology returns the PON technology of an interface, the technology of the manager if
+// the range profiles do not set it
+func (PONRMgr *PONResourceManager) GetPONTechnology(IntfID uint32) string {
+	if PONRMgr.RangeProfiles != nil {
+		if Technology := PONRMgr.RangeProfiles.technology(IntfID); Technology != "" {
+			return Technology
+		}
+	}
+	return PONRMgr.Technology
+}
+
+func (PONRMgr *PONResourceManager) rangeValue(Key string) uint32 {
+	Value, _ := PONRMgr.PonResourceRanges[Key].(uint32)
+	return Value
+}
+
+// GetIntfResourceRanges returns the ranges of the IDs allocated on an interface. The ranges of the
+// resource types with a pool shared by all the interfaces are the ranges of the OLT model.
+func (PONRMgr *PONResourceManager) GetIntfResourceRanges(IntfID uint32) ResourceRanges {
+	var Ranges ResourceRanges
+	Shared := make(map[string]bool)
+	for _, ResourceType := range []string{ONU_ID, ALLOC_ID, GEMPORT_ID, FLOW_ID} {
+		Keys := rangeKeysByType[ResourceType]
+		Ranges.setRange(ResourceType, PONRMgr.rangeValue(Keys[0]), PONRMgr.rangeValue(Keys[1]))
+		Shared[ResourceType] = PONRMgr.rangeValue(PONRMgr.SharedIdxByType[ResourceType]) != 0
+	}
+	if PONRMgr.RangeProfiles == nil {
+		return Ranges
+	}
+	if Technology := PONRMgr.RangeProfiles.technology(IntfID); Technology != "" {
+		Ranges.override(PONRMgr.RangeProfiles.TechnologyRanges[Technology], Shared)
+	}
+	if IntfRanges, ok := PONRMgr.RangeProfiles.intfRanges(IntfID); ok {
+		Ranges.override(IntfRanges, Shared)
+	}
+	return Ranges
+}
+
+// validateIntfResourceRanges checks the ranges resolved for the interfaces of the manager. The
+// pools shared by all the interfaces cannot have per interface ranges.
+func (PONRMgr *PONResourceManager) validateIntfResourceRanges(ctx context.Context) error {
+	if Profiles := PONRMgr.RangeProfiles; Profiles != nil {
+		for _, ResourceType := range []string{ONU_ID, ALLOC_ID, GEMPORT_ID, FLOW_ID} {
+			if PONRMgr.rangeValue(PONRMgr.SharedIdxByType[ResourceType]) == 0 {
+				continue
+			}
+			for _, Ranges := range [][]ResourceRanges{mapValues(Profiles.TechnologyRanges), mapValues(Profiles.IntfRanges)} {
+				for _, Range := range Ranges {
+					if Start, End := Range.Range(ResourceType); Start != 0 || End != 0 {
+						err := fmt.Errorf("the %s pool is shared by all interfaces and cannot have per interface ranges", ResourceType)
+						logger.Error(ctx, err.Error())
+						return err
+					}
+				}
+			}
+		}
+	}
+	for _, Intf := range PONRMgr.IntfIDs {
+		Ranges := PONRMgr.GetIntfResourceRanges(Intf)
+		for _, ResourceType := range []string{ONU_ID, ALLOC_ID, GEMPORT_ID, FLOW_ID} {
+			if Start, End := Ranges.Range(ResourceType); Start > End {
+				err := fmt.Errorf("invalid %s range [%d, %d] on interface %d", ResourceType, Start, End, Intf)
+				logger.Error(ctx, err.Error())
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func mapValues(Ranges map[string]ResourceRanges) []ResourceRanges {
+	Values := make([]ResourceRanges, 0, len(Ranges))
+	for _, Value := range Ranges {
+		Values = append(Values, Value)
+	}
+	return Values
+}
+
+// decodeResourceRanges decodes the resource ranges of an OLT model read from the KV store into the
+// ranges of the OLT model and the range profiles
+func decodeResourceRanges(Value []byte) (map[string]interface{}, *RangeProfiles, error) {
+	Fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(Value, &Fields); err != nil {
+		return nil, nil, err
+	}
+	Ranges := make(map[string]interface{})
+	var Profiles *RangeProfiles
+	for Key, Field := range Fields {
+		switch Key {
+		case INTF_TECHNOLOGY, TECHNOLOGY_RANGES, INTF_RANGES:
+			if Profiles == nil {
+				Profiles = &RangeProfiles{}
+				if err := json.Unmarshal(Value, Profiles); err != nil {
+					return nil, nil, fmt.Errorf("invalid range profiles: %w", err)
+				}
+				if err := Profiles.Validate(); err != nil {
+					return nil, nil, err
+				}
+			}
+		default:
+			// the ranges are handled as uint32, anything else is kept as decoded
+			var Number uint32
+			if err := json.Unmarshal(Field, &Number); err == nil {
+				Ranges[Key] = Number
+				continue
+			}
+			var Other interface{}
+			if err := json.Unmarshal(Field, &Other); err != nil {
+				return nil, nil, err
+			}
+			Ranges[Key] = Other
+		}
+	}
+	return Ranges, Profiles, nil
+}
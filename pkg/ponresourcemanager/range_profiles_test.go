@@ -0,0 +1,123 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ponresourcemanager
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRangeProfiles = `{
+	"onu_id_start": 1, "onu_id_end": 255,
+	"alloc_id_start": 1024, "alloc_id_end": 16383,
+	"gemport_id_start": 1024, "gemport_id_end": 65535,
+	"flow_id_start": 1, "flow_id_end": 16383,
+	"intf_technology": {"0-7": "xgspon", "8-15": "gpon"},
+	"technology_ranges": {"xgspon": {}, "gpon": {"onu_id_end": 127, "gemport_id_end": 4095}},
+	"intf_ranges": {"15": {"alloc_id_start": 2048}}
+}`
+
+func TestInitResourceRangesFromKVStore(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, _ := newTestPONResourceManager(ctx)
+	Path := fmt.Sprintf(PON_RESOURCE_RANGE_CONFIG_PATH, PONRMgr.OLTModel)
+	assert.Nil(t, PONRMgr.KVStore.Put(ctx, Path, testRangeProfiles))
+
+	assert.True(t, PONRMgr.InitResourceRangesFromKVStore(ctx))
+	assert.Equal(t, uint32(255), PONRMgr.PonResourceRanges[ONU_ID_END_IDX])
+	assert.NotContains(t, PONRMgr.PonResourceRanges, INTF_RANGES)
+	assert.Equal(t, "xgspon", PONRMgr.GetPONTechnology(3))
+	assert.Equal(t, "gpon", PONRMgr.GetPONTechnology(8))
+	assert.Equal(t, "xgspon", PONRMgr.GetPONTechnology(16))
+
+	PONRMgr.InitDefaultPONResourceRanges(ctx, 1, 255, 0, 1024, 16383, 0, 1024, 65535, 0, 1, 16383, 0, 0, 0, 2, []uint32{0, 8, 15})
+	assert.Equal(t, ResourceRanges{OnuIDStart: 1, OnuIDEnd: 255, AllocIDStart: 1024, AllocIDEnd: 16383,
+		GemportIDStart: 1024, GemportIDEnd: 65535, FlowIDStart: 1, FlowIDEnd: 16383}, PONRMgr.GetIntfResourceRanges(0))
+	assert.Equal(t, ResourceRanges{OnuIDStart: 1, OnuIDEnd: 127, AllocIDStart: 2048, AllocIDEnd: 16383,
+		GemportIDStart: 1024, GemportIDEnd: 4095, FlowIDStart: 1, FlowIDEnd: 16383}, PONRMgr.GetIntfResourceRanges(15))
+
+	assert.Nil(t, PONRMgr.InitDeviceResourcePool(ctx))
+	for Intf, Capacity := range map[uint32]uint32{0: 255, 8: 127, 15: 127} {
+		pu, err := PONRMgr.GetPoolUtilization(ctx, Intf, ONU_ID)
+		assert.Nil(t, err)
+		assert.Equal(t, Capacity, pu.Capacity(), "intf %d", Intf)
+	}
+	ids, err := PONRMgr.GetResourceID(ctx, 15, ALLOC_ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{2048}, ids)
+	ids, err = PONRMgr.GetResourceID(ctx, 8, ALLOC_ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{1024}, ids)
+
+	// invalid profiles are not loaded
+	assert.Nil(t, PONRMgr.KVStore.Put(ctx, Path, `{"intf_ranges": {"0-3": {}, "3": {}}}`))
+	assert.False(t, PONRMgr.InitResourceRangesFromKVStore(ctx))
+}
+
+func TestValidateRangeProfiles(t *testing.T) {
+	invalid := map[string]RangeProfiles{
+		"overlapping technology interfaces": {
+			IntfTechnology:   map[string]string{"0-7": "xgspon", "7-15": "gpon"},
+			TechnologyRanges: map[string]ResourceRanges{"xgspon": {}, "gpon": {}},
+		},
+		"overlapping interface ranges": {
+			IntfRanges: map[string]ResourceRanges{"4": {}, "2-6": {}},
+		},
+		"unknown technology": {
+			IntfTechnology: map[string]string{"0": "xgspon"},
+		},
+		"inverted interface id range": {
+			IntfRanges: map[string]ResourceRanges{"7-3": {}},
+		},
+		"bad interface id": {
+			IntfRanges: map[string]ResourceRanges{"pon0": {}},
+		},
+		"inverted resource range": {
+			IntfRanges: map[string]ResourceRanges{"0": {FlowIDStart: 10, FlowIDEnd: 5}},
+		},
+	}
+	for name, profiles := range invalid {
+		assert.NotNil(t, profiles.Validate(), name)
+	}
+	valid := RangeProfiles{
+		IntfTechnology:   map[string]string{"0-7": "xgspon", "8": "gpon"},
+		TechnologyRanges: map[string]ResourceRanges{"xgspon": {}, "gpon": {OnuIDEnd: 127}},
+		IntfRanges:       map[string]ResourceRanges{"0-3": {}, "4-15": {}},
+	}
+	assert.Nil(t, valid.Validate())
+}
+
+func TestIntfResourceRangesValidation(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, _ := newTestPONResourceManager(ctx)
+	// the alloc IDs are shared by all the interfaces
+	PONRMgr.InitDefaultPONResourceRanges(ctx, 1, 8, 0, 1024, 1031, 1, 1024, 1031, 0, 1, 8, 0, 0, 0, 2, []uint32{0, 1})
+
+	assert.Nil(t, PONRMgr.SetRangeProfiles(ctx, &RangeProfiles{IntfRanges: map[string]ResourceRanges{"1": {AllocIDEnd: 1027}}}))
+	assert.NotNil(t, PONRMgr.InitDeviceResourcePool(ctx))
+
+	// the range of an interface ends before the start of the range of the OLT model
+	assert.Nil(t, PONRMgr.SetRangeProfiles(ctx, &RangeProfiles{IntfRanges: map[string]ResourceRanges{"1": {OnuIDEnd: 0, GemportIDEnd: 512}}}))
+	assert.NotNil(t, PONRMgr.InitDeviceResourcePool(ctx))
+
+	assert.NotNil(t, PONRMgr.SetRangeProfiles(ctx, &RangeProfiles{IntfRanges: map[string]ResourceRanges{"1": {}, "0-1": {}}}))
+	assert.Nil(t, PONRMgr.SetRangeProfiles(ctx, nil))
+	assert.Nil(t, PONRMgr.InitDeviceResourcePool(ctx))
+}
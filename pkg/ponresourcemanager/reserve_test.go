@@ -0,0 +1,85 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ponresourcemanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserveResourceIDs(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, _ := newTestPONResourceManager(ctx)
+	initTestPools(t, ctx, PONRMgr)
+
+	assert.Nil(t, PONRMgr.ReserveResourceIDs(ctx, 0, ONU_ID, []uint32{1, 3}))
+	for ID, expected := range map[uint32]bool{1: true, 2: false, 3: true} {
+		allocated, err := PONRMgr.IsAllocated(ctx, 0, ONU_ID, ID)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, allocated, "onu id %d", ID)
+	}
+	// the other interfaces are not affected
+	allocated, err := PONRMgr.IsAllocated(ctx, 1, ONU_ID, 1)
+	assert.Nil(t, err)
+	assert.False(t, allocated)
+
+	// the reserved IDs are not handed out
+	ids, err := PONRMgr.GetResourceID(ctx, 0, ONU_ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{2}, ids)
+
+	// nothing is reserved if any ID is taken, duplicated or out of range
+	assert.NotNil(t, PONRMgr.ReserveResourceIDs(ctx, 0, ONU_ID, []uint32{4, 3}))
+	assert.NotNil(t, PONRMgr.ReserveResourceIDs(ctx, 0, ONU_ID, []uint32{4, 4}))
+	assert.NotNil(t, PONRMgr.ReserveResourceIDs(ctx, 0, ONU_ID, []uint32{4, 9}))
+	allocated, err = PONRMgr.IsAllocated(ctx, 0, ONU_ID, 4)
+	assert.Nil(t, err)
+	assert.False(t, allocated)
+
+	assert.NotNil(t, PONRMgr.ReserveResourceIDs(ctx, 0, "UNKNOWN", []uint32{4}))
+	assert.NotNil(t, PONRMgr.ReserveResourceIDs(ctx, 0, ONU_ID, nil))
+	_, err = PONRMgr.IsAllocated(ctx, 0, ONU_ID, 0)
+	assert.NotNil(t, err)
+
+	// a reserved ID is released like an allocated one
+	assert.Nil(t, PONRMgr.FreeResourceID(ctx, 0, ONU_ID, []uint32{1}))
+	allocated, err = PONRMgr.IsAllocated(ctx, 0, ONU_ID, 1)
+	assert.Nil(t, err)
+	assert.False(t, allocated)
+}
+
+func TestReserveResourceIDsHoldDown(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, _ := newTestPONResourceManager(ctx)
+	initTestPools(t, ctx, PONRMgr)
+	current := time.Now()
+	quarantine := NewQuarantineStrategy(time.Minute)
+	quarantine.now = func() time.Time { return current }
+	assert.Nil(t, PONRMgr.SetAllocationStrategy(GEMPORT_ID, quarantine))
+
+	ids, err := PONRMgr.GetResourceID(ctx, 0, GEMPORT_ID, 2)
+	assert.Nil(t, err)
+	assert.Nil(t, PONRMgr.FreeResourceID(ctx, 0, GEMPORT_ID, ids[:1]))
+
+	// the released ID cannot be reserved until its hold-down is over
+	assert.NotNil(t, PONRMgr.ReserveResourceIDs(ctx, 0, GEMPORT_ID, ids[:1]))
+	current = current.Add(time.Minute)
+	assert.Nil(t, PONRMgr.ReserveResourceIDs(ctx, 0, GEMPORT_ID, ids[:1]))
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ponresourcemanager
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	bitmap "github.com/boljen/go-bitmap"
+)
+
+const (
+	// RESOURCE_POOL_VERSION is the schema version of the resource pools written to the KV store.
+	// Version 0 designates the legacy layout, which had no version field.
+	RESOURCE_POOL_VERSION = 1
+
+	VERSION = "version"
+)
+
+// ResourcePool is the pool of IDs of a resource type on a PON interface, as stored in the KV store.
+// Tracking the resource allocation is done by setting the bits of Pool: the bit at index i is set
+// when the ID StartIdx+i is allocated.
+type ResourcePool struct {
+	Version   uint32 `json:"version"`
+	PonIntfID uint32 `json:"pon_intf_id"`
+	StartIdx  uint32 `json:"start_idx"`
+	EndIdx    uint32 `json:"end_idx"`
+	Pool      []byte `json:"pool"`
+	// State of the allocation strategies: the index following the last one allocated by the
+	// round-robin strategy, and the release time in unix milliseconds of the IDs in hold-down
+	// indexed by their index in the pool.
+	NextIdx    uint32           `json:"next_idx,omitempty"`
+	Quarantine map[uint32]int64 `json:"quarantine,omitempty"`
+	// IDs reserved by ReserveResourceIDs, which have no owner recorded elsewhere, sorted
+	Reserved []uint32 `json:"reserved,omitempty"`
+}
+
+// NewResourcePool creates an empty pool of the IDs from StartIdx to EndIdx
+func NewResourcePool(PonIntfID uint32, StartIdx uint32, EndIdx uint32) (*ResourcePool, error) {
+	if EndIdx < StartIdx {
+		return nil, fmt.Errorf("invalid resource pool range [%d, %d]", StartIdx, EndIdx)
+	}
+	// The bitmap is sized after the end index for compatibility with the existing pools
+	var TSData *bitmap.Threadsafe
+	if TSData = bitmap.NewTS(int(EndIdx)); TSData == nil {
+		return nil, errors.New("failed to create bitmap")
+	}
+	return &ResourcePool{
+		Version:   RESOURCE_POOL_VERSION,
+		PonIntfID: PonIntfID,
+		StartIdx:  StartIdx,
+		EndIdx:    EndIdx,
+		Pool:      TSData.Data(false),
+	}, nil
+}
+
+// Validate checks the consistency of the pool
+func (rp *ResourcePool) Validate() error {
+	if rp.Version > RESOURCE_POOL_VERSION {
+		return fmt.Errorf("unsupported resource pool version %d", rp.Version)
+	}
+	if rp.EndIdx < rp.StartIdx {
+		return fmt.Errorf("invalid resource pool range [%d, %d]", rp.StartIdx, rp.EndIdx)
+	}
+	if len(rp.Pool) == 0 {
+		return errors.New("empty resource pool bitmap")
+	}
+	if rp.NextIdx > uint32(rp.Capacity()) {
+		return fmt.Errorf("next index %d is out of the boundaries of the pool", rp.NextIdx)
+	}
+	for Idx := range rp.Quarantine {
+		if Idx >= uint32(rp.Capacity()) {
+			return fmt.Errorf("quarantined index %d is out of the boundaries of the pool", Idx)
+		}
+	}
+	for _, ID := range rp.Reserved {
+		if !rp.Contains(ID) {
+			return fmt.Errorf("reserved id %d is out of the boundaries of the pool", ID)
+		}
+	}
+	return nil
+}
+
+// Capacity returns the number of IDs of the pool. The bitmap is rounded up to a whole number of bytes
+// and, for pools starting at 0, can be one bit short of the range.
+func (rp *ResourcePool) Capacity() int {
+	Capacity := int(rp.EndIdx) - int(rp.StartIdx) + 1
+	if Capacity > len(rp.Pool)*8 {
+		return len(rp.Pool) * 8
+	}
+	return Capacity
+}
+
+// Contains returns true if ID is in the range of the pool
+func (rp *ResourcePool) Contains(ID uint32) bool {
+	return ID >= rp.StartIdx && int(ID-rp.StartIdx) < rp.Capacity()
+}
+
+// IsReserved returns true if ID was reserved by ReserveResourceIDs and not released since
+func (rp *ResourcePool) IsReserved(ID uint32) bool {
+	i := sort.Search(len(rp.Reserved), func(i int) bool { return rp.Reserved[i] >= ID })
+	return i < len(rp.Reserved) && rp.Reserved[i] == ID
+}
+
+func (rp *ResourcePool) setReserved(ID uint32, Reserved bool) {
+	i := sort.Search(len(rp.Reserved), func(i int) bool { return rp.Reserved[i] >= ID })
+	Found := i < len(rp.Reserved) && rp.Reserved[i] == ID
+	if Reserved && !Found {
+		rp.Reserved = append(rp.Reserved, 0)
+		copy(rp.Reserved[i+1:], rp.Reserved[i:])
+		rp.Reserved[i] = ID
+	} else if !Reserved && Found {
+		rp.Reserved = append(rp.Reserved[:i], rp.Reserved[i+1:]...)
+	}
+}
+
+// IsSet returns true if the ID at index Idx of the pool is allocated
+func (rp *ResourcePool) IsSet(Idx int) bool {
+	return rp.bitmap().Get(Idx)
+}
+
+func (rp *ResourcePool) set(Idx int, Value bool) {
+	rp.bitmap().Set(Idx, Value)
+}
+
+// bitmap wraps the pool data, without copying it
+func (rp *ResourcePool) bitmap() *bitmap.Threadsafe {
+	return bitmap.TSFromData(rp.Pool, false)
+}
+
+// Marshal encodes the pool in the current version of the schema
+func (rp *ResourcePool) Marshal() ([]byte, error) {
+	rp.Version = RESOURCE_POOL_VERSION
+	return json.Marshal(rp)
+}
+
+// UnmarshalResourcePool decodes and validates a pool read from the KV store, migrating the
+// legacy layout if needed. Corrupt pools result in an error.
+func UnmarshalResourcePool(Value []byte) (*ResourcePool, error) {
+	var Versioned struct {
+		Version *uint32 `json:"version"`
+	}
+	if err := json.Unmarshal(Value, &Versioned); err != nil {
+		return nil, fmt.Errorf("failed to decode resource pool: %w", err)
+	}
+	var rp *ResourcePool
+	var err error
+	if Versioned.Version == nil || *Versioned.Version == 0 {
+		if rp, err = migrateLegacyResourcePool(Value); err != nil {
+			return nil, err
+		}
+	} else {
+		rp = &ResourcePool{}
+		if err = json.Unmarshal(Value, rp); err != nil {
+			return nil, fmt.Errorf("failed to decode resource pool: %w", err)
+		}
+	}
+	if err = rp.Validate(); err != nil {
+		return nil, err
+	}
+	return rp, nil
+}
+
+// migrateLegacyResourcePool reads a pool stored as a free form json map by FormatResource, where
+// the numbers were decoded as floats.
+func migrateLegacyResourcePool(Value []byte) (*ResourcePool, error) {
+	Legacy := make(map[string]interface{})
+	if err := json.Unmarshal(Value, &Legacy); err != nil {
+		return nil, fmt.Errorf("failed to decode legacy resource pool: %w", err)
+	}
+	rp := &ResourcePool{Version: RESOURCE_POOL_VERSION}
+	var err error
+	if rp.PonIntfID, err = legacyUint32(Legacy, PON_INTF_ID); err != nil {
+		return nil, err
+	}
+	if rp.StartIdx, err = legacyUint32(Legacy, START_IDX); err != nil {
+		return nil, err
+	}
+	if rp.EndIdx, err = legacyUint32(Legacy, END_IDX); err != nil {
+		return nil, err
+	}
+	Pool, ok := Legacy[POOL].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid legacy resource pool field %s", POOL)
+	}
+	if rp.Pool, err = base64.StdEncoding.DecodeString(Pool); err != nil {
+		return nil, fmt.Errorf("invalid legacy resource pool bitmap: %w", err)
+	}
+	return rp, nil
+}
+
+func legacyUint32(Legacy map[string]interface{}, Key string) (uint32, error) {
+	Value, ok := Legacy[Key]
+	if !ok {
+		return 0, fmt.Errorf("missing legacy resource pool field %s", Key)
+	}
+	Number, ok := Value.(float64)
+	if !ok || Number < 0 || Number > math.MaxUint32 || Number != math.Trunc(Number) {
+		return 0, fmt.Errorf("invalid legacy resource pool field %s: %v", Key, Value)
+	}
+	return uint32(Number), nil
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ponresourcemanager
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// legacyPool encodes a pool the way versions without ResourcePool did
+func legacyPool(t *testing.T, Fields map[string]interface{}) []byte {
+	Value, err := json.Marshal(Fields)
+	assert.Nil(t, err)
+	return Value
+}
+
+func TestResourcePoolRoundTrip(t *testing.T) {
+	rp, err := NewResourcePool(2, 1024, 1031)
+	assert.Nil(t, err)
+	assert.Equal(t, 8, rp.Capacity())
+	rp.set(3, true)
+	rp.NextIdx = 4
+	rp.Quarantine = map[uint32]int64{1: 1700000000123}
+
+	Value, err := rp.Marshal()
+	assert.Nil(t, err)
+	decoded, err := UnmarshalResourcePool(Value)
+	assert.Nil(t, err)
+	assert.Equal(t, rp, decoded)
+	assert.True(t, decoded.IsSet(3))
+	assert.True(t, decoded.Contains(1031))
+	assert.False(t, decoded.Contains(1032))
+	assert.False(t, decoded.Contains(1023))
+
+	_, err = NewResourcePool(2, 10, 9)
+	assert.NotNil(t, err)
+}
+
+func TestMigrateLegacyResourcePool(t *testing.T) {
+	rp, err := NewResourcePool(1, 1, 16)
+	assert.Nil(t, err)
+	rp.set(0, true)
+	rp.set(15, true)
+
+	// the layout written by FormatResource and UpdateResource before the schema was versioned
+	decoded, err := UnmarshalResourcePool(legacyPool(t, map[string]interface{}{
+		PON_INTF_ID: 1,
+		START_IDX:   1,
+		END_IDX:     16,
+		POOL:        rp.Pool,
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(RESOURCE_POOL_VERSION), decoded.Version)
+	assert.Equal(t, uint32(1), decoded.PonIntfID)
+	assert.Equal(t, uint32(1), decoded.StartIdx)
+	assert.Equal(t, uint32(16), decoded.EndIdx)
+	assert.Equal(t, rp.Pool, decoded.Pool)
+	assert.Equal(t, uint32(0), decoded.NextIdx)
+	assert.Nil(t, decoded.Quarantine)
+}
+
+func TestUnmarshalCorruptResourcePool(t *testing.T) {
+	rp, err := NewResourcePool(1, 1, 16)
+	assert.Nil(t, err)
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{PON_INTF_ID: 1, START_IDX: 1, END_IDX: 16, POOL: rp.Pool}
+	}
+	corrupt := map[string]func(map[string]interface{}){
+		"missing start":  func(f map[string]interface{}) { delete(f, START_IDX) },
+		"string start":   func(f map[string]interface{}) { f[START_IDX] = "1" },
+		"negative end":   func(f map[string]interface{}) { f[END_IDX] = -1 },
+		"fractional end": func(f map[string]interface{}) { f[END_IDX] = 1.5 },
+		"inverted range": func(f map[string]interface{}) { f[START_IDX] = 17 },
+		"bad bitmap":     func(f map[string]interface{}) { f[POOL] = "not base64!" },
+		"empty bitmap":   func(f map[string]interface{}) { f[POOL] = "" },
+		"missing bitmap": func(f map[string]interface{}) { delete(f, POOL) },
+		"future version": func(f map[string]interface{}) { f[VERSION] = RESOURCE_POOL_VERSION + 1 },
+	}
+	for name, corruptFields := range corrupt {
+		Fields := valid()
+		corruptFields(Fields)
+		_, err := UnmarshalResourcePool(legacyPool(t, Fields))
+		assert.NotNil(t, err, name)
+	}
+	_, err = UnmarshalResourcePool([]byte("{"))
+	assert.NotNil(t, err)
+	_, err = UnmarshalResourcePool(legacyPool(t, valid()))
+	assert.Nil(t, err)
+}
+
+func TestGetLegacyResource(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, _ := newTestPONResourceManager(ctx)
+	initTestPools(t, ctx, PONRMgr)
+	Path := PONRMgr.GetPath(ctx, 0, GEMPORT_ID)
+
+	rp, err := NewResourcePool(0, 1024, 1031)
+	assert.Nil(t, err)
+	rp.set(0, true)
+	assert.Nil(t, PONRMgr.KVStore.Put(ctx, Path, legacyPool(t, map[string]interface{}{
+		PON_INTF_ID: 0, START_IDX: 1024, END_IDX: 1031, POOL: rp.Pool,
+	})))
+
+	// the legacy pool is read and written back in the current schema
+	ids, err := PONRMgr.GetResourceID(ctx, 0, GEMPORT_ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{1025}, ids)
+	KvPair, err := PONRMgr.KVStore.Get(ctx, Path)
+	assert.Nil(t, err)
+	Stored := make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal(KvPair.Value.([]byte), &Stored))
+	assert.Equal(t, float64(RESOURCE_POOL_VERSION), Stored[VERSION])
+
+	// corrupt pools are reported as errors
+	assert.Nil(t, PONRMgr.KVStore.Put(ctx, Path, legacyPool(t, map[string]interface{}{
+		PON_INTF_ID: 0, START_IDX: "1024", END_IDX: 1031, POOL: rp.Pool,
+	})))
+	_, err = PONRMgr.GetResourceID(ctx, 0, GEMPORT_ID, 1)
+	assert.NotNil(t, err)
+	assert.NotNil(t, PONRMgr.FreeResourceID(ctx, 0, GEMPORT_ID, []uint32{1024}))
+	_, err = PONRMgr.IsAllocated(ctx, 0, GEMPORT_ID, 1024)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,288 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ponresourcemanager
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/events/eventif"
+	"github.com/opencord/voltha-lib-go/v7/pkg/log"
+	"github.com/opencord/voltha-lib-go/v7/pkg/stats"
+	"github.com/opencord/voltha-protos/v5/go/voltha"
+)
+
+const (
+	// Device events raised and cleared when a resource pool crosses its high-water mark
+	RESOURCE_POOL_HIGH_UTILIZATION_RAISE_EVENT = "RESOURCE_POOL_HIGH_UTILIZATION_RAISE_EVENT"
+	RESOURCE_POOL_HIGH_UTILIZATION_CLEAR_EVENT = "RESOURCE_POOL_HIGH_UTILIZATION_CLEAR_EVENT"
+)
+
+// PoolUtilization reports the number of used and free IDs of the pool of a resource type on an interface.
+// For a pool shared by all the interfaces, IntfID is the shared pool ID.
+type PoolUtilization struct {
+	IntfID       uint32
+	ResourceType string
+	Used         uint32
+	Free         uint32
+}
+
+// Capacity returns the number of IDs of the pool
+func (pu PoolUtilization) Capacity() uint32 {
+	return pu.Used + pu.Free
+}
+
+// Ratio returns the fraction of the pool in use, between 0 and 1
+func (pu PoolUtilization) Ratio() float64 {
+	if pu.Capacity() == 0 {
+		return 0
+	}
+	return float64(pu.Used) / float64(pu.Capacity())
+}
+
+// UtilizationThreshold is the high-water mark of a resource pool. An event is raised once the
+// fraction of the pool in use reaches Raise, and cleared once it falls back to Clear or below.
+// Keeping Clear below Raise avoids flapping when the pool usage oscillates around the mark.
+type UtilizationThreshold struct {
+	Raise float64
+	Clear float64
+}
+
+func (ut UtilizationThreshold) validate() error {
+	if ut.Raise <= 0 || ut.Raise > 1 {
+		return fmt.Errorf("raise threshold %v must be in (0, 1]", ut.Raise)
+	}
+	if ut.Clear < 0 || ut.Clear >= ut.Raise {
+		return fmt.Errorf("clear threshold %v must be in [0, %v)", ut.Clear, ut.Raise)
+	}
+	return nil
+}
+
+// utilizationMonitor holds the reporting configuration and the alarms raised per pool.
+// It is referenced by pointer as some methods of PONResourceManager have value receivers.
+type utilizationMonitor struct {
+	lock        sync.Mutex
+	eventProxy  eventif.EventProxy
+	statsServer *stats.PromStatsServer
+	thresholds  map[string]UtilizationThreshold
+	// pool path -> true while the high utilization event is raised
+	raised map[string]bool
+}
+
+func newUtilizationMonitor() *utilizationMonitor {
+	return &utilizationMonitor{
+		thresholds: make(map[string]UtilizationThreshold),
+		raised:     make(map[string]bool),
+	}
+}
+
+// SetUtilizationReporting sets where the utilization of the resource pools is reported: the high
+// utilization events are sent on eventProxy and the pool gauges are published on statsServer.
+// Either can be nil. The utilization is reported on every allocation and release, and by ReportUtilization.
+func (PONRMgr *PONResourceManager) SetUtilizationReporting(eventProxy eventif.EventProxy, statsServer *stats.PromStatsServer) {
+	if PONRMgr.utilization == nil {
+		PONRMgr.utilization = newUtilizationMonitor()
+	}
+	PONRMgr.utilization.lock.Lock()
+	defer PONRMgr.utilization.lock.Unlock()
+	PONRMgr.utilization.eventProxy = eventProxy
+	PONRMgr.utilization.statsServer = statsServer
+}
+
+// SetUtilizationThreshold sets the high-water mark of the pools of a resource type. No event is
+// raised for a resource type without threshold.
+func (PONRMgr *PONResourceManager) SetUtilizationThreshold(ResourceType string, threshold UtilizationThreshold) error {
+	if !checkValidResourceType(ResourceType) {
+		return fmt.Errorf("invalid resource type: %s", ResourceType)
+	}
+	if err := threshold.validate(); err != nil {
+		return err
+	}
+	if PONRMgr.utilization == nil {
+		PONRMgr.utilization = newUtilizationMonitor()
+	}
+	PONRMgr.utilization.lock.Lock()
+	defer PONRMgr.utilization.lock.Unlock()
+	PONRMgr.utilization.thresholds[ResourceType] = threshold
+	return nil
+}
+
+// GetPoolUtilization returns the utilization of the pool of a resource type on an interface
+func (PONRMgr *PONResourceManager) GetPoolUtilization(ctx context.Context, IntfID uint32, ResourceType string) (*PoolUtilization, error) {
+	if !checkValidResourceType(ResourceType) {
+		return nil, fmt.Errorf("invalid resource type: %s", ResourceType)
+	}
+	// delegate to the master instance if sharing enabled across instances
+	SharedResourceMgr := PONRMgr.SharedResourceMgrs[PONRMgr.SharedIdxByType[ResourceType]]
+	if SharedResourceMgr != nil && PONRMgr != SharedResourceMgr {
+		return SharedResourceMgr.GetPoolUtilization(ctx, IntfID, ResourceType)
+	}
+	Path := PONRMgr.GetPath(ctx, IntfID, ResourceType)
+	if Path == "" {
+		return nil, fmt.Errorf("failed to get path for resource type %s", ResourceType)
+	}
+	Resource, err := PONRMgr.GetResource(ctx, Path)
+	if err != nil {
+		return nil, err
+	}
+	if Resource == nil {
+		return nil, fmt.Errorf("resource pool %s not found", Path)
+	}
+	return PONRMgr.poolUtilization(PONRMgr.poolIntfID(IntfID, ResourceType), ResourceType, Resource)
+}
+
+// GetUtilization returns the utilization of all the resource pools of the interfaces serviced by
+// this manager. A pool shared by the interfaces is reported once.
+func (PONRMgr *PONResourceManager) GetUtilization(ctx context.Context) ([]PoolUtilization, error) {
+	var result []PoolUtilization
+	seen := make(map[string]bool)
+	for _, ResourceType := range []string{ONU_ID, ALLOC_ID, GEMPORT_ID, FLOW_ID} {
+		for _, Intf := range PONRMgr.IntfIDs {
+			Path := PONRMgr.GetPath(ctx, Intf, ResourceType)
+			if Path == "" || seen[Path] {
+				continue
+			}
+			seen[Path] = true
+			pu, err := PONRMgr.GetPoolUtilization(ctx, Intf, ResourceType)
+			if err != nil {
+				logger.Warnw(ctx, "failed-to-get-pool-utilization", log.Fields{"intf-id": Intf, "resource-type": ResourceType, "error": err})
+				continue
+			}
+			result = append(result, *pu)
+		}
+	}
+	return result, nil
+}
+
+// ReportUtilization publishes the utilization of all the resource pools and raises or clears the
+// high utilization events accordingly. It returns the utilization reported.
+func (PONRMgr *PONResourceManager) ReportUtilization(ctx context.Context) ([]PoolUtilization, error) {
+	result, err := PONRMgr.GetUtilization(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, pu := range result {
+		PONRMgr.reportPoolUtilization(ctx, PONRMgr.GetPath(ctx, pu.IntfID, pu.ResourceType), pu)
+	}
+	return result, nil
+}
+
+// poolIntfID returns the interface ID the pool of a resource type on an interface is identified with
+func (PONRMgr *PONResourceManager) poolIntfID(IntfID uint32, ResourceType string) uint32 {
+	if SharedPoolID, ok := PONRMgr.PonResourceRanges[PONRMgr.SharedIdxByType[ResourceType]].(uint32); ok && SharedPoolID != 0 {
+		return SharedPoolID
+	}
+	return IntfID
+}
+
+// poolUtilization counts the IDs set in the bitmap of a pool fetched with GetResource
+func (PONRMgr *PONResourceManager) poolUtilization(IntfID uint32, ResourceType string, Resource *ResourcePool) (*PoolUtilization, error) {
+	if Resource == nil {
+		return nil, fmt.Errorf("nil resource pool")
+	}
+	Capacity := Resource.Capacity()
+	var Used uint32
+	for Idx := 0; Idx < Capacity; Idx++ {
+		if Resource.IsSet(Idx) {
+			Used++
+		}
+	}
+	return &PoolUtilization{
+		IntfID:       IntfID,
+		ResourceType: ResourceType,
+		Used:         Used,
+		Free:         uint32(Capacity) - Used,
+	}, nil
+}
+
+// checkUtilization reports the utilization of a pool after it has been updated in the KV store
+func (PONRMgr *PONResourceManager) checkUtilization(ctx context.Context, IntfID uint32, ResourceType string, Path string, Resource *ResourcePool) {
+	if PONRMgr.utilization == nil {
+		return
+	}
+	pu, err := PONRMgr.poolUtilization(PONRMgr.poolIntfID(IntfID, ResourceType), ResourceType, Resource)
+	if err != nil {
+		logger.Warnw(ctx, "failed-to-compute-pool-utilization", log.Fields{"path": Path, "error": err})
+		return
+	}
+	PONRMgr.reportPoolUtilization(ctx, Path, *pu)
+}
+
+func (PONRMgr *PONResourceManager) reportPoolUtilization(ctx context.Context, Path string, pu PoolUtilization) {
+	um := PONRMgr.utilization
+	if um == nil {
+		return
+	}
+
+	um.lock.Lock()
+	eventProxy := um.eventProxy
+	statsServer := um.statsServer
+	var eventName string
+	threshold, hasThreshold := um.thresholds[pu.ResourceType]
+	if hasThreshold {
+		ratio := pu.Ratio()
+		if !um.raised[Path] && ratio >= threshold.Raise {
+			um.raised[Path] = true
+			eventName = RESOURCE_POOL_HIGH_UTILIZATION_RAISE_EVENT
+		} else if um.raised[Path] && ratio <= threshold.Clear {
+			delete(um.raised, Path)
+			eventName = RESOURCE_POOL_HIGH_UTILIZATION_CLEAR_EVENT
+		}
+	}
+	um.lock.Unlock()
+
+	if statsServer != nil {
+		statsServer.SetResourcePoolUsage(PONRMgr.DeviceID, pu.IntfID, pu.ResourceType, pu.Used, pu.Free)
+	}
+	if eventName == "" {
+		return
+	}
+
+	logger.Infow(ctx, "resource-pool-utilization-threshold-crossed", log.Fields{
+		"device-id":     PONRMgr.DeviceID,
+		"intf-id":       pu.IntfID,
+		"resource-type": pu.ResourceType,
+		"used":          pu.Used,
+		"free":          pu.Free,
+		"event":         eventName,
+	})
+	if eventProxy == nil {
+		return
+	}
+	deviceEvent := &voltha.DeviceEvent{
+		ResourceId:      PONRMgr.DeviceID,
+		DeviceEventName: eventName,
+		Description: fmt.Sprintf("%s pool of interface %d is %.0f%% used (%d of %d IDs)",
+			pu.ResourceType, pu.IntfID, pu.Ratio()*100, pu.Used, pu.Capacity()),
+		Context: map[string]string{
+			"device-id":       PONRMgr.DeviceID,
+			"intf-id":         strconv.FormatUint(uint64(pu.IntfID), 10),
+			"resource-type":   pu.ResourceType,
+			"used":            strconv.FormatUint(uint64(pu.Used), 10),
+			"free":            strconv.FormatUint(uint64(pu.Free), 10),
+			"raise-threshold": strconv.FormatFloat(threshold.Raise, 'f', -1, 64),
+			"clear-threshold": strconv.FormatFloat(threshold.Clear, 'f', -1, 64),
+		},
+	}
+	if err := eventProxy.SendDeviceEvent(ctx, deviceEvent, voltha.EventCategory_EQUIPMENT, voltha.EventSubCategory_PON,
+		time.Now().Unix()); err != nil {
+		logger.Errorw(ctx, "failed-to-send-resource-pool-utilization-event", log.Fields{"event": eventName, "error": err})
+	}
+}
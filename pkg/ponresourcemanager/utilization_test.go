@@ -0,0 +1,135 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ponresourcemanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/events/eventif"
+	"github.com/opencord/voltha-protos/v5/go/voltha"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeEventProxy struct {
+	eventif.EventProxy
+	events []*voltha.DeviceEvent
+}
+
+func (f *fakeEventProxy) SendDeviceEvent(ctx context.Context, deviceEvent *voltha.DeviceEvent, category eventif.EventCategory,
+	subCategory eventif.EventSubCategory, raisedTs int64) error {
+	f.events = append(f.events, deviceEvent)
+	return nil
+}
+
+// initTestPools creates pools of 8 IDs for every resource type on interfaces 0 and 1
+func initTestPools(t *testing.T, ctx context.Context, PONRMgr *PONResourceManager) {
+	PONRMgr.InitDefaultPONResourceRanges(ctx, 1, 8, 0, 1024, 1031, 0, 1024, 1031, 0, 1, 8, 0, 0, 0, 2, []uint32{0, 1})
+	assert.Nil(t, PONRMgr.InitDeviceResourcePool(ctx))
+}
+
+func TestGetPoolUtilization(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, _ := newTestPONResourceManager(ctx)
+	initTestPools(t, ctx, PONRMgr)
+
+	_, err := PONRMgr.GetResourceID(ctx, 0, GEMPORT_ID, 3)
+	assert.Nil(t, err)
+
+	pu, err := PONRMgr.GetPoolUtilization(ctx, 0, GEMPORT_ID)
+	assert.Nil(t, err)
+	assert.Equal(t, PoolUtilization{IntfID: 0, ResourceType: GEMPORT_ID, Used: 3, Free: 5}, *pu)
+	assert.Equal(t, uint32(8), pu.Capacity())
+
+	pu, err = PONRMgr.GetPoolUtilization(ctx, 1, GEMPORT_ID)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), pu.Used)
+
+	_, err = PONRMgr.GetPoolUtilization(ctx, 0, "UNKNOWN")
+	assert.NotNil(t, err)
+
+	all, err := PONRMgr.GetUtilization(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, all, 8)
+}
+
+func TestUtilizationThresholdValidation(t *testing.T) {
+	PONRMgr, _ := newTestPONResourceManager(context.Background())
+	assert.NotNil(t, PONRMgr.SetUtilizationThreshold(ONU_ID, UtilizationThreshold{Raise: 1.5, Clear: 0.5}))
+	assert.NotNil(t, PONRMgr.SetUtilizationThreshold(ONU_ID, UtilizationThreshold{Raise: 0.8, Clear: 0.8}))
+	assert.NotNil(t, PONRMgr.SetUtilizationThreshold("UNKNOWN", UtilizationThreshold{Raise: 0.8, Clear: 0.5}))
+	assert.Nil(t, PONRMgr.SetUtilizationThreshold(ONU_ID, UtilizationThreshold{Raise: 0.8, Clear: 0.5}))
+}
+
+func TestUtilizationEvents(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, _ := newTestPONResourceManager(ctx)
+	initTestPools(t, ctx, PONRMgr)
+	eventProxy := &fakeEventProxy{}
+	PONRMgr.SetUtilizationReporting(eventProxy, nil)
+	assert.Nil(t, PONRMgr.SetUtilizationThreshold(ALLOC_ID, UtilizationThreshold{Raise: 0.75, Clear: 0.5}))
+
+	ids, err := PONRMgr.GetResourceID(ctx, 1, ALLOC_ID, 5)
+	assert.Nil(t, err)
+	assert.Empty(t, eventProxy.events)
+
+	// 6 of 8 IDs used raises the event, once
+	_, err = PONRMgr.GetResourceID(ctx, 1, ALLOC_ID, 1)
+	assert.Nil(t, err)
+	_, err = PONRMgr.GetResourceID(ctx, 1, ALLOC_ID, 1)
+	assert.Nil(t, err)
+	assert.Len(t, eventProxy.events, 1)
+	assert.Equal(t, RESOURCE_POOL_HIGH_UTILIZATION_RAISE_EVENT, eventProxy.events[0].DeviceEventName)
+	assert.Equal(t, "1", eventProxy.events[0].Context["intf-id"])
+	assert.Equal(t, ALLOC_ID, eventProxy.events[0].Context["resource-type"])
+	assert.Equal(t, "olt1", eventProxy.events[0].ResourceId)
+
+	// Going below the raise threshold does not clear it, going down to the clear threshold does
+	assert.Nil(t, PONRMgr.FreeResourceID(ctx, 1, ALLOC_ID, ids[:2]))
+	assert.Len(t, eventProxy.events, 1)
+	assert.Nil(t, PONRMgr.FreeResourceID(ctx, 1, ALLOC_ID, ids[2:3]))
+	assert.Len(t, eventProxy.events, 2)
+	assert.Equal(t, RESOURCE_POOL_HIGH_UTILIZATION_CLEAR_EVENT, eventProxy.events[1].DeviceEventName)
+
+	// Pools without threshold never raise events
+	_, err = PONRMgr.GetResourceID(ctx, 1, GEMPORT_ID, 8)
+	assert.Nil(t, err)
+	assert.Len(t, eventProxy.events, 2)
+}
+
+func TestReportUtilization(t *testing.T) {
+	ctx := context.Background()
+	PONRMgr, _ := newTestPONResourceManager(ctx)
+	initTestPools(t, ctx, PONRMgr)
+	_, err := PONRMgr.GetResourceID(ctx, 0, ONU_ID, 1)
+	assert.Nil(t, err)
+
+	// The pool already above the mark when reporting is enabled gets its event on the next report
+	eventProxy := &fakeEventProxy{}
+	PONRMgr.SetUtilizationReporting(eventProxy, nil)
+	assert.Nil(t, PONRMgr.SetUtilizationThreshold(ONU_ID, UtilizationThreshold{Raise: 0.1, Clear: 0}))
+	result, err := PONRMgr.ReportUtilization(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, result, 8)
+	assert.Len(t, eventProxy.events, 1)
+	assert.Equal(t, "0", eventProxy.events[0].Context["intf-id"])
+	assert.Equal(t, ONU_ID, eventProxy.events[0].Context["resource-type"])
+
+	_, err = PONRMgr.ReportUtilization(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, eventProxy.events, 1)
+}
@@ -93,6 +93,7 @@ type Probe struct {
 
 	mutex     sync.RWMutex
 	status    map[string]ServiceStatus
+	details   map[string]string
 	isReady   bool
 	isHealthy bool
 }
@@ -171,6 +172,32 @@ func (p *Probe) UpdateStatus(ctx context.Context, name string, status ServiceSta
 		})
 }
 
+// UpdateDetail publishes a detail, e.g. the state of a circuit breaker, on the detailz endpoint
+// along with the service statuses. Details are informational, they do not affect readiness or
+// health. An empty detail removes it.
+func (p *Probe) UpdateDetail(ctx context.Context, name string, detail string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if detail == "" {
+		delete(p.details, name)
+		return
+	}
+	if p.details == nil {
+		p.details = make(map[string]string)
+	}
+	if p.details[name] != detail {
+		p.details[name] = detail
+		logger.Debugw(ctx, "probe-detail-updated", log.Fields{"name": name, "detail": detail})
+	}
+}
+
+// GetDetail returns the detail published under name, an empty string if none
+func (p *Probe) GetDetail(name string) string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.details[name]
+}
+
 func (p *Probe) GetStatus(name string) ServiceStatus {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
@@ -247,6 +274,17 @@ func (p *Probe) detailzFunc(w http.ResponseWriter, req *http.Request) {
 		}
 		comma = ", "
 	}
+	for name, detail := range p.details {
+		if _, ok := p.status[name]; ok {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s\"%s\": \"%s\"", comma, name, detail); err != nil {
+			logger.Errorw(ctx, "write-response", log.Fields{"error": err})
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		comma = ", "
+	}
 	if _, err := w.Write([]byte("}")); err != nil {
 		logger.Errorw(ctx, "write-response", log.Fields{"error": err})
 		w.WriteHeader(http.StatusInternalServerError)
@@ -265,7 +303,7 @@ func (p *Probe) ListenAndServe(ctx context.Context, address string) {
 	// Returns the result of the healthFunc calculation
 	mux.HandleFunc("/healthz", p.healthzFunc)
 
-	// Returns the details of the services and their status as JSON
+	// Returns the details of the services, their status and the published details as JSON
 	mux.HandleFunc("/detailz", p.detailzFunc)
 	s := &http.Server{
 		Addr:    address,
@@ -190,6 +190,26 @@ func TestDetailzWithServies(t *testing.T) {
 	assert.Equal(t, "Unknown", vals["two"], "wrong value")
 }
 
+func TestDetailzWithDetails(t *testing.T) {
+	ctx := context.Background()
+	p := &Probe{}
+	p.RegisterService(ctx, "one")
+	p.UpdateStatus(ctx, "one", ServiceStatusRunning)
+	p.UpdateDetail(ctx, "circuit-breaker:one/svc/Method", "Open")
+	p.UpdateDetail(ctx, "removed", "Closed")
+	p.UpdateDetail(ctx, "removed", "")
+	assert.True(t, p.IsReady(), "details must not affect readiness")
+	assert.Equal(t, "Open", p.GetDetail("circuit-breaker:one/svc/Method"))
+
+	req := httptest.NewRequest("GET", "http://example.com/detailz", nil)
+	w := httptest.NewRecorder()
+	p.detailzFunc(w, req)
+	body, _ := io.ReadAll(w.Result().Body)
+	var vals map[string]string
+	assert.Nil(t, json.Unmarshal(body, &vals), "unable to unmarshal values")
+	assert.Equal(t, map[string]string{"one": "Running", "circuit-breaker:one/svc/Method": "Open"}, vals)
+}
+
 func TestReadzNoServices(t *testing.T) {
 	p := (&Probe{}).WithReadyFunc(AlwaysTrue)
 	req := httptest.NewRequest("GET", "http://example.com/readz", nil)
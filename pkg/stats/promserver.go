@@ -19,6 +19,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/opencord/voltha-lib-go/v7/pkg/log"
@@ -39,6 +40,10 @@ type PromStatsServer struct {
 	devDurations *prometheus.HistogramVec
 	// To hold the durations which are NOT tied to specific to devices
 	otherDurations *prometheus.HistogramVec
+	// To hold the number of used and free IDs of the PON resource pools
+	resourcePools *prometheus.GaugeVec
+	// To hold the state of the circuit breakers of the grpc clients
+	circuitBreakers *prometheus.GaugeVec
 }
 
 var StatsServer = PromStatsServer{}
@@ -123,10 +128,30 @@ func (ps *PromStatsServer) initializeCollectors(ctx context.Context, name Collec
 		[]string{"duration"},
 	)
 
+	ps.resourcePools = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: collectorName,
+			Name:      "resource_pool_ids",
+			Help:      "Number of used and free IDs in the PON resource pools of a device",
+		},
+		[]string{"device_id", "intf_id", "resource_type", "state"},
+	)
+
+	ps.circuitBreakers = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: collectorName,
+			Name:      "grpc_circuit_breaker_state",
+			Help:      "State of the circuit breaker of a grpc method of an endpoint: 0 closed, 1 open, 2 half-open",
+		},
+		[]string{"endpoint", "method"},
+	)
+
 	prometheus.MustRegister(ps.devCounters)
 	prometheus.MustRegister(ps.otherCounters)
 	prometheus.MustRegister(ps.devDurations)
 	prometheus.MustRegister(ps.otherDurations)
+	prometheus.MustRegister(ps.resourcePools)
+	prometheus.MustRegister(ps.circuitBreakers)
 }
 
 // CountForDevice counts the number of times the counterName happens for device devId with serial number sn. Each call to Count increments it by one.
@@ -172,3 +197,19 @@ func (ps *PromStatsServer) CollectDuration(dName NonDeviceDuration, startTime ti
 		ps.otherDurations.WithLabelValues(dName.String()).Observe(float64(timeSpent.Milliseconds()))
 	}
 }
+
+// SetResourcePoolUsage sets the number of used and free IDs of the resourceType pool of interface intfID of device devID.
+func (ps *PromStatsServer) SetResourcePoolUsage(devID string, intfID uint32, resourceType string, used, free uint32) {
+	if ps.resourcePools != nil {
+		intf := strconv.FormatUint(uint64(intfID), 10)
+		ps.resourcePools.WithLabelValues(devID, intf, resourceType, "used").Set(float64(used))
+		ps.resourcePools.WithLabelValues(devID, intf, resourceType, "free").Set(float64(free))
+	}
+}
+
+// SetCircuitBreakerState sets the state of the circuit breaker of the grpc method of the endpoint.
+func (ps *PromStatsServer) SetCircuitBreakerState(endpoint, method string, state uint8) {
+	if ps.circuitBreakers != nil {
+		ps.circuitBreakers.WithLabelValues(endpoint, method).Set(float64(state))
+	}
+}
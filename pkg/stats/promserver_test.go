@@ -60,6 +60,10 @@ func TestPromStatsServer_Start(t *testing.T) {
 
 	StatsServer.CollectDuration(DBWriteTime, startTime)
 
+	StatsServer.SetResourcePoolUsage("dev4", 2, "GEMPORT_ID", 12, 500)
+
+	StatsServer.SetCircuitBreakerState("127.0.0.1:1234", "/core_service.CoreService/GetDevice", 1)
+
 	clientCtx, clientCancel := context.WithTimeout(context.Background(), time.Second)
 	defer clientCancel()
 
@@ -82,4 +86,7 @@ func TestPromStatsServer_Start(t *testing.T) {
 	assert.Contains(t, string(bodyBytes), `voltha_rw_core_counters{counter="core_rpc_errors_total"} 4`)
 	assert.Contains(t, string(bodyBytes), `voltha_rw_core_device_counters{counter="discoveries_received_total",device_id="dev2",serial_no="serial2"} 56`)
 	assert.Contains(t, string(bodyBytes), `voltha_rw_core_device_durations_bucket{device_id="dev3",duration="onu_discovery_proc_time",serial_no="sn3",le="300"} 1`)
+	assert.Contains(t, string(bodyBytes), `voltha_rw_core_resource_pool_ids{device_id="dev4",intf_id="2",resource_type="GEMPORT_ID",state="used"} 12`)
+	assert.Contains(t, string(bodyBytes), `voltha_rw_core_resource_pool_ids{device_id="dev4",intf_id="2",resource_type="GEMPORT_ID",state="free"} 500`)
+	assert.Contains(t, string(bodyBytes), `voltha_rw_core_grpc_circuit_breaker_state{endpoint="127.0.0.1:1234",method="/core_service.CoreService/GetDevice"} 1`)
 }
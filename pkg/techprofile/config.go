@@ -78,6 +78,11 @@ const (
 	SCHEDULING_POLICY                  = "scheduling_policy"
 	MAX_Q_SIZE                         = "max_q_size"
 	AES_ENCRYPTION                     = "aes_encryption"
+	DISCARD_CONFIG_V2                  = "discard_config_v2"
+	IS_MULTICAST                       = "is_multicast"
+	MULTICAST_GEM_ID                   = "multicast_gem_id"
+	UPSTREAM_QUEUE_ATTRIBUTE_LIST      = "upstream_queue_attribute_list"
+	DOWNSTREAM_QUEUE_ATTRIBUTE_LIST    = "downstream_queue_attribute_list"
 	// String Keys for EPON
 	EPON_ATTRIBUTE              = "epon_attribute"
 	PACKAGE_TYPE                = "package_type"
@@ -116,6 +121,7 @@ type TechProfileFlags struct {
 	LogLevel                     int
 	DefaultTechProfileID         uint32
 	DefaultNumGemPorts           uint32
+	StrictValidation             bool // refuse to fall back to the default TP when the stored TP is missing or invalid
 }
 
 func NewTechProfileFlags(KVStoreType string, KVStoreAddress string, basePathKvStore string) *TechProfileFlags {
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package techprofile
+
+import (
+	"context"
+	"fmt"
+
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+)
+
+// TechProfileKVPrefix is the KV store prefix the TP definitions are read from
+const TechProfileKVPrefix = defaultTpKvPathPrefix
+
+// TechProfileKVKey returns the key of a TP definition under TechProfileKVPrefix
+func TechProfileKVKey(technology string, tpID uint32) string {
+	return fmt.Sprintf(defaultTechProfileKVPath, technology, tpID)
+}
+
+// RenderTechProfileInstance validates the TP and builds the instance a TechProfileMgr of the
+// registered GPON family technology would create for the UNI with the given alloc ID and GEM port
+// IDs, one per GEM port of the TP
+func RenderTechProfileInstance(ctx context.Context, technology string, tp *tp_pb.TechProfile, tpID uint32, uniPortName string, allocID uint32, gemPorts []uint32) (*tp_pb.TechProfileInstance, error) {
+	tech, ok := LookupTechnology(technology)
+	if !ok {
+		return nil, fmt.Errorf("unknown-technology-%s", technology)
+	}
+	gponTech, ok := tech.(GponFamilyTechnology)
+	if !ok {
+		return nil, fmt.Errorf("%w-%s-is-not-gpon", ErrWrongTechnology, technology)
+	}
+	if err := gponTech.ValidateTechProfile(tp); err != nil {
+		return nil, err
+	}
+	if len(gemPorts) != int(tp.NumGemPorts) {
+		return nil, fmt.Errorf("tp-has-%d-gem-ports-got-%d-gem-port-ids", tp.NumGemPorts, len(gemPorts))
+	}
+	if err := tech.ResourceIDLimits().check(allocID, gemPorts); err != nil {
+		return nil, err
+	}
+	tpInstance := gponTech.BuildTpInstance(ctx, tp, &tp_pb.ResourceInstance{
+		TpId:                 tpID,
+		ProfileType:          tp.ProfileType,
+		SubscriberIdentifier: uniPortName,
+		AllocId:              allocID,
+		GemportIds:           gemPorts,
+	})
+	if tpInstance == nil {
+		return nil, fmt.Errorf("failed-to-build-tp-instance-from-tp-%d", tpID)
+	}
+	return tpInstance, nil
+}
+
+// RenderEponTechProfileInstance is RenderTechProfileInstance for the EPON family
+func RenderEponTechProfileInstance(ctx context.Context, technology string, tp *tp_pb.EponTechProfile, tpID uint32, uniPortName string, allocID uint32, gemPorts []uint32) (*tp_pb.EponTechProfileInstance, error) {
+	tech, ok := LookupTechnology(technology)
+	if !ok {
+		return nil, fmt.Errorf("unknown-technology-%s", technology)
+	}
+	eponTech, ok := tech.(EponFamilyTechnology)
+	if !ok {
+		return nil, fmt.Errorf("%w-%s-is-not-epon", ErrWrongTechnology, technology)
+	}
+	if err := eponTech.ValidateTechProfile(tp); err != nil {
+		return nil, err
+	}
+	if len(gemPorts) != int(tp.NumGemPorts) {
+		return nil, fmt.Errorf("tp-has-%d-queues-got-%d-gem-port-ids", tp.NumGemPorts, len(gemPorts))
+	}
+	if err := tech.ResourceIDLimits().check(allocID, gemPorts); err != nil {
+		return nil, err
+	}
+	tpInstance := eponTech.BuildTpInstance(ctx, tp, &tp_pb.ResourceInstance{
+		TpId:                 tpID,
+		ProfileType:          tp.ProfileType,
+		SubscriberIdentifier: uniPortName,
+		AllocId:              allocID,
+		GemportIds:           gemPorts,
+	})
+	if tpInstance == nil {
+		return nil, fmt.Errorf("failed-to-build-tp-instance-from-tp-%d", tpID)
+	}
+	return tpInstance, nil
+}
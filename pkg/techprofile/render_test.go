@@ -0,0 +1,74 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package techprofile
+
+import (
+	"context"
+	"testing"
+
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRenderTechProfileInstance(t *testing.T) {
+	ctx := context.Background()
+	tpMgr, tpDefault := newTestTechProfileMgr(t, ctx, xgspon)
+	tp := &tp_pb.TechProfile{}
+	loadSampleProfile(t, "4QueueHybridProfileMap1.json", tp)
+	putTechProfile(t, ctx, tpDefault, xgspon, 64, tp)
+
+	// the fake resource manager hands out the alloc ID first and then the GEM port IDs
+	created, err := tpMgr.CreateTechProfileInstance(ctx, 64, testUniPortName, 0)
+	assert.Nil(t, err)
+	rendered, err := RenderTechProfileInstance(ctx, xgspon, tp, 64, testUniPortName, 1025, []uint32{1026, 1027, 1028, 1029})
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(created.(*tp_pb.TechProfileInstance), rendered))
+
+	_, err = RenderTechProfileInstance(ctx, xgspon, tp, 64, testUniPortName, 1025, []uint32{1026})
+	assert.EqualError(t, err, "tp-has-4-gem-ports-got-1-gem-port-ids")
+
+	tp.UpstreamGemPortAttributeList[0].PriorityQ = 9
+	_, err = RenderTechProfileInstance(ctx, xgspon, tp, 64, testUniPortName, 1025, []uint32{1026, 1027, 1028, 1029})
+	var errs ValidationErrors
+	assert.ErrorAs(t, err, &errs)
+	tp.UpstreamGemPortAttributeList[0].PriorityQ = 7
+
+	// the technology decides on the validation, the ID limits and the instance built
+	_, err = RenderTechProfileInstance(ctx, gpon, tp, 64, testUniPortName, 4096, []uint32{1026, 1027, 1028, 1029})
+	assert.EqualError(t, err, "alloc-id-4096-exceeds-4095")
+	_, err = RenderTechProfileInstance(ctx, epon, tp, 64, testUniPortName, 1025, []uint32{1026, 1027, 1028, 1029})
+	assert.ErrorIs(t, err, ErrWrongTechnology)
+	_, err = RenderTechProfileInstance(ctx, "UNKNOWN-PON", tp, 64, testUniPortName, 1025, []uint32{1026, 1027, 1028, 1029})
+	assert.NotNil(t, err)
+}
+
+func TestRenderEponTechProfileInstance(t *testing.T) {
+	ctx := context.Background()
+	tp := &tp_pb.EponTechProfile{}
+	loadSampleProfile(t, "SingleQueueEponProfile.json", tp)
+
+	tpInst, err := RenderEponTechProfileInstance(ctx, epon, tp, 64, testUniPortName, 1024, []uint32{1025})
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1024), tpInst.AllocId)
+	assert.Equal(t, testUniPortName, tpInst.SubscriberIdentifier)
+	assert.Equal(t, uint32(1025), tpInst.UpstreamQueueAttributeList[0].GemportId)
+	assert.Equal(t, "EPON/64", TechProfileKVKey(epon, 64))
+
+	_, err = RenderEponTechProfileInstance(ctx, xgspon, tp, 64, testUniPortName, 1024, []uint32{1025})
+	assert.ErrorIs(t, err, ErrWrongTechnology)
+}
@@ -127,10 +127,11 @@ const (
 )
 
 const (
-	xgspon = "XGS-PON"
-	xgpon  = "XGPON"
-	gpon   = "GPON"
-	epon   = "EPON"
+	xgspon  = "XGS-PON"
+	xgpon   = "XGPON"
+	gpon    = "GPON"
+	gs25pon = "25GS-PON"
+	epon    = "EPON"
 )
 
 const (
@@ -140,6 +141,7 @@ const (
 type TechProfileMgr struct {
 	config                *TechProfileFlags
 	resourceMgr           iPonResourceMgr
+	technology            Technology // technology of the resource manager, see RegisterTechnology
 	OnuIDMgmtLock         sync.RWMutex
 	GemPortIDMgmtLock     sync.RWMutex
 	AllocIDMgmtLock       sync.RWMutex
@@ -151,6 +153,10 @@ type TechProfileMgr struct {
 	tpMapLock             sync.RWMutex
 	eponTpMap             map[uint32]*tp_pb.EponTechProfile // map of tp id to epon tp
 	eponTpMapLock         sync.RWMutex
+	tpWatchCh             chan *kvstore.Event // watch on the TP definitions, see StartTpWatch
+	tpWatchDone           chan struct{}
+	tpUpdateCallbacks     []TpUpdateCallback
+	tpWatchLock           sync.Mutex
 }
 
 func (t *TechProfileMgr) SetKVClient(ctx context.Context, pathPrefix string) *db.Backend {
@@ -213,6 +219,7 @@ func NewTechProfile(ctx context.Context, IntfId uint32, deviceId string, resourc
 		return nil, errors.New("resource-instance-kv-backend-init-failed")
 	}
 	techprofileObj.resourceMgr = resourceMgr
+	techprofileObj.technology = resolveTechnology(ctx, resourceMgr.GetTechnology())
 	techprofileObj.tpInstanceMap = make(map[string]*tp_pb.TechProfileInstance)
 	techprofileObj.eponTpInstanceMap = make(map[string]*tp_pb.EponTechProfileInstance)
 	techprofileObj.tpMap = make(map[uint32]*tp_pb.TechProfile)
@@ -227,6 +234,13 @@ func NewTechProfile(ctx context.Context, IntfId uint32, deviceId string, resourc
 	return &techprofileObj, nil
 }
 
+// SetStrictValidation controls whether CreateTechProfileInstance fails when the TP is missing from
+// the KV store or does not pass validation, instead of falling back to the default TP. The instances
+// already stored are rebuilt from their TP either way.
+func (t *TechProfileMgr) SetStrictValidation(strict bool) {
+	t.config.StrictValidation = strict
+}
+
 // GetTechProfileInstanceKey returns the tp instance key that is used to reference TP Instance Map
 func (t *TechProfileMgr) GetTechProfileInstanceKey(ctx context.Context, tpID uint32, uniPortName string) string {
 	logger.Debugw(ctx, "get-tp-instance-kv-key", log.Fields{
@@ -247,8 +261,8 @@ func (t *TechProfileMgr) GetTechProfileInstanceKey(ctx context.Context, tpID uin
 // GetTPInstance gets TP instance from cache if found
 func (t *TechProfileMgr) GetTPInstance(ctx context.Context, path string) (interface{}, error) {
 	tech := t.resourceMgr.GetTechnology()
-	switch tech {
-	case xgspon, xgpon, gpon:
+	switch {
+	case t.isGpon():
 		t.tpInstanceMapLock.RLock()
 		defer t.tpInstanceMapLock.RUnlock()
 		tpInst, ok := t.tpInstanceMap[path]
@@ -256,7 +270,7 @@ func (t *TechProfileMgr) GetTPInstance(ctx context.Context, path string) (interf
 			return nil, fmt.Errorf("tp-instance-not-found-tp-path-%v", path)
 		}
 		return tpInst, nil
-	case epon:
+	case t.isEpon():
 		t.epontpInstanceMapLock.RLock()
 		defer t.epontpInstanceMapLock.RUnlock()
 		tpInst, ok := t.eponTpInstanceMap[path]
@@ -284,15 +298,18 @@ func (t *TechProfileMgr) CreateTechProfileInstance(ctx context.Context, tpID uin
 	}
 	tpInstancePathSuffix := t.GetTechProfileInstanceKey(ctx, tpID, uniPortName)
 
-	if t.resourceMgr.GetTechnology() == epon {
-		tp := t.getEponTPFromKVStore(ctx, tpID)
-		if tp != nil {
-			if err := t.validateInstanceControlAttr(ctx, tp.InstanceControl); err != nil {
-				logger.Error(ctx, "invalid-instance-ctrl-attr-using-default-tp")
-				tp = t.getDefaultEponProfile(ctx)
-			} else {
-				logger.Infow(ctx, "using-specified-tp-from-kv-store", log.Fields{"tpID": tpID})
-			}
+	if t.isEpon() {
+		tp, err := t.getEponTPFromKVStore(ctx, tpID)
+		if isValidationError(err) && !t.config.StrictValidation {
+			logger.Warnw(ctx, "invalid-tp-on-kv--creating-default-tp", log.Fields{"tpID": tpID})
+			tp = t.getDefaultEponProfile(ctx)
+		} else if err != nil {
+			return nil, err
+		} else if tp != nil {
+			logger.Infow(ctx, "using-specified-tp-from-kv-store", log.Fields{"tpID": tpID})
+		} else if t.config.StrictValidation {
+			logger.Errorw(ctx, "tp-not-found--strict-validation-refuses-default-tp", log.Fields{"tpID": tpID})
+			return nil, fmt.Errorf("tp-%d-not-found", tpID)
 		} else {
 			logger.Info(ctx, "tp-not-found-on-kv--creating-default-tp")
 			tp = t.getDefaultEponProfile(ctx)
@@ -335,14 +352,17 @@ func (t *TechProfileMgr) CreateTechProfileInstance(ctx context.Context, tpID uin
 		}
 		return eponTpInstance, nil
 	} else {
-		tp := t.getTPFromKVStore(ctx, tpID)
-		if tp != nil {
-			if err := t.validateInstanceControlAttr(ctx, tp.InstanceControl); err != nil {
-				logger.Error(ctx, "invalid-instance-ctrl-attr--using-default-tp")
-				tp = t.getDefaultTechProfile(ctx)
-			} else {
-				logger.Infow(ctx, "using-specified-tp-from-kv-store", log.Fields{"tpID": tpID})
-			}
+		tp, err := t.getTPFromKVStore(ctx, tpID)
+		if isValidationError(err) && !t.config.StrictValidation {
+			logger.Warnw(ctx, "invalid-tp-on-kv--creating-default-tp", log.Fields{"tpID": tpID})
+			tp = t.getDefaultTechProfile(ctx)
+		} else if err != nil {
+			return nil, err
+		} else if tp != nil {
+			logger.Infow(ctx, "using-specified-tp-from-kv-store", log.Fields{"tpID": tpID})
+		} else if t.config.StrictValidation {
+			logger.Errorw(ctx, "tp-not-found--strict-validation-refuses-default-tp", log.Fields{"tpID": tpID})
+			return nil, fmt.Errorf("tp-%d-not-found", tpID)
 		} else {
 			logger.Info(ctx, "tp-not-found-on-kv--creating-default-tp")
 			tp = t.getDefaultTechProfile(ctx)
@@ -523,7 +543,7 @@ func (t *TechProfileMgr) GetGemportForPbit(ctx context.Context, tp interface{},
 func (t *TechProfileMgr) FindAllTpInstances(ctx context.Context, oltDeviceID string, tpID uint32, intfID uint32, onuID uint32) interface{} {
 	onuTpInstancePathSuffix := fmt.Sprintf("%s/%d/olt-{%s}/pon-{%d}/onu-{%d}", t.resourceMgr.GetTechnology(), tpID, oltDeviceID, intfID, onuID)
 	tech := t.resourceMgr.GetTechnology()
-	if tech == xgspon || tech == xgpon || tech == gpon {
+	if t.isGpon() {
 		t.tpInstanceMapLock.RLock()
 		defer t.tpInstanceMapLock.RUnlock()
 		tpInstancesTech := make([]*tp_pb.TechProfileInstance, 0)
@@ -534,7 +554,7 @@ func (t *TechProfileMgr) FindAllTpInstances(ctx context.Context, oltDeviceID str
 			}
 		}
 		return tpInstancesTech
-	} else if tech == epon {
+	} else if t.isEpon() {
 		t.epontpInstanceMapLock.RLock()
 		defer t.epontpInstanceMapLock.RUnlock()
 		tpInstancesTech := make([]*tp_pb.EponTechProfileInstance, 0)
@@ -611,21 +631,20 @@ func (t *TechProfileMgr) FreeResourceID(ctx context.Context, intfID uint32, reso
 }
 
 func (t *TechProfileMgr) GetUsScheduler(tpInstance *tp_pb.TechProfileInstance) *tp_pb.SchedulerConfig {
-	return &tp_pb.SchedulerConfig{
-		Direction:    tpInstance.UsScheduler.Direction,
-		AdditionalBw: tpInstance.UsScheduler.AdditionalBw,
-		Priority:     tpInstance.UsScheduler.Priority,
-		Weight:       tpInstance.UsScheduler.Weight,
-		SchedPolicy:  tpInstance.UsScheduler.QSchedPolicy}
+	return t.schedulerConfig(tpInstance.UsScheduler)
 }
 
 func (t *TechProfileMgr) GetDsScheduler(tpInstance *tp_pb.TechProfileInstance) *tp_pb.SchedulerConfig {
-	return &tp_pb.SchedulerConfig{
-		Direction:    tpInstance.DsScheduler.Direction,
-		AdditionalBw: tpInstance.DsScheduler.AdditionalBw,
-		Priority:     tpInstance.DsScheduler.Priority,
-		Weight:       tpInstance.DsScheduler.Weight,
-		SchedPolicy:  tpInstance.DsScheduler.QSchedPolicy}
+	return t.schedulerConfig(tpInstance.DsScheduler)
+}
+
+// schedulerConfig maps the scheduler as the technology does, GPON instances of another technology
+// are mapped as for GPON
+func (t *TechProfileMgr) schedulerConfig(sched *tp_pb.SchedulerAttributes) *tp_pb.SchedulerConfig {
+	if tech, ok := t.technology.(GponFamilyTechnology); ok {
+		return tech.SchedulerConfig(sched)
+	}
+	return (&GponTechnology{}).SchedulerConfig(sched)
 }
 
 func (t *TechProfileMgr) GetTrafficScheduler(tpInstance *tp_pb.TechProfileInstance, SchedCfg *tp_pb.SchedulerConfig,
@@ -701,34 +720,12 @@ func (t *TechProfileMgr) GetTrafficQueues(ctx context.Context, tp *tp_pb.TechPro
 	return nil, fmt.Errorf("downstream gem port traffic queue creation failed due to unsupported direction %s", direction)
 }
 
-func (t *TechProfileMgr) validateInstanceControlAttr(ctx context.Context, instCtl *tp_pb.InstanceControl) error {
-	if instCtl.Onu != "single-instance" && instCtl.Onu != "multi-instance" {
-		logger.Errorw(ctx, "invalid-onu-instance-control-attribute", log.Fields{"onu-inst": instCtl.Onu})
-		return errors.New("invalid-onu-instance-ctl-attr")
-	}
-
-	if instCtl.Uni != "single-instance" && instCtl.Uni != "multi-instance" {
-		logger.Errorw(ctx, "invalid-uni-instance-control-attribute", log.Fields{"uni-inst": instCtl.Uni})
-		return errors.New("invalid-uni-instance-ctl-attr")
-	}
-
-	if instCtl.Uni == "multi-instance" {
-		logger.Error(ctx, "uni-multi-instance-tp-not-supported")
-		return errors.New("uni-multi-instance-tp-not-supported")
-	}
-
-	return nil
-}
-
-// allocateTPInstance for GPON, XGPON and XGS-PON technology
+// allocateTPInstance for the GPON family of technologies
 func (t *TechProfileMgr) allocateTPInstance(ctx context.Context, uniPortName string, tp *tp_pb.TechProfile, intfID uint32, tpInstPathSuffix string) *tp_pb.TechProfileInstance {
 
-	var usGemPortAttributeList []*tp_pb.GemPortAttributes
-	var dsGemPortAttributeList []*tp_pb.GemPortAttributes
-	var dsMulticastGemAttributeList []*tp_pb.GemPortAttributes
-	var dsUnicastGemAttributeList []*tp_pb.GemPortAttributes
 	var tcontIDs []uint32
 	var gemPorts []uint32
+	var sharedAllocID bool
 	var err error
 
 	logger.Infow(ctx, "Allocating TechProfileMgr instance from techprofile template", log.Fields{"uniPortName": uniPortName, "intfID": intfID, "numGem": tp.NumGemPorts})
@@ -751,6 +748,7 @@ func (t *TechProfileMgr) allocateTPInstance(ctx context.Context, uniPortName str
 		} else {
 			// Use the alloc-id from the existing TpInstance
 			tcontIDs = append(tcontIDs, tpInst.UsScheduler.AllocId)
+			sharedAllocID = true
 		}
 	}
 	logger.Debugw(ctx, "Num GEM ports in TP:", log.Fields{"NumGemPorts": tp.NumGemPorts})
@@ -760,100 +758,23 @@ func (t *TechProfileMgr) allocateTPInstance(ctx context.Context, uniPortName str
 		return nil
 	}
 	logger.Infow(ctx, "Allocated tconts and GEM ports successfully", log.Fields{"tconts": tcontIDs, "gemports": gemPorts})
-	for index := 0; index < int(tp.NumGemPorts); index++ {
-		usGemPortAttributeList = append(usGemPortAttributeList,
-			&tp_pb.GemPortAttributes{GemportId: gemPorts[index],
-				MaxQSize:         tp.UpstreamGemPortAttributeList[index].MaxQSize,
-				PbitMap:          tp.UpstreamGemPortAttributeList[index].PbitMap,
-				AesEncryption:    tp.UpstreamGemPortAttributeList[index].AesEncryption,
-				SchedulingPolicy: tp.UpstreamGemPortAttributeList[index].SchedulingPolicy,
-				PriorityQ:        tp.UpstreamGemPortAttributeList[index].PriorityQ,
-				Weight:           tp.UpstreamGemPortAttributeList[index].Weight,
-				DiscardPolicy:    tp.UpstreamGemPortAttributeList[index].DiscardPolicy,
-				DiscardConfig:    tp.UpstreamGemPortAttributeList[index].DiscardConfig})
-	}
-
-	logger.Info(ctx, "length of DownstreamGemPortAttributeList", len(tp.DownstreamGemPortAttributeList))
-	//put multicast and unicast downstream GEM port attributes in different lists first
-	for index := 0; index < len(tp.DownstreamGemPortAttributeList); index++ {
-		if isMulticastGem(tp.DownstreamGemPortAttributeList[index].IsMulticast) {
-			dsMulticastGemAttributeList = append(dsMulticastGemAttributeList,
-				&tp_pb.GemPortAttributes{
-					MulticastGemId:           tp.DownstreamGemPortAttributeList[index].MulticastGemId,
-					MaxQSize:                 tp.DownstreamGemPortAttributeList[index].MaxQSize,
-					PbitMap:                  tp.DownstreamGemPortAttributeList[index].PbitMap,
-					AesEncryption:            tp.DownstreamGemPortAttributeList[index].AesEncryption,
-					SchedulingPolicy:         tp.DownstreamGemPortAttributeList[index].SchedulingPolicy,
-					PriorityQ:                tp.DownstreamGemPortAttributeList[index].PriorityQ,
-					Weight:                   tp.DownstreamGemPortAttributeList[index].Weight,
-					DiscardPolicy:            tp.DownstreamGemPortAttributeList[index].DiscardPolicy,
-					DiscardConfig:            tp.DownstreamGemPortAttributeList[index].DiscardConfig,
-					IsMulticast:              tp.DownstreamGemPortAttributeList[index].IsMulticast,
-					DynamicAccessControlList: tp.DownstreamGemPortAttributeList[index].DynamicAccessControlList,
-					StaticAccessControlList:  tp.DownstreamGemPortAttributeList[index].StaticAccessControlList})
-		} else {
-			dsUnicastGemAttributeList = append(dsUnicastGemAttributeList,
-				&tp_pb.GemPortAttributes{
-					MaxQSize:         tp.DownstreamGemPortAttributeList[index].MaxQSize,
-					PbitMap:          tp.DownstreamGemPortAttributeList[index].PbitMap,
-					AesEncryption:    tp.DownstreamGemPortAttributeList[index].AesEncryption,
-					SchedulingPolicy: tp.DownstreamGemPortAttributeList[index].SchedulingPolicy,
-					PriorityQ:        tp.DownstreamGemPortAttributeList[index].PriorityQ,
-					Weight:           tp.DownstreamGemPortAttributeList[index].Weight,
-					DiscardPolicy:    tp.DownstreamGemPortAttributeList[index].DiscardPolicy,
-					DiscardConfig:    tp.DownstreamGemPortAttributeList[index].DiscardConfig})
-		}
-	}
-	//add unicast downstream GEM ports to dsGemPortAttributeList
-	if dsUnicastGemAttributeList != nil {
-		for index := 0; index < int(tp.NumGemPorts); index++ {
-			dsGemPortAttributeList = append(dsGemPortAttributeList,
-				&tp_pb.GemPortAttributes{GemportId: gemPorts[index],
-					MaxQSize:         dsUnicastGemAttributeList[index].MaxQSize,
-					PbitMap:          dsUnicastGemAttributeList[index].PbitMap,
-					AesEncryption:    dsUnicastGemAttributeList[index].AesEncryption,
-					SchedulingPolicy: dsUnicastGemAttributeList[index].SchedulingPolicy,
-					PriorityQ:        dsUnicastGemAttributeList[index].PriorityQ,
-					Weight:           dsUnicastGemAttributeList[index].Weight,
-					DiscardPolicy:    dsUnicastGemAttributeList[index].DiscardPolicy,
-					DiscardConfig:    dsUnicastGemAttributeList[index].DiscardConfig})
-		}
+	if err = t.checkResourceIDLimits(ctx, intfID, tcontIDs[0], sharedAllocID, gemPorts); err != nil {
+		return nil
 	}
-	//add multicast GEM ports to dsGemPortAttributeList afterwards
-	dsGemPortAttributeList = append(dsGemPortAttributeList, dsMulticastGemAttributeList...)
-
-	return &tp_pb.TechProfileInstance{
-		SubscriberIdentifier: uniPortName,
-		Name:                 tp.Name,
+	return t.buildTpInstanceFromResourceInstance(ctx, tp, &tp_pb.ResourceInstance{
 		ProfileType:          tp.ProfileType,
-		Version:              tp.Version,
-		NumGemPorts:          tp.NumGemPorts,
-		InstanceControl:      tp.InstanceControl,
-		UsScheduler: &tp_pb.SchedulerAttributes{
-			AllocId:      tcontIDs[0],
-			Direction:    tp.UsScheduler.Direction,
-			AdditionalBw: tp.UsScheduler.AdditionalBw,
-			Priority:     tp.UsScheduler.Priority,
-			Weight:       tp.UsScheduler.Weight,
-			QSchedPolicy: tp.UsScheduler.QSchedPolicy},
-		DsScheduler: &tp_pb.SchedulerAttributes{
-			AllocId:      tcontIDs[0],
-			Direction:    tp.DsScheduler.Direction,
-			AdditionalBw: tp.DsScheduler.AdditionalBw,
-			Priority:     tp.DsScheduler.Priority,
-			Weight:       tp.DsScheduler.Weight,
-			QSchedPolicy: tp.DsScheduler.QSchedPolicy},
-		UpstreamGemPortAttributeList:   usGemPortAttributeList,
-		DownstreamGemPortAttributeList: dsGemPortAttributeList}
+		SubscriberIdentifier: uniPortName,
+		AllocId:              tcontIDs[0],
+		GemportIds:           gemPorts,
+	})
 }
 
 // allocateTPInstance function for EPON
 func (t *TechProfileMgr) allocateEponTPInstance(ctx context.Context, uniPortName string, tp *tp_pb.EponTechProfile, intfID uint32, tpInstPath string) *tp_pb.EponTechProfileInstance {
 
-	var usQueueAttributeList []*tp_pb.EPONQueueAttributes
-	var dsQueueAttributeList []*tp_pb.EPONQueueAttributes
 	var tcontIDs []uint32
 	var gemPorts []uint32
+	var sharedAllocID bool
 	var err error
 
 	logger.Infow(ctx, "allocating-tp-instance-from-tp-template", log.Fields{"uniPortName": uniPortName, "intfID": intfID, "numGem": tp.NumGemPorts})
@@ -874,6 +795,7 @@ func (t *TechProfileMgr) allocateEponTPInstance(ctx context.Context, uniPortName
 		} else {
 			// Use the alloc-id from the existing TpInstance
 			tcontIDs = append(tcontIDs, tpInst.AllocId)
+			sharedAllocID = true
 		}
 	}
 	logger.Debugw(ctx, "Num GEM ports in TP:", log.Fields{"NumGemPorts": tp.NumGemPorts})
@@ -882,51 +804,15 @@ func (t *TechProfileMgr) allocateEponTPInstance(ctx context.Context, uniPortName
 		return nil
 	}
 	logger.Infow(ctx, "allocated-alloc-id-and-gemport-successfully", log.Fields{"tconts": tcontIDs, "gemports": gemPorts})
-	for index := 0; index < int(tp.NumGemPorts); index++ {
-		usQueueAttributeList = append(usQueueAttributeList,
-			&tp_pb.EPONQueueAttributes{GemportId: gemPorts[index],
-				MaxQSize:                  tp.UpstreamQueueAttributeList[index].MaxQSize,
-				PbitMap:                   tp.UpstreamQueueAttributeList[index].PbitMap,
-				AesEncryption:             tp.UpstreamQueueAttributeList[index].AesEncryption,
-				TrafficType:               tp.UpstreamQueueAttributeList[index].TrafficType,
-				UnsolicitedGrantSize:      tp.UpstreamQueueAttributeList[index].UnsolicitedGrantSize,
-				NominalInterval:           tp.UpstreamQueueAttributeList[index].NominalInterval,
-				ToleratedPollJitter:       tp.UpstreamQueueAttributeList[index].ToleratedPollJitter,
-				RequestTransmissionPolicy: tp.UpstreamQueueAttributeList[index].RequestTransmissionPolicy,
-				NumQSets:                  tp.UpstreamQueueAttributeList[index].NumQSets,
-				QThresholds:               tp.UpstreamQueueAttributeList[index].QThresholds,
-				SchedulingPolicy:          tp.UpstreamQueueAttributeList[index].SchedulingPolicy,
-				PriorityQ:                 tp.UpstreamQueueAttributeList[index].PriorityQ,
-				Weight:                    tp.UpstreamQueueAttributeList[index].Weight,
-				DiscardPolicy:             tp.UpstreamQueueAttributeList[index].DiscardPolicy,
-				DiscardConfig:             tp.UpstreamQueueAttributeList[index].DiscardConfig})
-	}
-
-	logger.Info(ctx, "length-of-downstream-gemport-attribute-list", len(tp.DownstreamQueueAttributeList))
-	for index := 0; index < int(tp.NumGemPorts); index++ {
-		dsQueueAttributeList = append(dsQueueAttributeList,
-			&tp_pb.EPONQueueAttributes{GemportId: gemPorts[index],
-				MaxQSize:         tp.DownstreamQueueAttributeList[index].MaxQSize,
-				PbitMap:          tp.DownstreamQueueAttributeList[index].PbitMap,
-				AesEncryption:    tp.DownstreamQueueAttributeList[index].AesEncryption,
-				SchedulingPolicy: tp.DownstreamQueueAttributeList[index].SchedulingPolicy,
-				PriorityQ:        tp.DownstreamQueueAttributeList[index].PriorityQ,
-				Weight:           tp.DownstreamQueueAttributeList[index].Weight,
-				DiscardPolicy:    tp.DownstreamQueueAttributeList[index].DiscardPolicy,
-				DiscardConfig:    tp.DownstreamQueueAttributeList[index].DiscardConfig})
+	if err = t.checkResourceIDLimits(ctx, intfID, tcontIDs[0], sharedAllocID, gemPorts); err != nil {
+		return nil
 	}
-
-	return &tp_pb.EponTechProfileInstance{
-		SubscriberIdentifier:         uniPortName,
-		Name:                         tp.Name,
-		ProfileType:                  tp.ProfileType,
-		Version:                      tp.Version,
-		NumGemPorts:                  tp.NumGemPorts,
-		InstanceControl:              tp.InstanceControl,
-		PackageType:                  tp.PackageType,
-		AllocId:                      tcontIDs[0],
-		UpstreamQueueAttributeList:   usQueueAttributeList,
-		DownstreamQueueAttributeList: dsQueueAttributeList}
+	return t.buildEponTpInstanceFromResourceInstance(ctx, tp, &tp_pb.ResourceInstance{
+		ProfileType:          tp.ProfileType,
+		SubscriberIdentifier: uniPortName,
+		AllocId:              tcontIDs[0],
+		GemportIds:           gemPorts,
+	})
 }
 
 // getSingleInstanceTp returns another TpInstance (GPON, XGPON, XGS-PON) for an ONU on a different
@@ -970,12 +856,17 @@ func (t *TechProfileMgr) getSingleInstanceEponTp(ctx context.Context, tpPathSuff
 	return nil
 }
 
-// getDefaultTechProfile returns a default TechProfile for GPON, XGPON, XGS-PON
+// getDefaultTechProfile returns the default TechProfile of the technology
 func (t *TechProfileMgr) getDefaultTechProfile(ctx context.Context) *tp_pb.TechProfile {
+	return t.gponTechnology().DefaultTechProfile(ctx, t.config)
+}
+
+// defaultGponTechProfile returns a default TechProfile for GPON, XGPON, XGS-PON
+func defaultGponTechProfile(ctx context.Context, config *TechProfileFlags, technology string) *tp_pb.TechProfile {
 	var usGemPortAttributeList []*tp_pb.GemPortAttributes
 	var dsGemPortAttributeList []*tp_pb.GemPortAttributes
 
-	for _, pbit := range t.config.DefaultPbits {
+	for _, pbit := range config.DefaultPbits {
 		logger.Debugw(ctx, "creating-gem-port-profile-profile", log.Fields{"pbit": pbit})
 		usGemPortAttributeList = append(usGemPortAttributeList,
 			&tp_pb.GemPortAttributes{
@@ -1032,9 +923,9 @@ func (t *TechProfileMgr) getDefaultTechProfile(ctx context.Context) *tp_pb.TechP
 				MulticastGemId:           defaultMcastGemID})
 	}
 	return &tp_pb.TechProfile{
-		Name:        t.config.DefaultTPName,
-		ProfileType: t.resourceMgr.GetTechnology(),
-		Version:     t.config.TPVersion,
+		Name:        config.DefaultTPName,
+		ProfileType: technology,
+		Version:     config.TPVersion,
 		NumGemPorts: uint32(len(usGemPortAttributeList)),
 		InstanceControl: &tp_pb.InstanceControl{
 			Onu:               defaultOnuInstance,
@@ -1056,13 +947,18 @@ func (t *TechProfileMgr) getDefaultTechProfile(ctx context.Context) *tp_pb.TechP
 		DownstreamGemPortAttributeList: dsGemPortAttributeList}
 }
 
-// getDefaultEponProfile returns a default TechProfile for EPON
+// getDefaultEponProfile returns the default EponTechProfile of the technology
 func (t *TechProfileMgr) getDefaultEponProfile(ctx context.Context) *tp_pb.EponTechProfile {
+	return t.eponTechnology().DefaultTechProfile(ctx, t.config)
+}
+
+// defaultEponTechProfile returns a default TechProfile for EPON
+func defaultEponTechProfile(ctx context.Context, config *TechProfileFlags, technology string) *tp_pb.EponTechProfile {
 
 	var usQueueAttributeList []*tp_pb.EPONQueueAttributes
 	var dsQueueAttributeList []*tp_pb.EPONQueueAttributes
 
-	for _, pbit := range t.config.DefaultPbits {
+	for _, pbit := range config.DefaultPbits {
 		logger.Debugw(ctx, "Creating Queue", log.Fields{"pbit": pbit})
 		usQueueAttributeList = append(usQueueAttributeList,
 			&tp_pb.EPONQueueAttributes{
@@ -1128,9 +1024,9 @@ func (t *TechProfileMgr) getDefaultEponProfile(ctx context.Context) *tp_pb.EponT
 				}})
 	}
 	return &tp_pb.EponTechProfile{
-		Name:        t.config.DefaultTPName,
-		ProfileType: t.resourceMgr.GetTechnology(),
-		Version:     t.config.TPVersion,
+		Name:        config.DefaultTPName,
+		ProfileType: technology,
+		Version:     config.TPVersion,
 		NumGemPorts: uint32(len(usQueueAttributeList)),
 		InstanceControl: &tp_pb.InstanceControl{
 			Onu:               defaultOnuInstance,
@@ -1167,72 +1063,114 @@ func (t *TechProfileMgr) removeResourceInstanceFromKVStore(ctx context.Context,
 	return nil
 }
 
-func (t *TechProfileMgr) getTPFromKVStore(ctx context.Context, tpID uint32) *tp_pb.TechProfile {
-	var tp *tp_pb.TechProfile
+// getTPFromKVStore returns nil without an error if the KV store has no such TP, and an error if
+// the TP could not be read. A TP that does not pass validation is returned along with its
+// ValidationErrors.
+func (t *TechProfileMgr) getTPFromKVStore(ctx context.Context, tpID uint32) (*tp_pb.TechProfile, error) {
+	tp, err := t.loadTechProfile(ctx, tpID)
+	if errors.Is(err, ErrTpNotFound) {
+		logger.Debugw(ctx, "tp-not-found-in-kv-store", log.Fields{"tpID": tpID})
+		return nil, nil
+	} else if isValidationError(err) {
+		return tp, err
+	} else if err != nil {
+		logger.Errorw(ctx, "failed-to-get-tp", log.Fields{"err": err, "tpID": tpID})
+		return nil, err
+	}
+	return tp, nil
+}
+
+// loadTechProfile returns the TP from the cache, or from the KV store if it was not cached yet.
+// ErrTpNotFound is returned if the KV store has no such TP, and ValidationErrors along with the
+// TP if it does not pass validation.
+func (t *TechProfileMgr) loadTechProfile(ctx context.Context, tpID uint32) (*tp_pb.TechProfile, error) {
 	t.tpMapLock.RLock()
 	tp, ok := t.tpMap[tpID]
 	t.tpMapLock.RUnlock()
 	if ok {
 		logger.Debugw(ctx, "found-tp-in-cache", log.Fields{"tpID": tpID})
-		return tp
+		return tp, nil
 	}
 	key := fmt.Sprintf(t.config.TPFileKVPath, t.resourceMgr.GetTechnology(), tpID)
 	logger.Debugw(ctx, "getting-tp-from-kv-store", log.Fields{"tpID": tpID, "Key": key})
 	kvresult, err := t.config.DefaultTpKVBackend.Get(ctx, key)
 	if err != nil {
 		logger.Errorw(ctx, "error-fetching-from-kv-store", log.Fields{"err": err, "key": key})
-		return nil
+		return nil, err
 	}
-	if kvresult != nil {
-		/* Backend will return Value in string format,needs to be converted to []byte before unmarshal*/
-		if value, err := kvstore.ToByte(kvresult.Value); err == nil {
-			lTp := &tp_pb.TechProfile{}
-			if err = protojson.Unmarshal(value, lTp); err != nil {
-				logger.Errorw(ctx, "error-unmarshalling-tp-from-kv-store", log.Fields{"err": err, "tpID": tpID, "error": err})
-				return nil
-			}
-
-			logger.Debugw(ctx, "success-fetched-tp-from-kv-store", log.Fields{"tpID": tpID})
-			return lTp
-		} else {
-			logger.Errorw(ctx, "error-decoding-tp", log.Fields{"err": err, "tpID": tpID})
-			// We we create a default profile in this case.
-		}
+	if kvresult == nil {
+		return nil, fmt.Errorf("%w-%s", ErrTpNotFound, key)
+	}
+	/* Backend will return Value in string format,needs to be converted to []byte before unmarshal*/
+	value, err := kvstore.ToByte(kvresult.Value)
+	if err != nil {
+		logger.Errorw(ctx, "error-decoding-tp", log.Fields{"err": err, "tpID": tpID})
+		return nil, err
+	}
+	lTp := &tp_pb.TechProfile{}
+	if err = protojson.Unmarshal(value, lTp); err != nil {
+		logger.Errorw(ctx, "error-unmarshalling-tp-from-kv-store", log.Fields{"err": err, "tpID": tpID})
+		return nil, err
+	}
+	if err = t.gponTechnology().ValidateTechProfile(lTp); err != nil {
+		logger.Errorw(ctx, "invalid-tp-in-kv-store", log.Fields{"err": err, "tpID": tpID})
+		return lTp, err
 	}
+	logger.Debugw(ctx, "success-fetched-tp-from-kv-store", log.Fields{"tpID": tpID})
+	return lTp, nil
+}
 
-	return nil
+// getEponTPFromKVStore is getTPFromKVStore for EPON
+func (t *TechProfileMgr) getEponTPFromKVStore(ctx context.Context, tpID uint32) (*tp_pb.EponTechProfile, error) {
+	eponTp, err := t.loadEponTechProfile(ctx, tpID)
+	if errors.Is(err, ErrTpNotFound) {
+		logger.Debugw(ctx, "epon-tp-not-found-in-kv-store", log.Fields{"tpID": tpID})
+		return nil, nil
+	} else if isValidationError(err) {
+		return eponTp, err
+	} else if err != nil {
+		logger.Errorw(ctx, "failed-to-get-epon-tp", log.Fields{"err": err, "tpID": tpID})
+		return nil, err
+	}
+	return eponTp, nil
 }
 
-func (t *TechProfileMgr) getEponTPFromKVStore(ctx context.Context, tpID uint32) *tp_pb.EponTechProfile {
-	var eponTp *tp_pb.EponTechProfile
+// loadEponTechProfile is loadTechProfile for EPON
+func (t *TechProfileMgr) loadEponTechProfile(ctx context.Context, tpID uint32) (*tp_pb.EponTechProfile, error) {
 	t.eponTpMapLock.RLock()
 	eponTp, ok := t.eponTpMap[tpID]
 	t.eponTpMapLock.RUnlock()
 	if ok {
 		logger.Debugw(ctx, "found-tp-in-cache", log.Fields{"tpID": tpID})
-		return eponTp
+		return eponTp, nil
 	}
 	key := fmt.Sprintf(t.config.TPFileKVPath, t.resourceMgr.GetTechnology(), tpID)
 	logger.Debugw(ctx, "getting-epon-tp-from-kv-store", log.Fields{"tpID": tpID, "Key": key})
 	kvresult, err := t.config.DefaultTpKVBackend.Get(ctx, key)
 	if err != nil {
 		logger.Errorw(ctx, "error-fetching-from-kv-store", log.Fields{"err": err, "key": key})
-		return nil
+		return nil, err
 	}
-	if kvresult != nil {
-		/* Backend will return Value in string format,needs to be converted to []byte before unmarshal*/
-		if value, err := kvstore.ToByte(kvresult.Value); err == nil {
-			lEponTp := &tp_pb.EponTechProfile{}
-			if err = protojson.Unmarshal(value, lEponTp); err != nil {
-				logger.Errorw(ctx, "error-unmarshalling-epon-tp-from-kv-store", log.Fields{"err": err, "tpID": tpID, "error": err})
-				return nil
-			}
-
-			logger.Debugw(ctx, "success-fetching-epon-tp-from-kv-store", log.Fields{"tpID": tpID})
-			return lEponTp
-		}
+	if kvresult == nil {
+		return nil, fmt.Errorf("%w-%s", ErrTpNotFound, key)
 	}
-	return nil
+	/* Backend will return Value in string format,needs to be converted to []byte before unmarshal*/
+	value, err := kvstore.ToByte(kvresult.Value)
+	if err != nil {
+		logger.Errorw(ctx, "error-decoding-epon-tp", log.Fields{"err": err, "tpID": tpID})
+		return nil, err
+	}
+	lEponTp := &tp_pb.EponTechProfile{}
+	if err = protojson.Unmarshal(value, lEponTp); err != nil {
+		logger.Errorw(ctx, "error-unmarshalling-epon-tp-from-kv-store", log.Fields{"err": err, "tpID": tpID})
+		return nil, err
+	}
+	if err = t.eponTechnology().ValidateTechProfile(lEponTp); err != nil {
+		logger.Errorw(ctx, "invalid-epon-tp-in-kv-store", log.Fields{"err": err, "tpID": tpID})
+		return lEponTp, err
+	}
+	logger.Debugw(ctx, "success-fetching-epon-tp-from-kv-store", log.Fields{"tpID": tpID})
+	return lEponTp, nil
 }
 
 func newKVClient(ctx context.Context, storeType string, address string, timeout time.Duration) (kvstore.Client, error) {
@@ -1250,14 +1188,24 @@ func newKVClient(ctx context.Context, storeType string, address string, timeout
 	return nil, errors.New("unsupported-kv-store")
 }
 
-// buildTpInstanceFromResourceInstance for GPON, XGPON and XGS-PON technology - build TpInstance from TechProfile template and ResourceInstance
+// buildTpInstanceFromResourceInstance builds the TpInstance of the ResourceInstance as the technology does
 func (t *TechProfileMgr) buildTpInstanceFromResourceInstance(ctx context.Context, tp *tp_pb.TechProfile, resInst *tp_pb.ResourceInstance) *tp_pb.TechProfileInstance {
+	return t.gponTechnology().BuildTpInstance(ctx, tp, resInst)
+}
+
+// buildGponTpInstance for GPON, XGPON and XGS-PON technology - build TpInstance from TechProfile template and ResourceInstance
+func buildGponTpInstance(ctx context.Context, tp *tp_pb.TechProfile, resInst *tp_pb.ResourceInstance) *tp_pb.TechProfileInstance {
 
 	if len(resInst.GemportIds) != int(tp.NumGemPorts) {
 		logger.Errorw(ctx, "mismatch-in-number-of-gemports-between-template-and-resource-instance",
 			log.Fields{"tpID": resInst.TpId, "totalResInstGemPortIDs": len(resInst.GemportIds), "totalTpTemplateGemPorts": tp.NumGemPorts})
 		return nil
 	}
+	// an invalid template may still be used for the instances built from it, when it can be
+	if tp.UsScheduler == nil || tp.DsScheduler == nil || len(tp.UpstreamGemPortAttributeList) < int(tp.NumGemPorts) {
+		logger.Errorw(ctx, "tp-template-cannot-build-tp-instance", log.Fields{"tpID": resInst.TpId})
+		return nil
+	}
 
 	usGemPortAttributeList := make([]*tp_pb.GemPortAttributes, 0, tp.NumGemPorts)
 	dsGemPortAttributeList := make([]*tp_pb.GemPortAttributes, 0, tp.NumGemPorts)
@@ -1267,9 +1215,9 @@ func (t *TechProfileMgr) buildTpInstanceFromResourceInstance(ctx context.Context
 	logger.Debugw(ctx, "Building TP Instance",
 		log.Fields{"tpID": resInst.TpId, "totalResInstGemPortIDs": len(resInst.GemportIds), "totalTpTemplateGemPorts": tp.NumGemPorts})
 
-	usGemPortAttributeList = t.buildUpstreamGemPortAttributes(ctx, tp, resInst, usGemPortAttributeList)
-	dsUnicastGemAttributeList, dsMulticastGemAttributeList = t.separateDownstreamGemPortAttributes(ctx, tp, dsUnicastGemAttributeList, dsMulticastGemAttributeList)
-	dsGemPortAttributeList = t.buildDownstreamGemPortAttributes(ctx, tp, resInst, dsUnicastGemAttributeList, dsMulticastGemAttributeList, dsGemPortAttributeList)
+	usGemPortAttributeList = buildUpstreamGemPortAttributes(ctx, tp, resInst, usGemPortAttributeList)
+	dsUnicastGemAttributeList, dsMulticastGemAttributeList = separateDownstreamGemPortAttributes(ctx, tp, dsUnicastGemAttributeList, dsMulticastGemAttributeList)
+	dsGemPortAttributeList = buildDownstreamGemPortAttributes(ctx, tp, resInst, dsUnicastGemAttributeList, dsMulticastGemAttributeList, dsGemPortAttributeList)
 
 	return &tp_pb.TechProfileInstance{
 		SubscriberIdentifier: resInst.SubscriberIdentifier,
@@ -1296,8 +1244,13 @@ func (t *TechProfileMgr) buildTpInstanceFromResourceInstance(ctx context.Context
 		DownstreamGemPortAttributeList: dsGemPortAttributeList}
 }
 
-// buildEponTpInstanceFromResourceInstance for EPON technology - build EponTpInstance from EponTechProfile template and ResourceInstance
+// buildEponTpInstanceFromResourceInstance builds the EponTpInstance of the ResourceInstance as the technology does
 func (t *TechProfileMgr) buildEponTpInstanceFromResourceInstance(ctx context.Context, tp *tp_pb.EponTechProfile, resInst *tp_pb.ResourceInstance) *tp_pb.EponTechProfileInstance {
+	return t.eponTechnology().BuildTpInstance(ctx, tp, resInst)
+}
+
+// buildEponTpInstance for EPON technology - build EponTpInstance from EponTechProfile template and ResourceInstance
+func buildEponTpInstance(ctx context.Context, tp *tp_pb.EponTechProfile, resInst *tp_pb.ResourceInstance) *tp_pb.EponTechProfileInstance {
 
 	var usQueueAttributeList []*tp_pb.EPONQueueAttributes
 	var dsQueueAttributeList []*tp_pb.EPONQueueAttributes
@@ -1307,6 +1260,10 @@ func (t *TechProfileMgr) buildEponTpInstanceFromResourceInstance(ctx context.Con
 			log.Fields{"tpID": resInst.TpId, "totalResInstGemPortIDs": len(resInst.GemportIds), "totalTpTemplateGemPorts": tp.NumGemPorts})
 		return nil
 	}
+	if len(tp.UpstreamQueueAttributeList) < int(tp.NumGemPorts) || len(tp.DownstreamQueueAttributeList) < int(tp.NumGemPorts) {
+		logger.Errorw(ctx, "epon-tp-template-cannot-build-tp-instance", log.Fields{"tpID": resInst.TpId})
+		return nil
+	}
 
 	for index := 0; index < int(tp.NumGemPorts); index++ {
 		usQueueAttributeList = append(usQueueAttributeList,
@@ -1359,8 +1316,13 @@ func (t *TechProfileMgr) getTpInstanceFromResourceInstance(ctx context.Context,
 		logger.Error(ctx, "resource-instance-nil")
 		return nil
 	}
-	tp := t.getTPFromKVStore(ctx, resInst.TpId)
-	if tp == nil {
+	// the instance was built from the stored TP, it is rebuilt from it even if it no longer passes validation
+	tp, err := t.getTPFromKVStore(ctx, resInst.TpId)
+	if isValidationError(err) {
+		logger.Warnw(ctx, "invalid-tp-on-kv--rebuilding-tp-instance-from-it", log.Fields{"tpID": resInst.TpId, "err": err})
+	} else if err != nil {
+		return nil
+	} else if tp == nil {
 		logger.Warnw(ctx, "tp-not-found-on-kv--creating-default-tp", log.Fields{"tpID": resInst.TpId})
 		tp = t.getDefaultTechProfile(ctx)
 	}
@@ -1372,8 +1334,12 @@ func (t *TechProfileMgr) getEponTpInstanceFromResourceInstance(ctx context.Conte
 		logger.Error(ctx, "resource-instance-nil")
 		return nil
 	}
-	eponTp := t.getEponTPFromKVStore(ctx, resInst.TpId)
-	if eponTp == nil {
+	eponTp, err := t.getEponTPFromKVStore(ctx, resInst.TpId)
+	if isValidationError(err) {
+		logger.Warnw(ctx, "invalid-epon-tp-on-kv--rebuilding-tp-instance-from-it", log.Fields{"tpID": resInst.TpId, "err": err})
+	} else if err != nil {
+		return nil
+	} else if eponTp == nil {
 		logger.Warnw(ctx, "tp-not-found-on-kv--creating-default-tp", log.Fields{"tpID": resInst.TpId})
 		eponTp = t.getDefaultEponProfile(ctx)
 	}
@@ -1429,7 +1395,7 @@ func (t *TechProfileMgr) reconcileTpInstancesToCache(ctx context.Context, IntfId
 						logger.Errorw(ctx, "error-unmarshal-kv-pair", log.Fields{"err": err, "keyPath": keyPath, "value": value})
 						continue
 					} else {
-						if tech == xgspon || tech == xgpon || tech == gpon {
+						if t.isGpon() {
 							if tpInst := t.getTpInstanceFromResourceInstance(ctx, &resInst); tpInst != nil {
 								keySuffixSlice := regexp.MustCompile(t.config.ResourceInstanceKVPathPrefix+"/").Split(keyPath, 2)
 								if len(keySuffixSlice) == 2 {
@@ -1447,7 +1413,7 @@ func (t *TechProfileMgr) reconcileTpInstancesToCache(ctx context.Context, IntfId
 								t.tpInstanceMapLock.Unlock()
 								logger.Infow(ctx, "reconciled-tp-success", log.Fields{"keyPath": keyPath})
 							}
-						} else if tech == epon {
+						} else if t.isEpon() {
 							if eponTpInst := t.getEponTpInstanceFromResourceInstance(ctx, &resInst); eponTpInst != nil {
 								keySuffixSlice := regexp.MustCompile(t.config.ResourceInstanceKVPathPrefix+"/").Split(keyPath, 2)
 								if len(keySuffixSlice) == 2 {
@@ -1479,7 +1445,7 @@ func (t *TechProfileMgr) reconcileTpInstancesToCache(ctx context.Context, IntfId
 	return nil
 }
 
-func (t *TechProfileMgr) buildUpstreamGemPortAttributes(ctx context.Context, tp *tp_pb.TechProfile, resInst *tp_pb.ResourceInstance, usGemPortAttributeList []*tp_pb.GemPortAttributes) []*tp_pb.GemPortAttributes {
+func buildUpstreamGemPortAttributes(ctx context.Context, tp *tp_pb.TechProfile, resInst *tp_pb.ResourceInstance, usGemPortAttributeList []*tp_pb.GemPortAttributes) []*tp_pb.GemPortAttributes {
 	for index := 0; index < int(tp.NumGemPorts); index++ {
 		usGemPortAttributeList = append(usGemPortAttributeList, &tp_pb.GemPortAttributes{
 			GemportId:        resInst.GemportIds[index],
@@ -1497,7 +1463,7 @@ func (t *TechProfileMgr) buildUpstreamGemPortAttributes(ctx context.Context, tp
 	return usGemPortAttributeList
 }
 
-func (t *TechProfileMgr) separateDownstreamGemPortAttributes(ctx context.Context, tp *tp_pb.TechProfile, dsUnicastGemAttributeList, dsMulticastGemAttributeList []*tp_pb.GemPortAttributes) ([]*tp_pb.GemPortAttributes, []*tp_pb.GemPortAttributes) {
+func separateDownstreamGemPortAttributes(ctx context.Context, tp *tp_pb.TechProfile, dsUnicastGemAttributeList, dsMulticastGemAttributeList []*tp_pb.GemPortAttributes) ([]*tp_pb.GemPortAttributes, []*tp_pb.GemPortAttributes) {
 	for _, attr := range tp.DownstreamGemPortAttributeList {
 		if isMulticastGem(attr.IsMulticast) {
 			dsMulticastGemAttributeList = append(dsMulticastGemAttributeList, &tp_pb.GemPortAttributes{
@@ -1532,7 +1498,7 @@ func (t *TechProfileMgr) separateDownstreamGemPortAttributes(ctx context.Context
 	return dsUnicastGemAttributeList, dsMulticastGemAttributeList
 }
 
-func (t *TechProfileMgr) buildDownstreamGemPortAttributes(ctx context.Context, tp *tp_pb.TechProfile, resInst *tp_pb.ResourceInstance, dsUnicastGemAttributeList, dsMulticastGemAttributeList, dsGemPortAttributeList []*tp_pb.GemPortAttributes) []*tp_pb.GemPortAttributes {
+func buildDownstreamGemPortAttributes(ctx context.Context, tp *tp_pb.TechProfile, resInst *tp_pb.ResourceInstance, dsUnicastGemAttributeList, dsMulticastGemAttributeList, dsGemPortAttributeList []*tp_pb.GemPortAttributes) []*tp_pb.GemPortAttributes {
 	for index := 0; index < int(tp.NumGemPorts) && index < len(dsUnicastGemAttributeList); index++ {
 		dsGemPortAttributeList = append(dsGemPortAttributeList, &tp_pb.GemPortAttributes{
 			GemportId:        resInst.GemportIds[index],
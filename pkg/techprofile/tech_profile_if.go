@@ -40,3 +40,21 @@ type TechProfileIf interface {
 	GetResourceID(ctx context.Context, IntfID uint32, ResourceType string, NumIDs uint32) ([]uint32, error)
 	FreeResourceID(ctx context.Context, IntfID uint32, ResourceType string, ReleaseContent []uint32) error
 }
+
+// GponTechProfileIf is the typed tech profile API for GPON, XGPON and XGS-PON, see TechProfileMgr.Gpon
+type GponTechProfileIf interface {
+	GetTechProfile(ctx context.Context, tpID uint32) (*tp_pb.TechProfile, error)
+	GetTPInstance(ctx context.Context, path string) (*tp_pb.TechProfileInstance, error)
+	CreateTechProfileInstance(ctx context.Context, tpID uint32, uniPortName string, intfID uint32) (*tp_pb.TechProfileInstance, error)
+	GetGemportForPbit(ctx context.Context, tpInst *tp_pb.TechProfileInstance, Dir tp_pb.Direction, pbit uint32) (*tp_pb.GemPortAttributes, error)
+	FindAllTpInstances(ctx context.Context, oltDeviceID string, tpID uint32, ponIntf uint32, onuID uint32) []*tp_pb.TechProfileInstance
+}
+
+// EponTechProfileIf is the typed tech profile API for EPON, see TechProfileMgr.Epon
+type EponTechProfileIf interface {
+	GetTechProfile(ctx context.Context, tpID uint32) (*tp_pb.EponTechProfile, error)
+	GetTPInstance(ctx context.Context, path string) (*tp_pb.EponTechProfileInstance, error)
+	CreateTechProfileInstance(ctx context.Context, tpID uint32, uniPortName string, intfID uint32) (*tp_pb.EponTechProfileInstance, error)
+	GetQueueForPbit(ctx context.Context, tpInst *tp_pb.EponTechProfileInstance, Dir tp_pb.Direction, pbit uint32) (*tp_pb.EPONQueueAttributes, error)
+	FindAllTpInstances(ctx context.Context, oltDeviceID string, tpID uint32, ponIntf uint32, onuID uint32) []*tp_pb.EponTechProfileInstance
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package techprofile
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/db"
+	mock_kvstore "github.com/opencord/voltha-lib-go/v7/pkg/mocks/kvstore"
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const testUniPortName = "olt-{olt1}/pon-{0}/onu-{1}/uni-{0}"
+
+// fakeResourceMgr hands out increasing IDs for every resource type and records the freed ones
+type fakeResourceMgr struct {
+	lock       sync.Mutex
+	technology string
+	nextID     uint32
+	freed      map[string][]uint32
+	// called before the IDs are handed out, if set
+	onGetResourceID func()
+}
+
+func (r *fakeResourceMgr) GetResourceID(ctx context.Context, intfID uint32, resourceType string, numIDs uint32) ([]uint32, error) {
+	if r.onGetResourceID != nil {
+		r.onGetResourceID()
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var ids []uint32
+	for i := uint32(0); i < numIDs; i++ {
+		r.nextID++
+		ids = append(ids, 1024+r.nextID)
+	}
+	return ids, nil
+}
+
+func (r *fakeResourceMgr) FreeResourceID(ctx context.Context, intfID uint32, resourceType string, ReleaseContent []uint32) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.freed == nil {
+		r.freed = make(map[string][]uint32)
+	}
+	r.freed[resourceType] = append(r.freed[resourceType], ReleaseContent...)
+	return nil
+}
+
+func (r *fakeResourceMgr) GetResourceTypeAllocID() string {
+	return "ALLOC_ID"
+}
+
+func (r *fakeResourceMgr) GetResourceTypeGemPortID() string {
+	return "GEMPORT_ID"
+}
+
+func (r *fakeResourceMgr) GetResourceTypeOnuID() string {
+	return "ONU_ID"
+}
+
+func (r *fakeResourceMgr) GetTechnology() string {
+	return r.technology
+}
+
+// newTestTechProfileMgr returns a manager whose KV stores are backed by in-memory clients, along
+// with the backend the TP templates are read from
+func newTestTechProfileMgr(t *testing.T, ctx context.Context, technology string) (*TechProfileMgr, *db.Backend) {
+	tpDefault := &db.Backend{Client: mock_kvstore.NewKVClient(), PathPrefix: defaultTpKvPathPrefix}
+	tpMgr, err := NewTechProfile(ctx, 0, "olt1", &fakeResourceMgr{technology: technology}, "etcd", "1:1", "service/voltha",
+		tpDefault,
+		&db.Backend{Client: mock_kvstore.NewKVClient(), PathPrefix: "service/voltha/technology_profiles"},
+		&db.Backend{Client: mock_kvstore.NewKVClient(), PathPrefix: "service/voltha/resource_instances"})
+	assert.Nil(t, err)
+	return tpMgr, tpDefault
+}
+
+// loadSampleProfile reads one of the sample TP files, ignoring their copyright notice
+func loadSampleProfile(t *testing.T, file string, tp proto.Message) {
+	value, err := os.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Nil(t, protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(value, tp))
+}
+
+func putTechProfile(t *testing.T, ctx context.Context, tpDefault *db.Backend, technology string, tpID uint32, tp *tp_pb.TechProfile) {
+	value, err := protojson.Marshal(tp)
+	assert.Nil(t, err)
+	assert.Nil(t, tpDefault.Put(ctx, fmt.Sprintf(defaultTechProfileKVPath, technology, tpID), value))
+}
+
+func TestCreateTechProfileInstanceStrictValidation(t *testing.T) {
+	ctx := context.Background()
+	tpMgr, tpDefault := newTestTechProfileMgr(t, ctx, xgspon)
+
+	tp := &tp_pb.TechProfile{}
+	loadSampleProfile(t, "4QueueHybridProfileMap1.json", tp)
+	putTechProfile(t, ctx, tpDefault, xgspon, 64, tp)
+	tp.UpstreamGemPortAttributeList[1].PbitMap = "0b00000001"
+	putTechProfile(t, ctx, tpDefault, xgspon, 65, tp)
+	putTechProfile(t, ctx, tpDefault, xgspon, 68, tp)
+
+	// a valid TP is used as is
+	tpInst, err := tpMgr.CreateTechProfileInstance(ctx, 64, testUniPortName, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "4QueueHybridProfileMap1", tpInst.(*tp_pb.TechProfileInstance).Name)
+	assert.Len(t, tpInst.(*tp_pb.TechProfileInstance).UpstreamGemPortAttributeList, 4)
+
+	// an invalid or missing TP falls back to the default one unless validation is strict
+	tpInst, err = tpMgr.CreateTechProfileInstance(ctx, 65, testUniPortName, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, defaultTechProfileName, tpInst.(*tp_pb.TechProfileInstance).Name)
+	assert.Nil(t, tpMgr.DeleteTechProfileInstance(ctx, 65, testUniPortName))
+	tpInst, err = tpMgr.CreateTechProfileInstance(ctx, 66, testUniPortName, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, defaultTechProfileName, tpInst.(*tp_pb.TechProfileInstance).Name)
+	assert.Nil(t, tpMgr.DeleteTechProfileInstance(ctx, 66, testUniPortName))
+
+	// the TP falling back to the default one is cached, other TP IDs are used
+	tpMgr.SetStrictValidation(true)
+	_, err = tpMgr.CreateTechProfileInstance(ctx, 68, testUniPortName, 0)
+	assert.True(t, isValidationError(err))
+	_, err = tpMgr.CreateTechProfileInstance(ctx, 67, testUniPortName, 0)
+	assert.NotNil(t, err)
+}
+
+func TestReconcileInvalidTechProfile(t *testing.T) {
+	ctx := context.Background()
+	tpMgr, tpDefault := newTestTechProfileMgr(t, ctx, xgspon)
+
+	tp := &tp_pb.TechProfile{}
+	loadSampleProfile(t, "4QueueHybridProfileMap1.json", tp)
+	putTechProfile(t, ctx, tpDefault, xgspon, 64, tp)
+	tpInst, err := tpMgr.CreateTechProfileInstance(ctx, 64, testUniPortName, 0)
+	assert.Nil(t, err)
+
+	// the TP is made invalid after the instance was created
+	tp.UpstreamGemPortAttributeList[1].PbitMap = "0b00000001"
+	putTechProfile(t, ctx, tpDefault, xgspon, 64, tp)
+	tpMgr.tpMapLock.Lock()
+	delete(tpMgr.tpMap, 64)
+	tpMgr.tpMapLock.Unlock()
+
+	for _, strict := range []bool{false, true} {
+		tpMgr.SetStrictValidation(strict)
+		tpMgr.tpInstanceMapLock.Lock()
+		tpMgr.tpInstanceMap = make(map[string]*tp_pb.TechProfileInstance)
+		tpMgr.tpInstanceMapLock.Unlock()
+
+		assert.Nil(t, tpMgr.reconcileTpInstancesToCache(ctx, 0, "olt1"))
+		reconciled, err := tpMgr.GetTPInstance(ctx, tpMgr.GetTechProfileInstanceKey(ctx, 64, testUniPortName))
+		assert.Nil(t, err, "strict %v", strict)
+		if err == nil {
+			assert.Equal(t, tpInst.(*tp_pb.TechProfileInstance).UsScheduler.AllocId, reconciled.(*tp_pb.TechProfileInstance).UsScheduler.AllocId)
+			assert.Equal(t, "0b00000001", reconciled.(*tp_pb.TechProfileInstance).UpstreamGemPortAttributeList[1].PbitMap)
+		}
+	}
+}
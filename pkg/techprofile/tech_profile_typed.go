@@ -0,0 +1,157 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package techprofile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+)
+
+// ErrTpNotFound is returned when the KV store has no definition for a TP
+var ErrTpNotFound = errors.New("tp-not-found")
+
+// ErrWrongTechnology is returned when the typed API of one technology family is asked for on a
+// manager of the other one
+var ErrWrongTechnology = errors.New("wrong-technology")
+
+var _ GponTechProfileIf = &GponTechProfileMgr{}
+var _ EponTechProfileIf = &EponTechProfileMgr{}
+
+// GponTechProfileMgr is the typed API of a TechProfileMgr for the GPON family of technologies
+type GponTechProfileMgr struct {
+	mgr *TechProfileMgr
+}
+
+// EponTechProfileMgr is the typed API of a TechProfileMgr for the EPON family of technologies
+type EponTechProfileMgr struct {
+	mgr *TechProfileMgr
+}
+
+// Gpon returns the typed API of the manager, it fails with ErrWrongTechnology for the EPON family
+func (t *TechProfileMgr) Gpon() (*GponTechProfileMgr, error) {
+	if !t.isGpon() {
+		return nil, fmt.Errorf("%w-%s-is-not-gpon", ErrWrongTechnology, t.resourceMgr.GetTechnology())
+	}
+	return &GponTechProfileMgr{mgr: t}, nil
+}
+
+// Epon returns the typed API of the manager, it fails with ErrWrongTechnology for the GPON family
+func (t *TechProfileMgr) Epon() (*EponTechProfileMgr, error) {
+	if !t.isEpon() {
+		return nil, fmt.Errorf("%w-%s-is-not-epon", ErrWrongTechnology, t.resourceMgr.GetTechnology())
+	}
+	return &EponTechProfileMgr{mgr: t}, nil
+}
+
+// GetTechProfile returns the validated TP definition, ErrTpNotFound if the KV store has none
+func (g *GponTechProfileMgr) GetTechProfile(ctx context.Context, tpID uint32) (*tp_pb.TechProfile, error) {
+	tp, err := g.mgr.loadTechProfile(ctx, tpID)
+	if err != nil {
+		return nil, err
+	}
+	return tp, nil
+}
+
+// GetTPInstance returns the cached TP instance of the path built by GetTechProfileInstanceKey
+func (g *GponTechProfileMgr) GetTPInstance(ctx context.Context, path string) (*tp_pb.TechProfileInstance, error) {
+	g.mgr.tpInstanceMapLock.RLock()
+	defer g.mgr.tpInstanceMapLock.RUnlock()
+	tpInst, ok := g.mgr.tpInstanceMap[path]
+	if !ok {
+		return nil, fmt.Errorf("tp-instance-not-found-tp-path-%v", path)
+	}
+	return tpInst, nil
+}
+
+// CreateTechProfileInstance creates a new TP instance, see TechProfileMgr.CreateTechProfileInstance
+func (g *GponTechProfileMgr) CreateTechProfileInstance(ctx context.Context, tpID uint32, uniPortName string, intfID uint32) (*tp_pb.TechProfileInstance, error) {
+	tpInst, err := g.mgr.CreateTechProfileInstance(ctx, tpID, uniPortName, intfID)
+	if err != nil {
+		return nil, err
+	}
+	return tpInst.(*tp_pb.TechProfileInstance), nil
+}
+
+// GetGemportForPbit returns the attributes of the GEM port the pbit is mapped to in the direction
+func (g *GponTechProfileMgr) GetGemportForPbit(ctx context.Context, tpInst *tp_pb.TechProfileInstance, dir tp_pb.Direction, pbit uint32) (*tp_pb.GemPortAttributes, error) {
+	if tpInst == nil {
+		return nil, errors.New("tp-instance-nil")
+	}
+	if pbit >= numPbits {
+		return nil, fmt.Errorf("invalid-pbit-%d", pbit)
+	}
+	if gem, ok := g.mgr.GetGemportForPbit(ctx, tpInst, dir, pbit).(*tp_pb.GemPortAttributes); ok && gem != nil {
+		return gem, nil
+	}
+	return nil, fmt.Errorf("no-gem-port-for-pbit-%d-direction-%s-tp-%s", pbit, dir, tpInst.SubscriberIdentifier)
+}
+
+// FindAllTpInstances returns the TP instances of the TP on all the UNIs of the ONU
+func (g *GponTechProfileMgr) FindAllTpInstances(ctx context.Context, oltDeviceID string, tpID uint32, intfID uint32, onuID uint32) []*tp_pb.TechProfileInstance {
+	return g.mgr.FindAllTpInstances(ctx, oltDeviceID, tpID, intfID, onuID).([]*tp_pb.TechProfileInstance)
+}
+
+// GetTechProfile returns the validated TP definition, ErrTpNotFound if the KV store has none
+func (e *EponTechProfileMgr) GetTechProfile(ctx context.Context, tpID uint32) (*tp_pb.EponTechProfile, error) {
+	eponTp, err := e.mgr.loadEponTechProfile(ctx, tpID)
+	if err != nil {
+		return nil, err
+	}
+	return eponTp, nil
+}
+
+// GetTPInstance returns the cached TP instance of the path built by GetTechProfileInstanceKey
+func (e *EponTechProfileMgr) GetTPInstance(ctx context.Context, path string) (*tp_pb.EponTechProfileInstance, error) {
+	e.mgr.epontpInstanceMapLock.RLock()
+	defer e.mgr.epontpInstanceMapLock.RUnlock()
+	tpInst, ok := e.mgr.eponTpInstanceMap[path]
+	if !ok {
+		return nil, fmt.Errorf("tp-instance-not-found-tp-path-%v", path)
+	}
+	return tpInst, nil
+}
+
+// CreateTechProfileInstance creates a new TP instance, see TechProfileMgr.CreateTechProfileInstance
+func (e *EponTechProfileMgr) CreateTechProfileInstance(ctx context.Context, tpID uint32, uniPortName string, intfID uint32) (*tp_pb.EponTechProfileInstance, error) {
+	tpInst, err := e.mgr.CreateTechProfileInstance(ctx, tpID, uniPortName, intfID)
+	if err != nil {
+		return nil, err
+	}
+	return tpInst.(*tp_pb.EponTechProfileInstance), nil
+}
+
+// GetQueueForPbit returns the attributes of the queue the pbit is mapped to in the direction
+func (e *EponTechProfileMgr) GetQueueForPbit(ctx context.Context, tpInst *tp_pb.EponTechProfileInstance, dir tp_pb.Direction, pbit uint32) (*tp_pb.EPONQueueAttributes, error) {
+	if tpInst == nil {
+		return nil, errors.New("tp-instance-nil")
+	}
+	if pbit >= numPbits {
+		return nil, fmt.Errorf("invalid-pbit-%d", pbit)
+	}
+	if queue, ok := e.mgr.GetGemportForPbit(ctx, tpInst, dir, pbit).(*tp_pb.EPONQueueAttributes); ok && queue != nil {
+		return queue, nil
+	}
+	return nil, fmt.Errorf("no-queue-for-pbit-%d-direction-%s-tp-%s", pbit, dir, tpInst.SubscriberIdentifier)
+}
+
+// FindAllTpInstances returns the TP instances of the TP on all the UNIs of the ONU
+func (e *EponTechProfileMgr) FindAllTpInstances(ctx context.Context, oltDeviceID string, tpID uint32, intfID uint32, onuID uint32) []*tp_pb.EponTechProfileInstance {
+	return e.mgr.FindAllTpInstances(ctx, oltDeviceID, tpID, intfID, onuID).([]*tp_pb.EponTechProfileInstance)
+}
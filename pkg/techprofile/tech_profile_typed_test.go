@@ -0,0 +1,102 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package techprofile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestGponTechProfileMgr(t *testing.T) {
+	ctx := context.Background()
+	tpMgr, tpDefault := newTestTechProfileMgr(t, ctx, xgspon)
+	_, err := tpMgr.Epon()
+	assert.True(t, errors.Is(err, ErrWrongTechnology))
+	gponMgr, err := tpMgr.Gpon()
+	assert.Nil(t, err)
+
+	_, err = gponMgr.GetTechProfile(ctx, 64)
+	assert.True(t, errors.Is(err, ErrTpNotFound))
+	tp := &tp_pb.TechProfile{}
+	loadSampleProfile(t, "4QueueHybridProfileMap1.json", tp)
+	tp.NumGemPorts = 5
+	putTechProfile(t, ctx, tpDefault, xgspon, 64, tp)
+	_, err = gponMgr.GetTechProfile(ctx, 64)
+	assert.ElementsMatch(t, []string{"upstream_gem_port_attribute_list", "downstream_gem_port_attribute_list"}, invalidFields(t, err))
+	tp.NumGemPorts = 4
+	putTechProfile(t, ctx, tpDefault, xgspon, 64, tp)
+	loaded, err := gponMgr.GetTechProfile(ctx, 64)
+	assert.Nil(t, err)
+	assert.Equal(t, "4QueueHybridProfileMap1", loaded.Name)
+
+	tpInst, err := gponMgr.CreateTechProfileInstance(ctx, 64, testUniPortName, 0)
+	assert.Nil(t, err)
+	cached, err := gponMgr.GetTPInstance(ctx, tpMgr.GetTechProfileInstanceKey(ctx, 64, testUniPortName))
+	assert.Nil(t, err)
+	assert.Same(t, tpInst, cached)
+	_, err = gponMgr.GetTPInstance(ctx, tpMgr.GetTechProfileInstanceKey(ctx, 65, testUniPortName))
+	assert.NotNil(t, err)
+
+	gem, err := gponMgr.GetGemportForPbit(ctx, tpInst, tp_pb.Direction_DOWNSTREAM, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, tpInst.DownstreamGemPortAttributeList[2], gem)
+	_, err = gponMgr.GetGemportForPbit(ctx, tpInst, tp_pb.Direction_UPSTREAM, 8)
+	assert.NotNil(t, err)
+	_, err = gponMgr.GetGemportForPbit(ctx, nil, tp_pb.Direction_UPSTREAM, 0)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, []*tp_pb.TechProfileInstance{tpInst}, gponMgr.FindAllTpInstances(ctx, "olt1", 64, 0, 1))
+	assert.Empty(t, gponMgr.FindAllTpInstances(ctx, "olt1", 64, 0, 2))
+}
+
+func TestEponTechProfileMgr(t *testing.T) {
+	ctx := context.Background()
+	tpMgr, tpDefault := newTestTechProfileMgr(t, ctx, epon)
+	_, err := tpMgr.Gpon()
+	assert.True(t, errors.Is(err, ErrWrongTechnology))
+	eponMgr, err := tpMgr.Epon()
+	assert.Nil(t, err)
+
+	_, err = eponMgr.GetTechProfile(ctx, 64)
+	assert.True(t, errors.Is(err, ErrTpNotFound))
+	tp := &tp_pb.EponTechProfile{}
+	loadSampleProfile(t, "SingleQueueEponProfile.json", tp)
+	value, err := protojson.Marshal(tp)
+	assert.Nil(t, err)
+	assert.Nil(t, tpDefault.Put(ctx, fmt.Sprintf(defaultTechProfileKVPath, epon, 64), value))
+
+	tpInst, err := eponMgr.CreateTechProfileInstance(ctx, 64, testUniPortName, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "SingleQueueEponProfile", tpInst.Name)
+	cached, err := eponMgr.GetTPInstance(ctx, tpMgr.GetTechProfileInstanceKey(ctx, 64, testUniPortName))
+	assert.Nil(t, err)
+	assert.Same(t, tpInst, cached)
+
+	queue, err := eponMgr.GetQueueForPbit(ctx, tpInst, tp_pb.Direction_UPSTREAM, 7)
+	assert.Nil(t, err)
+	assert.Equal(t, tpInst.UpstreamQueueAttributeList[0], queue)
+	_, err = eponMgr.GetQueueForPbit(ctx, tpInst, tp_pb.Direction_UPSTREAM, 8)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, []*tp_pb.EponTechProfileInstance{tpInst}, eponMgr.FindAllTpInstances(ctx, "olt1", 64, 0, 1))
+}
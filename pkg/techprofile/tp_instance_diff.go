@@ -0,0 +1,249 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package techprofile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/log"
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"google.golang.org/protobuf/proto"
+)
+
+// GemPortChange is a GEM port kept by a migration whose queue attributes differ in one direction
+type GemPortChange struct {
+	GemportID uint32
+	Direction tp_pb.Direction
+	Old       *tp_pb.GemPortAttributes
+	New       *tp_pb.GemPortAttributes
+}
+
+// SchedulerChange is a scheduler whose attributes differ between the instance and the TP
+type SchedulerChange struct {
+	Old *tp_pb.SchedulerAttributes
+	New *tp_pb.SchedulerAttributes
+}
+
+// TpInstanceDiff is the difference between an existing TP instance and the instance the current
+// TP definition would produce for the same UNI. GEM ports are matched by their position in the
+// upstream GEM port list, the alloc ID and the GEM port IDs of the kept positions are preserved.
+type TpInstanceDiff struct {
+	TpID        uint32
+	UniPortName string
+	// AddedGemPorts are the upstream attributes of the GEM ports to allocate, their GemportId is 0
+	AddedGemPorts []*tp_pb.GemPortAttributes
+	// RemovedGemPorts are the IDs of the GEM ports to free
+	RemovedGemPorts []uint32
+	ChangedQueues   []GemPortChange
+	// UsScheduler and DsScheduler are nil if the scheduler did not change
+	UsScheduler *SchedulerChange
+	DsScheduler *SchedulerChange
+	// MulticastChanged is set if the multicast GEM ports of the downstream list differ
+	MulticastChanged bool
+	// ProfileChanged is set if the name, type, version or instance control of the TP differ
+	ProfileChanged bool
+
+	current *tp_pb.TechProfileInstance
+	tp      *tp_pb.TechProfile
+}
+
+// IsEmpty returns true if the instance already matches the TP
+func (d *TpInstanceDiff) IsEmpty() bool {
+	return len(d.AddedGemPorts) == 0 && len(d.RemovedGemPorts) == 0 && len(d.ChangedQueues) == 0 &&
+		d.UsScheduler == nil && d.DsScheduler == nil && !d.MulticastChanged && !d.ProfileChanged
+}
+
+// buildMigratedTpInstance builds the instance of the TP keeping the alloc ID of the current instance
+// and the GEM port IDs of the positions both have in common, followed by newGemPorts
+func (t *TechProfileMgr) buildMigratedTpInstance(ctx context.Context, current *tp_pb.TechProfileInstance, tp *tp_pb.TechProfile,
+	tpID uint32, newGemPorts []uint32) *tp_pb.TechProfileInstance {
+	var gemPorts []uint32
+	for index := 0; index < len(current.UpstreamGemPortAttributeList) && index < int(tp.NumGemPorts); index++ {
+		gemPorts = append(gemPorts, current.UpstreamGemPortAttributeList[index].GemportId)
+	}
+	gemPorts = append(gemPorts, newGemPorts...)
+	return t.buildTpInstanceFromResourceInstance(ctx, tp, &tp_pb.ResourceInstance{
+		TpId:                 tpID,
+		ProfileType:          tp.ProfileType,
+		SubscriberIdentifier: current.SubscriberIdentifier,
+		AllocId:              current.UsScheduler.AllocId,
+		GemportIds:           gemPorts,
+	})
+}
+
+// DiffTechProfileInstance compares the instance of the TP on the UNI with the instance the current TP
+// definition would produce. Only instances of the GPON family of technologies are supported.
+func (t *TechProfileMgr) DiffTechProfileInstance(ctx context.Context, tpID uint32, uniPortName string) (*TpInstanceDiff, error) {
+	if !t.isGpon() {
+		return nil, fmt.Errorf("tp-instance-diff-not-supported-for-tech-%s", t.resourceMgr.GetTechnology())
+	}
+	key := t.GetTechProfileInstanceKey(ctx, tpID, uniPortName)
+	t.tpInstanceMapLock.RLock()
+	current, ok := t.tpInstanceMap[key]
+	t.tpInstanceMapLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("tp-instance-not-found-tp-path-%v", key)
+	}
+	tp, err := t.loadTechProfile(ctx, tpID)
+	if err != nil {
+		return nil, err
+	}
+
+	numKept := len(current.UpstreamGemPortAttributeList)
+	if int(tp.NumGemPorts) < numKept {
+		numKept = int(tp.NumGemPorts)
+	}
+	newGemPorts := make([]uint32, int(tp.NumGemPorts)-numKept)
+	target := t.buildMigratedTpInstance(ctx, current, tp, tpID, newGemPorts)
+	if target == nil {
+		return nil, fmt.Errorf("failed-to-build-tp-instance-from-tp-%d", tpID)
+	}
+
+	diff := &TpInstanceDiff{TpID: tpID, UniPortName: uniPortName, current: current, tp: tp}
+	diff.ProfileChanged = current.Name != target.Name || current.ProfileType != target.ProfileType ||
+		current.Version != target.Version || !proto.Equal(current.InstanceControl, target.InstanceControl)
+	if !proto.Equal(current.UsScheduler, target.UsScheduler) {
+		diff.UsScheduler = &SchedulerChange{Old: current.UsScheduler, New: target.UsScheduler}
+	}
+	if !proto.Equal(current.DsScheduler, target.DsScheduler) {
+		diff.DsScheduler = &SchedulerChange{Old: current.DsScheduler, New: target.DsScheduler}
+	}
+
+	// the unicast GEM ports come first in the downstream list, the multicast ones after them
+	for index := 0; index < numKept; index++ {
+		oldUs, newUs := current.UpstreamGemPortAttributeList[index], target.UpstreamGemPortAttributeList[index]
+		if !proto.Equal(oldUs, newUs) {
+			diff.ChangedQueues = append(diff.ChangedQueues,
+				GemPortChange{GemportID: oldUs.GemportId, Direction: tp_pb.Direction_UPSTREAM, Old: oldUs, New: newUs})
+		}
+		if index < len(current.DownstreamGemPortAttributeList) && index < len(target.DownstreamGemPortAttributeList) {
+			oldDs, newDs := current.DownstreamGemPortAttributeList[index], target.DownstreamGemPortAttributeList[index]
+			if !proto.Equal(oldDs, newDs) {
+				diff.ChangedQueues = append(diff.ChangedQueues,
+					GemPortChange{GemportID: oldDs.GemportId, Direction: tp_pb.Direction_DOWNSTREAM, Old: oldDs, New: newDs})
+			}
+		}
+	}
+	diff.AddedGemPorts = target.UpstreamGemPortAttributeList[numKept:]
+	for _, gem := range current.UpstreamGemPortAttributeList[numKept:] {
+		diff.RemovedGemPorts = append(diff.RemovedGemPorts, gem.GemportId)
+	}
+
+	var oldMcast, newMcast []*tp_pb.GemPortAttributes
+	if int(current.NumGemPorts) <= len(current.DownstreamGemPortAttributeList) {
+		oldMcast = current.DownstreamGemPortAttributeList[current.NumGemPorts:]
+	}
+	if int(target.NumGemPorts) <= len(target.DownstreamGemPortAttributeList) {
+		newMcast = target.DownstreamGemPortAttributeList[target.NumGemPorts:]
+	}
+	if len(oldMcast) != len(newMcast) {
+		diff.MulticastChanged = true
+	} else {
+		for index := range oldMcast {
+			if !proto.Equal(oldMcast[index], newMcast[index]) {
+				diff.MulticastChanged = true
+			}
+		}
+	}
+
+	logger.Debugw(ctx, "computed-tp-instance-diff", log.Fields{"tpID": tpID, "uni": uniPortName,
+		"added-gem-ports": len(diff.AddedGemPorts), "removed-gem-ports": diff.RemovedGemPorts,
+		"changed-queues": len(diff.ChangedQueues), "profile-changed": diff.ProfileChanged})
+	return diff, nil
+}
+
+// ApplyTechProfileInstanceDiff migrates the TP instance to the TP definition the diff was computed
+// against. Only the GEM ports of the diff are allocated or freed, the alloc ID and the kept GEM
+// ports are left untouched. A change of the ONU instance control cannot be applied in place.
+func (t *TechProfileMgr) ApplyTechProfileInstanceDiff(ctx context.Context, diff *TpInstanceDiff, intfID uint32) (*tp_pb.TechProfileInstance, error) {
+	if diff == nil || diff.current == nil || diff.tp == nil {
+		return nil, errors.New("invalid-tp-instance-diff")
+	}
+	if diff.current.InstanceControl.GetOnu() != diff.tp.InstanceControl.GetOnu() {
+		return nil, fmt.Errorf("onu-instance-control-change-requires-recreating-tp-instance-%d-%s", diff.TpID, diff.UniPortName)
+	}
+	key := t.GetTechProfileInstanceKey(ctx, diff.TpID, diff.UniPortName)
+	t.tpInstanceMapLock.RLock()
+	current := t.tpInstanceMap[key]
+	t.tpInstanceMapLock.RUnlock()
+	if current != diff.current {
+		return nil, fmt.Errorf("tp-instance-changed-since-diff-tp-path-%v", key)
+	}
+	if diff.IsEmpty() {
+		return current, nil
+	}
+
+	var newGemPorts []uint32
+	if len(diff.AddedGemPorts) > 0 {
+		var err error
+		if newGemPorts, err = t.GetResourceID(ctx, intfID, t.resourceMgr.GetResourceTypeGemPortID(), uint32(len(diff.AddedGemPorts))); err != nil {
+			logger.Errorw(ctx, "failed-to-allocate-gem-ports-for-tp-instance-migration", log.Fields{"err": err, "tpID": diff.TpID, "uni": diff.UniPortName})
+			return nil, err
+		}
+	}
+	freeNewGemPorts := func() {
+		if len(newGemPorts) == 0 {
+			return
+		}
+		if err := t.FreeResourceID(ctx, intfID, t.resourceMgr.GetResourceTypeGemPortID(), newGemPorts); err != nil {
+			logger.Errorw(ctx, "failed-to-free-new-gem-ports", log.Fields{"err": err, "gem-ports": newGemPorts})
+		}
+	}
+	tpInstance := t.buildMigratedTpInstance(ctx, current, diff.tp, diff.TpID, newGemPorts)
+	if tpInstance == nil {
+		freeNewGemPorts()
+		return nil, fmt.Errorf("failed-to-build-tp-instance-from-tp-%d", diff.TpID)
+	}
+
+	resInst := tp_pb.ResourceInstance{
+		TpId:                 diff.TpID,
+		ProfileType:          tpInstance.ProfileType,
+		SubscriberIdentifier: tpInstance.SubscriberIdentifier,
+		AllocId:              tpInstance.UsScheduler.AllocId,
+	}
+	for _, usQAttr := range tpInstance.UpstreamGemPortAttributeList {
+		resInst.GemportIds = append(resInst.GemportIds, usQAttr.GemportId)
+	}
+	// the instance may have been migrated or deleted while the GEM ports were allocated, it is checked
+	// again and replaced along with its resource instance under the lock
+	t.tpInstanceMapLock.Lock()
+	if t.tpInstanceMap[key] != diff.current {
+		t.tpInstanceMapLock.Unlock()
+		freeNewGemPorts()
+		return nil, fmt.Errorf("tp-instance-changed-since-diff-tp-path-%v", key)
+	}
+	if err := t.addResourceInstanceToKVStore(ctx, diff.TpID, diff.UniPortName, &resInst); err != nil {
+		t.tpInstanceMapLock.Unlock()
+		logger.Errorw(ctx, "failed-to-update-resource-instance-to-kv-store--freeing-up-new-gem-ports", log.Fields{"err": err, "tpID": diff.TpID, "uni": diff.UniPortName})
+		freeNewGemPorts()
+		return nil, err
+	}
+	t.tpInstanceMap[key] = tpInstance
+	t.tpInstanceMapLock.Unlock()
+
+	// the migrated instance is in place, failing to free the removed GEM ports only leaks them
+	if len(diff.RemovedGemPorts) > 0 {
+		if err := t.FreeResourceID(ctx, intfID, t.resourceMgr.GetResourceTypeGemPortID(), diff.RemovedGemPorts); err != nil {
+			logger.Errorw(ctx, "failed-to-free-removed-gem-ports", log.Fields{"err": err, "gem-ports": diff.RemovedGemPorts})
+		}
+	}
+	logger.Infow(ctx, "tp-instance-migrated", log.Fields{"tpID": diff.TpID, "uni": diff.UniPortName,
+		"allocated-gem-ports": newGemPorts, "freed-gem-ports": diff.RemovedGemPorts})
+	return tpInstance, nil
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package techprofile
+
+import (
+	"context"
+	"testing"
+
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+// updateTechProfile stores the new definition of the TP and drops the cached one
+func updateTechProfile(t *testing.T, ctx context.Context, tpMgr *TechProfileMgr, tpID uint32, tp *tp_pb.TechProfile) {
+	putTechProfile(t, ctx, tpMgr.config.DefaultTpKVBackend, xgspon, tpID, tp)
+	tpMgr.tpMapLock.Lock()
+	delete(tpMgr.tpMap, tpID)
+	tpMgr.tpMapLock.Unlock()
+}
+
+func gemPortIDs(gems []*tp_pb.GemPortAttributes) []uint32 {
+	var ids []uint32
+	for _, gem := range gems {
+		ids = append(ids, gem.GemportId)
+	}
+	return ids
+}
+
+func TestTechProfileInstanceMigration(t *testing.T) {
+	ctx := context.Background()
+	tpMgr, tpDefault := newTestTechProfileMgr(t, ctx, xgspon)
+	resourceMgr := tpMgr.resourceMgr.(*fakeResourceMgr)
+	tp := &tp_pb.TechProfile{}
+	loadSampleProfile(t, "4QueueHybridProfileMap1.json", tp)
+	putTechProfile(t, ctx, tpDefault, xgspon, 64, tp)
+
+	inst, err := tpMgr.CreateTechProfileInstance(ctx, 64, testUniPortName, 0)
+	assert.Nil(t, err)
+	original := inst.(*tp_pb.TechProfileInstance)
+	allocID := original.UsScheduler.AllocId
+	gems := gemPortIDs(original.UpstreamGemPortAttributeList)
+
+	diff, err := tpMgr.DiffTechProfileInstance(ctx, 64, testUniPortName)
+	assert.Nil(t, err)
+	assert.True(t, diff.IsEmpty())
+	tpInst, err := tpMgr.ApplyTechProfileInstanceDiff(ctx, diff, 0)
+	assert.Nil(t, err)
+	assert.Same(t, original, tpInst)
+
+	// drop the last GEM port, move its pbits to the third one and change a weight and the upstream scheduler
+	tp.NumGemPorts = 3
+	tp.UpstreamGemPortAttributeList = tp.UpstreamGemPortAttributeList[:3]
+	tp.DownstreamGemPortAttributeList = tp.DownstreamGemPortAttributeList[:3]
+	tp.UpstreamGemPortAttributeList[2].PbitMap = "0b11100000"
+	tp.DownstreamGemPortAttributeList[2].PbitMap = "0b11100000"
+	tp.UpstreamGemPortAttributeList[0].Weight = 50
+	tp.UsScheduler.AdditionalBw = tp_pb.AdditionalBW_AdditionalBW_NA
+	updateTechProfile(t, ctx, tpMgr, 64, tp)
+
+	diff, err = tpMgr.DiffTechProfileInstance(ctx, 64, testUniPortName)
+	assert.Nil(t, err)
+	assert.False(t, diff.IsEmpty())
+	assert.False(t, diff.ProfileChanged)
+	assert.False(t, diff.MulticastChanged)
+	assert.Empty(t, diff.AddedGemPorts)
+	assert.Equal(t, []uint32{gems[3]}, diff.RemovedGemPorts)
+	assert.NotNil(t, diff.UsScheduler)
+	assert.Nil(t, diff.DsScheduler)
+	var changed []GemPortChange
+	for _, change := range diff.ChangedQueues {
+		changed = append(changed, GemPortChange{GemportID: change.GemportID, Direction: change.Direction})
+	}
+	assert.Equal(t, []GemPortChange{
+		{GemportID: gems[0], Direction: tp_pb.Direction_UPSTREAM},
+		{GemportID: gems[2], Direction: tp_pb.Direction_UPSTREAM},
+		{GemportID: gems[2], Direction: tp_pb.Direction_DOWNSTREAM},
+	}, changed)
+	assert.Equal(t, uint32(50), diff.ChangedQueues[0].New.Weight)
+
+	tpInst, err = tpMgr.ApplyTechProfileInstanceDiff(ctx, diff, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, allocID, tpInst.UsScheduler.AllocId)
+	assert.Equal(t, gems[:3], gemPortIDs(tpInst.UpstreamGemPortAttributeList))
+	assert.Equal(t, gems[:3], gemPortIDs(tpInst.DownstreamGemPortAttributeList))
+	assert.Equal(t, []uint32{gems[3]}, resourceMgr.freed["GEMPORT_ID"])
+	cached, err := tpMgr.GetTPInstance(ctx, tpMgr.GetTechProfileInstanceKey(ctx, 64, testUniPortName))
+	assert.Nil(t, err)
+	assert.Same(t, tpInst, cached)
+
+	// a stale diff is refused
+	_, err = tpMgr.ApplyTechProfileInstanceDiff(ctx, diff, 0)
+	assert.NotNil(t, err)
+
+	// add two GEM ports and a multicast one
+	tp.NumGemPorts = 5
+	tp.UpstreamGemPortAttributeList[2].PbitMap = "0b00100000"
+	tp.DownstreamGemPortAttributeList[2].PbitMap = "0b00100000"
+	for _, pbitMap := range []string{"0b01000000", "0b10000000"} {
+		gem := proto.Clone(tp.UpstreamGemPortAttributeList[2]).(*tp_pb.GemPortAttributes)
+		gem.PbitMap = pbitMap
+		gem.PriorityQ = uint32(len(tp.UpstreamGemPortAttributeList) + 2)
+		tp.UpstreamGemPortAttributeList = append(tp.UpstreamGemPortAttributeList, gem)
+		tp.DownstreamGemPortAttributeList = append(tp.DownstreamGemPortAttributeList, proto.Clone(gem).(*tp_pb.GemPortAttributes))
+	}
+	tp.DownstreamGemPortAttributeList = append(tp.DownstreamGemPortAttributeList,
+		&tp_pb.GemPortAttributes{PbitMap: "0b00000001", IsMulticast: "True", MulticastGemId: 4069, AesEncryption: "False"})
+	updateTechProfile(t, ctx, tpMgr, 64, tp)
+
+	diff, err = tpMgr.DiffTechProfileInstance(ctx, 64, testUniPortName)
+	assert.Nil(t, err)
+	assert.Len(t, diff.AddedGemPorts, 2)
+	assert.Empty(t, diff.RemovedGemPorts)
+	assert.True(t, diff.MulticastChanged)
+	tpInst, err = tpMgr.ApplyTechProfileInstanceDiff(ctx, diff, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, gems[:3], gemPortIDs(tpInst.UpstreamGemPortAttributeList)[:3])
+	assert.Len(t, tpInst.UpstreamGemPortAttributeList, 5)
+	assert.Len(t, tpInst.DownstreamGemPortAttributeList, 6)
+	assert.Equal(t, "0b10000000", tpInst.UpstreamGemPortAttributeList[4].PbitMap)
+	assert.NotContains(t, gems, tpInst.UpstreamGemPortAttributeList[4].GemportId)
+	assert.Equal(t, []uint32{gems[3]}, resourceMgr.freed["GEMPORT_ID"])
+
+	// switching the ONU instance control needs the instance to be recreated
+	tp.InstanceControl.Onu = singleInstance
+	updateTechProfile(t, ctx, tpMgr, 64, tp)
+	diff, err = tpMgr.DiffTechProfileInstance(ctx, 64, testUniPortName)
+	assert.Nil(t, err)
+	assert.True(t, diff.ProfileChanged)
+	_, err = tpMgr.ApplyTechProfileInstanceDiff(ctx, diff, 0)
+	assert.NotNil(t, err)
+
+	_, err = tpMgr.DiffTechProfileInstance(ctx, 65, testUniPortName)
+	assert.NotNil(t, err)
+}
+
+func TestTechProfileInstanceMigrationRace(t *testing.T) {
+	ctx := context.Background()
+	tpMgr, tpDefault := newTestTechProfileMgr(t, ctx, xgspon)
+	resourceMgr := tpMgr.resourceMgr.(*fakeResourceMgr)
+	tp := &tp_pb.TechProfile{}
+	loadSampleProfile(t, "4QueueHybridProfileMap1.json", tp)
+	tp.NumGemPorts = 3
+	tp.UpstreamGemPortAttributeList = tp.UpstreamGemPortAttributeList[:3]
+	tp.DownstreamGemPortAttributeList = tp.DownstreamGemPortAttributeList[:3]
+	tp.UpstreamGemPortAttributeList[2].PbitMap = "0b11100000"
+	tp.DownstreamGemPortAttributeList[2].PbitMap = "0b11100000"
+	putTechProfile(t, ctx, tpDefault, xgspon, 64, tp)
+	_, err := tpMgr.CreateTechProfileInstance(ctx, 64, testUniPortName, 0)
+	assert.Nil(t, err)
+
+	loadSampleProfile(t, "4QueueHybridProfileMap1.json", tp)
+	updateTechProfile(t, ctx, tpMgr, 64, tp)
+	diff, err := tpMgr.DiffTechProfileInstance(ctx, 64, testUniPortName)
+	assert.Nil(t, err)
+	assert.Len(t, diff.AddedGemPorts, 1)
+
+	// another migration of the instance completes while the GEM port is allocated
+	key := tpMgr.GetTechProfileInstanceKey(ctx, 64, testUniPortName)
+	other := proto.Clone(diff.current).(*tp_pb.TechProfileInstance)
+	resourceMgr.onGetResourceID = func() {
+		tpMgr.tpInstanceMapLock.Lock()
+		tpMgr.tpInstanceMap[key] = other
+		tpMgr.tpInstanceMapLock.Unlock()
+	}
+	_, err = tpMgr.ApplyTechProfileInstanceDiff(ctx, diff, 0)
+	assert.NotNil(t, err)
+	cached, err := tpMgr.GetTPInstance(ctx, key)
+	assert.Nil(t, err)
+	assert.Same(t, other, cached)
+	assert.Len(t, resourceMgr.freed["GEMPORT_ID"], 1)
+}
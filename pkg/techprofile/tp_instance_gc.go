@@ -0,0 +1,211 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package techprofile
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/db/kvstore"
+	"github.com/opencord/voltha-lib-go/v7/pkg/log"
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"google.golang.org/protobuf/proto"
+)
+
+// resourceInstanceKeyRegexp matches the <tpID>/<uniPortName> part of a resource instance key
+var resourceInstanceKeyRegexp = regexp.MustCompile(`^([0-9]+)/(olt-{[a-z0-9\-]+}/pon-{([0-9]+)}/onu-{[0-9]+}/uni-{[0-9]+})$`)
+
+// OrphanResourceInstance is a resource instance of a UNI that is not live anymore
+type OrphanResourceInstance struct {
+	// Key is the key of the resource instance under the resource instance path prefix
+	Key         string
+	TpID        uint32
+	UniPortName string
+	IntfID      uint32
+	AllocID     uint32
+	GemPortIDs  []uint32
+	// AllocIDInUse is set when the alloc ID is shared with the instance of a live UNI (single-instance
+	// ONU control), it is kept allocated then
+	AllocIDInUse bool
+	// Removed is set once the resource instance is deleted, freeing its IDs may still have failed
+	Removed bool
+}
+
+// resourceInstanceEntry is a resource instance read back from the KV store
+type resourceInstanceEntry struct {
+	orphan *OrphanResourceInstance
+	live   bool
+}
+
+// CollectOrphanResourceInstances compares the resource instances of the OLT device with the UNIs
+// that are still live, e.g. after an adapter restart during which ONUs were deleted. The resource
+// instances of the other UNIs are returned sorted by key. With remove set they are also deleted
+// from the KV store and the TP instance cache and their alloc and GEM port IDs are freed, otherwise
+// they are only reported.
+func (t *TechProfileMgr) CollectOrphanResourceInstances(ctx context.Context, oltDeviceID string, liveUniPortNames []string, remove bool) ([]*OrphanResourceInstance, error) {
+	liveUnis := make(map[string]bool, len(liveUniPortNames))
+	for _, uniPortName := range liveUniPortNames {
+		liveUnis[uniPortName] = true
+	}
+
+	entries, err := t.getResourceInstances(ctx, oltDeviceID, liveUnis)
+	if err != nil {
+		return nil, err
+	}
+
+	// alloc IDs are shared by the UNIs of a single-instance ONU, only free those no live UNI uses
+	allocIDsInUse := make(map[uint32]map[uint32]bool)
+	for _, entry := range entries {
+		if !entry.live {
+			continue
+		}
+		if allocIDsInUse[entry.orphan.IntfID] == nil {
+			allocIDsInUse[entry.orphan.IntfID] = make(map[uint32]bool)
+		}
+		allocIDsInUse[entry.orphan.IntfID][entry.orphan.AllocID] = true
+	}
+
+	var orphans []*OrphanResourceInstance
+	for _, entry := range entries {
+		if entry.live {
+			continue
+		}
+		orphan := entry.orphan
+		orphan.AllocIDInUse = allocIDsInUse[orphan.IntfID][orphan.AllocID]
+		orphans = append(orphans, orphan)
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Key < orphans[j].Key })
+	logger.Infow(ctx, "collected-orphan-resource-instances", log.Fields{"oltDeviceID": oltDeviceID,
+		"resource-instances": len(entries), "orphans": len(orphans), "remove": remove})
+	if !remove {
+		for _, orphan := range orphans {
+			logger.Warnw(ctx, "orphan-resource-instance", log.Fields{"key": orphan.Key, "allocID": orphan.AllocID, "gemPorts": orphan.GemPortIDs})
+		}
+		return orphans, nil
+	}
+
+	var failed int
+	freedAllocIDs := make(map[uint32]map[uint32]bool)
+	for _, orphan := range orphans {
+		var err error
+		if orphan.Removed, err = t.removeOrphanResourceInstance(ctx, orphan, freedAllocIDs); err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return orphans, fmt.Errorf("failed-to-remove-%d-of-%d-orphan-resource-instances", failed, len(orphans))
+	}
+	return orphans, nil
+}
+
+// getResourceInstances reads the resource instances of the OLT device, keyed by their key
+func (t *TechProfileMgr) getResourceInstances(ctx context.Context, oltDeviceID string, liveUnis map[string]bool) (map[string]*resourceInstanceEntry, error) {
+	tech := t.resourceMgr.GetTechnology()
+	kvPairs, err := t.config.ResourceInstanceKVBacked.GetWithPrefix(ctx, tech+"/")
+	if err != nil {
+		logger.Errorw(ctx, "failed-to-get-resource-instances", log.Fields{"err": err, "tech": tech})
+		return nil, err
+	}
+	pathPrefix := fmt.Sprintf("%s/%s/", t.config.ResourceInstanceKVBacked.PathPrefix, tech)
+	oltPrefix := fmt.Sprintf("olt-{%s}/", oltDeviceID)
+
+	entries := make(map[string]*resourceInstanceEntry)
+	for keyPath, kvPair := range kvPairs {
+		match := resourceInstanceKeyRegexp.FindStringSubmatch(strings.TrimPrefix(keyPath, pathPrefix))
+		if match == nil {
+			logger.Debugw(ctx, "ignoring-non-resource-instance-key", log.Fields{"keyPath": keyPath})
+			continue
+		}
+		tpID, _ := strconv.ParseUint(match[1], 10, 32)
+		intfID, _ := strconv.ParseUint(match[3], 10, 32)
+		uniPortName := match[2]
+		if !strings.HasPrefix(uniPortName, oltPrefix) {
+			continue
+		}
+		value, err := kvstore.ToByte(kvPair.Value)
+		if err != nil {
+			logger.Errorw(ctx, "error-converting-kv-pair-value-to-byte", log.Fields{"err": err, "keyPath": keyPath})
+			continue
+		}
+		var resInst tp_pb.ResourceInstance
+		if err = proto.Unmarshal(value, &resInst); err != nil {
+			// an undecodable instance may still hold IDs, leave it for an operator to look at
+			logger.Errorw(ctx, "error-unmarshal-kv-pair", log.Fields{"err": err, "keyPath": keyPath})
+			continue
+		}
+		key := fmt.Sprintf("%s/%d/%s", tech, tpID, uniPortName)
+		entries[key] = &resourceInstanceEntry{
+			live: liveUnis[uniPortName],
+			orphan: &OrphanResourceInstance{
+				Key:         key,
+				TpID:        uint32(tpID),
+				UniPortName: uniPortName,
+				IntfID:      uint32(intfID),
+				AllocID:     resInst.AllocId,
+				GemPortIDs:  resInst.GemportIds,
+			},
+		}
+	}
+	return entries, nil
+}
+
+// removeOrphanResourceInstance deletes the resource instance before freeing its IDs, so that an ID is
+// never handed out again while a stored instance still refers to it. freedAllocIDs tracks the alloc IDs
+// already freed per PON port, as the orphan instances of a single-instance ONU share theirs.
+func (t *TechProfileMgr) removeOrphanResourceInstance(ctx context.Context, orphan *OrphanResourceInstance, freedAllocIDs map[uint32]map[uint32]bool) (bool, error) {
+	if err := t.removeResourceInstanceFromKVStore(ctx, orphan.TpID, orphan.UniPortName); err != nil {
+		return false, err
+	}
+	if t.isEpon() {
+		t.epontpInstanceMapLock.Lock()
+		delete(t.eponTpInstanceMap, orphan.Key)
+		t.epontpInstanceMapLock.Unlock()
+	} else {
+		t.tpInstanceMapLock.Lock()
+		delete(t.tpInstanceMap, orphan.Key)
+		t.tpInstanceMapLock.Unlock()
+	}
+
+	var freeErr error
+	if len(orphan.GemPortIDs) > 0 {
+		if err := t.FreeResourceID(ctx, orphan.IntfID, t.resourceMgr.GetResourceTypeGemPortID(), orphan.GemPortIDs); err != nil {
+			logger.Errorw(ctx, "failed-to-free-orphan-gem-ports", log.Fields{"err": err, "key": orphan.Key, "gemPorts": orphan.GemPortIDs})
+			freeErr = err
+		}
+	}
+	if !orphan.AllocIDInUse && !freedAllocIDs[orphan.IntfID][orphan.AllocID] {
+		if err := t.FreeResourceID(ctx, orphan.IntfID, t.resourceMgr.GetResourceTypeAllocID(), []uint32{orphan.AllocID}); err != nil {
+			logger.Errorw(ctx, "failed-to-free-orphan-alloc-id", log.Fields{"err": err, "key": orphan.Key, "allocID": orphan.AllocID})
+			freeErr = err
+		} else {
+			if freedAllocIDs[orphan.IntfID] == nil {
+				freedAllocIDs[orphan.IntfID] = make(map[uint32]bool)
+			}
+			freedAllocIDs[orphan.IntfID][orphan.AllocID] = true
+		}
+	}
+	if freeErr != nil {
+		return true, freeErr
+	}
+	logger.Infow(ctx, "removed-orphan-resource-instance", log.Fields{"key": orphan.Key, "allocID": orphan.AllocID,
+		"allocIDInUse": orphan.AllocIDInUse, "gemPorts": orphan.GemPortIDs})
+	return true, nil
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package techprofile
+
+import (
+	"context"
+	"testing"
+
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectOrphanResourceInstances(t *testing.T) {
+	ctx := context.Background()
+	tpMgr, tpDefault := newTestTechProfileMgr(t, ctx, xgspon)
+	tp := &tp_pb.TechProfile{}
+	loadSampleProfile(t, "4QueueHybridProfileMap1.json", tp)
+	putTechProfile(t, ctx, tpDefault, xgspon, 64, tp)
+	// the UNIs of a single-instance ONU share their alloc ID
+	tp.InstanceControl.Onu = "single-instance"
+	putTechProfile(t, ctx, tpDefault, xgspon, 65, tp)
+
+	const (
+		liveUni     = "olt-{olt1}/pon-{0}/onu-{1}/uni-{0}"
+		deletedUni  = "olt-{olt1}/pon-{0}/onu-{2}/uni-{0}"
+		sharedUni   = "olt-{olt1}/pon-{1}/onu-{3}/uni-{0}"
+		orphanUni   = "olt-{olt1}/pon-{1}/onu-{3}/uni-{1}"
+		otherOltUni = "olt-{olt2}/pon-{0}/onu-{1}/uni-{0}"
+	)
+	for _, uni := range []string{liveUni, deletedUni, otherOltUni} {
+		_, err := tpMgr.CreateTechProfileInstance(ctx, 64, uni, 0)
+		assert.Nil(t, err)
+	}
+	for _, uni := range []string{sharedUni, orphanUni} {
+		_, err := tpMgr.CreateTechProfileInstance(ctx, 65, uni, 1)
+		assert.Nil(t, err)
+	}
+	deletedInst, err := tpMgr.GetTPInstance(ctx, tpMgr.GetTechProfileInstanceKey(ctx, 64, deletedUni))
+	assert.Nil(t, err)
+	sharedInst, err := tpMgr.GetTPInstance(ctx, tpMgr.GetTechProfileInstanceKey(ctx, 65, sharedUni))
+	assert.Nil(t, err)
+	live := []string{liveUni, sharedUni}
+
+	// reporting leaves everything in place
+	orphans, err := tpMgr.CollectOrphanResourceInstances(ctx, "olt1", live, false)
+	assert.Nil(t, err)
+	if assert.Len(t, orphans, 2) {
+		assert.Equal(t, "XGS-PON/64/"+deletedUni, orphans[0].Key)
+		assert.Equal(t, uint32(64), orphans[0].TpID)
+		assert.Equal(t, uint32(0), orphans[0].IntfID)
+		assert.Equal(t, deletedInst.(*tp_pb.TechProfileInstance).UsScheduler.AllocId, orphans[0].AllocID)
+		assert.Len(t, orphans[0].GemPortIDs, 4)
+		assert.False(t, orphans[0].AllocIDInUse)
+		assert.False(t, orphans[0].Removed)
+
+		assert.Equal(t, "XGS-PON/65/"+orphanUni, orphans[1].Key)
+		assert.Equal(t, uint32(1), orphans[1].IntfID)
+		assert.Equal(t, sharedInst.(*tp_pb.TechProfileInstance).UsScheduler.AllocId, orphans[1].AllocID)
+		assert.True(t, orphans[1].AllocIDInUse)
+	}
+	assert.Empty(t, tpMgr.resourceMgr.(*fakeResourceMgr).freed)
+
+	orphans, err = tpMgr.CollectOrphanResourceInstances(ctx, "olt1", live, true)
+	assert.Nil(t, err)
+	assert.Len(t, orphans, 2)
+	for _, orphan := range orphans {
+		assert.True(t, orphan.Removed)
+		_, err = tpMgr.GetTPInstance(ctx, orphan.Key)
+		assert.NotNil(t, err)
+	}
+	freed := tpMgr.resourceMgr.(*fakeResourceMgr).freed
+	// the alloc ID the live UNI shares is kept
+	assert.Equal(t, []uint32{orphans[0].AllocID}, freed["ALLOC_ID"])
+	assert.ElementsMatch(t, append(append([]uint32{}, orphans[0].GemPortIDs...), orphans[1].GemPortIDs...), freed["GEMPORT_ID"])
+
+	// the live UNIs and the other OLT are untouched
+	kvPairs, err := tpMgr.config.ResourceInstanceKVBacked.GetWithPrefix(ctx, xgspon+"/")
+	assert.Nil(t, err)
+	assert.Len(t, kvPairs, 3)
+	orphans, err = tpMgr.CollectOrphanResourceInstances(ctx, "olt1", live, true)
+	assert.Nil(t, err)
+	assert.Empty(t, orphans)
+}
@@ -0,0 +1,279 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package techprofile
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/log"
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+)
+
+// Technology is a PON technology the TechProfileMgr can build TP instances for. A technology
+// belongs to one of two families, depending on the tech profile it uses: it implements either
+// GponFamilyTechnology or EponFamilyTechnology.
+type Technology interface {
+	// Name is the technology as returned by GetTechnology of the PON resource manager, e.g. XGS-PON
+	Name() string
+	// ResourceIDLimits are the highest alloc and GEM port IDs the technology can address
+	ResourceIDLimits() ResourceIDLimits
+}
+
+// GponFamilyTechnology is a technology using TechProfile, like GPON, XGPON, XGS-PON and 25GS-PON
+type GponFamilyTechnology interface {
+	Technology
+	// DefaultTechProfile returns the TP used when the KV store has none
+	DefaultTechProfile(ctx context.Context, config *TechProfileFlags) *tp_pb.TechProfile
+	// ValidateTechProfile checks a TP before instances are built from it
+	ValidateTechProfile(tp *tp_pb.TechProfile) error
+	// BuildTpInstance builds the TP instance of a resource instance, nil if they do not match
+	BuildTpInstance(ctx context.Context, tp *tp_pb.TechProfile, resInst *tp_pb.ResourceInstance) *tp_pb.TechProfileInstance
+	// SchedulerConfig maps the scheduler of a TP instance to the scheduler configured on the OLT
+	SchedulerConfig(sched *tp_pb.SchedulerAttributes) *tp_pb.SchedulerConfig
+}
+
+// EponFamilyTechnology is a technology using EponTechProfile
+type EponFamilyTechnology interface {
+	Technology
+	// DefaultTechProfile returns the TP used when the KV store has none
+	DefaultTechProfile(ctx context.Context, config *TechProfileFlags) *tp_pb.EponTechProfile
+	// ValidateTechProfile checks a TP before instances are built from it
+	ValidateTechProfile(tp *tp_pb.EponTechProfile) error
+	// BuildTpInstance builds the TP instance of a resource instance, nil if they do not match
+	BuildTpInstance(ctx context.Context, tp *tp_pb.EponTechProfile, resInst *tp_pb.ResourceInstance) *tp_pb.EponTechProfileInstance
+}
+
+// ResourceIDLimits are the range of IDs a technology can assign to the instances. The IDs below
+// the minimum are reserved, e.g. as default alloc IDs, and a maximum of 0 means not limited.
+type ResourceIDLimits struct {
+	MinAllocID   uint32
+	MaxAllocID   uint32
+	MinGemPortID uint32
+	MaxGemPortID uint32
+}
+
+// check returns an error if one of the IDs is out of the limits
+func (l ResourceIDLimits) check(allocID uint32, gemPorts []uint32) error {
+	if allocID < l.MinAllocID {
+		return fmt.Errorf("alloc-id-%d-below-%d", allocID, l.MinAllocID)
+	}
+	if l.MaxAllocID != 0 && allocID > l.MaxAllocID {
+		return fmt.Errorf("alloc-id-%d-exceeds-%d", allocID, l.MaxAllocID)
+	}
+	for _, gemPort := range gemPorts {
+		if gemPort < l.MinGemPortID {
+			return fmt.Errorf("gem-port-id-%d-below-%d", gemPort, l.MinGemPortID)
+		}
+		if l.MaxGemPortID != 0 && gemPort > l.MaxGemPortID {
+			return fmt.Errorf("gem-port-id-%d-exceeds-%d", gemPort, l.MaxGemPortID)
+		}
+	}
+	return nil
+}
+
+// GponTechnology implements GponFamilyTechnology with the behaviour common to the family. New
+// technologies embed it and override what differs.
+type GponTechnology struct {
+	TechName string
+	Limits   ResourceIDLimits
+	// largest max_gem_payload_size of the instance control in bytes, 0 when not checked
+	MaxGemPayloadSize uint32
+}
+
+func (g *GponTechnology) Name() string {
+	return g.TechName
+}
+
+func (g *GponTechnology) ResourceIDLimits() ResourceIDLimits {
+	return g.Limits
+}
+
+func (g *GponTechnology) DefaultTechProfile(ctx context.Context, config *TechProfileFlags) *tp_pb.TechProfile {
+	return defaultGponTechProfile(ctx, config, g.TechName)
+}
+
+func (g *GponTechnology) ValidateTechProfile(tp *tp_pb.TechProfile) error {
+	return validateGponTechProfile(tp, g.MaxGemPayloadSize)
+}
+
+func (g *GponTechnology) BuildTpInstance(ctx context.Context, tp *tp_pb.TechProfile, resInst *tp_pb.ResourceInstance) *tp_pb.TechProfileInstance {
+	return buildGponTpInstance(ctx, tp, resInst)
+}
+
+func (g *GponTechnology) SchedulerConfig(sched *tp_pb.SchedulerAttributes) *tp_pb.SchedulerConfig {
+	return &tp_pb.SchedulerConfig{
+		Direction:    sched.Direction,
+		AdditionalBw: sched.AdditionalBw,
+		Priority:     sched.Priority,
+		Weight:       sched.Weight,
+		SchedPolicy:  sched.QSchedPolicy}
+}
+
+// XGPonTechnology implements the GPON family technologies built on the XG-PON transmission
+// convergence layer (G.987.3): XG-PON, XGS-PON (G.9807.1) and 25GS-PON. They differ from GPON by
+// their ID ranges, their larger XGEM frames and their bandwidth assignment, which has no T-CONT type 5.
+type XGPonTechnology struct {
+	GponTechnology
+}
+
+// SchedulerConfig maps the scheduler as GPON does, except for the additional bandwidth: the Auto
+// eligibility stands for the T-CONT type 5 of GPON, which is requested as best effort.
+func (x *XGPonTechnology) SchedulerConfig(sched *tp_pb.SchedulerAttributes) *tp_pb.SchedulerConfig {
+	config := x.GponTechnology.SchedulerConfig(sched)
+	if config.AdditionalBw == tp_pb.AdditionalBW_AdditionalBW_Auto {
+		config.AdditionalBw = tp_pb.AdditionalBW_AdditionalBW_BestEffort
+	}
+	return config
+}
+
+// EponTechnology implements EponFamilyTechnology with the behaviour common to the family
+type EponTechnology struct {
+	TechName string
+	Limits   ResourceIDLimits
+}
+
+func (e *EponTechnology) Name() string {
+	return e.TechName
+}
+
+func (e *EponTechnology) ResourceIDLimits() ResourceIDLimits {
+	return e.Limits
+}
+
+func (e *EponTechnology) DefaultTechProfile(ctx context.Context, config *TechProfileFlags) *tp_pb.EponTechProfile {
+	return defaultEponTechProfile(ctx, config, e.TechName)
+}
+
+func (e *EponTechnology) ValidateTechProfile(tp *tp_pb.EponTechProfile) error {
+	return ValidateEponTechProfile(tp)
+}
+
+func (e *EponTechnology) BuildTpInstance(ctx context.Context, tp *tp_pb.EponTechProfile, resInst *tp_pb.ResourceInstance) *tp_pb.EponTechProfileInstance {
+	return buildEponTpInstance(ctx, tp, resInst)
+}
+
+var _ GponFamilyTechnology = &GponTechnology{}
+var _ GponFamilyTechnology = &XGPonTechnology{}
+var _ EponFamilyTechnology = &EponTechnology{}
+
+// The alloc and GEM port IDs of GPON are 12 bits wide, the alloc IDs up to 255 being the default
+// ones of the ONUs or unassigned (G.984.3). XG-PON widened the alloc IDs to 14 bits and the XGEM
+// port IDs to 16 bits, 0xFFFF being reserved, and keeps the IDs up to 1023 as the default ones of
+// the ONUs and for broadcast (G.987.3). The payload length of a GEM frame is 12 bits wide, the one
+// of an XGEM frame 14 bits. XGS-PON and 25GS-PON reuse the XG-PON transmission convergence layer.
+var (
+	gponIDLimits  = ResourceIDLimits{MinAllocID: 256, MaxAllocID: 4095, MaxGemPortID: 4095}
+	xgponIDLimits = ResourceIDLimits{MinAllocID: 1024, MaxAllocID: 16383, MinGemPortID: 1024, MaxGemPortID: 65534}
+)
+
+const (
+	gponMaxGemPayloadSize  = 4095
+	xgponMaxGemPayloadSize = 16383
+)
+
+func newXGPonTechnology(name string) *XGPonTechnology {
+	return &XGPonTechnology{GponTechnology{TechName: name, Limits: xgponIDLimits, MaxGemPayloadSize: xgponMaxGemPayloadSize}}
+}
+
+var technologyRegistry = struct {
+	sync.RWMutex
+	technologies map[string]Technology
+}{technologies: map[string]Technology{
+	gpon:    &GponTechnology{TechName: gpon, Limits: gponIDLimits, MaxGemPayloadSize: gponMaxGemPayloadSize},
+	xgpon:   newXGPonTechnology(xgpon),
+	xgspon:  newXGPonTechnology(xgspon),
+	gs25pon: newXGPonTechnology(gs25pon),
+	epon:    &EponTechnology{TechName: epon},
+}}
+
+// RegisterTechnology makes a PON technology known to the TechProfileMgrs created afterwards. A
+// technology can be registered once, and must implement GponFamilyTechnology or EponFamilyTechnology.
+func RegisterTechnology(tech Technology) error {
+	_, isGpon := tech.(GponFamilyTechnology)
+	_, isEpon := tech.(EponFamilyTechnology)
+	if isGpon == isEpon {
+		return fmt.Errorf("technology-%s-must-be-of-either-the-gpon-or-the-epon-family", tech.Name())
+	}
+	technologyRegistry.Lock()
+	defer technologyRegistry.Unlock()
+	if _, ok := technologyRegistry.technologies[tech.Name()]; ok {
+		return fmt.Errorf("technology-%s-already-registered", tech.Name())
+	}
+	technologyRegistry.technologies[tech.Name()] = tech
+	return nil
+}
+
+// LookupTechnology returns the registered technology of the name
+func LookupTechnology(name string) (Technology, bool) {
+	technologyRegistry.RLock()
+	defer technologyRegistry.RUnlock()
+	tech, ok := technologyRegistry.technologies[name]
+	return tech, ok
+}
+
+// resolveTechnology returns the technology of the name. A technology that was not registered gets
+// the GPON family defaults, which is what CreateTechProfileInstance always used for anything but EPON.
+func resolveTechnology(ctx context.Context, name string) Technology {
+	if tech, ok := LookupTechnology(name); ok {
+		return tech
+	}
+	logger.Warnw(ctx, "unknown-technology--using-gpon-family-defaults", log.Fields{"tech": name})
+	return &GponTechnology{TechName: name}
+}
+
+func (t *TechProfileMgr) isGpon() bool {
+	_, ok := t.technology.(GponFamilyTechnology)
+	return ok
+}
+
+func (t *TechProfileMgr) isEpon() bool {
+	_, ok := t.technology.(EponFamilyTechnology)
+	return ok
+}
+
+// gponTechnology returns the technology of a GPON family manager
+func (t *TechProfileMgr) gponTechnology() GponFamilyTechnology {
+	return t.technology.(GponFamilyTechnology)
+}
+
+// eponTechnology returns the technology of an EPON family manager
+func (t *TechProfileMgr) eponTechnology() EponFamilyTechnology {
+	return t.technology.(EponFamilyTechnology)
+}
+
+// checkResourceIDLimits frees the IDs of an instance and fails if one of them is beyond the limits of
+// the technology, which happens when the resource ranges of the OLT do not match the technology. An
+// alloc ID shared with another instance is not freed.
+func (t *TechProfileMgr) checkResourceIDLimits(ctx context.Context, intfID uint32, allocID uint32, sharedAllocID bool, gemPorts []uint32) error {
+	err := t.technology.ResourceIDLimits().check(allocID, gemPorts)
+	if err == nil {
+		return nil
+	}
+	logger.Errorw(ctx, "resource-ids-beyond-technology-limits--freeing-them", log.Fields{"err": err, "tech": t.technology.Name(),
+		"intfID": intfID, "allocID": allocID, "gemPorts": gemPorts})
+	if !sharedAllocID {
+		if freeErr := t.FreeResourceID(ctx, intfID, t.resourceMgr.GetResourceTypeAllocID(), []uint32{allocID}); freeErr != nil {
+			logger.Errorw(ctx, "failed-to-free-alloc-id", log.Fields{"err": freeErr, "intfID": intfID, "allocID": allocID})
+		}
+	}
+	if freeErr := t.FreeResourceID(ctx, intfID, t.resourceMgr.GetResourceTypeGemPortID(), gemPorts); freeErr != nil {
+		logger.Errorw(ctx, "failed-to-free-gem-ports", log.Fields{"err": freeErr, "intfID": intfID, "gemPorts": gemPorts})
+	}
+	return err
+}
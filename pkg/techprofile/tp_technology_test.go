@@ -0,0 +1,208 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package techprofile
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPonTechnology is a GPON family technology with its own defaults, validation and scheduler mapping
+type testPonTechnology struct {
+	GponTechnology
+}
+
+func (p *testPonTechnology) DefaultTechProfile(ctx context.Context, config *TechProfileFlags) *tp_pb.TechProfile {
+	tp := p.GponTechnology.DefaultTechProfile(ctx, config)
+	tp.Name = "test-pon-default"
+	return tp
+}
+
+func (p *testPonTechnology) ValidateTechProfile(tp *tp_pb.TechProfile) error {
+	if err := p.GponTechnology.ValidateTechProfile(tp); err != nil {
+		return err
+	}
+	if tp.UsScheduler.AdditionalBw == tp_pb.AdditionalBW_AdditionalBW_Auto {
+		return ValidationErrors{{Field: "us_scheduler.additional_bw", Message: "Auto is not supported"}}
+	}
+	return nil
+}
+
+func (p *testPonTechnology) SchedulerConfig(sched *tp_pb.SchedulerAttributes) *tp_pb.SchedulerConfig {
+	config := p.GponTechnology.SchedulerConfig(sched)
+	if config.AdditionalBw == tp_pb.AdditionalBW_AdditionalBW_Auto {
+		config.AdditionalBw = tp_pb.AdditionalBW_AdditionalBW_BestEffort
+	}
+	return config
+}
+
+// nameOnlyTechnology belongs to no family
+type nameOnlyTechnology struct{}
+
+func (nameOnlyTechnology) Name() string                       { return "NAME-ONLY-PON" }
+func (nameOnlyTechnology) ResourceIDLimits() ResourceIDLimits { return ResourceIDLimits{} }
+
+func TestLookupTechnology(t *testing.T) {
+	for name, limits := range map[string]ResourceIDLimits{
+		gpon:    {MinAllocID: 256, MaxAllocID: 4095, MaxGemPortID: 4095},
+		xgpon:   {MinAllocID: 1024, MaxAllocID: 16383, MinGemPortID: 1024, MaxGemPortID: 65534},
+		xgspon:  {MinAllocID: 1024, MaxAllocID: 16383, MinGemPortID: 1024, MaxGemPortID: 65534},
+		gs25pon: {MinAllocID: 1024, MaxAllocID: 16383, MinGemPortID: 1024, MaxGemPortID: 65534},
+	} {
+		tech, ok := LookupTechnology(name)
+		assert.True(t, ok, name)
+		assert.Implements(t, (*GponFamilyTechnology)(nil), tech, name)
+		assert.Equal(t, name, tech.Name())
+		assert.Equal(t, limits, tech.ResourceIDLimits(), name)
+	}
+	tech, ok := LookupTechnology(epon)
+	assert.True(t, ok)
+	assert.Implements(t, (*EponFamilyTechnology)(nil), tech)
+
+	_, ok = LookupTechnology("UNKNOWN-PON")
+	assert.False(t, ok)
+}
+
+func TestRegisterTechnology(t *testing.T) {
+	assert.EqualError(t, RegisterTechnology(&GponTechnology{TechName: xgspon}), "technology-XGS-PON-already-registered")
+	assert.EqualError(t, RegisterTechnology(nameOnlyTechnology{}), "technology-NAME-ONLY-PON-must-be-of-either-the-gpon-or-the-epon-family")
+	_, ok := LookupTechnology("NAME-ONLY-PON")
+	assert.False(t, ok)
+}
+
+func TestRegisteredTechnology(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, RegisterTechnology(&testPonTechnology{GponTechnology{TechName: "TEST-PON"}}))
+	tpMgr, tpDefault := newTestTechProfileMgr(t, ctx, "TEST-PON")
+	_, err := tpMgr.Epon()
+	assert.True(t, errors.Is(err, ErrWrongTechnology))
+
+	// the default TP of the technology is used when the KV store has none
+	tpInst, err := tpMgr.CreateTechProfileInstance(ctx, 64, testUniPortName, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "test-pon-default", tpInst.(*tp_pb.TechProfileInstance).Name)
+	assert.Equal(t, "TEST-PON", tpInst.(*tp_pb.TechProfileInstance).ProfileType)
+
+	// the sample TP does not pass the validation of the technology
+	tp := &tp_pb.TechProfile{}
+	loadSampleProfile(t, "4QueueHybridProfileMap1.json", tp)
+	putTechProfile(t, ctx, tpDefault, "TEST-PON", 65, tp)
+	gponMgr, _ := tpMgr.Gpon()
+	_, err = gponMgr.GetTechProfile(ctx, 65)
+	var errs ValidationErrors
+	assert.True(t, errors.As(err, &errs))
+	assert.Equal(t, "us_scheduler.additional_bw", errs[0].Field)
+
+	tp.UsScheduler.AdditionalBw = tp_pb.AdditionalBW_AdditionalBW_BestEffort
+	putTechProfile(t, ctx, tpDefault, "TEST-PON", 66, tp)
+	tpInst, err = tpMgr.CreateTechProfileInstance(ctx, 66, testUniPortName, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "4QueueHybridProfileMap1", tpInst.(*tp_pb.TechProfileInstance).Name)
+
+	// the scheduler is mapped by the technology
+	tpInst.(*tp_pb.TechProfileInstance).DsScheduler.AdditionalBw = tp_pb.AdditionalBW_AdditionalBW_Auto
+	assert.Equal(t, tp_pb.AdditionalBW_AdditionalBW_BestEffort, tpMgr.GetDsScheduler(tpInst.(*tp_pb.TechProfileInstance)).AdditionalBw)
+	assert.Equal(t, tp_pb.SchedulingPolicy_Hybrid, tpMgr.GetUsScheduler(tpInst.(*tp_pb.TechProfileInstance)).SchedPolicy)
+}
+
+func TestUnknownTechnologyIsGpon(t *testing.T) {
+	ctx := context.Background()
+	tpMgr, _ := newTestTechProfileMgr(t, ctx, "UNKNOWN-PON")
+	assert.True(t, tpMgr.isGpon())
+	tpInst, err := tpMgr.CreateTechProfileInstance(ctx, 64, testUniPortName, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "UNKNOWN-PON", tpInst.(*tp_pb.TechProfileInstance).ProfileType)
+}
+
+func TestResourceIDLimits(t *testing.T) {
+	ctx := context.Background()
+	// the fake resource manager hands out alloc ID 1025 and then GEM port 1026 for the default TP
+	assert.Nil(t, RegisterTechnology(&GponTechnology{TechName: "LIMITED-PON", Limits: ResourceIDLimits{MaxAllocID: 4095, MaxGemPortID: 1025}}))
+	tpMgr, _ := newTestTechProfileMgr(t, ctx, "LIMITED-PON")
+	_, err := tpMgr.CreateTechProfileInstance(ctx, 64, testUniPortName, 0)
+	assert.NotNil(t, err)
+	freed := tpMgr.resourceMgr.(*fakeResourceMgr).freed
+	assert.Equal(t, []uint32{1025}, freed["ALLOC_ID"])
+	assert.Equal(t, []uint32{1026}, freed["GEMPORT_ID"])
+	_, err = tpMgr.GetTPInstance(ctx, tpMgr.GetTechProfileInstanceKey(ctx, 64, testUniPortName))
+	assert.NotNil(t, err)
+
+	limits := xgponIDLimits
+	assert.Nil(t, limits.check(16383, []uint32{1024, 65534}))
+	assert.EqualError(t, limits.check(16384, nil), "alloc-id-16384-exceeds-16383")
+	assert.EqualError(t, limits.check(1024, []uint32{1024, 65535}), "gem-port-id-65535-exceeds-65534")
+	assert.EqualError(t, limits.check(1023, nil), "alloc-id-1023-below-1024")
+	assert.EqualError(t, limits.check(1024, []uint32{1023}), "gem-port-id-1023-below-1024")
+	assert.Nil(t, gponIDLimits.check(256, []uint32{1, 4095}))
+	assert.EqualError(t, gponIDLimits.check(253, nil), "alloc-id-253-below-256")
+	assert.Nil(t, ResourceIDLimits{}.check(1<<20, []uint32{1 << 20}))
+}
+
+func TestXGPonTechnology(t *testing.T) {
+	ctx := context.Background()
+	tp := &tp_pb.TechProfile{}
+	loadSampleProfile(t, "4QueueHybridProfileMap1.json", tp)
+	assert.Equal(t, tp_pb.AdditionalBW_AdditionalBW_Auto, tp.UsScheduler.AdditionalBw)
+
+	for _, name := range []string{xgpon, xgspon, gs25pon} {
+		tpMgr, tpDefault := newTestTechProfileMgr(t, ctx, name)
+		assert.IsType(t, &XGPonTechnology{}, tpMgr.technology, name)
+		putTechProfile(t, ctx, tpDefault, name, 64, tp)
+		tpInst, err := tpMgr.CreateTechProfileInstance(ctx, 64, testUniPortName, 0)
+		assert.Nil(t, err, name)
+
+		// there is no T-CONT type 5 on the XG-PON family, Auto is requested as best effort
+		usScheduler := tpMgr.GetUsScheduler(tpInst.(*tp_pb.TechProfileInstance))
+		assert.Equal(t, tp_pb.AdditionalBW_AdditionalBW_BestEffort, usScheduler.AdditionalBw, name)
+		assert.Equal(t, tp_pb.SchedulingPolicy_Hybrid, usScheduler.SchedPolicy, name)
+	}
+	tpMgr, _ := newTestTechProfileMgr(t, ctx, gpon)
+	tpInst, err := tpMgr.CreateTechProfileInstance(ctx, 64, testUniPortName, 0)
+	assert.Nil(t, err)
+	tpInst.(*tp_pb.TechProfileInstance).UsScheduler.AdditionalBw = tp_pb.AdditionalBW_AdditionalBW_Auto
+	assert.Equal(t, tp_pb.AdditionalBW_AdditionalBW_Auto, tpMgr.GetUsScheduler(tpInst.(*tp_pb.TechProfileInstance)).AdditionalBw)
+}
+
+func TestMaxGemPayloadSize(t *testing.T) {
+	tp := &tp_pb.TechProfile{}
+	loadSampleProfile(t, "4QueueHybridProfileMap1.json", tp)
+	gponTech, _ := LookupTechnology(gpon)
+	xgsponTech, _ := LookupTechnology(xgspon)
+	validate := func(tech Technology, size string) error {
+		tp.InstanceControl.MaxGemPayloadSize = size
+		return tech.(GponFamilyTechnology).ValidateTechProfile(tp)
+	}
+
+	for size, valid := range map[string]bool{"auto": true, "4095": true, "4096": false, "0": false, "large": false} {
+		assert.Equal(t, valid, validate(gponTech, size) == nil, size)
+	}
+	// XGEM frames carry larger payloads
+	for size, valid := range map[string]bool{"auto": true, "4096": true, "16383": true, "16384": false} {
+		assert.Equal(t, valid, validate(xgsponTech, size) == nil, size)
+	}
+	err := validate(xgsponTech, "16384")
+	var errs ValidationErrors
+	assert.ErrorAs(t, err, &errs)
+	assert.Equal(t, "instance_control.max_gem_payload_size", errs[0].Field)
+
+	// the technology independent validation does not check it
+	assert.Nil(t, ValidateTechProfile(tp))
+}
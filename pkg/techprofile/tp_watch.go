@@ -0,0 +1,259 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package techprofile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/opencord/voltha-lib-go/v7/pkg/db/kvstore"
+	"github.com/opencord/voltha-lib-go/v7/pkg/log"
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// TpUpdateType tells how a TP definition changed in the KV store
+type TpUpdateType int
+
+const (
+	// TpUpdated is sent when a valid new definition replaced the cached one
+	TpUpdated TpUpdateType = iota
+	// TpInvalid is sent when the new definition could not be decoded or failed validation, the
+	// cached definition is dropped so that new instances are no longer built from it
+	TpInvalid
+	// TpDeleted is sent when the definition was removed from the KV store
+	TpDeleted
+)
+
+func (u TpUpdateType) String() string {
+	switch u {
+	case TpUpdated:
+		return "updated"
+	case TpInvalid:
+		return "invalid"
+	case TpDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// TpUpdate describes a change of a TP definition and the TP instances built from its previous version
+type TpUpdate struct {
+	TpID       uint32
+	Technology string
+	UpdateType TpUpdateType
+	// Err is the decoding or validation error of a TpInvalid update
+	Err error
+	// AffectedInstances are the keys (see GetTechProfileInstanceKey) of the existing TP instances of the TP
+	AffectedInstances []string
+}
+
+// TpUpdateCallback is called for every change of a TP definition seen by the TP watch. The callbacks
+// are called one after the other from the goroutine of the TP watch, so a callback that blocks delays
+// the processing of the following changes, and StopTpWatch waits for it to return. A callback must
+// not call StopTpWatch, and should hand long running work, such as re-provisioning the affected
+// instances, over to another goroutine.
+type TpUpdateCallback func(ctx context.Context, update *TpUpdate)
+
+// RegisterTpUpdateCallback adds a subscriber to the TP definition changes reported by the TP watch
+func (t *TechProfileMgr) RegisterTpUpdateCallback(cb TpUpdateCallback) {
+	t.tpWatchLock.Lock()
+	defer t.tpWatchLock.Unlock()
+	t.tpUpdateCallbacks = append(t.tpUpdateCallbacks, cb)
+}
+
+// tpWatchKey is the prefix under which the TP definitions of the technology are stored
+func (t *TechProfileMgr) tpWatchKey() string {
+	return t.resourceMgr.GetTechnology() + "/"
+}
+
+// StartTpWatch watches the TP definitions of the technology in the KV store. Changed definitions
+// replace the cached ones once validated and are reported to the registered callbacks.
+func (t *TechProfileMgr) StartTpWatch(ctx context.Context) error {
+	t.tpWatchLock.Lock()
+	defer t.tpWatchLock.Unlock()
+	if t.tpWatchCh != nil {
+		return errors.New("tp-watch-already-started")
+	}
+	if t.config.DefaultTpKVBackend == nil {
+		return errors.New("tp-kv-backend-not-set")
+	}
+	key := t.tpWatchKey()
+	ch := t.config.DefaultTpKVBackend.CreateWatch(ctx, key, true)
+	if ch == nil {
+		logger.Errorw(ctx, "failed-to-create-tp-watch", log.Fields{"key": key})
+		return fmt.Errorf("failed-to-create-tp-watch-%s", key)
+	}
+	t.tpWatchCh = ch
+	t.tpWatchDone = make(chan struct{})
+	logger.Infow(ctx, "started-tp-watch", log.Fields{"key": key})
+
+	go t.processTpWatchEvents(ctx, ch, t.tpWatchDone)
+	return nil
+}
+
+// StopTpWatch stops the TP watch started by StartTpWatch and waits for the pending events to be processed
+func (t *TechProfileMgr) StopTpWatch(ctx context.Context) {
+	t.tpWatchLock.Lock()
+	ch, done := t.tpWatchCh, t.tpWatchDone
+	t.tpWatchCh, t.tpWatchDone = nil, nil
+	t.tpWatchLock.Unlock()
+	if ch == nil {
+		return
+	}
+	t.config.DefaultTpKVBackend.DeleteWatch(ctx, t.tpWatchKey(), ch)
+	<-done
+	logger.Infow(ctx, "stopped-tp-watch", log.Fields{"key": t.tpWatchKey()})
+}
+
+func (t *TechProfileMgr) processTpWatchEvents(ctx context.Context, ch chan *kvstore.Event, done chan struct{}) {
+	defer close(done)
+	pathPrefix := fmt.Sprintf("%s/%s", t.config.DefaultTpKVBackend.PathPrefix, t.tpWatchKey())
+	for event := range ch {
+		if event.EventType != kvstore.PUT && event.EventType != kvstore.DELETE {
+			logger.Warnw(ctx, "received-invalid-change-type-in-tp-watch", log.Fields{"change-type": event.EventType})
+			continue
+		}
+		key := fmt.Sprintf("%s", event.Key)
+		// only <technology>/<tpID> keys hold TP definitions
+		tpID, err := strconv.ParseUint(strings.TrimPrefix(key, pathPrefix), 10, 32)
+		if err != nil || !strings.HasPrefix(key, pathPrefix) {
+			logger.Debugw(ctx, "ignoring-non-tp-key-in-tp-watch", log.Fields{"key": key})
+			continue
+		}
+		if update := t.applyTpChange(ctx, uint32(tpID), event); update != nil {
+			t.notifyTpUpdate(ctx, update)
+		}
+	}
+}
+
+// applyTpChange refreshes the cached definition of the TP and returns the update to report, or nil
+// if the cached definition did not change
+func (t *TechProfileMgr) applyTpChange(ctx context.Context, tpID uint32, event *kvstore.Event) *TpUpdate {
+	tech := t.resourceMgr.GetTechnology()
+	update := &TpUpdate{TpID: tpID, Technology: tech, UpdateType: TpUpdated}
+
+	var tp proto.Message
+	if event.EventType == kvstore.DELETE {
+		update.UpdateType = TpDeleted
+	} else if value, err := kvstore.ToByte(event.Value); err != nil {
+		update.UpdateType, update.Err = TpInvalid, err
+	} else if t.isEpon() {
+		eponTp := &tp_pb.EponTechProfile{}
+		if update.Err = protojson.Unmarshal(value, eponTp); update.Err == nil {
+			update.Err = t.eponTechnology().ValidateTechProfile(eponTp)
+		}
+		tp = eponTp
+	} else {
+		gponTp := &tp_pb.TechProfile{}
+		if update.Err = protojson.Unmarshal(value, gponTp); update.Err == nil {
+			update.Err = t.gponTechnology().ValidateTechProfile(gponTp)
+		}
+		tp = gponTp
+	}
+	if update.Err != nil {
+		update.UpdateType = TpInvalid
+		logger.Errorw(ctx, "invalid-tp-update-dropping-cached-tp", log.Fields{"tpID": tpID, "err": update.Err})
+	}
+
+	if t.isEpon() {
+		t.eponTpMapLock.Lock()
+		cached, ok := t.eponTpMap[tpID]
+		if update.UpdateType == TpUpdated {
+			t.eponTpMap[tpID] = tp.(*tp_pb.EponTechProfile)
+		} else {
+			delete(t.eponTpMap, tpID)
+		}
+		t.eponTpMapLock.Unlock()
+		if ok && update.UpdateType == TpUpdated && proto.Equal(cached, tp) {
+			return nil
+		}
+	} else {
+		t.tpMapLock.Lock()
+		cached, ok := t.tpMap[tpID]
+		if update.UpdateType == TpUpdated {
+			t.tpMap[tpID] = tp.(*tp_pb.TechProfile)
+		} else {
+			delete(t.tpMap, tpID)
+		}
+		t.tpMapLock.Unlock()
+		if ok && update.UpdateType == TpUpdated && proto.Equal(cached, tp) {
+			return nil
+		}
+	}
+
+	update.AffectedInstances = t.getTpInstanceKeys(ctx, tpID)
+	logger.Infow(ctx, "tp-definition-changed", log.Fields{"tpID": tpID, "update-type": update.UpdateType,
+		"affected-instances": len(update.AffectedInstances)})
+	return update
+}
+
+// getTpInstanceKeys returns the sorted keys of the instances of the TP, both the cached ones and the
+// ones stored in the KV store that have not been loaded into the cache, e.g. after a restart
+func (t *TechProfileMgr) getTpInstanceKeys(ctx context.Context, tpID uint32) []string {
+	prefix := fmt.Sprintf("%s/%d/", t.resourceMgr.GetTechnology(), tpID)
+	found := make(map[string]bool)
+	if t.isEpon() {
+		t.epontpInstanceMapLock.RLock()
+		for key := range t.eponTpInstanceMap {
+			if strings.HasPrefix(key, prefix) {
+				found[key] = true
+			}
+		}
+		t.epontpInstanceMapLock.RUnlock()
+	} else {
+		t.tpInstanceMapLock.RLock()
+		for key := range t.tpInstanceMap {
+			if strings.HasPrefix(key, prefix) {
+				found[key] = true
+			}
+		}
+		t.tpInstanceMapLock.RUnlock()
+	}
+
+	if t.config.ResourceInstanceKVBacked != nil {
+		kvPairs, err := t.config.ResourceInstanceKVBacked.GetWithPrefix(ctx, prefix)
+		if err != nil {
+			logger.Errorw(ctx, "failed-to-get-resource-instances--reporting-cached-instances-only", log.Fields{"err": err, "tpID": tpID})
+		}
+		pathPrefix := t.config.ResourceInstanceKVBacked.PathPrefix + "/"
+		for keyPath := range kvPairs {
+			found[strings.TrimPrefix(keyPath, pathPrefix)] = true
+		}
+	}
+
+	var keys []string
+	for key := range found {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (t *TechProfileMgr) notifyTpUpdate(ctx context.Context, update *TpUpdate) {
+	t.tpWatchLock.Lock()
+	callbacks := append([]TpUpdateCallback(nil), t.tpUpdateCallbacks...)
+	t.tpWatchLock.Unlock()
+	for _, cb := range callbacks {
+		cb(ctx, update)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package techprofile
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"github.com/stretchr/testify/assert"
+)
+
+func waitTpUpdate(t *testing.T, updates chan *TpUpdate) *TpUpdate {
+	select {
+	case update := <-updates:
+		return update
+	case <-time.After(5 * time.Second):
+	}
+	t.Fatal("no-tp-update-received")
+	return nil
+}
+
+func TestTpWatch(t *testing.T) {
+	ctx := context.Background()
+	tpMgr, tpDefault := newTestTechProfileMgr(t, ctx, xgspon)
+	tp := &tp_pb.TechProfile{}
+	loadSampleProfile(t, "4QueueHybridProfileMap1.json", tp)
+	putTechProfile(t, ctx, tpDefault, xgspon, 64, tp)
+
+	uni0 := "olt-{olt1}/pon-{0}/onu-{1}/uni-{0}"
+	uni1 := "olt-{olt1}/pon-{0}/onu-{2}/uni-{0}"
+	for _, uni := range []string{uni1, uni0} {
+		_, err := tpMgr.CreateTechProfileInstance(ctx, 64, uni, 0)
+		assert.Nil(t, err)
+	}
+	_, err := tpMgr.CreateTechProfileInstance(ctx, 65, uni0, 0)
+	assert.Nil(t, err)
+	// an instance that is only in the KV store, e.g. not reconciled yet after a restart, is affected as well
+	uni2 := "olt-{olt1}/pon-{0}/onu-{3}/uni-{0}"
+	assert.Nil(t, tpMgr.addResourceInstanceToKVStore(ctx, 64, uni2, &tp_pb.ResourceInstance{TpId: 64}))
+	affected := []string{tpMgr.GetTechProfileInstanceKey(ctx, 64, uni0), tpMgr.GetTechProfileInstanceKey(ctx, 64, uni1),
+		tpMgr.GetTechProfileInstanceKey(ctx, 64, uni2)}
+
+	updates := make(chan *TpUpdate, 10)
+	tpMgr.RegisterTpUpdateCallback(func(ctx context.Context, update *TpUpdate) { updates <- update })
+	assert.Nil(t, tpMgr.StartTpWatch(ctx))
+	assert.NotNil(t, tpMgr.StartTpWatch(ctx))
+
+	// rewriting the cached definition is not reported, keys other than TP definitions are ignored
+	putTechProfile(t, ctx, tpDefault, xgspon, 64, tp)
+	assert.Nil(t, tpDefault.Put(ctx, xgspon+"/64/notes", []byte("not-a-tp")))
+
+	tp.UpstreamGemPortAttributeList[0].Weight = 50
+	putTechProfile(t, ctx, tpDefault, xgspon, 64, tp)
+	update := waitTpUpdate(t, updates)
+	assert.Equal(t, &TpUpdate{TpID: 64, Technology: xgspon, UpdateType: TpUpdated, AffectedInstances: affected}, update)
+	cached, err := tpMgr.getTPFromKVStore(ctx, 64)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(50), cached.UpstreamGemPortAttributeList[0].Weight)
+
+	// an invalid definition is reported with its errors and dropped from the cache
+	tp.NumGemPorts = 2
+	putTechProfile(t, ctx, tpDefault, xgspon, 64, tp)
+	update = waitTpUpdate(t, updates)
+	assert.Equal(t, TpInvalid, update.UpdateType)
+	assert.Equal(t, affected, update.AffectedInstances)
+	assert.ElementsMatch(t, []string{"upstream_gem_port_attribute_list", "downstream_gem_port_attribute_list"},
+		invalidFields(t, update.Err))
+	_, err = tpMgr.getTPFromKVStore(ctx, 64)
+	assert.True(t, isValidationError(err))
+
+	putTechProfile(t, ctx, tpDefault, xgspon, 66, tp)
+	assert.Nil(t, tpDefault.Delete(ctx, xgspon+"/66"))
+	update = waitTpUpdate(t, updates)
+	assert.Equal(t, TpInvalid, update.UpdateType)
+	assert.Equal(t, uint32(66), update.TpID)
+	update = waitTpUpdate(t, updates)
+	assert.Equal(t, &TpUpdate{TpID: 66, Technology: xgspon, UpdateType: TpDeleted}, update)
+
+	// no more updates once the watch is stopped
+	tpMgr.StopTpWatch(ctx)
+	tpMgr.StopTpWatch(ctx)
+	tp.NumGemPorts = 4
+	putTechProfile(t, ctx, tpDefault, xgspon, 64, tp)
+	select {
+	case update = <-updates:
+		t.Fatalf("unexpected-update-%v", update)
+	default:
+	}
+}
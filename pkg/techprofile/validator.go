@@ -0,0 +1,424 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package techprofile
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+)
+
+const (
+	singleInstance = "single-instance"
+	multiInstance  = "multi-instance"
+
+	pbitMapPrefix = "0b"
+	numPbits      = 8
+
+	maxPriorityQ    = 7
+	maxProbability  = 100
+	maxEponQueueSet = 8
+)
+
+// ValidationError describes a single problem found in a tech profile. Field is the
+// json path of the offending attribute, e.g. upstream_gem_port_attribute_list[1].pbit_map
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors is the list of all problems found in a tech profile
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// isValidationError tells whether err reports a TP that does not pass validation
+func isValidationError(err error) bool {
+	var errs ValidationErrors
+	return errors.As(err, &errs)
+}
+
+// tpValidator collects the errors found while walking a tech profile
+type tpValidator struct {
+	errs ValidationErrors
+}
+
+func (v *tpValidator) addf(field string, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *tpValidator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// queueAttributes holds the attributes GEM ports and EPON queues have in common
+type queueAttributes struct {
+	path             string
+	pbitMap          string
+	aesEncryption    string
+	schedulingPolicy tp_pb.SchedulingPolicy
+	priorityQ        uint32
+	weight           uint32
+	discardPolicy    tp_pb.DiscardPolicy
+	discardConfig    *tp_pb.RedDiscardConfig
+	discardConfigV2  *tp_pb.DiscardConfig
+}
+
+func gemPortQueueAttributes(path string, gem *tp_pb.GemPortAttributes) queueAttributes {
+	return queueAttributes{
+		path:             path,
+		pbitMap:          gem.PbitMap,
+		aesEncryption:    gem.AesEncryption,
+		schedulingPolicy: gem.SchedulingPolicy,
+		priorityQ:        gem.PriorityQ,
+		weight:           gem.Weight,
+		discardPolicy:    gem.DiscardPolicy,
+		discardConfig:    gem.DiscardConfig,
+		discardConfigV2:  gem.DiscardConfigV2,
+	}
+}
+
+func eponQueueAttributes(path string, queue *tp_pb.EPONQueueAttributes) queueAttributes {
+	return queueAttributes{
+		path:             path,
+		pbitMap:          queue.PbitMap,
+		aesEncryption:    queue.AesEncryption,
+		schedulingPolicy: queue.SchedulingPolicy,
+		priorityQ:        queue.PriorityQ,
+		weight:           queue.Weight,
+		discardPolicy:    queue.DiscardPolicy,
+		discardConfig:    queue.DiscardConfig,
+		discardConfigV2:  queue.DiscardConfigV2,
+	}
+}
+
+func attrPath(list string, idx int, attr string) string {
+	return fmt.Sprintf("%s[%d].%s", list, idx, attr)
+}
+
+// ParsePbitMap converts a pbit map of the form 0b00000101 to a bitmask where bit i is pbit i
+func ParsePbitMap(pbitMap string) (uint8, error) {
+	if !strings.HasPrefix(pbitMap, pbitMapPrefix) || len(pbitMap) != len(pbitMapPrefix)+numPbits {
+		return 0, fmt.Errorf("expected %s followed by %d binary digits, got %q", pbitMapPrefix, numPbits, pbitMap)
+	}
+	var mask uint8
+	for i, c := range pbitMap[len(pbitMapPrefix):] {
+		switch c {
+		case '1':
+			mask |= 1 << uint(numPbits-1-i)
+		case '0':
+		default:
+			return 0, fmt.Errorf("invalid digit %q in %q", c, pbitMap)
+		}
+	}
+	return mask, nil
+}
+
+func pbitList(mask uint8) []int {
+	var pbits []int
+	for pbit := 0; pbit < numPbits; pbit++ {
+		if mask&(1<<uint(pbit)) != 0 {
+			pbits = append(pbits, pbit)
+		}
+	}
+	return pbits
+}
+
+// validateInstanceControl checks the instance control, and its max GEM payload size against
+// maxGemPayloadSize unless 0
+func (v *tpValidator) validateInstanceControl(instCtl *tp_pb.InstanceControl, maxGemPayloadSize uint32) {
+	if instCtl == nil {
+		v.addf(INSTANCE_CONTROL, "missing")
+		return
+	}
+	if instCtl.Onu != singleInstance && instCtl.Onu != multiInstance {
+		v.addf(INSTANCE_CONTROL+"."+ONU, "must be %s or %s, got %q", singleInstance, multiInstance, instCtl.Onu)
+	}
+	if instCtl.Uni != singleInstance {
+		v.addf(INSTANCE_CONTROL+"."+UNI, "only %s is supported, got %q", singleInstance, instCtl.Uni)
+	}
+	if maxGemPayloadSize != 0 && instCtl.MaxGemPayloadSize != defaultGemPayloadSize {
+		size, err := strconv.ParseUint(instCtl.MaxGemPayloadSize, 10, 32)
+		if err != nil || size == 0 || size > uint64(maxGemPayloadSize) {
+			v.addf(INSTANCE_CONTROL+"."+MAX_GEM_PAYLOAD_SIZE, "must be %s or a size from 1 to %d, got %q",
+				defaultGemPayloadSize, maxGemPayloadSize, instCtl.MaxGemPayloadSize)
+		}
+	}
+}
+
+func (v *tpValidator) validateScheduler(field string, sched *tp_pb.SchedulerAttributes, dir tp_pb.Direction) {
+	if sched == nil {
+		v.addf(field, "missing")
+		return
+	}
+	if sched.Direction != dir {
+		v.addf(field+"."+DIRECTION, "must be %s, got %s", dir, sched.Direction)
+	}
+}
+
+// validatePbitMaps checks that no pbit is mapped to more than one queue of a direction and
+// that every pbit is mapped to one of them
+func (v *tpValidator) validatePbitMaps(list string, queues []queueAttributes) {
+	var covered uint8
+	owner := make(map[int]string)
+	for _, q := range queues {
+		field := q.path + "." + PBIT_MAP
+		mask, err := ParsePbitMap(q.pbitMap)
+		if err != nil {
+			v.addf(field, "%s", err)
+			continue
+		}
+		if mask == 0 {
+			v.addf(field, "no pbit mapped")
+			continue
+		}
+		for _, pbit := range pbitList(mask & covered) {
+			v.addf(field, "pbit %d already mapped by %s", pbit, owner[pbit])
+		}
+		for _, pbit := range pbitList(mask &^ covered) {
+			owner[pbit] = q.path
+		}
+		covered |= mask
+	}
+	if uncovered := pbitList(^covered); len(uncovered) > 0 && len(owner) > 0 {
+		v.addf(list, "pbits %v are not mapped to any queue", uncovered)
+	}
+}
+
+// validateQueues checks the queues of one direction against each other and, if schedPolicy
+// is given, against the scheduler they hang off
+func (v *tpValidator) validateQueues(list string, queues []queueAttributes, schedPolicy *tp_pb.SchedulingPolicy) {
+	v.validatePbitMaps(list, queues)
+
+	numWrr := 0
+	for _, q := range queues {
+		if q.schedulingPolicy == tp_pb.SchedulingPolicy_WRR {
+			numWrr++
+		}
+	}
+	priorityOwner := make(map[uint32]string)
+	for _, q := range queues {
+		if q.priorityQ > maxPriorityQ {
+			v.addf(q.path+"."+PRIORITY_Q, "must be in range 0-%d, got %d", maxPriorityQ, q.priorityQ)
+		} else if other, ok := priorityOwner[q.priorityQ]; ok {
+			v.addf(q.path+"."+PRIORITY_Q, "priority %d already used by %s", q.priorityQ, other)
+		} else {
+			priorityOwner[q.priorityQ] = q.path
+		}
+
+		switch q.schedulingPolicy {
+		case tp_pb.SchedulingPolicy_WRR, tp_pb.SchedulingPolicy_StrictPriority:
+		default:
+			v.addf(q.path+"."+SCHEDULING_POLICY, "queues must be WRR or StrictPriority, got %s", q.schedulingPolicy)
+		}
+		if schedPolicy != nil && *schedPolicy != tp_pb.SchedulingPolicy_Hybrid && q.schedulingPolicy != *schedPolicy {
+			v.addf(q.path+"."+SCHEDULING_POLICY, "must be %s to match the %s scheduler, got %s", *schedPolicy, *schedPolicy, q.schedulingPolicy)
+		}
+		// the weight only matters when WRR queues compete with each other
+		if q.schedulingPolicy == tp_pb.SchedulingPolicy_WRR && numWrr > 1 && q.weight == 0 {
+			v.addf(q.path+"."+WEIGHT, "must be non-zero for a WRR queue sharing the scheduler with other WRR queues")
+		}
+		if q.aesEncryption != "True" && q.aesEncryption != "False" {
+			v.addf(q.path+"."+AES_ENCRYPTION, "must be True or False, got %q", q.aesEncryption)
+		}
+		v.validateDiscardConfig(q)
+	}
+}
+
+func (v *tpValidator) validateRedDiscardConfig(field string, cfg *tp_pb.RedDiscardConfig) {
+	if cfg == nil {
+		return
+	}
+	if cfg.MinThreshold > cfg.MaxThreshold {
+		v.addf(field+"."+MIN_THRESHOLD, "%d is greater than %s %d", cfg.MinThreshold, MAX_THRESHOLD, cfg.MaxThreshold)
+	}
+	if cfg.MaxProbability > maxProbability {
+		v.addf(field+"."+MAX_PROBABILITY, "must be at most %d, got %d", maxProbability, cfg.MaxProbability)
+	}
+}
+
+func (v *tpValidator) validateDiscardConfig(q queueAttributes) {
+	v.validateRedDiscardConfig(q.path+"."+DISCARD_CONFIG, q.discardConfig)
+	if q.discardConfigV2 != nil {
+		field := q.path + "." + DISCARD_CONFIG_V2
+		v.validateRedDiscardConfig(field+".red_discard_config", q.discardConfigV2.GetRedDiscardConfig())
+		if wred := q.discardConfigV2.GetWredDiscardConfig(); wred != nil {
+			v.validateRedDiscardConfig(field+".wred_discard_config.green", wred.Green)
+			v.validateRedDiscardConfig(field+".wred_discard_config.yellow", wred.Yellow)
+			v.validateRedDiscardConfig(field+".wred_discard_config.red", wred.Red)
+		}
+	}
+	switch q.discardPolicy {
+	case tp_pb.DiscardPolicy_Red, tp_pb.DiscardPolicy_WRed:
+		if q.discardConfig == nil && q.discardConfigV2 == nil {
+			v.addf(q.path+"."+DISCARD_CONFIG, "required by discard policy %s", q.discardPolicy)
+		}
+	}
+}
+
+// ValidateTechProfile checks a GPON, XGPON or XGS-PON tech profile before instances are
+// created from it. All problems found are returned as ValidationErrors.
+func ValidateTechProfile(tp *tp_pb.TechProfile) error {
+	return validateGponTechProfile(tp, 0)
+}
+
+// validateGponTechProfile is ValidateTechProfile, also checking the max GEM payload size of the
+// instance control against maxGemPayloadSize unless 0
+func validateGponTechProfile(tp *tp_pb.TechProfile, maxGemPayloadSize uint32) error {
+	v := &tpValidator{}
+	if tp == nil {
+		v.addf(NAME, "tech profile is nil")
+		return v.err()
+	}
+	v.validateInstanceControl(tp.InstanceControl, maxGemPayloadSize)
+	v.validateScheduler(US_SCHEDULER, tp.UsScheduler, tp_pb.Direction_UPSTREAM)
+	v.validateScheduler(DS_SCHEDULER, tp.DsScheduler, tp_pb.Direction_DOWNSTREAM)
+
+	if tp.NumGemPorts == 0 {
+		v.addf(NUM_GEM_PORTS, "must be greater than 0")
+	}
+	if len(tp.UpstreamGemPortAttributeList) != int(tp.NumGemPorts) {
+		v.addf(UPSTREAM_GEM_PORT_ATTRIBUTE_LIST, "has %d GEM ports, %s is %d",
+			len(tp.UpstreamGemPortAttributeList), NUM_GEM_PORTS, tp.NumGemPorts)
+	}
+	var usQueues []queueAttributes
+	for idx, gem := range tp.UpstreamGemPortAttributeList {
+		usQueues = append(usQueues, gemPortQueueAttributes(fmt.Sprintf("%s[%d]", UPSTREAM_GEM_PORT_ATTRIBUTE_LIST, idx), gem))
+	}
+	var usPolicy *tp_pb.SchedulingPolicy
+	if tp.UsScheduler != nil {
+		usPolicy = &tp.UsScheduler.QSchedPolicy
+	}
+	v.validateQueues(UPSTREAM_GEM_PORT_ATTRIBUTE_LIST, usQueues, usPolicy)
+
+	// multicast GEM ports are not part of num_gem_ports and carry their own pbit map
+	var dsQueues []queueAttributes
+	for idx, gem := range tp.DownstreamGemPortAttributeList {
+		if isMulticastGem(gem.IsMulticast) {
+			if gem.MulticastGemId == 0 {
+				v.addf(attrPath(DOWNSTREAM_GEM_PORT_ATTRIBUTE_LIST, idx, MULTICAST_GEM_ID), "required for a multicast GEM port")
+			}
+			if _, err := ParsePbitMap(gem.PbitMap); err != nil {
+				v.addf(attrPath(DOWNSTREAM_GEM_PORT_ATTRIBUTE_LIST, idx, PBIT_MAP), "%s", err)
+			}
+			continue
+		}
+		if gem.IsMulticast != "" && !strings.EqualFold(gem.IsMulticast, "false") {
+			v.addf(attrPath(DOWNSTREAM_GEM_PORT_ATTRIBUTE_LIST, idx, IS_MULTICAST), "must be True or False, got %q", gem.IsMulticast)
+		}
+		dsQueues = append(dsQueues, gemPortQueueAttributes(fmt.Sprintf("%s[%d]", DOWNSTREAM_GEM_PORT_ATTRIBUTE_LIST, idx), gem))
+	}
+	if len(dsQueues) != int(tp.NumGemPorts) {
+		v.addf(DOWNSTREAM_GEM_PORT_ATTRIBUTE_LIST, "has %d unicast GEM ports, %s is %d",
+			len(dsQueues), NUM_GEM_PORTS, tp.NumGemPorts)
+	}
+	var dsPolicy *tp_pb.SchedulingPolicy
+	if tp.DsScheduler != nil {
+		dsPolicy = &tp.DsScheduler.QSchedPolicy
+	}
+	v.validateQueues(DOWNSTREAM_GEM_PORT_ATTRIBUTE_LIST, dsQueues, dsPolicy)
+
+	return v.err()
+}
+
+// validateQueueThresholds checks that the report thresholds of the num_q_sets queue sets
+// are set and strictly increasing and that the unused ones are zero
+func (v *tpValidator) validateQueueThresholds(path string, queue *tp_pb.EPONQueueAttributes, required bool) {
+	if queue.NumQSets == 0 && !required {
+		return
+	}
+	if queue.NumQSets < 1 || queue.NumQSets > maxEponQueueSet {
+		v.addf(path+"."+NUM_Q_SETS, "must be in range 1-%d, got %d", maxEponQueueSet, queue.NumQSets)
+		return
+	}
+	th := queue.QThresholds
+	if th == nil {
+		th = &tp_pb.QThresholds{}
+	}
+	thresholds := []uint32{th.QThreshold1, th.QThreshold2, th.QThreshold3, th.QThreshold4,
+		th.QThreshold5, th.QThreshold6, th.QThreshold7}
+	// num_q_sets queue sets are delimited by num_q_sets-1 thresholds
+	var prev uint32
+	for idx, threshold := range thresholds {
+		field := fmt.Sprintf("%s.%s.q_threshold%d", path, Q_THRESHOLDS, idx+1)
+		if uint32(idx) < queue.NumQSets-1 {
+			if threshold == 0 {
+				v.addf(field, "must be non-zero with %s %d", NUM_Q_SETS, queue.NumQSets)
+			} else if threshold <= prev {
+				v.addf(field, "%d must be greater than the previous threshold %d", threshold, prev)
+			}
+			prev = threshold
+		} else if threshold != 0 {
+			v.addf(field, "must be 0 with %s %d, got %d", NUM_Q_SETS, queue.NumQSets, threshold)
+		}
+	}
+}
+
+// ValidateEponTechProfile checks an EPON tech profile before instances are created from
+// it. All problems found are returned as ValidationErrors.
+func ValidateEponTechProfile(tp *tp_pb.EponTechProfile) error {
+	v := &tpValidator{}
+	if tp == nil {
+		v.addf(NAME, "tech profile is nil")
+		return v.err()
+	}
+	v.validateInstanceControl(tp.InstanceControl, 0)
+	if tp.PackageType != "" && tp.PackageType != "A" && tp.PackageType != "B" {
+		v.addf(PACKAGE_TYPE, "must be A or B, got %q", tp.PackageType)
+	}
+
+	if tp.NumGemPorts == 0 {
+		v.addf(NUM_GEM_PORTS, "must be greater than 0")
+	}
+	for _, list := range []struct {
+		name   string
+		queues []*tp_pb.EPONQueueAttributes
+	}{
+		{UPSTREAM_QUEUE_ATTRIBUTE_LIST, tp.UpstreamQueueAttributeList},
+		{DOWNSTREAM_QUEUE_ATTRIBUTE_LIST, tp.DownstreamQueueAttributeList},
+	} {
+		if len(list.queues) != int(tp.NumGemPorts) {
+			v.addf(list.name, "has %d queues, %s is %d", len(list.queues), NUM_GEM_PORTS, tp.NumGemPorts)
+		}
+		var queues []queueAttributes
+		for idx, queue := range list.queues {
+			path := fmt.Sprintf("%s[%d]", list.name, idx)
+			queues = append(queues, eponQueueAttributes(path, queue))
+			// queue sets are reported upstream only, downstream queues may leave them out
+			v.validateQueueThresholds(path, queue, list.name == UPSTREAM_QUEUE_ATTRIBUTE_LIST)
+		}
+		v.validateQueues(list.name, queues, nil)
+	}
+
+	return v.err()
+}
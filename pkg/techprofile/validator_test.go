@@ -0,0 +1,190 @@
+/*
+ * Copyright 2021-2024 Open Networking Foundation (ONF) and the ONF Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package techprofile
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tp_pb "github.com/opencord/voltha-protos/v5/go/tech_profile"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+// invalidFields returns the field paths of the validation errors in err
+func invalidFields(t *testing.T, err error) []string {
+	var errs ValidationErrors
+	if !assert.True(t, errors.As(err, &errs), "%v", err) {
+		return nil
+	}
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	return fields
+}
+
+func TestParsePbitMap(t *testing.T) {
+	mask, err := ParsePbitMap("0b00000101")
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(0x05), mask)
+	assert.Equal(t, []int{0, 2}, pbitList(mask))
+	mask, err = ParsePbitMap("0b11111111")
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(0xff), mask)
+
+	for _, pbitMap := range []string{"", "0b", "00000101", "0b0000010", "0b000001011", "0b0000010x", "0x00000101"} {
+		_, err = ParsePbitMap(pbitMap)
+		assert.NotNil(t, err, pbitMap)
+	}
+}
+
+func TestValidateDefaultAndSampleProfiles(t *testing.T) {
+	ctx := context.Background()
+	config := NewTechProfileFlags("etcd", "1:1", "service/voltha")
+	for _, name := range []string{gpon, xgpon, xgspon, gs25pon} {
+		tech, _ := LookupTechnology(name)
+		assert.Nil(t, ValidateTechProfile(tech.(GponFamilyTechnology).DefaultTechProfile(ctx, config)), name)
+	}
+	tech, _ := LookupTechnology(epon)
+	assert.Nil(t, ValidateEponTechProfile(tech.(EponFamilyTechnology).DefaultTechProfile(ctx, config)))
+
+	tp := &tp_pb.TechProfile{}
+	loadSampleProfile(t, "4QueueHybridProfileMap1.json", tp)
+	assert.Nil(t, ValidateTechProfile(tp))
+	eponTp := &tp_pb.EponTechProfile{}
+	loadSampleProfile(t, "SingleQueueEponProfile.json", eponTp)
+	assert.Nil(t, ValidateEponTechProfile(eponTp))
+
+	assert.NotNil(t, ValidateTechProfile(nil))
+	assert.NotNil(t, ValidateEponTechProfile(nil))
+}
+
+func TestValidateTechProfileErrors(t *testing.T) {
+	sample := &tp_pb.TechProfile{}
+	loadSampleProfile(t, "4QueueHybridProfileMap1.json", sample)
+
+	tests := []struct {
+		name   string
+		modify func(tp *tp_pb.TechProfile)
+		fields []string
+	}{
+		{"bad onu instance control", func(tp *tp_pb.TechProfile) { tp.InstanceControl.Onu = "any" },
+			[]string{"instance_control.onu"}},
+		{"multi instance uni", func(tp *tp_pb.TechProfile) { tp.InstanceControl.Uni = "multi-instance" },
+			[]string{"instance_control.uni"}},
+		{"missing scheduler", func(tp *tp_pb.TechProfile) { tp.UsScheduler = nil },
+			[]string{"us_scheduler"}},
+		{"scheduler direction", func(tp *tp_pb.TechProfile) { tp.DsScheduler.Direction = tp_pb.Direction_UPSTREAM },
+			[]string{"ds_scheduler.direction"}},
+		{"gem count", func(tp *tp_pb.TechProfile) { tp.NumGemPorts = 3 },
+			[]string{"upstream_gem_port_attribute_list", "downstream_gem_port_attribute_list"}},
+		{"malformed pbit map", func(tp *tp_pb.TechProfile) { tp.UpstreamGemPortAttributeList[0].PbitMap = "0b0101" },
+			[]string{"upstream_gem_port_attribute_list[0].pbit_map", "upstream_gem_port_attribute_list"}},
+		{"overlapping pbits", func(tp *tp_pb.TechProfile) { tp.DownstreamGemPortAttributeList[2].PbitMap = "0b00100001" },
+			[]string{"downstream_gem_port_attribute_list[2].pbit_map"}},
+		{"uncovered pbits", func(tp *tp_pb.TechProfile) { tp.UpstreamGemPortAttributeList[3].PbitMap = "0b10000000" },
+			[]string{"upstream_gem_port_attribute_list"}},
+		{"sp queue under wrr scheduler", func(tp *tp_pb.TechProfile) { tp.UsScheduler.QSchedPolicy = tp_pb.SchedulingPolicy_WRR },
+			[]string{"upstream_gem_port_attribute_list[2].scheduling_policy", "upstream_gem_port_attribute_list[3].scheduling_policy"}},
+		{"hybrid queue", func(tp *tp_pb.TechProfile) {
+			tp.UpstreamGemPortAttributeList[2].SchedulingPolicy = tp_pb.SchedulingPolicy_Hybrid
+		}, []string{"upstream_gem_port_attribute_list[2].scheduling_policy"}},
+		{"zero wrr weight", func(tp *tp_pb.TechProfile) { tp.DownstreamGemPortAttributeList[1].Weight = 0 },
+			[]string{"downstream_gem_port_attribute_list[1].weight"}},
+		{"duplicate priority", func(tp *tp_pb.TechProfile) { tp.UpstreamGemPortAttributeList[1].PriorityQ = 4 },
+			[]string{"upstream_gem_port_attribute_list[1].priority_q"}},
+		{"priority out of range", func(tp *tp_pb.TechProfile) { tp.UpstreamGemPortAttributeList[1].PriorityQ = 8 },
+			[]string{"upstream_gem_port_attribute_list[1].priority_q"}},
+		{"red thresholds", func(tp *tp_pb.TechProfile) {
+			tp.UpstreamGemPortAttributeList[0].DiscardPolicy = tp_pb.DiscardPolicy_Red
+			tp.UpstreamGemPortAttributeList[0].DiscardConfig = &tp_pb.RedDiscardConfig{MinThreshold: 20, MaxThreshold: 10, MaxProbability: 101}
+		}, []string{"upstream_gem_port_attribute_list[0].discard_config.min_threshold",
+			"upstream_gem_port_attribute_list[0].discard_config.max_probability"}},
+		{"red without config", func(tp *tp_pb.TechProfile) {
+			tp.UpstreamGemPortAttributeList[0].DiscardPolicy = tp_pb.DiscardPolicy_WRed
+			tp.UpstreamGemPortAttributeList[0].DiscardConfig = nil
+		}, []string{"upstream_gem_port_attribute_list[0].discard_config"}},
+		{"aes encryption", func(tp *tp_pb.TechProfile) { tp.DownstreamGemPortAttributeList[3].AesEncryption = "yes" },
+			[]string{"downstream_gem_port_attribute_list[3].aes_encryption"}},
+		{"multicast gem without id", func(tp *tp_pb.TechProfile) {
+			tp.DownstreamGemPortAttributeList = append(tp.DownstreamGemPortAttributeList,
+				&tp_pb.GemPortAttributes{PbitMap: "0b00000001", IsMulticast: "True"})
+		}, []string{"downstream_gem_port_attribute_list[4].multicast_gem_id"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := proto.Clone(sample).(*tp_pb.TechProfile)
+			tt.modify(tp)
+			assert.ElementsMatch(t, tt.fields, invalidFields(t, ValidateTechProfile(tp)))
+		})
+	}
+
+	// multicast GEM ports are neither counted nor checked for pbit overlap
+	tp := proto.Clone(sample).(*tp_pb.TechProfile)
+	tp.DownstreamGemPortAttributeList = append(tp.DownstreamGemPortAttributeList,
+		&tp_pb.GemPortAttributes{PbitMap: "0b00000001", IsMulticast: "True", MulticastGemId: 4069})
+	assert.Nil(t, ValidateTechProfile(tp))
+}
+
+func TestValidateEponTechProfileErrors(t *testing.T) {
+	sample := &tp_pb.EponTechProfile{}
+	loadSampleProfile(t, "SingleQueueEponProfile.json", sample)
+
+	tests := []struct {
+		name   string
+		modify func(tp *tp_pb.EponTechProfile)
+		fields []string
+	}{
+		{"package type", func(tp *tp_pb.EponTechProfile) { tp.PackageType = "C" },
+			[]string{"package_type"}},
+		{"queue count", func(tp *tp_pb.EponTechProfile) { tp.NumGemPorts = 2 },
+			[]string{"upstream_queue_attribute_list", "downstream_queue_attribute_list"}},
+		{"missing queue sets", func(tp *tp_pb.EponTechProfile) { tp.UpstreamQueueAttributeList[0].NumQSets = 0 },
+			[]string{"upstream_queue_attribute_list[0].num_q_sets"}},
+		{"too many queue sets", func(tp *tp_pb.EponTechProfile) { tp.UpstreamQueueAttributeList[0].NumQSets = 9 },
+			[]string{"upstream_queue_attribute_list[0].num_q_sets"}},
+		{"missing threshold", func(tp *tp_pb.EponTechProfile) { tp.UpstreamQueueAttributeList[0].NumQSets = 3 },
+			[]string{"upstream_queue_attribute_list[0].q_thresholds.q_threshold2"}},
+		{"decreasing thresholds", func(tp *tp_pb.EponTechProfile) {
+			tp.UpstreamQueueAttributeList[0].NumQSets = 3
+			tp.UpstreamQueueAttributeList[0].QThresholds.QThreshold2 = 5000
+		}, []string{"upstream_queue_attribute_list[0].q_thresholds.q_threshold2"}},
+		{"unused threshold", func(tp *tp_pb.EponTechProfile) { tp.UpstreamQueueAttributeList[0].QThresholds.QThreshold7 = 1 },
+			[]string{"upstream_queue_attribute_list[0].q_thresholds.q_threshold7"}},
+		{"downstream queue sets", func(tp *tp_pb.EponTechProfile) { tp.DownstreamQueueAttributeList[0].NumQSets = 2 },
+			[]string{"downstream_queue_attribute_list[0].q_thresholds.q_threshold1"}},
+		{"uncovered pbits", func(tp *tp_pb.EponTechProfile) { tp.DownstreamQueueAttributeList[0].PbitMap = "0b01111111" },
+			[]string{"downstream_queue_attribute_list"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := proto.Clone(sample).(*tp_pb.EponTechProfile)
+			tt.modify(tp)
+			assert.ElementsMatch(t, tt.fields, invalidFields(t, ValidateEponTechProfile(tp)))
+		})
+	}
+}
+
+func TestValidationErrorsString(t *testing.T) {
+	err := ValidationErrors{
+		{Field: "num_gem_ports", Message: "must be greater than 0"},
+		{Field: "upstream_gem_port_attribute_list[0].pbit_map", Message: "no pbit mapped"},
+	}
+	assert.Equal(t, "num_gem_ports: must be greater than 0; upstream_gem_port_attribute_list[0].pbit_map: no pbit mapped", err.Error())
+}